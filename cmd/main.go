@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"github.com/drTragger/messenger-backend/internal/services"
 	"github.com/drTragger/messenger-backend/internal/storage"
@@ -58,26 +59,100 @@ func main() {
 	// Initialize repositories
 	userRepo := repository.NewUserRepository(pdb)
 	tokenRepo := repository.NewTokenRepository(rdb)
+	idempotencyRepo := repository.NewIdempotencyRepository(rdb)
 	msgRepo := repository.NewMessageRepository(pdb)
 	chatRepo := repository.NewChatRepository(pdb)
 	attachmentRepo := repository.NewAttachmentRepository(pdb)
+	pinRepo := repository.NewPinnedMessageRepository(pdb)
+	entityRepo := repository.NewMessageEntityRepository(pdb)
+	linkPreviewRepo := repository.NewLinkPreviewRepository(pdb, rdb)
+	bookmarkRepo := repository.NewBookmarkRepository(pdb)
+	keyRepo := repository.NewKeyRepository(pdb)
+	pollRepo := repository.NewPollRepository(pdb)
+	locationRepo := repository.NewLocationRepository(pdb)
+	contactRepo := repository.NewContactRepository(pdb)
+	stickerRepo := repository.NewStickerRepository(pdb)
+	gifRepo := repository.NewGifRepository(pdb)
+	updateRepo := repository.NewUpdateRepository(pdb)
+	outboxRepo := repository.NewOutboxRepository(pdb)
+	moderationRepo := repository.NewModerationRepository(pdb, rdb)
+	reportRepo := repository.NewReportRepository(pdb)
+	restrictionRepo := repository.NewRestrictionRepository(pdb)
 
 	// Initialize services
-	msgService := services.NewMessageService(attachmentRepo, storageInst)
-	wsService := services.NewWsService(clientManager)
+	moderationRules, err := services.LoadModerationRules(getBasePath() + "/moderation/rules.json")
+	if err != nil {
+		log.Fatalf("Cannot load moderation rules: %v", err)
+	}
+	moderationFilters := []services.ModerationFilter{
+		services.NewKeywordFilter(moderationRules.Keywords),
+		services.NewLinkFilter(moderationRules.Links),
+		services.NewSpamFilter(moderationRepo),
+	}
+	if cfg.ClassifierURL != "" {
+		moderationFilters = append(moderationFilters, services.NewHTTPClassifier(cfg.ClassifierURL))
+	}
+	moderationService := services.NewModerationService(moderationFilters...)
+
+	msgService := services.NewMessageService(pdb, msgRepo, chatRepo, entityRepo, attachmentRepo, outboxRepo, moderationRepo, moderationService, storageInst)
+	wsService := services.NewWsService(clientManager, updateRepo)
+	mentionService := services.NewMentionService(userRepo)
+	previewService := services.NewLinkPreviewService(pdb, linkPreviewRepo, msgRepo, outboxRepo, services.NewHTTPFetcher())
+	stickerService := services.NewStickerService(pdb, stickerRepo, storageInst)
+	chatService := services.NewChatService(pdb, chatRepo, outboxRepo)
+	deliveryService := services.NewDeliveryService(pdb, msgRepo, outboxRepo)
+	pollService := services.NewPollService(pdb, pollRepo, msgRepo, outboxRepo, msgService)
+	locationService := services.NewLocationService(pdb, locationRepo, msgRepo, outboxRepo)
+	pinService := services.NewPinService(pdb, pinRepo, outboxRepo)
+	keyService := services.NewKeyService(pdb, keyRepo, outboxRepo)
+	reportService := services.NewReportService(pdb, reportRepo, restrictionRepo, msgRepo, msgService, outboxRepo, clientManager, storageInst)
+	clientManager.OnDelivered(deliveryService.HandleDelivered)
+
+	var gifProvider services.GifProvider = services.NewLocalGifProvider()
+	if cfg.GiphyKey != "" {
+		gifProvider = services.NewGiphyProvider(cfg.GiphyKey)
+	}
+
+	// Start background workers
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	scheduledDispatcher := services.NewScheduledMessageDispatcher(msgService, previewService)
+	go scheduledDispatcher.Run(ctx)
+
+	pollCloser := services.NewPollCloser(pollService)
+	go pollCloser.Run(ctx)
+
+	locationExpirer := services.NewLocationExpirer(locationService)
+	go locationExpirer.Run(ctx)
+
+	outboxRelay := services.NewOutboxRelay(outboxRepo, wsService)
+	go outboxRelay.Run(ctx)
 
 	// Initialize handlers
-	authHandler := handlers.NewAuthHandler(userRepo, tokenRepo, jwtSecret, translator)
-	messageHandler := handlers.NewMessageHandler(msgService, wsService, msgRepo, userRepo, chatRepo, attachmentRepo, storageInst, translator)
-	chatHandler := handlers.NewChatHandler(chatRepo, userRepo, clientManager, translator)
+	authHandler := handlers.NewAuthHandler(userRepo, tokenRepo, restrictionRepo, jwtSecret, translator)
+	messageHandler := handlers.NewMessageHandler(msgService, mentionService, previewService, deliveryService, msgRepo, userRepo, chatRepo, storageInst, translator)
+	chatHandler := handlers.NewChatHandler(chatRepo, userRepo, pinRepo, clientManager, chatService, translator)
+	pinHandler := handlers.NewPinHandler(pinService, pinRepo, chatRepo, msgRepo, translator)
+	bookmarkHandler := handlers.NewBookmarkHandler(bookmarkRepo, chatRepo, msgRepo, translator)
+	pollHandler := handlers.NewPollHandler(pollService, pollRepo, msgRepo, chatRepo, translator)
+	locationHandler := handlers.NewLocationHandler(msgService, locationService, locationRepo, msgRepo, chatRepo, translator)
+	contactHandler := handlers.NewContactHandler(msgService, contactRepo, msgRepo, chatRepo, userRepo, translator)
+	stickerHandler := handlers.NewStickerHandler(stickerService, msgService, stickerRepo, chatRepo, storageInst, translator)
+	gifHandler := handlers.NewGifHandler(gifProvider, msgService, gifRepo, chatRepo, translator)
+	syncHandler := handlers.NewSyncHandler(updateRepo, translator)
+	encryptionHandler := handlers.NewEncryptionHandler(keyService, msgService, keyRepo, msgRepo, chatRepo, translator)
+	moderationHandler := handlers.NewModerationHandler(moderationRepo, msgService, msgRepo, translator)
+	reportHandler := handlers.NewReportHandler(reportService, reportRepo, msgRepo, userRepo, storageInst, translator)
+	restrictionHandler := handlers.NewRestrictionHandler(restrictionRepo, userRepo, clientManager, translator)
 	userHandler := handlers.NewUserHandler(userRepo, clientManager, storageInst, translator)
-	wsHandler := handlers.NewWebSocketHandler(clientManager, tokenRepo, translator, jwtSecret)
+	wsHandler := handlers.NewWebSocketHandler(clientManager, tokenRepo, restrictionRepo, translator, jwtSecret)
 
 	// Setup routes
 	r := mux.NewRouter()
 	r.Use(middleware.CORS())
 	r.Use(middleware.LanguageMiddleware(utils.FallbackLang))
-	handlers.RegisterRoutes(r, authHandler, messageHandler, chatHandler, userHandler, wsHandler)
+	handlers.RegisterRoutes(r, authHandler, messageHandler, chatHandler, pinHandler, bookmarkHandler, pollHandler, locationHandler, contactHandler, stickerHandler, gifHandler, syncHandler, encryptionHandler, moderationHandler, reportHandler, restrictionHandler, userHandler, wsHandler, idempotencyRepo, cfg.IdempotencyTTL)
 
 	log.Printf("Server running on %s", cfg.ServerPort)
 	if err := http.ListenAndServe(cfg.ServerPort, r); err != nil {
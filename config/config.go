@@ -3,6 +3,7 @@ package config
 import (
 	"log"
 	"os"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -16,6 +17,10 @@ type Config struct {
 	RedisHost  string
 	RedisPort  string
 	ServerPort string
+	GiphyKey   string
+
+	IdempotencyTTL time.Duration
+	ClassifierURL  string
 }
 
 func LoadConfig() *Config {
@@ -33,6 +38,10 @@ func LoadConfig() *Config {
 		RedisHost:  getEnv("REDIS_HOST", "localhost"),
 		RedisPort:  getEnv("REDIS_PORT", "6379"),
 		ServerPort: getEnv("SERVER_PORT", ":8080"),
+		GiphyKey:   getEnv("GIPHY_API_KEY", ""),
+
+		IdempotencyTTL: getDurationEnv("IDEMPOTENCY_TTL", 24*time.Hour),
+		ClassifierURL:  getEnv("MODERATION_CLASSIFIER_URL", ""),
 	}
 }
 
@@ -42,3 +51,16 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid duration %q for %s, using %s", value, key, defaultValue)
+		return defaultValue
+	}
+	return duration
+}
@@ -0,0 +1,6 @@
+ALTER TABLE messages
+    DROP COLUMN IF EXISTS is_forwarded,
+    DROP COLUMN IF EXISTS forwarded_from_message_id,
+    DROP COLUMN IF EXISTS forwarded_from_chat_id,
+    DROP COLUMN IF EXISTS forwarded_from_user_id,
+    DROP COLUMN IF EXISTS forwarded_from_date;
\ No newline at end of file
@@ -0,0 +1,6 @@
+ALTER TABLE messages
+    ADD COLUMN is_forwarded              BOOLEAN NOT NULL DEFAULT FALSE,                      -- Whether the message was forwarded
+    ADD COLUMN forwarded_from_message_id INT REFERENCES messages (id) ON DELETE SET NULL,     -- Original message
+    ADD COLUMN forwarded_from_chat_id    INT REFERENCES chats (id) ON DELETE SET NULL,        -- Chat the original message was sent to
+    ADD COLUMN forwarded_from_user_id    INT REFERENCES users (id) ON DELETE SET NULL,        -- Original sender (NULL when hidden)
+    ADD COLUMN forwarded_from_date       TIMESTAMP WITH TIME ZONE;                            -- When the original message was sent
\ No newline at end of file
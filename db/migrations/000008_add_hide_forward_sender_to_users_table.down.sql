@@ -0,0 +1 @@
+ALTER TABLE users DROP COLUMN IF EXISTS hide_forward_sender;
\ No newline at end of file
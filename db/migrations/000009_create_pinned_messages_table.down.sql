@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS pinned_messages CASCADE;
+DROP INDEX IF EXISTS idx_pinned_messages_chat_id;
\ No newline at end of file
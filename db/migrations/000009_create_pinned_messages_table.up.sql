@@ -0,0 +1,12 @@
+CREATE TABLE pinned_messages
+(
+    id         SERIAL PRIMARY KEY,                                                  -- Unique identifier for the pin
+    chat_id    INT                      NOT NULL REFERENCES chats (id) ON DELETE CASCADE,
+    message_id INT                      NOT NULL REFERENCES messages (id) ON DELETE CASCADE,
+    pinned_by  INT                      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    notified   BOOLEAN                  NOT NULL DEFAULT FALSE,                     -- Whether the other participant was notified
+    pinned_at  TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (chat_id, message_id)
+);
+
+CREATE INDEX idx_pinned_messages_chat_id ON pinned_messages (chat_id, pinned_at DESC);
\ No newline at end of file
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_messages_scheduled_at;
+ALTER TABLE messages DROP COLUMN IF EXISTS scheduled_at;
\ No newline at end of file
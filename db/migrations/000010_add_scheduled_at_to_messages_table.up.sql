@@ -0,0 +1,4 @@
+ALTER TABLE messages
+    ADD COLUMN scheduled_at TIMESTAMP WITH TIME ZONE NULL; -- Set while the message is waiting to be delivered
+
+CREATE INDEX idx_messages_scheduled_at ON messages (scheduled_at) WHERE scheduled_at IS NOT NULL;
\ No newline at end of file
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_messages_parent_id;
+ALTER TABLE messages
+    DROP COLUMN IF EXISTS quote_text,
+    DROP COLUMN IF EXISTS quote_offset;
\ No newline at end of file
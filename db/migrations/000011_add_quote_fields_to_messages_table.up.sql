@@ -0,0 +1,5 @@
+ALTER TABLE messages
+    ADD COLUMN quote_text   TEXT NULL, -- Quoted excerpt of the parent message
+    ADD COLUMN quote_offset INT  NULL; -- Offset of the excerpt in the parent content (UTF-16 code units)
+
+CREATE INDEX idx_messages_parent_id ON messages (parent_id) WHERE parent_id IS NOT NULL;
\ No newline at end of file
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_messages_search_vector;
+ALTER TABLE messages DROP COLUMN IF EXISTS search_vector;
+
+DROP TEXT SEARCH CONFIGURATION IF EXISTS messenger_en;
+DROP TEXT SEARCH CONFIGURATION IF EXISTS messenger_uk;
+DROP TEXT SEARCH CONFIGURATION IF EXISTS messenger_pl;
\ No newline at end of file
@@ -0,0 +1,15 @@
+-- One text search configuration per supported locale. Ukrainian and Polish start as copies of
+-- "simple" because PostgreSQL ships no stemmers for them; a hunspell dictionary can be mapped
+-- onto them later with ALTER TEXT SEARCH CONFIGURATION without touching the application.
+CREATE TEXT SEARCH CONFIGURATION messenger_en (COPY = english);
+CREATE TEXT SEARCH CONFIGURATION messenger_uk (COPY = simple);
+CREATE TEXT SEARCH CONFIGURATION messenger_pl (COPY = simple);
+
+ALTER TABLE messages
+    ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (
+        to_tsvector('messenger_en', COALESCE(content, '')) ||
+        to_tsvector('messenger_uk', COALESCE(content, '')) ||
+        to_tsvector('messenger_pl', COALESCE(content, ''))
+    ) STORED;
+
+CREATE INDEX idx_messages_search_vector ON messages USING GIN (search_vector);
\ No newline at end of file
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS message_entities CASCADE;
+DROP INDEX IF EXISTS idx_message_entities_message_id;
+DROP INDEX IF EXISTS idx_message_entities_mentions;
\ No newline at end of file
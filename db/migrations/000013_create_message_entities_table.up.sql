@@ -0,0 +1,13 @@
+CREATE TABLE message_entities
+(
+    id         SERIAL PRIMARY KEY,                                                  -- Unique identifier for the entity
+    message_id INT                      NOT NULL REFERENCES messages (id) ON DELETE CASCADE,
+    type       VARCHAR(20)              NOT NULL,                                   -- Entity type (e.g., mention)
+    "offset"   INT                      NOT NULL,                                   -- Start in the content (UTF-16 code units)
+    length     INT                      NOT NULL,                                   -- Length in the content (UTF-16 code units)
+    user_id    INT                      REFERENCES users (id) ON DELETE SET NULL,   -- Referenced user for mentions
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_message_entities_message_id ON message_entities (message_id);
+CREATE INDEX idx_message_entities_mentions ON message_entities (user_id, type) WHERE user_id IS NOT NULL;
\ No newline at end of file
@@ -0,0 +1,13 @@
+CREATE TABLE link_previews
+(
+    id          SERIAL PRIMARY KEY,                      -- Unique identifier for the preview
+    message_id  INT          NOT NULL UNIQUE,            -- Foreign key referencing messages
+    url         TEXT         NOT NULL,                   -- URL the preview was built for
+    title       VARCHAR(300),                            -- og:title, twitter:title or <title>
+    description TEXT,                                    -- og:description, twitter:description or meta description
+    image_url   TEXT,                                    -- og:image or twitter:image
+    site_name   VARCHAR(200),                            -- og:site_name or twitter:site
+    created_at  TIMESTAMP WITH TIME ZONE DEFAULT NOW(),  -- Timestamp when the preview was created
+    updated_at  TIMESTAMP WITH TIME ZONE DEFAULT NOW(),  -- Timestamp when the preview was updated
+    CONSTRAINT fk_message FOREIGN KEY (message_id) REFERENCES messages (id) ON DELETE CASCADE
+);
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE message_entities
+    DROP COLUMN IF EXISTS url,
+    DROP COLUMN IF EXISTS language;
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE message_entities
+    ADD COLUMN url      TEXT        NULL, -- Target of text_link entities
+    ADD COLUMN language VARCHAR(50) NULL; -- Programming language of pre entities
\ No newline at end of file
@@ -0,0 +1,39 @@
+ALTER TABLE messages
+    ADD COLUMN type VARCHAR(20) NOT NULL DEFAULT 'text'; -- Message kind (e.g., text, poll)
+
+CREATE TABLE polls
+(
+    id                      SERIAL PRIMARY KEY,                                                  -- Unique identifier for the poll
+    message_id              INT                      NOT NULL UNIQUE REFERENCES messages (id) ON DELETE CASCADE,
+    question                VARCHAR(300)             NOT NULL,
+    is_anonymous            BOOLEAN                  NOT NULL DEFAULT TRUE,                      -- Whether voters are hidden
+    allows_multiple_answers BOOLEAN                  NOT NULL DEFAULT FALSE,
+    is_quiz                 BOOLEAN                  NOT NULL DEFAULT FALSE,
+    correct_option          INT                      NULL,                                       -- Position of the correct option in quiz mode
+    closes_at               TIMESTAMP WITH TIME ZONE NULL,                                       -- When the poll closes automatically
+    closed_at               TIMESTAMP WITH TIME ZONE NULL,                                       -- Set once the results are locked
+    created_at              TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_polls_closes_at ON polls (closes_at) WHERE closes_at IS NOT NULL AND closed_at IS NULL;
+
+CREATE TABLE poll_options
+(
+    id       SERIAL PRIMARY KEY,                                                                 -- Unique identifier for the option
+    poll_id  INT          NOT NULL REFERENCES polls (id) ON DELETE CASCADE,
+    position INT          NOT NULL,                                                              -- Order of the option in the poll
+    text     VARCHAR(100) NOT NULL,
+    UNIQUE (poll_id, position)
+);
+
+CREATE TABLE poll_votes
+(
+    id         SERIAL PRIMARY KEY,                                                               -- Unique identifier for the vote
+    poll_id    INT                      NOT NULL REFERENCES polls (id) ON DELETE CASCADE,
+    option_id  INT                      NOT NULL REFERENCES poll_options (id) ON DELETE CASCADE,
+    user_id    INT                      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (option_id, user_id)
+);
+
+CREATE INDEX idx_poll_votes_poll_id ON poll_votes (poll_id, user_id);
\ No newline at end of file
@@ -0,0 +1,13 @@
+CREATE TABLE locations
+(
+    id          SERIAL PRIMARY KEY,                                                  -- Unique identifier for the location
+    message_id  INT                      NOT NULL UNIQUE REFERENCES messages (id) ON DELETE CASCADE,
+    latitude    DOUBLE PRECISION         NOT NULL,
+    longitude   DOUBLE PRECISION         NOT NULL,
+    accuracy    DOUBLE PRECISION         NULL,                                       -- Radius of uncertainty in meters
+    heading     INT                      NULL,                                       -- Direction of movement in degrees (0-359)
+    live_period INT                      NULL,                                       -- Chosen sharing period in seconds for live locations
+    live_until  TIMESTAMP WITH TIME ZONE NULL,                                       -- When live sharing ends or was stopped
+    created_at  TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at  TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
\ No newline at end of file
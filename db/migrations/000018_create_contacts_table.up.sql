@@ -0,0 +1,11 @@
+CREATE TABLE contacts
+(
+    id         SERIAL PRIMARY KEY,                                                  -- Unique identifier for the contact card
+    message_id INT                      NOT NULL UNIQUE REFERENCES messages (id) ON DELETE CASCADE,
+    first_name VARCHAR(100)             NOT NULL,
+    last_name  VARCHAR(100)             NULL,
+    phone      VARCHAR(32)              NOT NULL,                                   -- Normalized phone number
+    user_id    INT                      REFERENCES users (id) ON DELETE SET NULL,   -- Registered user with the same phone
+    vcard      TEXT                     NULL,                                       -- Original vCard submitted by the sender
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
\ No newline at end of file
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS gifs;
+ALTER TABLE messages DROP COLUMN IF EXISTS sticker_id;
+DROP TABLE IF EXISTS recent_stickers;
+DROP TABLE IF EXISTS user_sticker_packs;
+DROP TABLE IF EXISTS stickers;
+DROP TABLE IF EXISTS sticker_packs;
\ No newline at end of file
@@ -0,0 +1,55 @@
+CREATE TABLE sticker_packs
+(
+    id         SERIAL PRIMARY KEY,                                                  -- Unique identifier for the pack
+    name       VARCHAR(64)              NOT NULL UNIQUE,                            -- Short name used in links
+    title      VARCHAR(100)             NOT NULL,
+    author_id  INT                      REFERENCES users (id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE stickers
+(
+    id         SERIAL PRIMARY KEY,                                                  -- Unique identifier for the sticker
+    pack_id    INT                      NOT NULL REFERENCES sticker_packs (id) ON DELETE CASCADE,
+    file_path  VARCHAR(255)             NOT NULL,                                   -- File name in the stickers storage
+    emojis     TEXT[]                   NOT NULL DEFAULT '{}',                      -- Emojis the sticker is associated with
+    position   INT                      NOT NULL,                                   -- Order of the sticker in the pack
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_stickers_pack_id ON stickers (pack_id, position);
+
+CREATE TABLE user_sticker_packs
+(
+    user_id      INT                      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    pack_id      INT                      NOT NULL REFERENCES sticker_packs (id) ON DELETE CASCADE,
+    position     INT                      NOT NULL,                                 -- Order of the pack in the user's panel
+    installed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, pack_id)
+);
+
+CREATE TABLE recent_stickers
+(
+    user_id    INT                      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    sticker_id INT                      NOT NULL REFERENCES stickers (id) ON DELETE CASCADE,
+    used_at    TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, sticker_id)
+);
+
+ALTER TABLE messages
+    ADD COLUMN sticker_id INT NULL REFERENCES stickers (id) ON DELETE SET NULL; -- Sticker of sticker messages
+
+CREATE TABLE gifs
+(
+    id          SERIAL PRIMARY KEY,                                                 -- Unique identifier for the GIF
+    message_id  INT                      NOT NULL UNIQUE REFERENCES messages (id) ON DELETE CASCADE,
+    provider    VARCHAR(20)              NOT NULL,                                  -- Search provider the GIF came from
+    external_id VARCHAR(100)             NOT NULL,                                  -- GIF ID at the provider
+    url         TEXT                     NOT NULL,
+    preview_url TEXT                     NULL,
+    width       INT                      NOT NULL DEFAULT 0,
+    height      INT                      NOT NULL DEFAULT 0,
+    title       VARCHAR(255)             NULL,
+    created_at  TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
\ No newline at end of file
@@ -0,0 +1,5 @@
+ALTER TABLE attachments
+    DROP COLUMN type,
+    DROP COLUMN duration_ms,
+    DROP COLUMN waveform,
+    DROP COLUMN listened_at;
\ No newline at end of file
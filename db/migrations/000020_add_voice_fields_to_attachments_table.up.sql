@@ -0,0 +1,5 @@
+ALTER TABLE attachments
+    ADD COLUMN type        VARCHAR(20)              NOT NULL DEFAULT 'file', -- Attachment type: file or voice
+    ADD COLUMN duration_ms INT                      NULL,                    -- Length of voice messages in milliseconds
+    ADD COLUMN waveform    SMALLINT[]               NULL,                    -- Downsampled amplitudes of voice messages
+    ADD COLUMN listened_at TIMESTAMP WITH TIME ZONE NULL;                    -- When the recipient played the voice message
\ No newline at end of file
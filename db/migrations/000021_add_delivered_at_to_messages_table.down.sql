@@ -0,0 +1 @@
+ALTER TABLE messages DROP COLUMN IF EXISTS delivered_at;
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE messages
+    ADD COLUMN delivered_at TIMESTAMP WITH TIME ZONE NULL; -- When the message reached a device of the recipient
\ No newline at end of file
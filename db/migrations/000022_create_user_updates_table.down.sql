@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS user_updates;
+DROP TABLE IF EXISTS user_update_seqs;
\ No newline at end of file
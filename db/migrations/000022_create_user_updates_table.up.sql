@@ -0,0 +1,15 @@
+CREATE TABLE user_update_seqs
+(
+    user_id INT    PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    seq     BIGINT NOT NULL DEFAULT 0 -- Sequence number of the latest update of the user
+);
+
+CREATE TABLE user_updates
+(
+    user_id    INT                      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    seq        BIGINT                   NOT NULL,                         -- Position of the update in the user's log
+    event      VARCHAR(50)              NOT NULL,                         -- Websocket event type
+    payload    JSONB                    NOT NULL,                         -- Message of the websocket notification
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, seq)
+);
\ No newline at end of file
@@ -0,0 +1,15 @@
+CREATE TABLE outbox_events
+(
+    id              BIGSERIAL PRIMARY KEY,                                                     -- Publishing order of the events
+    user_id         INT                      NOT NULL REFERENCES users (id) ON DELETE CASCADE, -- Recipient of the event
+    event           VARCHAR(50)              NOT NULL,                                         -- Websocket event type
+    payload         JSONB                    NOT NULL,
+    attempts        INT                      NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,               -- Also leases claimed events to one relay
+    last_error      TEXT                     NULL,
+    delivered_at    TIMESTAMP WITH TIME ZONE NULL,
+    failed_at       TIMESTAMP WITH TIME ZONE NULL,                                             -- Set when the relay gives up on the event
+    created_at      TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_outbox_events_pending ON outbox_events (next_attempt_at) WHERE delivered_at IS NULL AND failed_at IS NULL;
\ No newline at end of file
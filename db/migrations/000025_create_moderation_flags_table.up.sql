@@ -0,0 +1,15 @@
+CREATE TABLE moderation_flags
+(
+    id          SERIAL PRIMARY KEY,
+    message_id  INT                      NULL REFERENCES messages (id) ON DELETE SET NULL, -- Kept after the message is removed
+    sender_id   INT                      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    filter      VARCHAR(50)              NOT NULL,                                         -- Filter that flagged the message
+    reason      VARCHAR(255)             NOT NULL,
+    details     TEXT                     NULL,                                             -- Name of the flagged attachment, if any
+    status      VARCHAR(20)              NOT NULL DEFAULT 'pending',                       -- pending, approved or removed
+    reviewed_by INT                      NULL REFERENCES users (id) ON DELETE SET NULL,
+    reviewed_at TIMESTAMP WITH TIME ZONE NULL,
+    created_at  TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_moderation_flags_status ON moderation_flags (status, created_at);
\ No newline at end of file
@@ -0,0 +1,20 @@
+CREATE TABLE reports
+(
+    id               SERIAL PRIMARY KEY,
+    reporter_id      INT                      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    reported_user_id INT                      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    message_id       INT                      NULL REFERENCES messages (id) ON DELETE SET NULL, -- Kept after the message is deleted
+    reason           VARCHAR(30)              NOT NULL,                                         -- Category picked by the reporter
+    comment          TEXT                     NULL,
+    snapshot         JSONB                    NULL,                                             -- Copy of the message and its attachments
+    status           VARCHAR(20)              NOT NULL DEFAULT 'open',                          -- open, resolved or dismissed
+    assignee_id      INT                      NULL REFERENCES users (id) ON DELETE SET NULL,
+    assigned_at      TIMESTAMP WITH TIME ZONE NULL,
+    action           VARCHAR(20)              NULL,                                             -- delete_content, warn, suspend or dismiss
+    resolution_note  TEXT                     NULL,
+    resolved_by      INT                      NULL REFERENCES users (id) ON DELETE SET NULL,
+    resolved_at      TIMESTAMP WITH TIME ZONE NULL,
+    created_at       TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_reports_status ON reports (status, created_at);
\ No newline at end of file
@@ -0,0 +1,13 @@
+CREATE TABLE user_restrictions
+(
+    id         SERIAL PRIMARY KEY,
+    user_id    INT                      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    type       VARCHAR(20)              NOT NULL,                                         -- Kind of restriction, such as suspension
+    reason     TEXT                     NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    report_id  INT                      NULL REFERENCES reports (id) ON DELETE SET NULL,  -- Report that led to the restriction
+    created_by INT                      NULL REFERENCES users (id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_user_restrictions_user_id ON user_restrictions (user_id, expires_at);
\ No newline at end of file
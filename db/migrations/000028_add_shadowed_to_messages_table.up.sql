@@ -0,0 +1,2 @@
+ALTER TABLE messages
+    ADD COLUMN shadowed BOOLEAN NOT NULL DEFAULT FALSE; -- Sent while the sender was shadow-banned, so only the sender sees it
\ No newline at end of file
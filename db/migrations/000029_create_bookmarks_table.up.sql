@@ -0,0 +1,12 @@
+CREATE TABLE bookmarks
+(
+    id         SERIAL PRIMARY KEY,
+    user_id    INT                      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    message_id INT                      NOT NULL REFERENCES messages (id) ON DELETE CASCADE, -- Deleting the message removes its bookmarks
+    tags       TEXT[]                   NOT NULL DEFAULT '{}',                              -- Labels the user filters the starred list by
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, message_id)
+);
+
+CREATE INDEX idx_bookmarks_user_id ON bookmarks (user_id, created_at DESC);
+CREATE INDEX idx_bookmarks_tags ON bookmarks USING GIN (tags);
\ No newline at end of file
@@ -0,0 +1,13 @@
+DROP INDEX IF EXISTS idx_messages_search_vector;
+ALTER TABLE messages DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE messages
+    ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (
+        to_tsvector('messenger_en', COALESCE(content, '')) ||
+        to_tsvector('messenger_uk', COALESCE(content, '')) ||
+        to_tsvector('messenger_pl', COALESCE(content, ''))
+    ) STORED;
+
+CREATE INDEX idx_messages_search_vector ON messages USING GIN (search_vector);
+
+DROP TABLE IF EXISTS one_time_prekeys;
+DROP TABLE IF EXISTS device_keys;
\ No newline at end of file
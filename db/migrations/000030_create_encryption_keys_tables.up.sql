@@ -0,0 +1,39 @@
+CREATE TABLE device_keys
+(
+    id                      SERIAL PRIMARY KEY,
+    user_id                 INT                      NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    device_id               INT                      NOT NULL,                                         -- Chosen by the client, unique per user
+    registration_id         INT                      NOT NULL,
+    identity_key            TEXT                     NOT NULL,                                         -- Base64 public identity key
+    signed_prekey_id        INT                      NOT NULL,
+    signed_prekey           TEXT                     NOT NULL,                                         -- Base64 public key, rotated by the client
+    signed_prekey_signature TEXT                     NOT NULL,                                         -- Signature of the signed prekey by the identity key
+    created_at              TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at              TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, device_id)
+);
+
+CREATE TABLE one_time_prekeys
+(
+    id            SERIAL PRIMARY KEY,
+    device_key_id INT                      NOT NULL REFERENCES device_keys (id) ON DELETE CASCADE,
+    key_id        INT                      NOT NULL,
+    public_key    TEXT                     NOT NULL,                                               -- Base64 public key, deleted once handed out
+    created_at    TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (device_key_id, key_id)
+);
+
+-- Encrypted messages hold a ciphertext envelope, which must not be indexed for search
+DROP INDEX IF EXISTS idx_messages_search_vector;
+ALTER TABLE messages DROP COLUMN IF EXISTS search_vector;
+ALTER TABLE messages
+    ADD COLUMN search_vector TSVECTOR GENERATED ALWAYS AS (
+        CASE
+            WHEN type = 'encrypted' THEN NULL
+            ELSE to_tsvector('messenger_en', COALESCE(content, '')) ||
+                 to_tsvector('messenger_uk', COALESCE(content, '')) ||
+                 to_tsvector('messenger_pl', COALESCE(content, ''))
+            END
+    ) STORED;
+
+CREATE INDEX idx_messages_search_vector ON messages USING GIN (search_vector);
\ No newline at end of file
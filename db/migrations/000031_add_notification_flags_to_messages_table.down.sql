@@ -0,0 +1,3 @@
+ALTER TABLE messages
+    DROP COLUMN IF EXISTS silent,
+    DROP COLUMN IF EXISTS no_forward;
\ No newline at end of file
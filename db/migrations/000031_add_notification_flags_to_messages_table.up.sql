@@ -0,0 +1,3 @@
+ALTER TABLE messages
+    ADD COLUMN silent     BOOLEAN NOT NULL DEFAULT FALSE, -- Delivered without a sound or a push notification
+    ADD COLUMN no_forward BOOLEAN NOT NULL DEFAULT FALSE; -- Recipients can't forward the message
\ No newline at end of file
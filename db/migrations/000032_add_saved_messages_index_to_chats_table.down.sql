@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_chats_saved_messages;
\ No newline at end of file
@@ -0,0 +1 @@
+CREATE UNIQUE INDEX IF NOT EXISTS idx_chats_saved_messages ON chats (user1_id) WHERE user1_id = user2_id; -- One Saved Messages chat per user
\ No newline at end of file
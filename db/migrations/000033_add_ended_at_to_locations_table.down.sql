@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_locations_live_until;
+
+ALTER TABLE locations
+    DROP COLUMN IF EXISTS ended_at;
\ No newline at end of file
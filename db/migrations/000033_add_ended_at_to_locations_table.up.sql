@@ -0,0 +1,9 @@
+ALTER TABLE locations
+    ADD COLUMN ended_at TIMESTAMP WITH TIME ZONE NULL; -- When the end of live sharing was announced
+
+-- Live locations that are already over must not be announced again
+UPDATE locations
+SET ended_at = live_until
+WHERE live_until IS NOT NULL AND live_until <= NOW();
+
+CREATE INDEX idx_locations_live_until ON locations (live_until) WHERE live_until IS NOT NULL AND ended_at IS NULL;
\ No newline at end of file
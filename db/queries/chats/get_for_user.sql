@@ -27,6 +27,7 @@ SELECT c.id,
        m.recipient_id     AS last_message_recipient_id,
        LEFT(
                CASE
+                   WHEN m.type = 'encrypted' THEN NULL
                    WHEN LENGTH(m.content) > $4 THEN CONCAT(SUBSTRING(m.content, 1, $4), '...')
                    ELSE m.content
                    END,
@@ -42,7 +43,20 @@ SELECT c.id,
        a.file_type        AS attachment_file_type,
        a.file_size        AS attachment_file_size,
        a.created_at       AS attachment_created_at,
-       a.updated_at       AS attachment_updated_at
+       a.updated_at       AS attachment_updated_at,
+       (SELECT COUNT(*)
+        FROM messages um
+        WHERE um.chat_id = c.id
+          AND um.recipient_id = $1
+          AND um.read_at IS NULL
+          AND um.scheduled_at IS NULL
+          AND NOT um.shadowed
+          AND um.sender_id <> um.recipient_id
+          AND EXISTS (SELECT 1
+                      FROM message_entities ue
+                      WHERE ue.message_id = um.id
+                        AND ue.type = 'mention'
+                        AND ue.user_id = $1)) AS unread_mention_count
 FROM chats c
          LEFT JOIN users u1 ON c.user1_id = u1.id
          LEFT JOIN users u2 ON c.user2_id = u2.id
@@ -56,5 +70,5 @@ FROM chats c
     ) a ON true
 WHERE c.user1_id = $1
    OR c.user2_id = $1
-ORDER BY c.updated_at DESC
+ORDER BY c.user1_id = c.user2_id DESC, c.updated_at DESC
 LIMIT $2 OFFSET $3
\ No newline at end of file
@@ -13,6 +13,7 @@ require (
 	github.com/nicksnyder/go-i18n/v2 v2.4.1
 	github.com/twilio/twilio-go v1.23.8
 	golang.org/x/crypto v0.31.0
+	golang.org/x/net v0.21.0
 	golang.org/x/text v0.21.0
 )
 
@@ -26,6 +27,5 @@ require (
 	github.com/leodido/go-urn v1.4.0 // indirect
 	github.com/onsi/gomega v1.25.0 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
-	golang.org/x/net v0.21.0 // indirect
 	golang.org/x/sys v0.28.0 // indirect
 )
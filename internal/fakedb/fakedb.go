@@ -0,0 +1,178 @@
+// Package fakedb is a database/sql driver for tests. Its answers come from a function of the test,
+// so code can run against the real repositories without a Postgres server.
+package fakedb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Result is the answer of the fake database to one statement: the rows of a query,
+// or the number of rows an exec affected.
+type Result struct {
+	Columns  []string
+	Rows     [][]driver.Value
+	Affected int64
+	Err      error
+}
+
+// Row answers a query with a single row.
+func Row(values ...driver.Value) Result {
+	columns := make([]string, len(values))
+	for i := range columns {
+		columns[i] = "column"
+	}
+	return Result{Columns: columns, Rows: [][]driver.Value{values}}
+}
+
+type Statement struct {
+	Query string
+	Args  []driver.Value
+}
+
+// Database records every statement and the number of committed and rolled back transactions.
+type Database struct {
+	respond func(query string, args []driver.Value) Result
+
+	mu         sync.Mutex
+	statements []Statement
+	Commits    int
+	Rollbacks  int
+}
+
+// Open returns a database answered by respond, which is closed when the test ends.
+func Open(t testing.TB, respond func(query string, args []driver.Value) Result) (*sql.DB, *Database) {
+	t.Helper()
+
+	fake := &Database{respond: respond}
+	db := sql.OpenDB(fake)
+	t.Cleanup(func() { db.Close() })
+	return db, fake
+}
+
+// Executed returns the statements whose query contains the given text.
+func (d *Database) Executed(text string) []Statement {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	statements := make([]Statement, 0)
+	for _, statement := range d.statements {
+		if strings.Contains(statement.Query, text) {
+			statements = append(statements, statement)
+		}
+	}
+	return statements
+}
+
+func (d *Database) run(query string, args []driver.Value) Result {
+	d.mu.Lock()
+	d.statements = append(d.statements, Statement{Query: query, Args: args})
+	d.mu.Unlock()
+
+	return d.respond(query, args)
+}
+
+func (d *Database) Connect(context.Context) (driver.Conn, error) {
+	return &conn{db: d}, nil
+}
+
+func (d *Database) Driver() driver.Driver {
+	return fakeDriver{}
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("the fake database is opened with fakedb.Open")
+}
+
+type conn struct {
+	db *Database
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return &stmt{db: c.db, query: query}, nil
+}
+
+func (c *conn) Close() error {
+	return nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return &tx{db: c.db}, nil
+}
+
+type tx struct {
+	db *Database
+}
+
+func (tx *tx) Commit() error {
+	tx.db.mu.Lock()
+	defer tx.db.mu.Unlock()
+	tx.db.Commits++
+	return nil
+}
+
+func (tx *tx) Rollback() error {
+	tx.db.mu.Lock()
+	defer tx.db.mu.Unlock()
+	tx.db.Rollbacks++
+	return nil
+}
+
+type stmt struct {
+	db    *Database
+	query string
+}
+
+func (s *stmt) Close() error {
+	return nil
+}
+
+func (s *stmt) NumInput() int {
+	return -1
+}
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	result := s.db.run(s.query, args)
+	if result.Err != nil {
+		return nil, result.Err
+	}
+	return driver.RowsAffected(result.Affected), nil
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	result := s.db.run(s.query, args)
+	if result.Err != nil {
+		return nil, result.Err
+	}
+	return &rows{columns: result.Columns, rows: result.Rows}, nil
+}
+
+type rows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *rows) Columns() []string {
+	return r.columns
+}
+
+func (r *rows) Close() error {
+	return nil
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
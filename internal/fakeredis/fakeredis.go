@@ -0,0 +1,131 @@
+// Package fakeredis is an in-memory server of the Redis protocol for tests.
+package fakeredis
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Start serves the GET, SET (with NX), SETNX, DEL, INCR and EXPIRE commands from memory and returns
+// the address of the server, which is stopped when the test ends. Expiration is ignored.
+func Start(t testing.TB) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	var mu sync.Mutex
+	values := make(map[string]string)
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				for {
+					args, err := readCommand(reader)
+					if err != nil {
+						return
+					}
+
+					mu.Lock()
+					execute(conn, values, args)
+					mu.Unlock()
+				}
+			}()
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func execute(conn io.Writer, values map[string]string, args []string) {
+	switch strings.ToUpper(args[0]) {
+	case "GET":
+		if value, ok := values[args[1]]; ok {
+			fmt.Fprintf(conn, "$%d\r\n%s\r\n", len(value), value)
+		} else {
+			io.WriteString(conn, "$-1\r\n")
+		}
+	case "SET":
+		onlyNew := false
+		for _, option := range args[3:] {
+			if strings.EqualFold(option, "NX") {
+				onlyNew = true
+			}
+		}
+		if _, exists := values[args[1]]; exists && onlyNew {
+			io.WriteString(conn, "$-1\r\n")
+			return
+		}
+		values[args[1]] = args[2]
+		io.WriteString(conn, "+OK\r\n")
+	case "SETNX":
+		if _, exists := values[args[1]]; exists {
+			io.WriteString(conn, ":0\r\n")
+			return
+		}
+		values[args[1]] = args[2]
+		io.WriteString(conn, ":1\r\n")
+	case "DEL":
+		deleted := 0
+		for _, key := range args[1:] {
+			if _, exists := values[key]; exists {
+				delete(values, key)
+				deleted++
+			}
+		}
+		fmt.Fprintf(conn, ":%d\r\n", deleted)
+	case "INCR":
+		count, _ := strconv.Atoi(values[args[1]])
+		values[args[1]] = strconv.Itoa(count + 1)
+		fmt.Fprintf(conn, ":%d\r\n", count+1)
+	case "EXPIRE":
+		io.WriteString(conn, ":1\r\n")
+	default:
+		fmt.Fprintf(conn, "-ERR unknown command '%s'\r\n", args[0])
+	}
+}
+
+// readCommand reads a command sent as an array of bulk strings.
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil || count < 1 {
+		return nil, fmt.Errorf("malformed command: %q", line)
+	}
+
+	args := make([]string, count)
+	for i := range args {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		if err != nil {
+			return nil, fmt.Errorf("malformed argument: %q", line)
+		}
+		arg := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, arg); err != nil {
+			return nil, err
+		}
+		args[i] = string(arg[:size])
+	}
+	return args, nil
+}
@@ -26,23 +26,26 @@ const (
 )
 
 type AuthHandler struct {
-	UserRepo  *repository.UserRepository
-	TokenRepo *repository.TokenRepository
-	Secret    string
-	Trans     *utils.Translator
+	UserRepo        *repository.UserRepository
+	TokenRepo       *repository.TokenRepository
+	RestrictionRepo *repository.RestrictionRepository
+	Secret          string
+	Trans           *utils.Translator
 }
 
 func NewAuthHandler(
 	ur *repository.UserRepository,
 	tr *repository.TokenRepository,
+	rr *repository.RestrictionRepository,
 	secret string,
 	trans *utils.Translator,
 ) *AuthHandler {
 	return &AuthHandler{
-		UserRepo:  ur,
-		TokenRepo: tr,
-		Secret:    secret,
-		Trans:     trans,
+		UserRepo:        ur,
+		TokenRepo:       tr,
+		RestrictionRepo: rr,
+		Secret:          secret,
+		Trans:           trans,
 	}
 }
 
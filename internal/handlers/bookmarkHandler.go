@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/drTragger/messenger-backend/internal/models"
+	"github.com/drTragger/messenger-backend/internal/repository"
+	"github.com/drTragger/messenger-backend/internal/requests"
+	"github.com/drTragger/messenger-backend/internal/responses"
+	"github.com/drTragger/messenger-backend/internal/utils"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type BookmarkHandler struct {
+	BookmarkRepo *repository.BookmarkRepository
+	ChatRepo     *repository.ChatRepository
+	MsgRepo      *repository.MessageRepository
+	Trans        *utils.Translator
+}
+
+func NewBookmarkHandler(
+	bookmarkRepo *repository.BookmarkRepository,
+	chatRepo *repository.ChatRepository,
+	msgRepo *repository.MessageRepository,
+	trans *utils.Translator,
+) *BookmarkHandler {
+	return &BookmarkHandler{
+		BookmarkRepo: bookmarkRepo,
+		ChatRepo:     chatRepo,
+		MsgRepo:      msgRepo,
+		Trans:        trans,
+	}
+}
+
+// Star bookmarks a message of the chat for the caller. Bookmarks are private, so the other participant isn't notified.
+func (h *BookmarkHandler) Star(w http.ResponseWriter, r *http.Request) {
+	var payload requests.StarMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), err.Error())
+		return
+	}
+	payload.Tags = normalizeTags(payload.Tags)
+
+	if err := utils.ValidateStruct(&payload); err != nil {
+		responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), utils.FormatValidationError(r, err, h.Trans))
+		return
+	}
+
+	chat, userID, ok := getParticipantChat(w, r, h.ChatRepo, h.Trans)
+	if !ok {
+		return
+	}
+
+	message, err := h.MsgRepo.GetById(payload.MessageID)
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+	if message == nil || message.ChatID != chat.ID || message.ScheduledAt != nil || (message.Shadowed && message.SenderID != userID) {
+		responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), map[string]string{
+			"messageId": h.Trans.Translate(r, "validation.exists", nil),
+		})
+		return
+	}
+
+	bookmark, err := h.BookmarkRepo.Star(&models.Bookmark{
+		UserID:    userID,
+		MessageID: message.ID,
+		Tags:      payload.Tags,
+	})
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+	bookmark.Message = message
+
+	responses.SuccessResponse(w, http.StatusCreated, h.Trans.Translate(r, "success.bookmark.star", nil), bookmark)
+}
+
+func (h *BookmarkHandler) Unstar(w http.ResponseWriter, r *http.Request) {
+	messageID, err := strconv.Atoi(mux.Vars(r)["messageId"])
+	if err != nil || messageID < 0 {
+		responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), "Invalid message ID")
+		return
+	}
+
+	_, userID, ok := getParticipantChat(w, r, h.ChatRepo, h.Trans)
+	if !ok {
+		return
+	}
+
+	unstarred, err := h.BookmarkRepo.Unstar(userID, uint(messageID))
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+	if !unstarred {
+		responses.ErrorResponse(w, http.StatusNotFound, h.Trans.Translate(r, "errors.not_found", nil), "Starred message not found.")
+		return
+	}
+
+	responses.SuccessResponse(w, http.StatusOK, h.Trans.Translate(r, "success.bookmark.unstar", nil), nil)
+}
+
+// GetStarred returns the caller's starred messages across all of their chats, optionally filtered by a tag.
+func (h *BookmarkHandler) GetStarred(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var err error
+
+	limitStr := query.Get("limit")
+	limit := repository.BookmarksLimit
+	if limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), "Invalid limit")
+			return
+		}
+	}
+
+	offsetStr := query.Get("offset")
+	offset := repository.BookmarksOffset
+	if offsetStr != "" {
+		offset, err = strconv.Atoi(offsetStr)
+		if err != nil || offset < 0 {
+			responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), "Invalid offset.")
+			return
+		}
+	}
+
+	userID := r.Context().Value("user_id").(uint)
+	tag := strings.ToLower(strings.TrimSpace(query.Get("tag")))
+
+	bookmarks, err := h.MsgRepo.GetStarred(userID, tag, limit, offset)
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+
+	responses.SuccessResponse(w, http.StatusOK, h.Trans.Translate(r, "success.bookmark.get_list", nil), bookmarks)
+}
+
+// normalizeTags trims and lowercases the tags and drops duplicates, so "Work" and "work " are the same tag.
+func normalizeTags(tags []string) []string {
+	normalized := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
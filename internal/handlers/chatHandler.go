@@ -4,9 +4,11 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"github.com/drTragger/messenger-backend/internal/models"
 	"github.com/drTragger/messenger-backend/internal/repository"
 	"github.com/drTragger/messenger-backend/internal/requests"
 	"github.com/drTragger/messenger-backend/internal/responses"
+	"github.com/drTragger/messenger-backend/internal/services"
 	"github.com/drTragger/messenger-backend/internal/utils"
 	"github.com/drTragger/messenger-backend/internal/websocket"
 	"github.com/gorilla/mux"
@@ -17,20 +19,26 @@ import (
 type ChatHandler struct {
 	ChatRepo      *repository.ChatRepository
 	UserRepo      *repository.UserRepository
+	PinRepo       *repository.PinnedMessageRepository
 	ClientManager *websocket.ClientManager
+	ChatService   *services.ChatService
 	Trans         *utils.Translator
 }
 
 func NewChatHandler(
 	chatRepo *repository.ChatRepository,
 	userRepo *repository.UserRepository,
+	pinRepo *repository.PinnedMessageRepository,
 	clientManager *websocket.ClientManager,
+	chatService *services.ChatService,
 	trans *utils.Translator,
 ) *ChatHandler {
 	return &ChatHandler{
 		ChatRepo:      chatRepo,
 		UserRepo:      userRepo,
+		PinRepo:       pinRepo,
 		ClientManager: clientManager,
+		ChatService:   chatService,
 		Trans:         trans,
 	}
 }
@@ -66,13 +74,38 @@ func (h *ChatHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	chat, err := h.ChatRepo.Create(user1.ID, user2.ID, nil)
+	// Every user has a single Saved Messages chat, so asking for it again returns the existing one
+	if user1.ID == user2.ID {
+		saved, err := h.ChatRepo.GetSaved(user1.ID)
+		if err != nil {
+			responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+			return
+		}
+		if saved != nil {
+			responses.SuccessResponse(w, http.StatusOK, h.Trans.Translate(r, "success.chats.show", nil), saved)
+			return
+		}
+	}
+
+	chat, err := h.ChatService.Create(user1, user2)
+	// A concurrent request created the Saved Messages chat after it was looked up
+	if user1.ID == user2.ID && repository.IsUniqueViolation(err) {
+		saved, err := h.ChatRepo.GetSaved(user1.ID)
+		if err != nil {
+			responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+			return
+		}
+		if saved == nil {
+			responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), "Saved Messages chat not found.")
+			return
+		}
+		responses.SuccessResponse(w, http.StatusOK, h.Trans.Translate(r, "success.chats.show", nil), saved)
+		return
+	}
 	if err != nil {
 		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
 		return
 	}
-	chat.User1 = user1
-	chat.User2 = user2
 
 	responses.SuccessResponse(w, http.StatusCreated, h.Trans.Translate(r, "success.chats.create", nil), chat)
 }
@@ -164,5 +197,47 @@ func (h *ChatHandler) GetByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	chat.PinnedMessage, err = h.PinRepo.GetLatestForChat(chat.ID, userID)
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+
 	responses.SuccessResponse(w, http.StatusOK, h.Trans.Translate(r, "success.chats.show", nil), chat)
 }
+
+// getParticipantChat loads the chat from the route and makes sure the current user participates in it.
+// It writes the error response itself and returns false if the request should stop.
+func getParticipantChat(w http.ResponseWriter, r *http.Request, chatRepo *repository.ChatRepository, trans *utils.Translator) (*models.Chat, uint, bool) {
+	chatID, err := strconv.Atoi(mux.Vars(r)["chatId"])
+	if err != nil || chatID < 0 {
+		responses.ErrorResponse(w, http.StatusBadRequest, trans.Translate(r, "errors.input", nil), "Invalid chat ID")
+		return nil, 0, false
+	}
+
+	chat, err := chatRepo.GetByID(uint(chatID))
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, trans.Translate(r, "errors.server", nil), err.Error())
+		return nil, 0, false
+	}
+	if chat == nil {
+		responses.ErrorResponse(w, http.StatusNotFound, trans.Translate(r, "errors.not_found", nil), "Chat not found.")
+		return nil, 0, false
+	}
+
+	userID := r.Context().Value("user_id").(uint)
+	if chat.User1ID != userID && chat.User2ID != userID {
+		responses.ErrorResponse(w, http.StatusForbidden, trans.Translate(r, "errors.forbidden", nil), "Forbidden")
+		return nil, 0, false
+	}
+
+	return chat, userID, true
+}
+
+// otherParticipant returns the ID of the chat participant that is not userID.
+func otherParticipant(chat *models.Chat, userID uint) uint {
+	if chat.User1ID == userID {
+		return chat.User2ID
+	}
+	return chat.User1ID
+}
@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/drTragger/messenger-backend/internal/models"
+	"github.com/drTragger/messenger-backend/internal/repository"
+	"github.com/drTragger/messenger-backend/internal/requests"
+	"github.com/drTragger/messenger-backend/internal/responses"
+	"github.com/drTragger/messenger-backend/internal/services"
+	"github.com/drTragger/messenger-backend/internal/utils"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type ContactHandler struct {
+	MsgService  *services.MessageService
+	ContactRepo *repository.ContactRepository
+	MsgRepo     *repository.MessageRepository
+	ChatRepo    *repository.ChatRepository
+	UserRepo    *repository.UserRepository
+	Trans       *utils.Translator
+}
+
+func NewContactHandler(
+	msgService *services.MessageService,
+	contactRepo *repository.ContactRepository,
+	msgRepo *repository.MessageRepository,
+	chatRepo *repository.ChatRepository,
+	userRepo *repository.UserRepository,
+	trans *utils.Translator,
+) *ContactHandler {
+	return &ContactHandler{
+		MsgService:  msgService,
+		ContactRepo: contactRepo,
+		MsgRepo:     msgRepo,
+		ChatRepo:    chatRepo,
+		UserRepo:    userRepo,
+		Trans:       trans,
+	}
+}
+
+func (h *ContactHandler) Send(w http.ResponseWriter, r *http.Request) {
+	var payload requests.SendContactRequest
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), err.Error())
+		return
+	}
+
+	if err := utils.ValidateStruct(&payload); err != nil {
+		responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), utils.FormatValidationError(r, err, h.Trans))
+		return
+	}
+
+	contact := &models.Contact{}
+	if payload.VCard != nil {
+		parsed, err := services.ParseVCard(*payload.VCard)
+		if err != nil {
+			responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), map[string]string{
+				"vcard": h.Trans.Translate(r, "validation.vcard", nil),
+			})
+			return
+		}
+		contact = parsed
+		contact.VCard = payload.VCard
+	}
+	if payload.FirstName != nil {
+		contact.FirstName = *payload.FirstName
+	}
+	if payload.LastName != nil {
+		contact.LastName = payload.LastName
+	}
+	if payload.Phone != nil {
+		contact.Phone = *payload.Phone
+	}
+	// The submitted card doesn't match an overridden contact, so the card is generated from the contact instead
+	if payload.FirstName != nil || payload.LastName != nil || payload.Phone != nil {
+		contact.VCard = nil
+	}
+
+	contact.Phone = utils.NormalizePhone(contact.Phone)
+	if !utils.IsPhone(contact.Phone) {
+		responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), map[string]string{
+			"phone": h.Trans.Translate(r, "validation.phone", nil),
+		})
+		return
+	}
+
+	chat, userID, ok := getParticipantChat(w, r, h.ChatRepo, h.Trans)
+	if !ok {
+		return
+	}
+
+	user, err := h.findUserByPhone(contact.Phone)
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+	if user != nil {
+		contact.UserID = &user.ID
+		contact.User = &models.User{
+			ID:             user.ID,
+			Username:       user.Username,
+			FirstName:      user.FirstName,
+			LastName:       user.LastName,
+			ProfilePicture: user.ProfilePicture,
+		}
+	}
+
+	message := &models.Message{
+		SenderID:    userID,
+		RecipientID: otherParticipant(chat, userID),
+		ChatID:      chat.ID,
+		Type:        models.ContactMessage,
+	}
+	err = h.MsgService.SendTyped(message, contactText(contact), utils.GetLocale(r), func(tx *sql.Tx) error {
+		contact.MessageID = message.ID
+		message.Contact = contact
+		_, err := h.ContactRepo.WithTx(tx).Create(contact)
+		return err
+	})
+	if err != nil {
+		if respondModerationError(w, r, h.Trans, err) {
+			return
+		}
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+
+	responses.SuccessResponse(w, http.StatusCreated, h.Trans.Translate(r, "success.contact.send", nil), message)
+}
+
+// GetVCard exports a shared contact card. Without a version the card is returned as it was submitted,
+// or as vCard 4.0 if the sender did not submit one.
+func (h *ContactHandler) GetVCard(w http.ResponseWriter, r *http.Request) {
+	chat, _, ok := getParticipantChat(w, r, h.ChatRepo, h.Trans)
+	if !ok {
+		return
+	}
+
+	messageID, err := strconv.Atoi(mux.Vars(r)["messageId"])
+	if err != nil || messageID < 0 {
+		responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), "Invalid message ID")
+		return
+	}
+
+	message, err := h.MsgRepo.GetById(uint(messageID))
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+	if message == nil || message.ChatID != chat.ID || message.Contact == nil {
+		responses.ErrorResponse(w, http.StatusNotFound, h.Trans.Translate(r, "errors.not_found", nil), "Contact not found")
+		return
+	}
+
+	contact := message.Contact
+	version := r.URL.Query().Get("version")
+
+	var card string
+	if version == "" && contact.VCard != nil {
+		card = *contact.VCard
+	} else {
+		if version == "" {
+			version = services.VCard4
+		}
+		card, err = services.GenerateVCard(contact, version)
+		if err != nil {
+			responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), map[string]string{
+				"version": h.Trans.Translate(r, "validation.oneof", map[string]interface{}{
+					"Param": strings.Join([]string{services.VCard3, services.VCard4}, " "),
+				}),
+			})
+			return
+		}
+	}
+
+	fileName := fmt.Sprintf("%s.vcf", strings.TrimSpace(contact.FirstName))
+	responses.DownloadResponse(w, "text/vcard; charset=utf-8", fileName, []byte(card))
+}
+
+// findUserByPhone looks up a registered user by phone, with and without the leading plus sign.
+func (h *ContactHandler) findUserByPhone(phone string) (*models.User, error) {
+	user, err := h.UserRepo.GetUserByPhone(phone)
+	if err != nil || user != nil {
+		return user, err
+	}
+
+	if strings.HasPrefix(phone, "+") {
+		return h.UserRepo.GetUserByPhone(strings.TrimPrefix(phone, "+"))
+	}
+	return h.UserRepo.GetUserByPhone("+" + phone)
+}
+
+// contactText is what the moderation filters check in a shared contact: its names and its vCard.
+func contactText(contact *models.Contact) string {
+	parts := []string{contact.FirstName}
+	if contact.LastName != nil {
+		parts = append(parts, *contact.LastName)
+	}
+	if contact.VCard != nil {
+		parts = append(parts, *contact.VCard)
+	}
+	return strings.Join(parts, "\n")
+}
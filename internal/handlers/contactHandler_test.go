@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"github.com/drTragger/messenger-backend/internal/fakedb"
+	"github.com/drTragger/messenger-backend/internal/repository"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestContactHandlerSendVCard(t *testing.T) {
+	card := "BEGIN:VCARD\r\nVERSION:4.0\r\nN:Doe;John;;;\r\nTEL;VALUE=uri;TYPE=cell:tel:+380501234567\r\nEND:VCARD\r\n"
+
+	tests := []struct {
+		name      string
+		overrides map[string]string
+		firstName string
+		phone     string
+		keepsCard bool
+	}{
+		{name: "card as submitted", firstName: "John", phone: "+380501234567", keepsCard: true},
+		{name: "overridden name", overrides: map[string]string{"firstName": "Johnny"}, firstName: "Johnny", phone: "+380501234567"},
+		{name: "overridden phone", overrides: map[string]string{"phone": "+380671234567"}, firstName: "John", phone: "+380671234567"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := fakedb.Open(t, func(query string, args []driver.Value) fakedb.Result {
+				switch {
+				case strings.Contains(query, "FROM chats AS c"):
+					return chatRow(7, 1, 2)
+				case strings.Contains(query, "INSERT INTO messages"):
+					return fakedb.Row(int64(40), false, nil, nil, time.Now(), time.Now())
+				case strings.Contains(query, "INSERT INTO contacts"):
+					return fakedb.Row(int64(5))
+				case strings.Contains(query, "FROM users"):
+					return fakedb.Result{}
+				}
+				return fakedb.Result{Affected: 1}
+			})
+			handler := NewContactHandler(
+				newTestMessageService(db),
+				repository.NewContactRepository(db),
+				repository.NewMessageRepository(db),
+				repository.NewChatRepository(db),
+				repository.NewUserRepository(db),
+				newTestTranslator(),
+			)
+
+			payload := map[string]string{"vcard": card}
+			for field, value := range tt.overrides {
+				payload[field] = value
+			}
+			body, _ := json.Marshal(payload)
+
+			r := httptest.NewRequest(http.MethodPost, "/chats/7/contacts", strings.NewReader(string(body)))
+			w := serve(handler.Send, r, 1, map[string]string{"chatId": "7"})
+			if w.Code != http.StatusCreated {
+				t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusCreated, w.Body)
+			}
+
+			contacts := fake.Executed("INSERT INTO contacts")
+			if len(contacts) != 1 {
+				t.Fatalf("%d contacts stored, want 1", len(contacts))
+			}
+			args := contacts[0].Args
+			if args[1] != tt.firstName || args[3] != tt.phone {
+				t.Errorf("contact stored as %v %v, want %s %s", args[1], args[3], tt.firstName, tt.phone)
+			}
+			// An overridden contact no longer matches the submitted card, so it is exported from the contact instead
+			if keepsCard := args[5] != nil; keepsCard != tt.keepsCard {
+				t.Errorf("vCard stored = %t, want %t", keepsCard, tt.keepsCard)
+			}
+		})
+	}
+}
@@ -0,0 +1,287 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/drTragger/messenger-backend/internal/models"
+	"github.com/drTragger/messenger-backend/internal/repository"
+	"github.com/drTragger/messenger-backend/internal/requests"
+	"github.com/drTragger/messenger-backend/internal/responses"
+	"github.com/drTragger/messenger-backend/internal/services"
+	"github.com/drTragger/messenger-backend/internal/utils"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+)
+
+type EncryptionHandler struct {
+	KeyService *services.KeyService
+	MsgService *services.MessageService
+	KeyRepo    *repository.KeyRepository
+	MsgRepo    *repository.MessageRepository
+	ChatRepo   *repository.ChatRepository
+	Trans      *utils.Translator
+}
+
+func NewEncryptionHandler(
+	keyService *services.KeyService,
+	msgService *services.MessageService,
+	keyRepo *repository.KeyRepository,
+	msgRepo *repository.MessageRepository,
+	chatRepo *repository.ChatRepository,
+	trans *utils.Translator,
+) *EncryptionHandler {
+	return &EncryptionHandler{
+		KeyService: keyService,
+		MsgService: msgService,
+		KeyRepo:    keyRepo,
+		MsgRepo:    msgRepo,
+		ChatRepo:   chatRepo,
+		Trans:      trans,
+	}
+}
+
+// PublishDevice publishes the identity key, the signed prekey and the one-time prekeys of one of the
+// caller's devices. Publishing again replaces all of them, e.g. after the app is reinstalled.
+func (h *EncryptionHandler) PublishDevice(w http.ResponseWriter, r *http.Request) {
+	var payload requests.PublishDeviceKeysRequest
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), err.Error())
+		return
+	}
+
+	if err := utils.ValidateStruct(&payload); err != nil {
+		responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), utils.FormatValidationError(r, err, h.Trans))
+		return
+	}
+
+	deviceID, ok := h.getDeviceID(w, r)
+	if !ok {
+		return
+	}
+
+	userID := r.Context().Value("user_id").(uint)
+
+	keys, err := h.KeyService.PublishDevice(&models.DeviceKeys{
+		UserID:         userID,
+		DeviceID:       deviceID,
+		RegistrationID: payload.RegistrationID,
+		IdentityKey:    payload.IdentityKey,
+		SignedPreKey: &models.SignedPreKey{
+			KeyID:     payload.SignedPreKey.KeyID,
+			PublicKey: payload.SignedPreKey.PublicKey,
+			Signature: payload.SignedPreKey.Signature,
+		},
+	}, toPreKeys(payload.PreKeys))
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+
+	responses.SuccessResponse(w, http.StatusOK, h.Trans.Translate(r, "success.keys.publish", nil), keys)
+}
+
+// UploadPreKeys adds one-time prekeys to a device that is running low on them.
+func (h *EncryptionHandler) UploadPreKeys(w http.ResponseWriter, r *http.Request) {
+	var payload requests.UploadPreKeysRequest
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), err.Error())
+		return
+	}
+
+	if err := utils.ValidateStruct(&payload); err != nil {
+		responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), utils.FormatValidationError(r, err, h.Trans))
+		return
+	}
+
+	keys, ok := h.getDevice(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.KeyRepo.AddPreKeys(keys.ID, toPreKeys(payload.PreKeys)); err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+
+	keys, err := h.KeyRepo.GetDevice(keys.UserID, keys.DeviceID)
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+
+	responses.SuccessResponse(w, http.StatusCreated, h.Trans.Translate(r, "success.keys.upload", nil), keys)
+}
+
+// GetDevice returns the published keys of one of the caller's devices with the number of one-time prekeys left.
+func (h *EncryptionHandler) GetDevice(w http.ResponseWriter, r *http.Request) {
+	keys, ok := h.getDevice(w, r)
+	if !ok {
+		return
+	}
+
+	responses.SuccessResponse(w, http.StatusOK, h.Trans.Translate(r, "success.keys.get", nil), keys)
+}
+
+// DeleteDevice removes the keys of a device, e.g. when the user logs out of it, so nobody encrypts for it anymore.
+func (h *EncryptionHandler) DeleteDevice(w http.ResponseWriter, r *http.Request) {
+	deviceID, ok := h.getDeviceID(w, r)
+	if !ok {
+		return
+	}
+
+	userID := r.Context().Value("user_id").(uint)
+
+	deleted, err := h.KeyRepo.DeleteDevice(userID, deviceID)
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+	if !deleted {
+		responses.ErrorResponse(w, http.StatusNotFound, h.Trans.Translate(r, "errors.not_found", nil), "Device not found")
+		return
+	}
+
+	responses.SuccessResponse(w, http.StatusOK, h.Trans.Translate(r, "success.keys.delete", nil), nil)
+}
+
+// GetBundles returns a prekey bundle for each device of a user. Every call uses up one-time prekeys,
+// so clients should only fetch bundles when they start a session.
+func (h *EncryptionHandler) GetBundles(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(mux.Vars(r)["userId"])
+	if err != nil || userID <= 0 {
+		responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), "Invalid user ID")
+		return
+	}
+
+	bundles, err := h.KeyService.ClaimBundles(uint(userID))
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+	if len(bundles) == 0 {
+		responses.ErrorResponse(w, http.StatusNotFound, h.Trans.Translate(r, "errors.keys.not_found", nil), "User has no devices with published keys")
+		return
+	}
+
+	responses.SuccessResponse(w, http.StatusOK, h.Trans.Translate(r, "success.keys.get_bundles", nil), bundles)
+}
+
+// SendEncrypted sends an end-to-end encrypted message. Its content is an envelope with a ciphertext for each
+// device of the participants, so moderation, mentions, link previews and search indexing don't apply to it.
+func (h *EncryptionHandler) SendEncrypted(w http.ResponseWriter, r *http.Request) {
+	var payload requests.SendEncryptedMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), err.Error())
+		return
+	}
+
+	if err := utils.ValidateStruct(&payload); err != nil {
+		responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), utils.FormatValidationError(r, err, h.Trans))
+		return
+	}
+
+	chat, userID, ok := getParticipantChat(w, r, h.ChatRepo, h.Trans)
+	if !ok {
+		return
+	}
+	recipientID := otherParticipant(chat, userID)
+
+	if payload.ParentID != nil {
+		parent, err := h.MsgRepo.GetById(*payload.ParentID)
+		if err != nil {
+			responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+			return
+		}
+		if parent == nil || parent.ChatID != chat.ID || parent.ScheduledAt != nil {
+			responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), map[string]string{
+				"parentId": h.Trans.Translate(r, "validation.exists", nil),
+			})
+			return
+		}
+	}
+
+	envelope := &models.EncryptedEnvelope{
+		SenderDeviceID: payload.SenderDeviceID,
+		Ciphertexts:    make([]*models.DeviceCiphertext, 0, len(payload.Ciphertexts)),
+	}
+	for _, ciphertext := range payload.Ciphertexts {
+		envelope.Ciphertexts = append(envelope.Ciphertexts, &models.DeviceCiphertext{
+			UserID:   ciphertext.UserID,
+			DeviceID: ciphertext.DeviceID,
+			Type:     ciphertext.Type,
+			Body:     ciphertext.Body,
+		})
+	}
+
+	content, err := h.KeyService.EncodeEnvelope(envelope, userID, recipientID)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidEnvelope) {
+			responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), map[string]string{
+				"ciphertexts": h.Trans.Translate(r, "validation.devices", nil),
+			})
+			return
+		}
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+
+	// The message has no entities, so it is announced without mentions, and it is never moderated
+	// or previewed since the server can't read it
+	message := &models.Message{
+		SenderID:    userID,
+		RecipientID: recipientID,
+		ChatID:      chat.ID,
+		ParentID:    payload.ParentID,
+		Content:     &content,
+		Type:        models.EncryptedMessage,
+	}
+	if err := h.MsgService.SendTyped(message, "", utils.GetLocale(r), nil); err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+
+	responses.SuccessResponse(w, http.StatusCreated, h.Trans.Translate(r, "success.message.send", nil), message)
+}
+
+func (h *EncryptionHandler) getDeviceID(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	deviceID, err := strconv.Atoi(mux.Vars(r)["deviceId"])
+	if err != nil || deviceID <= 0 {
+		responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), "Invalid device ID")
+		return 0, false
+	}
+	return uint(deviceID), true
+}
+
+// getDevice returns the keys of the caller's device from the path.
+func (h *EncryptionHandler) getDevice(w http.ResponseWriter, r *http.Request) (*models.DeviceKeys, bool) {
+	deviceID, ok := h.getDeviceID(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	userID := r.Context().Value("user_id").(uint)
+
+	keys, err := h.KeyRepo.GetDevice(userID, deviceID)
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return nil, false
+	}
+	if keys == nil {
+		responses.ErrorResponse(w, http.StatusNotFound, h.Trans.Translate(r, "errors.not_found", nil), "Device not found")
+		return nil, false
+	}
+
+	return keys, true
+}
+
+func toPreKeys(preKeys []*requests.PreKeyRequest) []*models.PreKey {
+	keys := make([]*models.PreKey, 0, len(preKeys))
+	for _, preKey := range preKeys {
+		keys = append(keys, &models.PreKey{
+			KeyID:     preKey.KeyID,
+			PublicKey: preKey.PublicKey,
+		})
+	}
+	return keys
+}
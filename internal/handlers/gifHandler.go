@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/drTragger/messenger-backend/internal/models"
+	"github.com/drTragger/messenger-backend/internal/repository"
+	"github.com/drTragger/messenger-backend/internal/requests"
+	"github.com/drTragger/messenger-backend/internal/responses"
+	"github.com/drTragger/messenger-backend/internal/services"
+	"github.com/drTragger/messenger-backend/internal/utils"
+	"net/http"
+	"strconv"
+)
+
+type GifHandler struct {
+	Provider   services.GifProvider
+	MsgService *services.MessageService
+	GifRepo    *repository.GifRepository
+	ChatRepo   *repository.ChatRepository
+	Trans      *utils.Translator
+}
+
+func NewGifHandler(
+	provider services.GifProvider,
+	msgService *services.MessageService,
+	gifRepo *repository.GifRepository,
+	chatRepo *repository.ChatRepository,
+	trans *utils.Translator,
+) *GifHandler {
+	return &GifHandler{
+		Provider:   provider,
+		MsgService: msgService,
+		GifRepo:    gifRepo,
+		ChatRepo:   chatRepo,
+		Trans:      trans,
+	}
+}
+
+func (h *GifHandler) Search(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var err error
+
+	q := query.Get("q")
+	if q == "" {
+		responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), map[string]string{
+			"q": h.Trans.Translate(r, "validation.required", nil),
+		})
+		return
+	}
+
+	limitStr := query.Get("limit")
+	limit := services.GifSearchLimit
+	if limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), "Invalid limit")
+			return
+		}
+	}
+
+	offsetStr := query.Get("offset")
+	offset := services.GifSearchOffset
+	if offsetStr != "" {
+		offset, err = strconv.Atoi(offsetStr)
+		if err != nil || offset < 0 {
+			responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), "Invalid offset.")
+			return
+		}
+	}
+
+	gifs, err := h.Provider.Search(r.Context(), q, limit, offset)
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusBadGateway, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+
+	responses.SuccessResponse(w, http.StatusOK, h.Trans.Translate(r, "success.gif.search", nil), gifs)
+}
+
+// Send sends a GIF picked from the search results. The GIF is resolved again through the provider,
+// so clients can't send arbitrary URLs as GIFs.
+func (h *GifHandler) Send(w http.ResponseWriter, r *http.Request) {
+	var payload requests.SendGifRequest
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), err.Error())
+		return
+	}
+
+	if err := utils.ValidateStruct(&payload); err != nil {
+		responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), utils.FormatValidationError(r, err, h.Trans))
+		return
+	}
+
+	chat, userID, ok := getParticipantChat(w, r, h.ChatRepo, h.Trans)
+	if !ok {
+		return
+	}
+
+	gif, err := h.Provider.Get(r.Context(), payload.GifID)
+	if err != nil {
+		if errors.Is(err, services.ErrGifNotFound) {
+			responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), map[string]string{
+				"gifId": h.Trans.Translate(r, "validation.exists", nil),
+			})
+			return
+		}
+		responses.ErrorResponse(w, http.StatusBadGateway, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+
+	message := &models.Message{
+		SenderID:    userID,
+		RecipientID: otherParticipant(chat, userID),
+		ChatID:      chat.ID,
+		Type:        models.GifMessage,
+	}
+	err = h.MsgService.SendTyped(message, "", utils.GetLocale(r), func(tx *sql.Tx) error {
+		gif.MessageID = message.ID
+		message.Gif = gif
+		_, err := h.GifRepo.WithTx(tx).Create(gif)
+		return err
+	})
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+
+	responses.SuccessResponse(w, http.StatusCreated, h.Trans.Translate(r, "success.gif.send", nil), message)
+}
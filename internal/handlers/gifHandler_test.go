@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"github.com/drTragger/messenger-backend/internal/fakedb"
+	"github.com/drTragger/messenger-backend/internal/models"
+	"github.com/drTragger/messenger-backend/internal/repository"
+	"github.com/drTragger/messenger-backend/internal/services"
+	"github.com/drTragger/messenger-backend/internal/websocket"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGifHandlerSearch(t *testing.T) {
+	handler := NewGifHandler(newTestGifProvider(), nil, nil, nil, newTestTranslator())
+
+	tests := []struct {
+		name   string
+		query  string
+		status int
+		ids    []string
+	}{
+		{name: "title match", query: "q=CAT", status: http.StatusOK, ids: []string{"cat-1", "cat-2"}},
+		{name: "limit and offset", query: "q=cat&limit=1&offset=1", status: http.StatusOK, ids: []string{"cat-2"}},
+		{name: "offset past the results", query: "q=cat&offset=5", status: http.StatusOK, ids: []string{}},
+		{name: "no match", query: "q=bird", status: http.StatusOK, ids: []string{}},
+		{name: "missing query", query: "", status: http.StatusUnprocessableEntity},
+		{name: "invalid limit", query: "q=cat&limit=0", status: http.StatusBadRequest},
+		{name: "invalid offset", query: "q=cat&offset=-1", status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/gifs/search?"+tt.query, nil)
+			w := serve(handler.Search, r, 1, nil)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if tt.ids == nil {
+				return
+			}
+
+			var response struct {
+				Data []*models.Gif `json:"data"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+			ids := make([]string, 0, len(response.Data))
+			for _, gif := range response.Data {
+				ids = append(ids, gif.ExternalID)
+			}
+			if fmt.Sprint(ids) != fmt.Sprint(tt.ids) {
+				t.Errorf("GIFs = %v, want %v", ids, tt.ids)
+			}
+		})
+	}
+}
+
+func TestGifHandlerSend(t *testing.T) {
+	db, fake := fakedb.Open(t, func(query string, args []driver.Value) fakedb.Result {
+		switch {
+		case strings.Contains(query, "FROM chats AS c"):
+			return chatRow(7, 1, 2)
+		case strings.Contains(query, "INSERT INTO messages"):
+			return fakedb.Row(int64(40), false, nil, nil, time.Now(), time.Now())
+		case strings.Contains(query, "INSERT INTO gifs"):
+			return fakedb.Row(int64(3))
+		}
+		return fakedb.Result{Affected: 1}
+	})
+	handler := NewGifHandler(newTestGifProvider(), newTestMessageService(db), repository.NewGifRepository(db), repository.NewChatRepository(db), newTestTranslator())
+
+	r := httptest.NewRequest(http.MethodPost, "/chats/7/gifs", strings.NewReader(`{"gifId": "cat-2"}`))
+	w := serve(handler.Send, r, 1, map[string]string{"chatId": "7"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusCreated, w.Body)
+	}
+
+	var response struct {
+		Data *models.Message `json:"data"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	message := response.Data
+	if message.ID != 40 || message.Type != models.GifMessage || message.RecipientID != 2 {
+		t.Errorf("message = %+v, want GIF message 40 to user 2", message)
+	}
+	if message.Gif == nil || message.Gif.ExternalID != "cat-2" || message.Gif.URL != "https://gifs.example/cat-2.mp4" {
+		t.Errorf("Gif = %+v, want cat-2 resolved through the provider", message.Gif)
+	}
+
+	gifs := fake.Executed("INSERT INTO gifs")
+	if len(gifs) != 1 {
+		t.Fatalf("%d GIFs stored, want 1", len(gifs))
+	}
+	if got := gifs[0].Args[:3]; fmt.Sprint(got) != fmt.Sprint([]driver.Value{int64(40), "local", "cat-2"}) {
+		t.Errorf("GIF stored with %v, want message 40 and the local cat-2", got)
+	}
+
+	events := fake.Executed("INSERT INTO outbox_events")
+	if len(events) != 1 || events[0].Args[0] != int64(2) || events[0].Args[1] != string(websocket.NewMessageEvent) {
+		t.Errorf("outbox events = %v, want a NewMessageEvent for user 2", events)
+	}
+	if fake.Commits != 1 {
+		t.Errorf("%d commits, want the message, the GIF and the event in one transaction", fake.Commits)
+	}
+}
+
+func TestGifHandlerSendRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		userID uint
+		body   string
+		status int
+	}{
+		{name: "unknown GIF", userID: 1, body: `{"gifId": "dog-9"}`, status: http.StatusUnprocessableEntity},
+		{name: "missing GIF ID", userID: 1, body: `{}`, status: http.StatusUnprocessableEntity},
+		{name: "chat of other users", userID: 3, body: `{"gifId": "cat-1"}`, status: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := fakedb.Open(t, func(query string, args []driver.Value) fakedb.Result {
+				if strings.Contains(query, "FROM chats AS c") {
+					return chatRow(7, 1, 2)
+				}
+				return fakedb.Result{Err: fmt.Errorf("unexpected query: %s", query)}
+			})
+			handler := NewGifHandler(newTestGifProvider(), newTestMessageService(db), repository.NewGifRepository(db), repository.NewChatRepository(db), newTestTranslator())
+
+			r := httptest.NewRequest(http.MethodPost, "/chats/7/gifs", strings.NewReader(tt.body))
+			w := serve(handler.Send, r, tt.userID, map[string]string{"chatId": "7"})
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if messages := fake.Executed("INSERT INTO messages"); len(messages) != 0 {
+				t.Errorf("%d messages stored, want none", len(messages))
+			}
+		})
+	}
+}
+
+func newTestGifProvider() *services.LocalGifProvider {
+	gif := func(id, title string) *models.Gif {
+		return &models.Gif{
+			ExternalID: id,
+			URL:        "https://gifs.example/" + id + ".mp4",
+			Width:      320,
+			Height:     240,
+			Title:      &title,
+		}
+	}
+	return services.NewLocalGifProvider(gif("cat-1", "Happy cat"), gif("dog-1", "Dancing dog"), gif("cat-2", "Cat nap"))
+}
+
+func newTestMessageService(db *sql.DB) *services.MessageService {
+	return services.NewMessageService(
+		db,
+		repository.NewMessageRepository(db),
+		repository.NewChatRepository(db),
+		repository.NewMessageEntityRepository(db),
+		repository.NewAttachmentRepository(db),
+		repository.NewOutboxRepository(db),
+		repository.NewModerationRepository(db, nil),
+		services.NewModerationService(),
+		nil,
+	)
+}
+
+// chatRow answers ChatRepository.GetByID with a chat between two users without messages.
+func chatRow(id, user1ID, user2ID int64) fakedb.Result {
+	now := time.Now()
+	return fakedb.Row(
+		id, user1ID, user2ID, nil, now, now,
+		user1ID, fmt.Sprintf("user%d", user1ID), nil, nil, "+380000000001", nil, nil,
+		user2ID, fmt.Sprintf("user%d", user2ID), nil, nil, "+380000000002", nil, nil,
+		nil, nil, nil, nil, nil, nil, nil,
+	)
+}
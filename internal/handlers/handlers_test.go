@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"context"
+	"github.com/drTragger/messenger-backend/internal/utils"
+	"github.com/gorilla/mux"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+)
+
+var (
+	testTranslator     *utils.Translator
+	testTranslatorOnce sync.Once
+)
+
+func newTestTranslator() *utils.Translator {
+	testTranslatorOnce.Do(func() {
+		testTranslator = utils.NewTranslator("../..")
+	})
+	return testTranslator
+}
+
+// serve runs a handler for a request of the given user, with the route variables set as the router would.
+func serve(handler http.HandlerFunc, r *http.Request, userID uint, vars map[string]string) *httptest.ResponseRecorder {
+	r = r.WithContext(context.WithValue(r.Context(), "user_id", userID))
+	r = mux.SetURLVars(r, vars)
+
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
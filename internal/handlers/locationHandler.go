@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/drTragger/messenger-backend/internal/models"
+	"github.com/drTragger/messenger-backend/internal/repository"
+	"github.com/drTragger/messenger-backend/internal/requests"
+	"github.com/drTragger/messenger-backend/internal/responses"
+	"github.com/drTragger/messenger-backend/internal/services"
+	"github.com/drTragger/messenger-backend/internal/utils"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+)
+
+type LocationHandler struct {
+	MsgService      *services.MessageService
+	LocationService *services.LocationService
+	LocationRepo    *repository.LocationRepository
+	MsgRepo         *repository.MessageRepository
+	ChatRepo        *repository.ChatRepository
+	Trans           *utils.Translator
+}
+
+func NewLocationHandler(
+	msgService *services.MessageService,
+	locationService *services.LocationService,
+	locationRepo *repository.LocationRepository,
+	msgRepo *repository.MessageRepository,
+	chatRepo *repository.ChatRepository,
+	trans *utils.Translator,
+) *LocationHandler {
+	return &LocationHandler{
+		MsgService:      msgService,
+		LocationService: locationService,
+		LocationRepo:    locationRepo,
+		MsgRepo:         msgRepo,
+		ChatRepo:        chatRepo,
+		Trans:           trans,
+	}
+}
+
+func (h *LocationHandler) Send(w http.ResponseWriter, r *http.Request) {
+	var payload requests.SendLocationRequest
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), err.Error())
+		return
+	}
+
+	if err := utils.ValidateStruct(&payload); err != nil {
+		responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), utils.FormatValidationError(r, err, h.Trans))
+		return
+	}
+
+	chat, userID, ok := getParticipantChat(w, r, h.ChatRepo, h.Trans)
+	if !ok {
+		return
+	}
+
+	message := &models.Message{
+		SenderID:    userID,
+		RecipientID: otherParticipant(chat, userID),
+		ChatID:      chat.ID,
+		Type:        models.LocationMessage,
+	}
+	location := &models.Location{
+		Latitude:   *payload.Latitude,
+		Longitude:  *payload.Longitude,
+		Accuracy:   payload.Accuracy,
+		Heading:    payload.Heading,
+		LivePeriod: payload.LivePeriod,
+	}
+	err := h.MsgService.SendTyped(message, "", utils.GetLocale(r), func(tx *sql.Tx) error {
+		location.MessageID = message.ID
+		message.Location = location
+		_, err := h.LocationRepo.WithTx(tx).Create(location)
+		return err
+	})
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+
+	responses.SuccessResponse(w, http.StatusCreated, h.Trans.Translate(r, "success.location.send", nil), message)
+}
+
+// Update is called periodically by the sender's client while a live location is being shared.
+func (h *LocationHandler) Update(w http.ResponseWriter, r *http.Request) {
+	var payload requests.UpdateLocationRequest
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), err.Error())
+		return
+	}
+
+	if err := utils.ValidateStruct(&payload); err != nil {
+		responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), utils.FormatValidationError(r, err, h.Trans))
+		return
+	}
+
+	message, ok := h.getOwnLocationMessage(w, r)
+	if !ok {
+		return
+	}
+
+	location, err := h.LocationService.Update(message, &models.Location{
+		Latitude:  *payload.Latitude,
+		Longitude: *payload.Longitude,
+		Accuracy:  payload.Accuracy,
+		Heading:   payload.Heading,
+	})
+	if err != nil {
+		h.liveLocationError(w, r, err)
+		return
+	}
+
+	responses.SuccessResponse(w, http.StatusOK, h.Trans.Translate(r, "success.location.update", nil), location)
+}
+
+func (h *LocationHandler) Stop(w http.ResponseWriter, r *http.Request) {
+	message, ok := h.getOwnLocationMessage(w, r)
+	if !ok {
+		return
+	}
+
+	location, err := h.LocationService.Stop(message)
+	if err != nil {
+		h.liveLocationError(w, r, err)
+		return
+	}
+
+	responses.SuccessResponse(w, http.StatusOK, h.Trans.Translate(r, "success.location.stop", nil), location)
+}
+
+// getOwnLocationMessage loads the location message addressed by the request and checks that the user sent it.
+func (h *LocationHandler) getOwnLocationMessage(w http.ResponseWriter, r *http.Request) (*models.Message, bool) {
+	chat, userID, ok := getParticipantChat(w, r, h.ChatRepo, h.Trans)
+	if !ok {
+		return nil, false
+	}
+
+	messageID, err := strconv.Atoi(mux.Vars(r)["messageId"])
+	if err != nil || messageID < 0 {
+		responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), "Invalid message ID")
+		return nil, false
+	}
+
+	message, err := h.MsgRepo.GetById(uint(messageID))
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return nil, false
+	}
+	if message == nil || message.ChatID != chat.ID || message.Location == nil {
+		responses.ErrorResponse(w, http.StatusNotFound, h.Trans.Translate(r, "errors.not_found", nil), "Location not found")
+		return nil, false
+	}
+	if message.SenderID != userID {
+		responses.ErrorResponse(w, http.StatusForbidden, h.Trans.Translate(r, "errors.forbidden", nil), "Only the sender can change the location")
+		return nil, false
+	}
+
+	return message, true
+}
+
+func (h *LocationHandler) liveLocationError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		responses.ErrorResponse(w, http.StatusConflict, h.Trans.Translate(r, "errors.location.not_live", nil), "Location is not live")
+		return
+	}
+	responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+}
@@ -12,11 +12,11 @@ import (
 	"github.com/drTragger/messenger-backend/internal/services"
 	"github.com/drTragger/messenger-backend/internal/storage"
 	"github.com/drTragger/messenger-backend/internal/utils"
-	"github.com/drTragger/messenger-backend/internal/websocket"
 	"github.com/gorilla/mux"
-	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 )
 
 const (
@@ -24,35 +24,38 @@ const (
 )
 
 type MessageHandler struct {
-	MsgService     *services.MessageService
-	WsService      *services.WsService
-	MsgRepo        *repository.MessageRepository
-	UserRepo       *repository.UserRepository
-	ChatRepo       *repository.ChatRepository
-	AttachmentRepo *repository.AttachmentRepository
-	Storage        storage.Storage
-	Trans          *utils.Translator
+	MsgService      *services.MessageService
+	MentionService  *services.MentionService
+	PreviewService  *services.LinkPreviewService
+	DeliveryService *services.DeliveryService
+	MsgRepo         *repository.MessageRepository
+	UserRepo        *repository.UserRepository
+	ChatRepo        *repository.ChatRepository
+	Storage         storage.Storage
+	Trans           *utils.Translator
 }
 
 func NewMessageHandler(
 	msgService *services.MessageService,
-	wsService *services.WsService,
+	mentionService *services.MentionService,
+	previewService *services.LinkPreviewService,
+	deliveryService *services.DeliveryService,
 	msgRepo *repository.MessageRepository,
 	userRepo *repository.UserRepository,
 	chatRepo *repository.ChatRepository,
-	attachmentRepo *repository.AttachmentRepository,
 	storage storage.Storage,
 	trans *utils.Translator,
 ) *MessageHandler {
 	return &MessageHandler{
-		MsgService:     msgService,
-		WsService:      wsService,
-		MsgRepo:        msgRepo,
-		UserRepo:       userRepo,
-		ChatRepo:       chatRepo,
-		AttachmentRepo: attachmentRepo,
-		Storage:        storage,
-		Trans:          trans,
+		MsgService:      msgService,
+		MentionService:  mentionService,
+		PreviewService:  previewService,
+		DeliveryService: deliveryService,
+		MsgRepo:         msgRepo,
+		UserRepo:        userRepo,
+		ChatRepo:        chatRepo,
+		Storage:         storage,
+		Trans:           trans,
 	}
 }
 
@@ -90,11 +93,72 @@ func (h *MessageHandler) SendMessage(w http.ResponseWriter, r *http.Request) {
 		payload.ParentID = &parentIDUint
 	}
 
+	if scheduledAtStr := r.FormValue("scheduledAt"); scheduledAtStr != "" {
+		scheduledAt, err := time.Parse(time.RFC3339, scheduledAtStr)
+		if err != nil {
+			responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), "Invalid scheduled time")
+			return
+		}
+		payload.ScheduledAt = &scheduledAt
+	}
+
+	if quoteText := r.FormValue("quoteText"); quoteText != "" {
+		payload.QuoteText = &quoteText
+		if quoteOffsetStr := r.FormValue("quoteOffset"); quoteOffsetStr != "" {
+			quoteOffset, err := strconv.Atoi(quoteOffsetStr)
+			if err != nil {
+				responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), "Invalid quote offset")
+				return
+			}
+			payload.QuoteOffset = &quoteOffset
+		}
+	}
+
+	if entitiesStr := r.FormValue("entities"); entitiesStr != "" {
+		if err := json.Unmarshal([]byte(entitiesStr), &payload.Entities); err != nil {
+			responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), "Invalid entities")
+			return
+		}
+	}
+
+	if parseMode := r.FormValue("parseMode"); parseMode != "" {
+		payload.ParseMode = &parseMode
+	}
+
+	if silentStr := r.FormValue("silent"); silentStr != "" {
+		payload.Silent, err = strconv.ParseBool(silentStr)
+		if err != nil {
+			responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), "Invalid silent flag")
+			return
+		}
+	}
+
+	if noForwardStr := r.FormValue("noForward"); noForwardStr != "" {
+		payload.NoForward, err = strconv.ParseBool(noForwardStr)
+		if err != nil {
+			responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), "Invalid noForward flag")
+			return
+		}
+	}
+
 	if err := utils.ValidateStruct(&payload); err != nil {
 		responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), utils.FormatValidationError(r, err, h.Trans))
 		return
 	}
 
+	content, formatting, ok := h.formatContent(w, r, content, payload.Entities, payload.ParseMode)
+	if !ok {
+		return
+	}
+	payload.Content = &content
+
+	if payload.ScheduledAt != nil && !payload.ScheduledAt.After(time.Now()) {
+		responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), map[string]string{
+			"scheduledAt": h.Trans.Translate(r, "validation.future", nil),
+		})
+		return
+	}
+
 	senderID := r.Context().Value("user_id").(uint)
 
 	sender, err := h.UserRepo.GetUserByID(senderID)
@@ -113,15 +177,39 @@ func (h *MessageHandler) SendMessage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// A chat that doesn't exist yet is created together with the message
 	chat, err := h.ChatRepo.GetByID(uint(chatID))
 	if err != nil || chat == nil {
-		chat, err = h.ChatRepo.Create(payload.RecipientID, senderID, nil)
-		if err != nil {
-			responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
-			return
+		chat = &models.Chat{User1ID: payload.RecipientID, User2ID: senderID}
+		// A user has a single Saved Messages chat, which may exist under another ID
+		if payload.RecipientID == senderID {
+			saved, err := h.ChatRepo.GetSaved(senderID)
+			if err != nil {
+				responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+				return
+			}
+			if saved != nil {
+				chat = saved
+			}
 		}
 	}
 
+	// Users can only write to themselves in their own Saved Messages
+	if payload.RecipientID == senderID && (!chat.IsSaved() || chat.User1ID != senderID) {
+		responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), map[string]string{
+			"recipientId": h.Trans.Translate(r, "validation.recipient", nil),
+		})
+		return
+	}
+
+	if payload.QuoteText != nil && payload.ParentID == nil {
+		responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), map[string]string{
+			"parentId": h.Trans.Translate(r, "validation.required", nil),
+		})
+		return
+	}
+
+	var quote *models.Quote
 	if payload.ParentID != nil {
 		parent, err := h.MsgRepo.GetById(*payload.ParentID)
 		if err != nil || parent == nil {
@@ -130,33 +218,57 @@ func (h *MessageHandler) SendMessage(w http.ResponseWriter, r *http.Request) {
 			})
 			return
 		}
-	}
 
-	message := &models.Message{
-		SenderID:    senderID,
-		RecipientID: payload.RecipientID,
-		Content:     payload.Content,
-		ChatID:      chat.ID,
-		ParentID:    payload.ParentID,
+		if payload.QuoteText != nil {
+			var ok bool
+			quote, ok = resolveQuote(parent, *payload.QuoteText, payload.QuoteOffset)
+			if !ok {
+				responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), map[string]string{
+					"quoteText": h.Trans.Translate(r, "validation.quote", nil),
+				})
+				return
+			}
+		}
 	}
-	message, err = h.MsgRepo.Create(message)
+
+	entities, err := h.resolveEntities(payload.Content, formatting)
 	if err != nil {
 		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
 		return
 	}
 
-	if err := h.MsgService.ProcessAttachments(r, message); err != nil {
+	message, err := h.MsgService.Send(chat, &models.Message{
+		SenderID:    senderID,
+		RecipientID: payload.RecipientID,
+		Content:     payload.Content,
+		ParentID:    payload.ParentID,
+		ScheduledAt: payload.ScheduledAt,
+		Quote:       quote,
+		Silent:      payload.Silent,
+		NoForward:   payload.NoForward,
+	}, r.MultipartForm.File["attachments"], entities, utils.GetLocale(r))
+	if err != nil {
+		if respondModerationError(w, r, h.Trans, err) {
+			return
+		}
+		if errors.Is(err, services.ErrInvalidAudio) {
+			responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), map[string]string{
+				"attachments": h.Trans.Translate(r, "validation.voice", nil),
+			})
+			return
+		}
 		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
 		return
 	}
 
-	err = h.ChatRepo.UpdateLastMessage(chat.ID, message.ID)
-	if err != nil {
-		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+	// Scheduled messages are delivered later by the ScheduledMessageDispatcher
+	if message.ScheduledAt != nil {
+		responses.SuccessResponse(w, http.StatusCreated, h.Trans.Translate(r, "success.message.schedule", nil), message)
 		return
 	}
 
-	go h.WsService.SendMessage(websocket.NewMessageEvent, payload.RecipientID, message)
+	// The events about the message are published by the outbox relay
+	go h.PreviewService.Process(message)
 
 	responses.SuccessResponse(w, http.StatusCreated, h.Trans.Translate(r, "success.message.send", nil), message)
 }
@@ -173,6 +285,11 @@ func (h *MessageHandler) EditMessage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	content, formatting, ok := h.formatContent(w, r, payload.Content, payload.Entities, payload.ParseMode)
+	if !ok {
+		return
+	}
+
 	messageIDStr := mux.Vars(r)["messageId"]
 	messageID, err := strconv.Atoi(messageIDStr)
 	if err != nil {
@@ -180,8 +297,36 @@ func (h *MessageHandler) EditMessage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	message, err := h.MsgRepo.Edit(uint(messageID), payload.Content)
+	previous, err := h.MsgRepo.GetById(uint(messageID))
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+	// Scheduled messages are edited through their own route, so that nothing is announced before they are due
+	if previous == nil || previous.ScheduledAt != nil {
+		responses.ErrorResponse(w, http.StatusNotFound, h.Trans.Translate(r, "errors.not_found", nil), "Message not found")
+		return
+	}
+	if previous.SenderID != r.Context().Value("user_id").(uint) {
+		responses.ErrorResponse(w, http.StatusForbidden, h.Trans.Translate(r, "errors.forbidden", nil), "Forbidden")
+		return
+	}
+	if previous.Type != models.TextMessage {
+		responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), "Only text messages can be edited")
+		return
+	}
+
+	entities, err := h.resolveEntities(&content, formatting)
 	if err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+
+	message, err := h.MsgService.Edit(previous, content, entities, utils.GetLocale(r))
+	if err != nil {
+		if respondModerationError(w, r, h.Trans, err) {
+			return
+		}
 		if errors.Is(err, sql.ErrNoRows) {
 			responses.ErrorResponse(w, http.StatusNotFound, h.Trans.Translate(r, "errors.not_found", nil), err.Error())
 			return
@@ -190,19 +335,96 @@ func (h *MessageHandler) EditMessage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	chat, err := h.ChatRepo.GetByID(message.ChatID)
+	go h.PreviewService.Process(message)
+
+	responses.SuccessResponse(w, http.StatusOK, h.Trans.Translate(r, "success.message.edit", nil), message)
+}
+
+func (h *MessageHandler) DeleteMessage(w http.ResponseWriter, r *http.Request) {
+	chat, userID, ok := getParticipantChat(w, r, h.ChatRepo, h.Trans)
+	if !ok {
+		return
+	}
+
+	messageIDStr := mux.Vars(r)["messageId"]
+	messageID, err := strconv.Atoi(messageIDStr)
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), err.Error())
+		return
+	}
+
+	message, err := h.MsgRepo.GetById(uint(messageID))
 	if err != nil {
 		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
 		return
 	}
-	message.Chat = chat
+	if message == nil || message.ChatID != chat.ID {
+		responses.ErrorResponse(w, http.StatusNotFound, h.Trans.Translate(r, "errors.not_found", nil), "Message not found")
+		return
+	}
+	// Only the sender can delete a message, so reported messages stay up until a moderator acts on them
+	if message.SenderID != userID {
+		responses.ErrorResponse(w, http.StatusForbidden, h.Trans.Translate(r, "errors.forbidden", nil), "Forbidden")
+		return
+	}
 
-	go h.WsService.SendMessage(websocket.EditMessageEvent, message.RecipientID, message)
+	if err := h.MsgService.Delete(message); err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
 
-	responses.SuccessResponse(w, http.StatusOK, h.Trans.Translate(r, "success.message.edit", nil), message)
+	responses.SuccessResponse(w, http.StatusOK, h.Trans.Translate(r, "success.message.delete", nil), nil)
 }
 
-func (h *MessageHandler) DeleteMessage(w http.ResponseWriter, r *http.Request) {
+func (h *MessageHandler) GetMessages(w http.ResponseWriter, r *http.Request) {
+	chat, userID, ok := getParticipantChat(w, r, h.ChatRepo, h.Trans)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	var err error
+	limitStr := query.Get("limit")
+	limit := repository.MessagesLimit
+	if limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), "Invalid limit")
+			return
+		}
+	}
+
+	offsetStr := query.Get("offset")
+	offset := repository.MessagesOffset
+	if offsetStr != "" {
+		offset, err = strconv.Atoi(offsetStr)
+		if err != nil || offset < 0 {
+			responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), "Invalid offset.")
+			return
+		}
+	}
+
+	messages, err := h.MsgRepo.GetChatMessages(chat.ID, userID, limit, offset)
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+
+	if len(messages) == 0 {
+		responses.ErrorResponse(w, http.StatusNotFound, h.Trans.Translate(r, "errors.not_found", nil), "Messages not found.")
+		return
+	}
+
+	// Fetching the messages delivers them to the recipient's device
+	if err := h.DeliveryService.MarkDelivered(userID, messages); err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+
+	responses.SuccessResponse(w, http.StatusOK, h.Trans.Translate(r, "success.message.get_list", nil), messages)
+}
+
+func (h *MessageHandler) MarkMessageRead(w http.ResponseWriter, r *http.Request) {
 	messageIDStr := mux.Vars(r)["messageId"]
 	messageID, err := strconv.Atoi(messageIDStr)
 	if err != nil {
@@ -210,58 +432,171 @@ func (h *MessageHandler) DeleteMessage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	currentUserID := r.Context().Value("user_id").(uint)
+
 	message, err := h.MsgRepo.GetById(uint(messageID))
 	if err != nil {
 		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
 		return
 	}
+
 	if message == nil {
 		responses.ErrorResponse(w, http.StatusNotFound, h.Trans.Translate(r, "errors.not_found", nil), "Message not found")
 		return
 	}
 
-	err = h.MsgService.DeleteAttachments(message.Attachments)
-	if err != nil {
-		log.Printf("Error deleting attachments: %s", err.Error())
+	if message.SenderID == currentUserID {
+		responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.message.sender_read", nil), "Sender is not allowed")
+		return
 	}
 
-	if err := h.MsgRepo.Delete(uint(messageID)); err != nil {
+	if err := h.MsgService.MarkRead(message); err != nil {
 		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
 		return
 	}
 
-	lastMessage, err := h.MsgRepo.GetLastMessageForChat(message.ChatID)
+	responses.SuccessResponse(w, http.StatusOK, h.Trans.Translate(r, "success.message.read", nil), nil)
+}
+
+// MarkMessageListened marks the voice message of a message as played by its recipient.
+func (h *MessageHandler) MarkMessageListened(w http.ResponseWriter, r *http.Request) {
+	chat, currentUserID, ok := getParticipantChat(w, r, h.ChatRepo, h.Trans)
+	if !ok {
+		return
+	}
+
+	messageIDStr := mux.Vars(r)["messageId"]
+	messageID, err := strconv.Atoi(messageIDStr)
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), err.Error())
+		return
+	}
+
+	message, err := h.MsgRepo.GetById(uint(messageID))
 	if err != nil {
 		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
 		return
 	}
 
-	lastMessageID := uint(0)
-	if lastMessage != nil {
-		lastMessageID = lastMessage.ID
+	if message == nil || message.ChatID != chat.ID {
+		responses.ErrorResponse(w, http.StatusNotFound, h.Trans.Translate(r, "errors.not_found", nil), "Message not found")
+		return
+	}
+
+	if message.SenderID == currentUserID {
+		responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.message.sender_listen", nil), "Sender is not allowed")
+		return
 	}
-	if err := h.ChatRepo.UpdateLastMessage(message.ChatID, lastMessageID); err != nil {
+	if message.RecipientID != currentUserID {
+		responses.ErrorResponse(w, http.StatusForbidden, h.Trans.Translate(r, "errors.forbidden", nil), "Forbidden")
+		return
+	}
+
+	listened, err := h.MsgService.MarkListened(message)
+	if err != nil {
 		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
 		return
 	}
+	if !listened {
+		responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.message.not_voice", nil), "Message has no voice attachments")
+		return
+	}
 
-	go h.WsService.SendMessage(websocket.DeleteMessageEvent, message.RecipientID, map[string]*models.Message{"deleted": message, "last": lastMessage})
+	responses.SuccessResponse(w, http.StatusOK, h.Trans.Translate(r, "success.message.listen", nil), nil)
+}
 
-	responses.SuccessResponse(w, http.StatusOK, h.Trans.Translate(r, "success.message.delete", nil), nil)
+func (h *MessageHandler) ForwardMessages(w http.ResponseWriter, r *http.Request) {
+	var payload requests.ForwardMessagesRequest
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), err.Error())
+		return
+	}
+
+	if err := utils.ValidateStruct(&payload); err != nil {
+		responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), utils.FormatValidationError(r, err, h.Trans))
+		return
+	}
+
+	chat, userID, ok := getParticipantChat(w, r, h.ChatRepo, h.Trans)
+	if !ok {
+		return
+	}
+
+	recipientID := otherParticipant(chat, userID)
+
+	originals := make([]*models.Message, 0, len(payload.MessageIDs))
+	for _, messageID := range payload.MessageIDs {
+		original, err := h.MsgRepo.GetById(messageID)
+		if err != nil {
+			responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+			return
+		}
+		if original == nil || original.ScheduledAt != nil {
+			responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), map[string]string{
+				"messageIds": h.Trans.Translate(r, "validation.exists", nil),
+			})
+			return
+		}
+		if original.SenderID != userID && original.RecipientID != userID {
+			responses.ErrorResponse(w, http.StatusForbidden, h.Trans.Translate(r, "errors.forbidden", nil), "Forbidden")
+			return
+		}
+		// Polls and locations keep state of their own, so only text messages are copied into another chat,
+		// and only if their sender allows it
+		if original.Type != models.TextMessage || original.NoForward {
+			responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), map[string]string{
+				"messageIds": h.Trans.Translate(r, "validation.forwardable", nil),
+			})
+			return
+		}
+		originals = append(originals, original)
+	}
+
+	senders := make(map[uint]*models.User)
+	forwarded := make([]*models.Message, 0, len(originals))
+	for _, original := range originals {
+		origin, err := h.forwardOrigin(original, senders)
+		if err != nil {
+			responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+			return
+		}
+
+		forwarded = append(forwarded, &models.Message{
+			SenderID:      userID,
+			RecipientID:   recipientID,
+			Content:       original.Content,
+			ForwardedFrom: origin,
+		})
+	}
+
+	if err := h.MsgService.Forward(chat.ID, forwarded, originals, utils.GetLocale(r)); err != nil {
+		if respondModerationError(w, r, h.Trans, err) {
+			return
+		}
+		if errors.Is(err, services.ErrForwardedMessageDeleted) {
+			responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), map[string]string{
+				"messageIds": h.Trans.Translate(r, "validation.exists", nil),
+			})
+			return
+		}
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+
+	responses.SuccessResponse(w, http.StatusCreated, h.Trans.Translate(r, "success.message.forward", nil), forwarded)
 }
 
-func (h *MessageHandler) GetMessages(w http.ResponseWriter, r *http.Request) {
+func (h *MessageHandler) GetReplies(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query()
-	chatIDStr := mux.Vars(r)["chatId"]
-	if chatIDStr == "" {
-		responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), map[string]string{
-			"chatId": h.Trans.Translate(r, "validation.required", nil),
-		})
+
+	chat, userID, ok := getParticipantChat(w, r, h.ChatRepo, h.Trans)
+	if !ok {
 		return
 	}
-	chatID, err := strconv.Atoi(chatIDStr)
-	if err != nil {
-		responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), "Invalid sender_id")
+
+	messageID, err := strconv.Atoi(mux.Vars(r)["messageId"])
+	if err != nil || messageID < 0 {
+		responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), "Invalid message ID")
 		return
 	}
 
@@ -285,56 +620,209 @@ func (h *MessageHandler) GetMessages(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	messages, err := h.MsgRepo.GetChatMessages(uint(chatID), limit, offset)
+	parent, err := h.MsgRepo.GetById(uint(messageID))
 	if err != nil {
 		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
 		return
 	}
+	if parent == nil || parent.ChatID != chat.ID || parent.ScheduledAt != nil {
+		responses.ErrorResponse(w, http.StatusNotFound, h.Trans.Translate(r, "errors.not_found", nil), "Message not found")
+		return
+	}
 
-	if len(messages) == 0 {
-		responses.ErrorResponse(w, http.StatusNotFound, h.Trans.Translate(r, "errors.not_found", nil), "Messages not found.")
+	replies, err := h.MsgRepo.GetReplies(parent.ID, userID, limit, offset)
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
 		return
 	}
 
-	responses.SuccessResponse(w, http.StatusOK, h.Trans.Translate(r, "success.message.get_list", nil), messages)
+	responses.SuccessResponse(w, http.StatusOK, h.Trans.Translate(r, "success.message.get_replies", nil), replies)
 }
 
-func (h *MessageHandler) MarkMessageRead(w http.ResponseWriter, r *http.Request) {
-	messageIDStr := mux.Vars(r)["messageId"]
-	messageID, err := strconv.Atoi(messageIDStr)
+func (h *MessageHandler) SearchChatMessages(w http.ResponseWriter, r *http.Request) {
+	chat, _, ok := getParticipantChat(w, r, h.ChatRepo, h.Trans)
+	if !ok {
+		return
+	}
+
+	filter, ok := h.parseSearchFilter(w, r)
+	if !ok {
+		return
+	}
+	filter.ChatID = &chat.ID
+
+	page, err := h.MsgRepo.Search(filter)
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+
+	responses.SuccessResponse(w, http.StatusOK, h.Trans.Translate(r, "success.message.search", nil), page)
+}
+
+func (h *MessageHandler) SearchMessages(w http.ResponseWriter, r *http.Request) {
+	filter, ok := h.parseSearchFilter(w, r)
+	if !ok {
+		return
+	}
+
+	page, err := h.MsgRepo.Search(filter)
 	if err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+
+	responses.SuccessResponse(w, http.StatusOK, h.Trans.Translate(r, "success.message.search", nil), page)
+}
+
+func (h *MessageHandler) GetMentions(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	userID := r.Context().Value("user_id").(uint)
+	var err error
+
+	limitStr := query.Get("limit")
+	limit := repository.MessagesLimit
+	if limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), "Invalid limit")
+			return
+		}
+	}
+
+	offsetStr := query.Get("offset")
+	offset := repository.MessagesOffset
+	if offsetStr != "" {
+		offset, err = strconv.Atoi(offsetStr)
+		if err != nil || offset < 0 {
+			responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), "Invalid offset.")
+			return
+		}
+	}
+
+	messages, err := h.MsgRepo.GetMentions(userID, limit, offset)
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+
+	responses.SuccessResponse(w, http.StatusOK, h.Trans.Translate(r, "success.message.get_mentions", nil), messages)
+}
+
+func (h *MessageHandler) GetScheduledMessages(w http.ResponseWriter, r *http.Request) {
+	chat, userID, ok := getParticipantChat(w, r, h.ChatRepo, h.Trans)
+	if !ok {
+		return
+	}
+
+	messages, err := h.MsgRepo.GetScheduled(chat.ID, userID)
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+
+	responses.SuccessResponse(w, http.StatusOK, h.Trans.Translate(r, "success.message.get_scheduled", nil), messages)
+}
+
+func (h *MessageHandler) EditScheduledMessage(w http.ResponseWriter, r *http.Request) {
+	var payload requests.EditMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
 		responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), err.Error())
 		return
 	}
 
-	currentUserID := r.Context().Value("user_id").(uint)
+	if err := utils.ValidateStruct(&payload); err != nil {
+		responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), utils.FormatValidationError(r, err, h.Trans))
+		return
+	}
 
-	message, err := h.MsgRepo.GetById(uint(messageID))
+	content, formatting, ok := h.formatContent(w, r, payload.Content, payload.Entities, payload.ParseMode)
+	if !ok {
+		return
+	}
+
+	scheduled, ok := h.getScheduledMessage(w, r)
+	if !ok {
+		return
+	}
+
+	entities, err := h.resolveEntities(&content, formatting)
 	if err != nil {
 		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
 		return
 	}
 
-	if message == nil {
-		responses.ErrorResponse(w, http.StatusNotFound, h.Trans.Translate(r, "errors.not_found", nil), "Message not found")
+	message, err := h.MsgService.EditScheduled(scheduled, content, entities, utils.GetLocale(r))
+	if err != nil {
+		if respondModerationError(w, r, h.Trans, err) {
+			return
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			responses.ErrorResponse(w, http.StatusNotFound, h.Trans.Translate(r, "errors.not_found", nil), "Scheduled message not found")
+			return
+		}
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
 		return
 	}
 
-	if message.SenderID == currentUserID {
-		responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.message.sender_read", nil), "Sender is not allowed")
+	responses.SuccessResponse(w, http.StatusOK, h.Trans.Translate(r, "success.message.edit", nil), message)
+}
+
+func (h *MessageHandler) RescheduleMessage(w http.ResponseWriter, r *http.Request) {
+	var payload requests.RescheduleMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), err.Error())
 		return
 	}
 
-	readAt, err := h.MsgRepo.MarkAsRead(uint(messageID))
+	if err := utils.ValidateStruct(&payload); err != nil {
+		responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), utils.FormatValidationError(r, err, h.Trans))
+		return
+	}
+
+	if !payload.ScheduledAt.After(time.Now()) {
+		responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), map[string]string{
+			"scheduledAt": h.Trans.Translate(r, "validation.future", nil),
+		})
+		return
+	}
+
+	scheduled, ok := h.getScheduledMessage(w, r)
+	if !ok {
+		return
+	}
+
+	message, err := h.MsgRepo.Reschedule(scheduled.ID, payload.ScheduledAt)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			responses.ErrorResponse(w, http.StatusNotFound, h.Trans.Translate(r, "errors.not_found", nil), "Scheduled message not found")
+			return
+		}
 		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
 		return
 	}
-	message.ReadAt = readAt
+	message.Attachments = scheduled.Attachments
 
-	go h.WsService.SendMessage(websocket.ReadMessageEvent, message.SenderID, message)
+	responses.SuccessResponse(w, http.StatusOK, h.Trans.Translate(r, "success.message.reschedule", nil), message)
+}
 
-	responses.SuccessResponse(w, http.StatusOK, h.Trans.Translate(r, "success.message.read", nil), nil)
+func (h *MessageHandler) CancelScheduledMessage(w http.ResponseWriter, r *http.Request) {
+	scheduled, ok := h.getScheduledMessage(w, r)
+	if !ok {
+		return
+	}
+
+	deleted, err := h.MsgService.DeleteScheduled(scheduled)
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+	if !deleted {
+		responses.ErrorResponse(w, http.StatusNotFound, h.Trans.Translate(r, "errors.not_found", nil), "Scheduled message not found")
+		return
+	}
+
+	responses.SuccessResponse(w, http.StatusOK, h.Trans.Translate(r, "success.message.cancel_scheduled", nil), nil)
 }
 
 func (h *MessageHandler) GetAttachment(w http.ResponseWriter, r *http.Request) {
@@ -355,3 +843,247 @@ func (h *MessageHandler) GetAttachment(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Cache-Control", "public, max-age=3600")
 	responses.ServeFileResponse(w, r, filePath)
 }
+
+// forwardOrigin resolves the origin of a message being forwarded. Forwarding an already
+// forwarded message keeps the very first origin, and the original sender's privacy setting
+// decides whether their identity is recorded.
+func (h *MessageHandler) forwardOrigin(original *models.Message, senders map[uint]*models.User) (*models.ForwardedFrom, error) {
+	if original.ForwardedFrom != nil {
+		origin := *original.ForwardedFrom
+		return &origin, nil
+	}
+
+	sender, ok := senders[original.SenderID]
+	if !ok {
+		var err error
+		sender, err = h.UserRepo.GetUserByID(original.SenderID)
+		if err != nil {
+			return nil, err
+		}
+		senders[original.SenderID] = sender
+	}
+
+	origin := &models.ForwardedFrom{Date: original.CreatedAt}
+	if sender == nil || sender.HideForwardSender {
+		origin.Hidden = true
+		return origin, nil
+	}
+
+	origin.MessageID = &original.ID
+	origin.ChatID = &original.ChatID
+	origin.SenderID = &original.SenderID
+
+	return origin, nil
+}
+
+// getScheduledMessage loads a pending scheduled message of the current user from the route.
+// It writes the error response itself and returns false if the request should stop.
+func (h *MessageHandler) getScheduledMessage(w http.ResponseWriter, r *http.Request) (*models.Message, bool) {
+	messageID, err := strconv.Atoi(mux.Vars(r)["messageId"])
+	if err != nil || messageID < 0 {
+		responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), "Invalid message ID")
+		return nil, false
+	}
+
+	message, err := h.MsgRepo.GetById(uint(messageID))
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return nil, false
+	}
+	if message == nil || message.ScheduledAt == nil {
+		responses.ErrorResponse(w, http.StatusNotFound, h.Trans.Translate(r, "errors.not_found", nil), "Scheduled message not found")
+		return nil, false
+	}
+
+	if message.SenderID != r.Context().Value("user_id").(uint) {
+		responses.ErrorResponse(w, http.StatusForbidden, h.Trans.Translate(r, "errors.forbidden", nil), "Forbidden")
+		return nil, false
+	}
+
+	return message, true
+}
+
+// resolveQuote checks that text is an excerpt of the parent content. When offset is nil,
+// the first occurrence of text is used.
+func resolveQuote(parent *models.Message, text string, offset *int) (*models.Quote, bool) {
+	if parent.Content == nil {
+		return nil, false
+	}
+
+	if offset != nil {
+		excerpt, ok := utils.UTF16Substring(*parent.Content, *offset, utils.UTF16Length(text))
+		if !ok || excerpt != text {
+			return nil, false
+		}
+		return &models.Quote{Text: text, Offset: *offset}, true
+	}
+
+	index := utils.UTF16Index(*parent.Content, text)
+	if index < 0 {
+		return nil, false
+	}
+	return &models.Quote{Text: text, Offset: index}, true
+}
+
+// parseSearchFilter builds a search filter for the current user from the query parameters.
+// It writes the error response itself and returns false if the request should stop.
+func (h *MessageHandler) parseSearchFilter(w http.ResponseWriter, r *http.Request) (*repository.MessageSearchFilter, bool) {
+	query := r.URL.Query()
+
+	payload := requests.SearchMessagesRequest{
+		Query: strings.TrimSpace(query.Get("query")),
+		Limit: repository.SearchLimit,
+	}
+	if limitStr := query.Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil {
+			responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), "Invalid limit")
+			return nil, false
+		}
+		payload.Limit = limit
+	}
+
+	if err := utils.ValidateStruct(&payload); err != nil {
+		responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), utils.FormatValidationError(r, err, h.Trans))
+		return nil, false
+	}
+
+	filter := &repository.MessageSearchFilter{
+		UserID: r.Context().Value("user_id").(uint),
+		Query:  payload.Query,
+		Config: searchConfig(r),
+		Limit:  payload.Limit,
+	}
+
+	if senderIDStr := query.Get("senderId"); senderIDStr != "" {
+		senderID, err := strconv.Atoi(senderIDStr)
+		if err != nil || senderID <= 0 {
+			responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), "Invalid sender ID")
+			return nil, false
+		}
+		senderIDUint := uint(senderID)
+		filter.SenderID = &senderIDUint
+	}
+
+	if fromStr := query.Get("from"); fromStr != "" {
+		from, err := time.Parse(time.RFC3339, fromStr)
+		if err != nil {
+			responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), "Invalid from date")
+			return nil, false
+		}
+		filter.From = &from
+	}
+
+	if toStr := query.Get("to"); toStr != "" {
+		to, err := time.Parse(time.RFC3339, toStr)
+		if err != nil {
+			responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), "Invalid to date")
+			return nil, false
+		}
+		filter.To = &to
+	}
+
+	if hasAttachmentsStr := query.Get("hasAttachments"); hasAttachmentsStr != "" {
+		hasAttachments, err := strconv.ParseBool(hasAttachmentsStr)
+		if err != nil {
+			responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), "Invalid hasAttachments flag")
+			return nil, false
+		}
+		filter.HasAttachments = &hasAttachments
+	}
+
+	if cursorStr := query.Get("cursor"); cursorStr != "" {
+		cursor, err := repository.DecodeMessageCursor(cursorStr)
+		if err != nil {
+			responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), "Invalid cursor")
+			return nil, false
+		}
+		filter.Cursor = cursor
+	}
+
+	return filter, true
+}
+
+// searchConfig picks the text search configuration matching the request locale.
+func searchConfig(r *http.Request) string {
+	lang := strings.ToLower(utils.GetLocale(r))
+	if len(lang) > 2 {
+		lang = lang[:2]
+	}
+
+	if config, ok := repository.SearchConfigs[lang]; ok {
+		return config
+	}
+	return repository.DefaultSearchConfig
+}
+
+// formatContent turns the submitted content into plain text with formatting entities,
+// either by parsing Markdown or by validating the entities sent along with the text.
+func (h *MessageHandler) formatContent(w http.ResponseWriter, r *http.Request, content string, entities []*requests.MessageEntityRequest, parseMode *string) (string, []*models.MessageEntity, bool) {
+	if parseMode != nil && len(entities) > 0 {
+		responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), map[string]string{
+			"entities": h.Trans.Translate(r, "validation.entities", nil),
+		})
+		return "", nil, false
+	}
+
+	var formatting []*models.MessageEntity
+	switch {
+	case parseMode == nil:
+		formatting = make([]*models.MessageEntity, 0, len(entities))
+		for _, entity := range entities {
+			formatting = append(formatting, &models.MessageEntity{
+				Type:     entity.Type,
+				Offset:   entity.Offset,
+				Length:   entity.Length,
+				URL:      entity.URL,
+				Language: entity.Language,
+			})
+		}
+	case *parseMode == services.MarkdownParseMode:
+		content, formatting = services.ParseMarkdown(content)
+	default:
+		responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), map[string]string{
+			"parseMode": h.Trans.Translate(r, "validation.oneof", map[string]interface{}{"Param": services.MarkdownParseMode}),
+		})
+		return "", nil, false
+	}
+
+	if err := services.ValidateEntities(content, formatting); err != nil {
+		responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), map[string]string{
+			"entities": h.Trans.Translate(r, "validation.entities", nil),
+		})
+		return "", nil, false
+	}
+
+	return content, formatting, true
+}
+
+// resolveEntities detects the mentions in the content and merges them with the formatting entities.
+func (h *MessageHandler) resolveEntities(content *string, formatting []*models.MessageEntity) ([]*models.MessageEntity, error) {
+	mentions, err := h.MentionService.Resolve(content, formatting)
+	if err != nil {
+		return nil, err
+	}
+	return services.MergeEntities(formatting, mentions), nil
+}
+
+// respondModerationError responds with the reason a filter rejected a message and reports whether err was such a rejection.
+func respondModerationError(w http.ResponseWriter, r *http.Request, trans *utils.Translator, err error) bool {
+	var moderationErr *services.ModerationError
+	if !errors.As(err, &moderationErr) {
+		return false
+	}
+	responses.ErrorResponse(w, http.StatusUnprocessableEntity, trans.Translate(r, moderationErrorKey(moderationErr.Verdict.Filter), nil), err.Error())
+	return true
+}
+
+// moderationErrorKey returns the translation explaining why a filter rejected a message.
+func moderationErrorKey(filter string) string {
+	switch filter {
+	case "keyword", "link", "spam":
+		return "errors.moderation." + filter
+	default:
+		return "errors.moderation.rejected"
+	}
+}
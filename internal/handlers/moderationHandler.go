@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/drTragger/messenger-backend/internal/models"
+	"github.com/drTragger/messenger-backend/internal/repository"
+	"github.com/drTragger/messenger-backend/internal/requests"
+	"github.com/drTragger/messenger-backend/internal/responses"
+	"github.com/drTragger/messenger-backend/internal/services"
+	"github.com/drTragger/messenger-backend/internal/utils"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+)
+
+type ModerationHandler struct {
+	ModerationRepo *repository.ModerationRepository
+	MsgService     *services.MessageService
+	MsgRepo        *repository.MessageRepository
+	Trans          *utils.Translator
+}
+
+func NewModerationHandler(
+	moderationRepo *repository.ModerationRepository,
+	msgService *services.MessageService,
+	msgRepo *repository.MessageRepository,
+	trans *utils.Translator,
+) *ModerationHandler {
+	return &ModerationHandler{
+		ModerationRepo: moderationRepo,
+		MsgService:     msgService,
+		MsgRepo:        msgRepo,
+		Trans:          trans,
+	}
+}
+
+// GetFlags returns the review queue, oldest flags first. The status defaults to pending.
+func (h *ModerationHandler) GetFlags(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var err error
+
+	status := query.Get("status")
+	if status == "" {
+		status = models.PendingFlag
+	}
+	if status != models.PendingFlag && status != models.ApprovedFlag && status != models.RemovedFlag {
+		responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), "Invalid status")
+		return
+	}
+
+	limitStr := query.Get("limit")
+	limit := repository.ModerationFlagsLimit
+	if limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), "Invalid limit")
+			return
+		}
+	}
+
+	offsetStr := query.Get("offset")
+	offset := repository.ModerationFlagsOffset
+	if offsetStr != "" {
+		offset, err = strconv.Atoi(offsetStr)
+		if err != nil || offset < 0 {
+			responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), "Invalid offset.")
+			return
+		}
+	}
+
+	flags, err := h.ModerationRepo.GetFlags(status, limit, offset)
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+
+	for _, flag := range flags {
+		if flag.MessageID == nil {
+			continue
+		}
+		flag.Message, err = h.MsgRepo.GetById(*flag.MessageID)
+		if err != nil {
+			responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+			return
+		}
+	}
+
+	responses.SuccessResponse(w, http.StatusOK, h.Trans.Translate(r, "success.moderation.get_flags", nil), flags)
+}
+
+// ReviewFlag approves a flagged message, or removes it, which resolves every pending flag of the message.
+func (h *ModerationHandler) ReviewFlag(w http.ResponseWriter, r *http.Request) {
+	var payload requests.ReviewFlagRequest
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), err.Error())
+		return
+	}
+
+	if err := utils.ValidateStruct(&payload); err != nil {
+		responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), utils.FormatValidationError(r, err, h.Trans))
+		return
+	}
+
+	flagID, err := strconv.Atoi(mux.Vars(r)["flagId"])
+	if err != nil || flagID < 0 {
+		responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), "Invalid flag ID")
+		return
+	}
+
+	flag, err := h.ModerationRepo.GetFlagByID(uint(flagID))
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+	if flag == nil {
+		responses.ErrorResponse(w, http.StatusNotFound, h.Trans.Translate(r, "errors.not_found", nil), "Flag not found")
+		return
+	}
+	if flag.Status != models.PendingFlag {
+		responses.ErrorResponse(w, http.StatusConflict, h.Trans.Translate(r, "errors.moderation.reviewed", nil), "Flag is already reviewed")
+		return
+	}
+
+	moderatorID := r.Context().Value("user_id").(uint)
+
+	// The flags are resolved before the message is deleted, which detaches them from it
+	if err := h.ModerationRepo.ReviewFlag(flag.ID, payload.Status, moderatorID); err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+
+	if payload.Status == models.RemovedFlag && flag.MessageID != nil {
+		message, err := h.MsgRepo.GetById(*flag.MessageID)
+		if err != nil {
+			responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+			return
+		}
+		if message != nil {
+			if err := h.MsgService.Delete(message); err != nil {
+				responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+				return
+			}
+		}
+	}
+
+	flag, err = h.ModerationRepo.GetFlagByID(flag.ID)
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+
+	responses.SuccessResponse(w, http.StatusOK, h.Trans.Translate(r, "success.moderation.review_flag", nil), flag)
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/drTragger/messenger-backend/internal/repository"
+	"github.com/drTragger/messenger-backend/internal/requests"
+	"github.com/drTragger/messenger-backend/internal/responses"
+	"github.com/drTragger/messenger-backend/internal/services"
+	"github.com/drTragger/messenger-backend/internal/utils"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+)
+
+type PinHandler struct {
+	PinService *services.PinService
+	PinRepo    *repository.PinnedMessageRepository
+	ChatRepo   *repository.ChatRepository
+	MsgRepo    *repository.MessageRepository
+	Trans      *utils.Translator
+}
+
+func NewPinHandler(
+	pinService *services.PinService,
+	pinRepo *repository.PinnedMessageRepository,
+	chatRepo *repository.ChatRepository,
+	msgRepo *repository.MessageRepository,
+	trans *utils.Translator,
+) *PinHandler {
+	return &PinHandler{
+		PinService: pinService,
+		PinRepo:    pinRepo,
+		ChatRepo:   chatRepo,
+		MsgRepo:    msgRepo,
+		Trans:      trans,
+	}
+}
+
+func (h *PinHandler) Pin(w http.ResponseWriter, r *http.Request) {
+	var payload requests.PinMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), err.Error())
+		return
+	}
+
+	if err := utils.ValidateStruct(&payload); err != nil {
+		responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), utils.FormatValidationError(r, err, h.Trans))
+		return
+	}
+
+	chat, userID, ok := getParticipantChat(w, r, h.ChatRepo, h.Trans)
+	if !ok {
+		return
+	}
+
+	message, err := h.MsgRepo.GetById(payload.MessageID)
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+	// Shadowed messages only exist for their sender
+	if message == nil || message.ChatID != chat.ID || message.ScheduledAt != nil || (message.Shadowed && message.SenderID != userID) {
+		responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), map[string]string{
+			"messageId": h.Trans.Translate(r, "validation.exists", nil),
+		})
+		return
+	}
+
+	pin, err := h.PinService.Pin(chat, userID, message, payload.Notify)
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+
+	responses.SuccessResponse(w, http.StatusCreated, h.Trans.Translate(r, "success.pin.pin", nil), pin)
+}
+
+func (h *PinHandler) Unpin(w http.ResponseWriter, r *http.Request) {
+	messageID, err := strconv.Atoi(mux.Vars(r)["messageId"])
+	if err != nil || messageID < 0 {
+		responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), "Invalid message ID")
+		return
+	}
+
+	chat, userID, ok := getParticipantChat(w, r, h.ChatRepo, h.Trans)
+	if !ok {
+		return
+	}
+
+	unpinned, err := h.PinService.Unpin(chat, userID, uint(messageID))
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+	if !unpinned {
+		responses.ErrorResponse(w, http.StatusNotFound, h.Trans.Translate(r, "errors.not_found", nil), "Pinned message not found.")
+		return
+	}
+
+	responses.SuccessResponse(w, http.StatusOK, h.Trans.Translate(r, "success.pin.unpin", nil), nil)
+}
+
+func (h *PinHandler) UnpinAll(w http.ResponseWriter, r *http.Request) {
+	chat, userID, ok := getParticipantChat(w, r, h.ChatRepo, h.Trans)
+	if !ok {
+		return
+	}
+
+	if err := h.PinService.UnpinAll(chat, userID); err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+
+	responses.SuccessResponse(w, http.StatusOK, h.Trans.Translate(r, "success.pin.unpin_all", nil), nil)
+}
+
+func (h *PinHandler) GetForChat(w http.ResponseWriter, r *http.Request) {
+	chat, userID, ok := getParticipantChat(w, r, h.ChatRepo, h.Trans)
+	if !ok {
+		return
+	}
+
+	pins, err := h.PinRepo.GetForChat(chat.ID, userID)
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+
+	responses.SuccessResponse(w, http.StatusOK, h.Trans.Translate(r, "success.pin.get_list", nil), pins)
+}
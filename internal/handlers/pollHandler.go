@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/drTragger/messenger-backend/internal/models"
+	"github.com/drTragger/messenger-backend/internal/repository"
+	"github.com/drTragger/messenger-backend/internal/requests"
+	"github.com/drTragger/messenger-backend/internal/responses"
+	"github.com/drTragger/messenger-backend/internal/services"
+	"github.com/drTragger/messenger-backend/internal/utils"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type PollHandler struct {
+	PollService *services.PollService
+	PollRepo    *repository.PollRepository
+	MsgRepo     *repository.MessageRepository
+	ChatRepo    *repository.ChatRepository
+	Trans       *utils.Translator
+}
+
+func NewPollHandler(
+	pollService *services.PollService,
+	pollRepo *repository.PollRepository,
+	msgRepo *repository.MessageRepository,
+	chatRepo *repository.ChatRepository,
+	trans *utils.Translator,
+) *PollHandler {
+	return &PollHandler{
+		PollService: pollService,
+		PollRepo:    pollRepo,
+		MsgRepo:     msgRepo,
+		ChatRepo:    chatRepo,
+		Trans:       trans,
+	}
+}
+
+func (h *PollHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var payload requests.CreatePollRequest
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), err.Error())
+		return
+	}
+
+	if err := utils.ValidateStruct(&payload); err != nil {
+		responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), utils.FormatValidationError(r, err, h.Trans))
+		return
+	}
+
+	if payload.ClosesAt != nil && !payload.ClosesAt.After(time.Now()) {
+		responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), map[string]string{
+			"closesAt": h.Trans.Translate(r, "validation.future", nil),
+		})
+		return
+	}
+
+	if payload.IsQuiz {
+		if payload.AllowsMultipleAnswers {
+			responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), map[string]string{
+				"allowsMultipleAnswers": h.Trans.Translate(r, "validation.quiz_multiple", nil),
+			})
+			return
+		}
+		if *payload.CorrectOption >= len(payload.Options) {
+			responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), map[string]string{
+				"correctOption": h.Trans.Translate(r, "validation.exists", nil),
+			})
+			return
+		}
+	} else {
+		payload.CorrectOption = nil
+	}
+
+	chat, userID, ok := getParticipantChat(w, r, h.ChatRepo, h.Trans)
+	if !ok {
+		return
+	}
+
+	message := &models.Message{
+		SenderID:    userID,
+		RecipientID: otherParticipant(chat, userID),
+		ChatID:      chat.ID,
+		Type:        models.PollMessage,
+	}
+	poll := &models.Poll{
+		Question:              payload.Question,
+		IsAnonymous:           payload.IsAnonymous == nil || *payload.IsAnonymous,
+		AllowsMultipleAnswers: payload.AllowsMultipleAnswers,
+		IsQuiz:                payload.IsQuiz,
+		CorrectOption:         payload.CorrectOption,
+		ClosesAt:              payload.ClosesAt,
+		Options:               make([]*models.PollOption, 0, len(payload.Options)),
+	}
+	for i, text := range payload.Options {
+		poll.Options = append(poll.Options, &models.PollOption{Position: i, Text: text})
+	}
+
+	if err := h.PollService.Create(message, poll, utils.GetLocale(r)); err != nil {
+		if respondModerationError(w, r, h.Trans, err) {
+			return
+		}
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+
+	responses.SuccessResponse(w, http.StatusCreated, h.Trans.Translate(r, "success.poll.create", nil), message)
+}
+
+func (h *PollHandler) Vote(w http.ResponseWriter, r *http.Request) {
+	var payload requests.VotePollRequest
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), err.Error())
+		return
+	}
+
+	if err := utils.ValidateStruct(&payload); err != nil {
+		responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), utils.FormatValidationError(r, err, h.Trans))
+		return
+	}
+
+	message, poll, userID, ok := h.getChatPoll(w, r)
+	if !ok {
+		return
+	}
+
+	if len(payload.OptionIDs) > 1 && !poll.AllowsMultipleAnswers {
+		responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), map[string]string{
+			"optionIds": h.Trans.Translate(r, "validation.single_choice", nil),
+		})
+		return
+	}
+
+	poll, err := h.PollService.Vote(message, userID, payload.OptionIDs)
+	if err != nil {
+		if errors.Is(err, repository.ErrPollOption) {
+			responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), map[string]string{
+				"optionIds": h.Trans.Translate(r, "validation.exists", nil),
+			})
+			return
+		}
+		h.voteError(w, r, err)
+		return
+	}
+
+	// A voter may see the answer of the quiz right away
+	responses.SuccessResponse(w, http.StatusOK, h.Trans.Translate(r, "success.poll.vote", nil), poll)
+}
+
+func (h *PollHandler) Retract(w http.ResponseWriter, r *http.Request) {
+	message, _, userID, ok := h.getChatPoll(w, r)
+	if !ok {
+		return
+	}
+
+	poll, err := h.PollService.Retract(message, userID)
+	if err != nil {
+		h.voteError(w, r, err)
+		return
+	}
+	if poll == nil {
+		responses.ErrorResponse(w, http.StatusNotFound, h.Trans.Translate(r, "errors.not_found", nil), "Vote not found")
+		return
+	}
+
+	responses.SuccessResponse(w, http.StatusOK, h.Trans.Translate(r, "success.poll.retract", nil), poll)
+}
+
+func (h *PollHandler) Close(w http.ResponseWriter, r *http.Request) {
+	message, _, userID, ok := h.getChatPoll(w, r)
+	if !ok {
+		return
+	}
+
+	if message.SenderID != userID {
+		responses.ErrorResponse(w, http.StatusForbidden, h.Trans.Translate(r, "errors.forbidden", nil), "Only the author can close the poll")
+		return
+	}
+
+	poll, err := h.PollService.Close(message)
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+	if poll == nil {
+		responses.ErrorResponse(w, http.StatusConflict, h.Trans.Translate(r, "errors.poll.closed", nil), "Poll is already closed")
+		return
+	}
+
+	responses.SuccessResponse(w, http.StatusOK, h.Trans.Translate(r, "success.poll.close", nil), poll)
+}
+
+// getChatPoll loads the poll of the message addressed by the request and checks that the user takes part in its chat.
+// The returned poll includes the correct option of a quiz.
+func (h *PollHandler) getChatPoll(w http.ResponseWriter, r *http.Request) (*models.Message, *models.Poll, uint, bool) {
+	chat, userID, ok := getParticipantChat(w, r, h.ChatRepo, h.Trans)
+	if !ok {
+		return nil, nil, 0, false
+	}
+
+	messageID, err := strconv.Atoi(mux.Vars(r)["messageId"])
+	if err != nil || messageID < 0 {
+		responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), "Invalid message ID")
+		return nil, nil, 0, false
+	}
+
+	message, err := h.MsgRepo.GetById(uint(messageID))
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return nil, nil, 0, false
+	}
+	// Shadowed messages only exist for their sender
+	if message == nil || message.ChatID != chat.ID || message.Poll == nil || (message.Shadowed && message.SenderID != userID) {
+		responses.ErrorResponse(w, http.StatusNotFound, h.Trans.Translate(r, "errors.not_found", nil), "Poll not found")
+		return nil, nil, 0, false
+	}
+
+	poll, err := h.PollRepo.GetByID(message.Poll.ID)
+	if err != nil || poll == nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), "Failed to load poll")
+		return nil, nil, 0, false
+	}
+
+	return message, poll, userID, true
+}
+
+func (h *PollHandler) voteError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, repository.ErrPollClosed):
+		responses.ErrorResponse(w, http.StatusConflict, h.Trans.Translate(r, "errors.poll.closed", nil), err.Error())
+	case errors.Is(err, repository.ErrQuizAnswered):
+		responses.ErrorResponse(w, http.StatusConflict, h.Trans.Translate(r, "errors.poll.quiz_answered", nil), err.Error())
+	case errors.Is(err, sql.ErrNoRows):
+		responses.ErrorResponse(w, http.StatusNotFound, h.Trans.Translate(r, "errors.not_found", nil), "Poll not found")
+	default:
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+	}
+}
@@ -0,0 +1,311 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/drTragger/messenger-backend/internal/models"
+	"github.com/drTragger/messenger-backend/internal/repository"
+	"github.com/drTragger/messenger-backend/internal/requests"
+	"github.com/drTragger/messenger-backend/internal/responses"
+	"github.com/drTragger/messenger-backend/internal/services"
+	"github.com/drTragger/messenger-backend/internal/storage"
+	"github.com/drTragger/messenger-backend/internal/utils"
+	"github.com/gorilla/mux"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type ReportHandler struct {
+	ReportService *services.ReportService
+	ReportRepo    *repository.ReportRepository
+	MsgRepo       *repository.MessageRepository
+	UserRepo      *repository.UserRepository
+	Storage       storage.Storage
+	Trans         *utils.Translator
+}
+
+func NewReportHandler(
+	reportService *services.ReportService,
+	reportRepo *repository.ReportRepository,
+	msgRepo *repository.MessageRepository,
+	userRepo *repository.UserRepository,
+	storage storage.Storage,
+	trans *utils.Translator,
+) *ReportHandler {
+	return &ReportHandler{
+		ReportService: reportService,
+		ReportRepo:    reportRepo,
+		MsgRepo:       msgRepo,
+		UserRepo:      userRepo,
+		Storage:       storage,
+		Trans:         trans,
+	}
+}
+
+// Create reports a message or a user. A message can only be reported by a participant of its chat,
+// and its sender is the reported user.
+func (h *ReportHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var payload requests.CreateReportRequest
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), err.Error())
+		return
+	}
+
+	if err := utils.ValidateStruct(&payload); err != nil {
+		responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), utils.FormatValidationError(r, err, h.Trans))
+		return
+	}
+
+	userID := r.Context().Value("user_id").(uint)
+
+	report := &models.Report{
+		ReporterID: userID,
+		MessageID:  payload.MessageID,
+		Reason:     payload.Reason,
+		Comment:    payload.Comment,
+	}
+
+	var message *models.Message
+	if payload.MessageID != nil {
+		var err error
+		message, err = h.MsgRepo.GetById(*payload.MessageID)
+		if err != nil {
+			responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+			return
+		}
+		if message == nil || (message.SenderID != userID && message.RecipientID != userID) {
+			responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), map[string]string{
+				"messageId": h.Trans.Translate(r, "validation.exists", nil),
+			})
+			return
+		}
+		report.ReportedUserID = message.SenderID
+	} else {
+		user, err := h.UserRepo.GetUserByID(*payload.UserID)
+		if err != nil {
+			responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+			return
+		}
+		if user == nil {
+			responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), map[string]string{
+				"userId": h.Trans.Translate(r, "validation.exists", nil),
+			})
+			return
+		}
+		report.ReportedUserID = user.ID
+	}
+
+	if report.ReportedUserID == userID {
+		responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.report.self", nil), "Users can't report themselves")
+		return
+	}
+
+	if err := h.ReportService.Create(report, message); err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+
+	// The reporter doesn't need the snapshot of a message they have already seen
+	report.Snapshot = nil
+	responses.SuccessResponse(w, http.StatusCreated, h.Trans.Translate(r, "success.report.create", nil), report)
+}
+
+// GetQueue returns the reports with a status, open by default, oldest first. The assignee filter takes
+// "me" for the caller's reports, "none" for unassigned ones or the ID of a moderator.
+func (h *ReportHandler) GetQueue(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var err error
+
+	status := query.Get("status")
+	if status == "" {
+		status = models.OpenReport
+	}
+	if status != models.OpenReport && status != models.ResolvedReport && status != models.DismissedReport {
+		responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), "Invalid status")
+		return
+	}
+
+	var assignee *uint
+	switch assigneeStr := query.Get("assignee"); assigneeStr {
+	case "":
+	case "me":
+		userID := r.Context().Value("user_id").(uint)
+		assignee = &userID
+	case "none":
+		unassigned := uint(0)
+		assignee = &unassigned
+	default:
+		assigneeID, err := strconv.Atoi(assigneeStr)
+		if err != nil || assigneeID <= 0 {
+			responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), "Invalid assignee")
+			return
+		}
+		id := uint(assigneeID)
+		assignee = &id
+	}
+
+	limitStr := query.Get("limit")
+	limit := repository.ReportsLimit
+	if limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), "Invalid limit")
+			return
+		}
+	}
+
+	offsetStr := query.Get("offset")
+	offset := repository.ReportsOffset
+	if offsetStr != "" {
+		offset, err = strconv.Atoi(offsetStr)
+		if err != nil || offset < 0 {
+			responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), "Invalid offset.")
+			return
+		}
+	}
+
+	reports, err := h.ReportRepo.GetQueue(status, assignee, limit, offset)
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+
+	responses.SuccessResponse(w, http.StatusOK, h.Trans.Translate(r, "success.report.get_queue", nil), reports)
+}
+
+func (h *ReportHandler) GetReport(w http.ResponseWriter, r *http.Request) {
+	report, ok := h.getReport(w, r)
+	if !ok {
+		return
+	}
+
+	responses.SuccessResponse(w, http.StatusOK, h.Trans.Translate(r, "success.report.get", nil), report)
+}
+
+// Assign gives an open report to a moderator, the caller by default.
+func (h *ReportHandler) Assign(w http.ResponseWriter, r *http.Request) {
+	var payload requests.AssignReportRequest
+	// The body is optional
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil && !errors.Is(err, io.EOF) {
+		responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), err.Error())
+		return
+	}
+
+	report, ok := h.getReport(w, r)
+	if !ok {
+		return
+	}
+
+	moderatorID := r.Context().Value("user_id").(uint)
+	if payload.ModeratorID != nil && *payload.ModeratorID != moderatorID {
+		isModerator, err := h.UserRepo.IsModerator(*payload.ModeratorID)
+		if err != nil {
+			responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+			return
+		}
+		if !isModerator {
+			responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), map[string]string{
+				"moderatorId": h.Trans.Translate(r, "validation.exists", nil),
+			})
+			return
+		}
+		moderatorID = *payload.ModeratorID
+	}
+
+	assigned, err := h.ReportRepo.Assign(report.ID, moderatorID)
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+	if !assigned {
+		responses.ErrorResponse(w, http.StatusConflict, h.Trans.Translate(r, "errors.report.closed", nil), services.ErrReportClosed.Error())
+		return
+	}
+
+	h.respondWithReport(w, r, report.ID, "success.report.assign")
+}
+
+// Resolve deletes the reported content, warns or suspends the reported user, or dismisses the report.
+func (h *ReportHandler) Resolve(w http.ResponseWriter, r *http.Request) {
+	var payload requests.ResolveReportRequest
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), err.Error())
+		return
+	}
+
+	if err := utils.ValidateStruct(&payload); err != nil {
+		responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), utils.FormatValidationError(r, err, h.Trans))
+		return
+	}
+
+	if payload.Action == models.SuspendAction && !payload.SuspendUntil.After(time.Now()) {
+		responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), map[string]string{
+			"suspendUntil": h.Trans.Translate(r, "validation.future", nil),
+		})
+		return
+	}
+
+	report, ok := h.getReport(w, r)
+	if !ok {
+		return
+	}
+
+	moderatorID := r.Context().Value("user_id").(uint)
+
+	if err := h.ReportService.Resolve(report, payload.Action, payload.Note, payload.SuspendUntil, moderatorID); err != nil {
+		if errors.Is(err, services.ErrReportClosed) {
+			responses.ErrorResponse(w, http.StatusConflict, h.Trans.Translate(r, "errors.report.closed", nil), err.Error())
+			return
+		}
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+
+	h.respondWithReport(w, r, report.ID, "success.report.resolve")
+}
+
+// GetEvidenceFile serves a copy of a file attached to a reported message.
+func (h *ReportHandler) GetEvidenceFile(w http.ResponseWriter, r *http.Request) {
+	fileName := mux.Vars(r)["filename"]
+
+	filePath, err := h.Storage.GetFile(storage.ReportEvidenceDir, fileName)
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusNotFound, h.Trans.Translate(r, "errors.not_found", nil), fmt.Sprintf("File not found: %v", err))
+		return
+	}
+
+	responses.ServeFileResponse(w, r, filePath)
+}
+
+func (h *ReportHandler) getReport(w http.ResponseWriter, r *http.Request) (*models.Report, bool) {
+	reportID, err := strconv.Atoi(mux.Vars(r)["reportId"])
+	if err != nil || reportID < 0 {
+		responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), "Invalid report ID")
+		return nil, false
+	}
+
+	report, err := h.ReportRepo.GetByID(uint(reportID))
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return nil, false
+	}
+	if report == nil {
+		responses.ErrorResponse(w, http.StatusNotFound, h.Trans.Translate(r, "errors.not_found", nil), "Report not found")
+		return nil, false
+	}
+
+	return report, true
+}
+
+func (h *ReportHandler) respondWithReport(w http.ResponseWriter, r *http.Request, reportID uint, messageID string) {
+	report, err := h.ReportRepo.GetByID(reportID)
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+
+	responses.SuccessResponse(w, http.StatusOK, h.Trans.Translate(r, messageID, nil), report)
+}
@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"github.com/drTragger/messenger-backend/internal/fakedb"
+	"github.com/drTragger/messenger-backend/internal/models"
+	"github.com/drTragger/messenger-backend/internal/repository"
+	"github.com/drTragger/messenger-backend/internal/services"
+	"github.com/drTragger/messenger-backend/internal/storage"
+	"github.com/drTragger/messenger-backend/internal/websocket"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReportHandlerCreate(t *testing.T) {
+	tests := []struct {
+		name     string
+		userID   uint
+		body     string
+		status   int
+		reported driver.Value
+	}{
+		{name: "message of the reporter's chat", userID: 2, body: `{"messageId": 40, "reason": "spam"}`, status: http.StatusCreated, reported: int64(1)},
+		{name: "message of another chat", userID: 3, body: `{"messageId": 40, "reason": "spam"}`, status: http.StatusUnprocessableEntity},
+		{name: "own message", userID: 1, body: `{"messageId": 40, "reason": "spam"}`, status: http.StatusBadRequest},
+		{name: "unknown reason", userID: 2, body: `{"messageId": 40, "reason": "boring"}`, status: http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := fakedb.Open(t, func(query string, args []driver.Value) fakedb.Result {
+				switch {
+				case isMessageByID(query):
+					return messageRow(40, 1, 2, 7)
+				case strings.Contains(query, "INSERT INTO reports"):
+					return fakedb.Row(int64(12), models.OpenReport, time.Now())
+				}
+				return fakedb.Result{}
+			})
+			handler := newTestReportHandler(t, db)
+
+			r := httptest.NewRequest(http.MethodPost, "/reports", strings.NewReader(tt.body))
+			w := serve(handler.Create, r, tt.userID, nil)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+
+			reports := fake.Executed("INSERT INTO reports")
+			if tt.reported == nil {
+				if len(reports) != 0 {
+					t.Errorf("%d reports stored, want none", len(reports))
+				}
+				return
+			}
+			if len(reports) != 1 || reports[0].Args[0] != int64(tt.userID) || reports[0].Args[1] != tt.reported {
+				t.Errorf("reports = %v, want a report of user %v by user %d", reports, tt.reported, tt.userID)
+			}
+			if strings.Contains(w.Body.String(), "snapshot") {
+				t.Errorf("response %s has the snapshot, want it left out", w.Body)
+			}
+		})
+	}
+}
+
+func TestReportHandlerAssign(t *testing.T) {
+	tests := []struct {
+		name   string
+		open   bool
+		status int
+	}{
+		{name: "open report", open: true, status: http.StatusOK},
+		{name: "closed report", status: http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := fakedb.Open(t, func(query string, args []driver.Value) fakedb.Result {
+				switch {
+				case strings.Contains(query, "FROM reports"):
+					return reportRow(12, 2, 1, 40)
+				case strings.Contains(query, "SET assignee_id"):
+					if !tt.open {
+						return fakedb.Result{}
+					}
+					return fakedb.Result{Affected: 1}
+				}
+				return fakedb.Result{Err: fmt.Errorf("unexpected query: %s", query)}
+			})
+			handler := newTestReportHandler(t, db)
+
+			r := httptest.NewRequest(http.MethodPost, "/moderation/reports/12/assign", nil)
+			w := serve(handler.Assign, r, 9, map[string]string{"reportId": "12"})
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+
+			// The claim only succeeds for an open report, so two moderators can't take the same one
+			assigns := fake.Executed("SET assignee_id")
+			if len(assigns) != 1 || fmt.Sprint(assigns[0].Args) != fmt.Sprint([]driver.Value{int64(12), int64(9)}) {
+				t.Errorf("assigns = %v, want report 12 claimed for moderator 9", assigns)
+			}
+		})
+	}
+}
+
+func TestReportHandlerResolve(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		reportOpen bool
+		status     int
+		resolution string
+		statements []string
+		events     []string
+	}{
+		{
+			name:       "dismiss",
+			body:       `{"action": "dismiss"}`,
+			reportOpen: true,
+			status:     http.StatusOK,
+			resolution: models.DismissedReport,
+			events:     []string{"2 " + string(websocket.ReportResolvedEvent)},
+		},
+		{
+			name:       "warn",
+			body:       `{"action": "warn", "note": "Mind your language"}`,
+			reportOpen: true,
+			status:     http.StatusOK,
+			resolution: models.ResolvedReport,
+			events:     []string{"1 " + string(websocket.WarningEvent), "2 " + string(websocket.ReportResolvedEvent)},
+		},
+		{
+			name:       "delete content",
+			body:       `{"action": "delete_content"}`,
+			reportOpen: true,
+			status:     http.StatusOK,
+			resolution: models.ResolvedReport,
+			statements: []string{"DELETE FROM messages"},
+			events:     []string{"2 " + string(websocket.DeleteMessageEvent), "2 " + string(websocket.ReportResolvedEvent)},
+		},
+		{
+			name:       "suspend",
+			body:       fmt.Sprintf(`{"action": "suspend", "suspendUntil": %q}`, time.Now().Add(24*time.Hour).Format(time.RFC3339)),
+			reportOpen: true,
+			status:     http.StatusOK,
+			resolution: models.ResolvedReport,
+			statements: []string{"INSERT INTO user_restrictions"},
+			events:     []string{"2 " + string(websocket.ReportResolvedEvent)},
+		},
+		{
+			name:   "closed report",
+			body:   `{"action": "warn"}`,
+			status: http.StatusConflict,
+		},
+		{
+			name:   "suspension in the past",
+			body:   fmt.Sprintf(`{"action": "suspend", "suspendUntil": %q}`, time.Now().Add(-time.Hour).Format(time.RFC3339)),
+			status: http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := fakedb.Open(t, func(query string, args []driver.Value) fakedb.Result {
+				switch {
+				case strings.Contains(query, "FROM reports"):
+					return reportRow(12, 2, 1, 40)
+				case strings.Contains(query, "SET status"):
+					if !tt.reportOpen {
+						return fakedb.Result{}
+					}
+					return fakedb.Result{Affected: 1}
+				case isMessageByID(query):
+					return messageRow(40, 1, 2, 7)
+				case strings.Contains(query, "INSERT INTO user_restrictions"):
+					return fakedb.Row(int64(3), time.Now())
+				}
+				return fakedb.Result{Affected: 1}
+			})
+			handler := newTestReportHandler(t, db)
+
+			r := httptest.NewRequest(http.MethodPost, "/moderation/reports/12/resolve", strings.NewReader(tt.body))
+			w := serve(handler.Resolve, r, 9, map[string]string{"reportId": "12"})
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+
+			for _, statement := range []string{"DELETE FROM messages", "INSERT INTO user_restrictions"} {
+				want := 0
+				for _, expected := range tt.statements {
+					if expected == statement {
+						want = 1
+					}
+				}
+				if got := len(fake.Executed(statement)); got != want {
+					t.Errorf("%q ran %d times, want %d", statement, got, want)
+				}
+			}
+
+			events := make([]string, 0)
+			for _, event := range fake.Executed("INSERT INTO outbox_events") {
+				events = append(events, fmt.Sprintf("%v %v", event.Args[0], event.Args[1]))
+			}
+			if fmt.Sprint(events) != fmt.Sprint(tt.events) {
+				t.Errorf("outbox events = %v, want %v", events, tt.events)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			resolves := fake.Executed("SET status")
+			if len(resolves) != 1 || resolves[0].Args[1] != tt.resolution || resolves[0].Args[4] != int64(9) {
+				t.Errorf("resolves = %v, want report 12 %s by moderator 9", resolves, tt.resolution)
+			}
+			if fake.Commits != 1 {
+				t.Errorf("%d commits, want the action and its events in one transaction", fake.Commits)
+			}
+		})
+	}
+}
+
+func newTestReportHandler(t *testing.T, db *sql.DB) *ReportHandler {
+	t.Helper()
+
+	storageInst, err := storage.NewStorage(&storage.Config{Type: storage.LocalStorageType, LocalPath: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reportRepo := repository.NewReportRepository(db)
+	msgRepo := repository.NewMessageRepository(db)
+	return NewReportHandler(
+		services.NewReportService(
+			db,
+			reportRepo,
+			repository.NewRestrictionRepository(db),
+			msgRepo,
+			newTestMessageService(db),
+			repository.NewOutboxRepository(db),
+			websocket.NewClientManager(),
+			storageInst,
+		),
+		reportRepo,
+		msgRepo,
+		repository.NewUserRepository(db),
+		storageInst,
+		newTestTranslator(),
+	)
+}
+
+// isMessageByID tells the query of MessageRepository.GetById from the other queries of messages.
+func isMessageByID(query string) bool {
+	return strings.Contains(query, "FROM messages") && strings.Contains(query, "WHERE id = $1")
+}
+
+// messageRow answers MessageRepository.GetById with a text message without attachments.
+func messageRow(id, senderID, recipientID, chatID int64) fakedb.Result {
+	now := time.Now()
+	return fakedb.Row(
+		id, senderID, recipientID, "Hello", string(models.TextMessage), nil, nil, chatID, nil, now, now,
+		false, nil, nil, nil, nil,
+		nil, nil, nil, false, false, false,
+	)
+}
+
+// reportRow answers ReportRepository.GetByID with an open report of a message.
+func reportRow(id, reporterID, reportedUserID, messageID int64) fakedb.Result {
+	return fakedb.Row(
+		id, reporterID, reportedUserID, messageID, "spam", nil, nil, models.OpenReport, nil, nil,
+		nil, nil, nil, nil, time.Now(),
+	)
+}
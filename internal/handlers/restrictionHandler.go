@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"encoding/json"
+	"github.com/drTragger/messenger-backend/internal/models"
+	"github.com/drTragger/messenger-backend/internal/repository"
+	"github.com/drTragger/messenger-backend/internal/requests"
+	"github.com/drTragger/messenger-backend/internal/responses"
+	"github.com/drTragger/messenger-backend/internal/utils"
+	"github.com/drTragger/messenger-backend/internal/websocket"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type RestrictionHandler struct {
+	RestrictionRepo *repository.RestrictionRepository
+	UserRepo        *repository.UserRepository
+	ClientManager   *websocket.ClientManager
+	Trans           *utils.Translator
+}
+
+func NewRestrictionHandler(
+	restrictionRepo *repository.RestrictionRepository,
+	userRepo *repository.UserRepository,
+	clientManager *websocket.ClientManager,
+	trans *utils.Translator,
+) *RestrictionHandler {
+	return &RestrictionHandler{
+		RestrictionRepo: restrictionRepo,
+		UserRepo:        userRepo,
+		ClientManager:   clientManager,
+		Trans:           trans,
+	}
+}
+
+// GetForUser returns every restriction of a user, including the expired and lifted ones.
+func (h *RestrictionHandler) GetForUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := h.getUser(w, r)
+	if !ok {
+		return
+	}
+
+	restrictions, err := h.RestrictionRepo.GetForUser(user.ID)
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+
+	responses.SuccessResponse(w, http.StatusOK, h.Trans.Translate(r, "success.restriction.get_list", nil), restrictions)
+}
+
+// Create suspends a user, makes them read-only or shadow-bans them until the restriction expires.
+// A suspended user is disconnected from the websocket straight away.
+func (h *RestrictionHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var payload requests.CreateRestrictionRequest
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), err.Error())
+		return
+	}
+
+	if err := utils.ValidateStruct(&payload); err != nil {
+		responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), utils.FormatValidationError(r, err, h.Trans))
+		return
+	}
+
+	if !payload.ExpiresAt.After(time.Now()) {
+		responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), map[string]string{
+			"expiresAt": h.Trans.Translate(r, "validation.future", nil),
+		})
+		return
+	}
+
+	user, ok := h.getUser(w, r)
+	if !ok {
+		return
+	}
+
+	moderatorID := r.Context().Value("user_id").(uint)
+	restriction := &models.Restriction{
+		UserID:    user.ID,
+		Type:      payload.Type,
+		Reason:    payload.Reason,
+		ExpiresAt: payload.ExpiresAt,
+		CreatedBy: &moderatorID,
+	}
+	if err := h.RestrictionRepo.Create(restriction); err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+
+	if restriction.Type == models.SuspensionRestriction {
+		h.ClientManager.RemoveClient(user.ID)
+	}
+
+	responses.SuccessResponse(w, http.StatusCreated, h.Trans.Translate(r, "success.restriction.create", nil), restriction)
+}
+
+// Lift ends a restriction before it expires.
+func (h *RestrictionHandler) Lift(w http.ResponseWriter, r *http.Request) {
+	restrictionID, err := strconv.Atoi(mux.Vars(r)["restrictionId"])
+	if err != nil || restrictionID < 0 {
+		responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), "Invalid restriction ID")
+		return
+	}
+
+	restriction, err := h.RestrictionRepo.GetByID(uint(restrictionID))
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+	if restriction == nil {
+		responses.ErrorResponse(w, http.StatusNotFound, h.Trans.Translate(r, "errors.not_found", nil), "Restriction not found")
+		return
+	}
+
+	if err := h.RestrictionRepo.Lift(restriction.ID); err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+
+	responses.SuccessResponse(w, http.StatusOK, h.Trans.Translate(r, "success.restriction.lift", nil), nil)
+}
+
+func (h *RestrictionHandler) getUser(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	userID, err := strconv.Atoi(mux.Vars(r)["userId"])
+	if err != nil || userID < 0 {
+		responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), "Invalid user ID")
+		return nil, false
+	}
+
+	user, err := h.UserRepo.GetUserByID(uint(userID))
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return nil, false
+	}
+	if user == nil {
+		responses.ErrorResponse(w, http.StatusNotFound, h.Trans.Translate(r, "errors.not_found", nil), "User not found")
+		return nil, false
+	}
+
+	return user, true
+}
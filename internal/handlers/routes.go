@@ -2,13 +2,25 @@ package handlers
 
 import (
 	"github.com/drTragger/messenger-backend/internal/middleware"
+	"github.com/drTragger/messenger-backend/internal/models"
+	"github.com/drTragger/messenger-backend/internal/repository"
 	"github.com/gorilla/mux"
+	"net/http"
+	"time"
 )
 
-func RegisterRoutes(r *mux.Router, authHandler *AuthHandler, messageHandler *MessageHandler, chatHandler *ChatHandler, userHandler *UserHandler, wsHandler *WebSocketHandler) {
+func RegisterRoutes(r *mux.Router, authHandler *AuthHandler, messageHandler *MessageHandler, chatHandler *ChatHandler, pinHandler *PinHandler,
+	bookmarkHandler *BookmarkHandler, pollHandler *PollHandler, locationHandler *LocationHandler,
+	contactHandler *ContactHandler, stickerHandler *StickerHandler, gifHandler *GifHandler,
+	syncHandler *SyncHandler, encryptionHandler *EncryptionHandler, moderationHandler *ModerationHandler, reportHandler *ReportHandler,
+	restrictionHandler *RestrictionHandler, userHandler *UserHandler, wsHandler *WebSocketHandler,
+	idempotencyRepo *repository.IdempotencyRepository, idempotencyTTL time.Duration) {
 	apiRouter := r.PathPrefix("/api").Subrouter()
 	authApiRouter := apiRouter.PathPrefix("/").Subrouter()
-	authApiRouter.Use(middleware.Auth(authHandler.Secret, authHandler.TokenRepo, authHandler.UserRepo, authHandler.Trans))
+	authApiRouter.Use(middleware.Auth(authHandler.Secret, authHandler.TokenRepo, authHandler.UserRepo, authHandler.RestrictionRepo, authHandler.Trans))
+	authApiRouter.Use(middleware.Idempotency(idempotencyRepo, idempotencyTTL, authHandler.Trans))
+	// Read-only users can still log in and read, but not send or change messages
+	canSend := middleware.Unrestricted(models.ReadOnlyRestriction, authHandler.RestrictionRepo, authHandler.Trans)
 
 	// Auth routes
 	apiRouter.HandleFunc("/register", authHandler.Register).Methods("POST", "OPTIONS")
@@ -24,20 +36,95 @@ func RegisterRoutes(r *mux.Router, authHandler *AuthHandler, messageHandler *Mes
 	authApiRouter.HandleFunc("/chats", chatHandler.GetForUser).Methods("GET", "OPTIONS")
 	authApiRouter.HandleFunc("/chats/{id}", chatHandler.GetByID).Methods("GET", "OPTIONS")
 
+	// Pinned message routes
+	authApiRouter.Handle("/chats/{chatId}/pins", canSend(http.HandlerFunc(pinHandler.Pin))).Methods("POST", "OPTIONS")
+	authApiRouter.HandleFunc("/chats/{chatId}/pins", pinHandler.GetForChat).Methods("GET", "OPTIONS")
+	authApiRouter.Handle("/chats/{chatId}/pins", canSend(http.HandlerFunc(pinHandler.UnpinAll))).Methods("DELETE", "OPTIONS")
+	authApiRouter.Handle("/chats/{chatId}/pins/{messageId}", canSend(http.HandlerFunc(pinHandler.Unpin))).Methods("DELETE", "OPTIONS")
+	authApiRouter.Handle("/chats/{chatId}/polls", canSend(http.HandlerFunc(pollHandler.Create))).Methods("POST", "OPTIONS")
+	authApiRouter.Handle("/chats/{chatId}/polls/{messageId}/votes", canSend(http.HandlerFunc(pollHandler.Vote))).Methods("POST", "OPTIONS")
+	authApiRouter.Handle("/chats/{chatId}/polls/{messageId}/votes", canSend(http.HandlerFunc(pollHandler.Retract))).Methods("DELETE", "OPTIONS")
+	authApiRouter.Handle("/chats/{chatId}/polls/{messageId}/close", canSend(http.HandlerFunc(pollHandler.Close))).Methods("POST", "OPTIONS")
+	authApiRouter.Handle("/chats/{chatId}/locations", canSend(http.HandlerFunc(locationHandler.Send))).Methods("POST", "OPTIONS")
+	authApiRouter.Handle("/chats/{chatId}/locations/{messageId}", canSend(http.HandlerFunc(locationHandler.Update))).Methods("PATCH", "OPTIONS")
+	authApiRouter.Handle("/chats/{chatId}/locations/{messageId}/stop", canSend(http.HandlerFunc(locationHandler.Stop))).Methods("POST", "OPTIONS")
+	authApiRouter.Handle("/chats/{chatId}/contacts", canSend(http.HandlerFunc(contactHandler.Send))).Methods("POST", "OPTIONS")
+	authApiRouter.HandleFunc("/chats/{chatId}/contacts/{messageId}/vcard", contactHandler.GetVCard).Methods("GET", "OPTIONS")
+	authApiRouter.Handle("/chats/{chatId}/stickers", canSend(http.HandlerFunc(stickerHandler.Send))).Methods("POST", "OPTIONS")
+	authApiRouter.Handle("/chats/{chatId}/gifs", canSend(http.HandlerFunc(gifHandler.Send))).Methods("POST", "OPTIONS")
+
 	// Message routes
-	authApiRouter.HandleFunc("/chats/{chatId}/messages", messageHandler.SendMessage).Methods("POST", "OPTIONS")
+	authApiRouter.Handle("/chats/{chatId}/messages", canSend(http.HandlerFunc(messageHandler.SendMessage))).Methods("POST", "OPTIONS")
 	authApiRouter.HandleFunc("/chats/{chatId}/messages", messageHandler.GetMessages).Methods("GET", "OPTIONS")
-	authApiRouter.HandleFunc("/chats/{chatId}/messages/{messageId}", messageHandler.EditMessage).Methods("PATCH", "OPTIONS")
-	authApiRouter.HandleFunc("/chats/{chatId}/messages/{messageId}", messageHandler.DeleteMessage).Methods("DELETE", "OPTIONS")
+	authApiRouter.HandleFunc("/messages/search", messageHandler.SearchMessages).Methods("GET", "OPTIONS")
+	authApiRouter.HandleFunc("/mentions", messageHandler.GetMentions).Methods("GET", "OPTIONS")
+	authApiRouter.HandleFunc("/chats/{chatId}/messages/search", messageHandler.SearchChatMessages).Methods("GET", "OPTIONS")
+	authApiRouter.HandleFunc("/chats/{chatId}/messages/scheduled", messageHandler.GetScheduledMessages).Methods("GET", "OPTIONS")
+	authApiRouter.Handle("/chats/{chatId}/messages/scheduled/{messageId}", canSend(http.HandlerFunc(messageHandler.EditScheduledMessage))).Methods("PATCH", "OPTIONS")
+	authApiRouter.HandleFunc("/chats/{chatId}/messages/scheduled/{messageId}", messageHandler.CancelScheduledMessage).Methods("DELETE", "OPTIONS")
+	authApiRouter.Handle("/chats/{chatId}/messages/scheduled/{messageId}/reschedule", canSend(http.HandlerFunc(messageHandler.RescheduleMessage))).Methods("PATCH", "OPTIONS")
+	authApiRouter.Handle("/chats/{chatId}/messages/forward", canSend(http.HandlerFunc(messageHandler.ForwardMessages))).Methods("POST", "OPTIONS")
+	authApiRouter.Handle("/chats/{chatId}/messages/{messageId}", canSend(http.HandlerFunc(messageHandler.EditMessage))).Methods("PATCH", "OPTIONS")
+	authApiRouter.Handle("/chats/{chatId}/messages/{messageId}", canSend(http.HandlerFunc(messageHandler.DeleteMessage))).Methods("DELETE", "OPTIONS")
+	authApiRouter.HandleFunc("/chats/{chatId}/messages/{messageId}/replies", messageHandler.GetReplies).Methods("GET", "OPTIONS")
 	authApiRouter.HandleFunc("/chats/{chatId}/messages/{messageId}/read", messageHandler.MarkMessageRead).Methods("PATCH", "OPTIONS")
+	authApiRouter.HandleFunc("/chats/{chatId}/messages/{messageId}/listened", messageHandler.MarkMessageListened).Methods("PATCH", "OPTIONS")
 	apiRouter.HandleFunc("/chats/{chatId}/messages/{messageId}/attachments/{filename}", messageHandler.GetAttachment).Methods("GET", "OPTIONS")
 
+	// Sticker routes
+	authApiRouter.HandleFunc("/sticker-packs", stickerHandler.CreatePack).Methods("POST", "OPTIONS")
+	authApiRouter.HandleFunc("/sticker-packs", stickerHandler.GetPacks).Methods("GET", "OPTIONS")
+	authApiRouter.HandleFunc("/sticker-packs/installed", stickerHandler.GetInstalled).Methods("GET", "OPTIONS")
+	authApiRouter.HandleFunc("/sticker-packs/installed/order", stickerHandler.Reorder).Methods("PUT", "OPTIONS")
+	authApiRouter.HandleFunc("/sticker-packs/{packId}", stickerHandler.GetPack).Methods("GET", "OPTIONS")
+	authApiRouter.HandleFunc("/sticker-packs/{packId}/install", stickerHandler.Install).Methods("POST", "OPTIONS")
+	authApiRouter.HandleFunc("/sticker-packs/{packId}/install", stickerHandler.Uninstall).Methods("DELETE", "OPTIONS")
+	authApiRouter.HandleFunc("/stickers/recent", stickerHandler.GetRecent).Methods("GET", "OPTIONS")
+	apiRouter.HandleFunc("/stickers/files/{filename}", stickerHandler.GetStickerFile).Methods("GET", "OPTIONS")
+
+	// GIF routes
+	authApiRouter.HandleFunc("/gifs/search", gifHandler.Search).Methods("GET", "OPTIONS")
+
+	// Sync routes
+	authApiRouter.HandleFunc("/sync", syncHandler.GetDifference).Methods("GET", "OPTIONS")
+
+	// Encryption routes
+	authApiRouter.HandleFunc("/keys/devices/{deviceId}", encryptionHandler.PublishDevice).Methods("PUT", "OPTIONS")
+	authApiRouter.HandleFunc("/keys/devices/{deviceId}", encryptionHandler.GetDevice).Methods("GET", "OPTIONS")
+	authApiRouter.HandleFunc("/keys/devices/{deviceId}", encryptionHandler.DeleteDevice).Methods("DELETE", "OPTIONS")
+	authApiRouter.HandleFunc("/keys/devices/{deviceId}/prekeys", encryptionHandler.UploadPreKeys).Methods("POST", "OPTIONS")
+	authApiRouter.HandleFunc("/users/{userId}/keys", encryptionHandler.GetBundles).Methods("GET", "OPTIONS")
+	authApiRouter.Handle("/chats/{chatId}/encrypted", canSend(http.HandlerFunc(encryptionHandler.SendEncrypted))).Methods("POST", "OPTIONS")
+
+	// Bookmark routes
+	authApiRouter.HandleFunc("/chats/{chatId}/stars", bookmarkHandler.Star).Methods("POST", "OPTIONS")
+	authApiRouter.HandleFunc("/chats/{chatId}/stars/{messageId}", bookmarkHandler.Unstar).Methods("DELETE", "OPTIONS")
+	authApiRouter.HandleFunc("/starred", bookmarkHandler.GetStarred).Methods("GET", "OPTIONS")
+
+	// Report routes
+	authApiRouter.HandleFunc("/reports", reportHandler.Create).Methods("POST", "OPTIONS")
+
+	// Moderation routes
+	moderatorRouter := authApiRouter.PathPrefix("/moderation").Subrouter()
+	moderatorRouter.Use(middleware.Moderator(authHandler.UserRepo, authHandler.Trans))
+	moderatorRouter.HandleFunc("/flags", moderationHandler.GetFlags).Methods("GET", "OPTIONS")
+	moderatorRouter.HandleFunc("/flags/{flagId}", moderationHandler.ReviewFlag).Methods("PATCH", "OPTIONS")
+	moderatorRouter.HandleFunc("/reports", reportHandler.GetQueue).Methods("GET", "OPTIONS")
+	moderatorRouter.HandleFunc("/reports/files/{filename}", reportHandler.GetEvidenceFile).Methods("GET", "OPTIONS")
+	moderatorRouter.HandleFunc("/reports/{reportId}", reportHandler.GetReport).Methods("GET", "OPTIONS")
+	moderatorRouter.HandleFunc("/reports/{reportId}/assign", reportHandler.Assign).Methods("POST", "OPTIONS")
+	moderatorRouter.HandleFunc("/reports/{reportId}/resolve", reportHandler.Resolve).Methods("POST", "OPTIONS")
+	moderatorRouter.HandleFunc("/users/{userId}/restrictions", restrictionHandler.GetForUser).Methods("GET", "OPTIONS")
+	moderatorRouter.HandleFunc("/users/{userId}/restrictions", restrictionHandler.Create).Methods("POST", "OPTIONS")
+	moderatorRouter.HandleFunc("/restrictions/{restrictionId}", restrictionHandler.Lift).Methods("DELETE", "OPTIONS")
+
 	// User routes
 	authApiRouter.HandleFunc("/users", userHandler.GetUsers).Methods("GET", "OPTIONS")
 	apiRouter.HandleFunc("/users/profile-picture/{filename}", userHandler.GetProfilePicture).Methods("GET", "OPTIONS")
 	authApiRouter.HandleFunc("/users/profile-picture", userHandler.UpdateProfilePicture).Methods("PATCH", "OPTIONS")
 	authApiRouter.HandleFunc("/users/profile-picture", userHandler.DeleteProfilePicture).Methods("DELETE", "OPTIONS")
 	authApiRouter.HandleFunc("/users/personal-info", userHandler.ChangePersonalInfo).Methods("PATCH", "OPTIONS")
+	authApiRouter.HandleFunc("/users/privacy", userHandler.ChangePrivacy).Methods("PATCH", "OPTIONS")
 
 	// WebSocket routes
 	r.HandleFunc("/ws", wsHandler.HandleWebSocket).Methods("GET")
@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/drTragger/messenger-backend/internal/models"
+	"github.com/drTragger/messenger-backend/internal/repository"
+	"github.com/drTragger/messenger-backend/internal/requests"
+	"github.com/drTragger/messenger-backend/internal/responses"
+	"github.com/drTragger/messenger-backend/internal/services"
+	"github.com/drTragger/messenger-backend/internal/storage"
+	"github.com/drTragger/messenger-backend/internal/utils"
+	"github.com/gorilla/mux"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+const MaxStickerPackSize = 64 // MegaBytes
+
+type StickerHandler struct {
+	StickerService *services.StickerService
+	MsgService     *services.MessageService
+	StickerRepo    *repository.StickerRepository
+	ChatRepo       *repository.ChatRepository
+	Storage        storage.Storage
+	Trans          *utils.Translator
+}
+
+func NewStickerHandler(
+	stickerService *services.StickerService,
+	msgService *services.MessageService,
+	stickerRepo *repository.StickerRepository,
+	chatRepo *repository.ChatRepository,
+	storage storage.Storage,
+	trans *utils.Translator,
+) *StickerHandler {
+	return &StickerHandler{
+		StickerService: stickerService,
+		MsgService:     msgService,
+		StickerRepo:    stickerRepo,
+		ChatRepo:       chatRepo,
+		Storage:        storage,
+		Trans:          trans,
+	}
+}
+
+// CreatePack creates a sticker pack from the uploaded "stickers" files.
+// The "emojis" form values hold the space separated emojis of each file, in the same order.
+func (h *StickerHandler) CreatePack(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseMultipartForm(MaxStickerPackSize << 20); err != nil {
+		responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), "Failed to parse form data")
+		return
+	}
+
+	payload := requests.CreateStickerPackRequest{
+		Name:  r.FormValue("name"),
+		Title: r.FormValue("title"),
+	}
+	if err := utils.ValidateStruct(&payload); err != nil {
+		responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), utils.FormatValidationError(r, err, h.Trans))
+		return
+	}
+
+	files := r.MultipartForm.File["stickers"]
+	if len(files) == 0 || len(files) > services.MaxStickersPerPack {
+		responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), map[string]string{
+			"stickers": h.Trans.Translate(r, "validation.stickers", map[string]interface{}{"Param": services.MaxStickersPerPack}),
+		})
+		return
+	}
+
+	exists, err := h.StickerRepo.PackNameExists(payload.Name)
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+	if exists {
+		responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), map[string]string{
+			"name": h.Trans.Translate(r, "validation.unique", nil),
+		})
+		return
+	}
+
+	userID := r.Context().Value("user_id").(uint)
+	pack, err := h.StickerService.CreatePack(&models.StickerPack{
+		Name:     payload.Name,
+		Title:    payload.Title,
+		AuthorID: &userID,
+	}, files, r.MultipartForm.Value["emojis"])
+	if err != nil {
+		if errors.Is(err, services.ErrStickerFile) {
+			responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), map[string]string{
+				"stickers": h.Trans.Translate(r, "validation.sticker_file", nil),
+			})
+			return
+		}
+		if errors.Is(err, services.ErrPackNameTaken) {
+			responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), map[string]string{
+				"name": h.Trans.Translate(r, "validation.unique", nil),
+			})
+			return
+		}
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+
+	responses.SuccessResponse(w, http.StatusCreated, h.Trans.Translate(r, "success.sticker.create_pack", nil), pack)
+}
+
+func (h *StickerHandler) GetPacks(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var err error
+
+	limitStr := query.Get("limit")
+	limit := repository.StickerPacksLimit
+	if limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), "Invalid limit")
+			return
+		}
+	}
+
+	offsetStr := query.Get("offset")
+	offset := repository.StickerPacksOffset
+	if offsetStr != "" {
+		offset, err = strconv.Atoi(offsetStr)
+		if err != nil || offset < 0 {
+			responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), "Invalid offset.")
+			return
+		}
+	}
+
+	packs, err := h.StickerRepo.GetPacks(query.Get("q"), limit, offset)
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+
+	responses.SuccessResponse(w, http.StatusOK, h.Trans.Translate(r, "success.sticker.get_packs", nil), packs)
+}
+
+func (h *StickerHandler) GetPack(w http.ResponseWriter, r *http.Request) {
+	pack, ok := h.getPack(w, r)
+	if !ok {
+		return
+	}
+
+	responses.SuccessResponse(w, http.StatusOK, h.Trans.Translate(r, "success.sticker.get_pack", nil), pack)
+}
+
+func (h *StickerHandler) GetInstalled(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(uint)
+
+	packs, err := h.StickerRepo.GetInstalled(userID)
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+
+	responses.SuccessResponse(w, http.StatusOK, h.Trans.Translate(r, "success.sticker.get_packs", nil), packs)
+}
+
+func (h *StickerHandler) Install(w http.ResponseWriter, r *http.Request) {
+	pack, ok := h.getPack(w, r)
+	if !ok {
+		return
+	}
+
+	userID := r.Context().Value("user_id").(uint)
+	if err := h.StickerRepo.Install(userID, pack.ID); err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+
+	responses.SuccessResponse(w, http.StatusOK, h.Trans.Translate(r, "success.sticker.install", nil), pack)
+}
+
+func (h *StickerHandler) Uninstall(w http.ResponseWriter, r *http.Request) {
+	packID, err := strconv.Atoi(mux.Vars(r)["packId"])
+	if err != nil || packID < 0 {
+		responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), "Invalid pack ID")
+		return
+	}
+
+	userID := r.Context().Value("user_id").(uint)
+	uninstalled, err := h.StickerRepo.Uninstall(userID, uint(packID))
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+	if !uninstalled {
+		responses.ErrorResponse(w, http.StatusNotFound, h.Trans.Translate(r, "errors.not_found", nil), "Sticker pack is not installed")
+		return
+	}
+
+	responses.SuccessResponse(w, http.StatusOK, h.Trans.Translate(r, "success.sticker.uninstall", nil), nil)
+}
+
+func (h *StickerHandler) Reorder(w http.ResponseWriter, r *http.Request) {
+	var payload requests.ReorderStickerPacksRequest
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), err.Error())
+		return
+	}
+
+	if err := utils.ValidateStruct(&payload); err != nil {
+		responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), utils.FormatValidationError(r, err, h.Trans))
+		return
+	}
+
+	userID := r.Context().Value("user_id").(uint)
+	if err := h.StickerService.Reorder(userID, payload.PackIDs); err != nil {
+		if errors.Is(err, repository.ErrPackNotInstalled) {
+			responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), map[string]string{
+				"packIds": h.Trans.Translate(r, "validation.exists", nil),
+			})
+			return
+		}
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+
+	packs, err := h.StickerRepo.GetInstalled(userID)
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+
+	responses.SuccessResponse(w, http.StatusOK, h.Trans.Translate(r, "success.sticker.reorder", nil), packs)
+}
+
+func (h *StickerHandler) GetRecent(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("user_id").(uint)
+
+	stickers, err := h.StickerRepo.GetRecent(userID)
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+
+	responses.SuccessResponse(w, http.StatusOK, h.Trans.Translate(r, "success.sticker.get_recent", nil), stickers)
+}
+
+// Send sends a sticker message. The message references the stored sticker instead of carrying a file.
+func (h *StickerHandler) Send(w http.ResponseWriter, r *http.Request) {
+	var payload requests.SendStickerRequest
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), err.Error())
+		return
+	}
+
+	if err := utils.ValidateStruct(&payload); err != nil {
+		responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), utils.FormatValidationError(r, err, h.Trans))
+		return
+	}
+
+	chat, userID, ok := getParticipantChat(w, r, h.ChatRepo, h.Trans)
+	if !ok {
+		return
+	}
+
+	sticker, err := h.StickerRepo.GetSticker(payload.StickerID)
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+	if sticker == nil {
+		responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), map[string]string{
+			"stickerId": h.Trans.Translate(r, "validation.exists", nil),
+		})
+		return
+	}
+
+	message := &models.Message{
+		SenderID:    userID,
+		RecipientID: otherParticipant(chat, userID),
+		ChatID:      chat.ID,
+		Type:        models.StickerMessage,
+		Sticker:     sticker,
+	}
+	if err := h.MsgService.SendTyped(message, "", utils.GetLocale(r), nil); err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+
+	if err := h.StickerRepo.AddRecent(userID, sticker.ID); err != nil {
+		log.Printf("Error saving recent sticker: %s", err)
+	}
+
+	responses.SuccessResponse(w, http.StatusCreated, h.Trans.Translate(r, "success.sticker.send", nil), message)
+}
+
+func (h *StickerHandler) GetStickerFile(w http.ResponseWriter, r *http.Request) {
+	fileName := mux.Vars(r)["filename"]
+
+	filePath, err := h.Storage.GetFile(storage.StickersDir, fileName)
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusNotFound, h.Trans.Translate(r, "errors.not_found", nil), fmt.Sprintf("File not found: %v", err))
+		return
+	}
+
+	responses.ServeFileResponse(w, r, filePath)
+}
+
+func (h *StickerHandler) getPack(w http.ResponseWriter, r *http.Request) (*models.StickerPack, bool) {
+	packID, err := strconv.Atoi(mux.Vars(r)["packId"])
+	if err != nil || packID < 0 {
+		responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), "Invalid pack ID")
+		return nil, false
+	}
+
+	pack, err := h.StickerRepo.GetPack(uint(packID))
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return nil, false
+	}
+	if pack == nil {
+		responses.ErrorResponse(w, http.StatusNotFound, h.Trans.Translate(r, "errors.not_found", nil), "Sticker pack not found")
+		return nil, false
+	}
+
+	return pack, true
+}
@@ -0,0 +1,344 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"github.com/drTragger/messenger-backend/internal/fakedb"
+	"github.com/drTragger/messenger-backend/internal/models"
+	"github.com/drTragger/messenger-backend/internal/repository"
+	"github.com/drTragger/messenger-backend/internal/services"
+	"github.com/drTragger/messenger-backend/internal/storage"
+	"github.com/lib/pq"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestStickerHandlerCreatePack(t *testing.T) {
+	var stickerIDs int64
+	db, fake := fakedb.Open(t, func(query string, args []driver.Value) fakedb.Result {
+		switch {
+		case strings.Contains(query, "SELECT EXISTS"):
+			return fakedb.Row(args[0] == "taken")
+		case strings.Contains(query, "INSERT INTO sticker_packs"):
+			return fakedb.Row(int64(5), time.Now(), time.Now())
+		case strings.Contains(query, "INSERT INTO stickers"):
+			return fakedb.Row(atomic.AddInt64(&stickerIDs, 1))
+		}
+		return fakedb.Result{Err: fmt.Errorf("unexpected query: %s", query)}
+	})
+	dir := t.TempDir()
+	handler := newTestStickerHandler(t, db, dir)
+
+	body, contentType := stickerPackForm(t, "cats", []string{"happy.webp", "Sad.PNG"}, []string{"😀 😺", "😿"})
+	r := httptest.NewRequest(http.MethodPost, "/stickers/packs", body)
+	r.Header.Set("Content-Type", contentType)
+	w := serve(handler.CreatePack, r, 9, nil)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusCreated, w.Body)
+	}
+
+	var response struct {
+		Data *models.StickerPack `json:"data"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	pack := response.Data
+	if pack.ID != 5 || pack.Name != "cats" || pack.AuthorID == nil || *pack.AuthorID != 9 {
+		t.Errorf("pack = %+v, want pack 5 named cats by user 9", pack)
+	}
+	if len(pack.Stickers) != 2 {
+		t.Fatalf("%d stickers, want 2", len(pack.Stickers))
+	}
+	for i, emojis := range [][]string{{"😀", "😺"}, {"😿"}} {
+		sticker := pack.Stickers[i]
+		if sticker.Position != i || fmt.Sprint(sticker.Emojis) != fmt.Sprint(emojis) {
+			t.Errorf("sticker %d = %+v, want position %d and emojis %v", i, sticker, i, emojis)
+		}
+		if _, err := os.Stat(filepath.Join(dir, storage.StickersDir, sticker.FilePath)); err != nil {
+			t.Errorf("sticker %d file: %v", i, err)
+		}
+	}
+	if ext := filepath.Ext(pack.Stickers[1].FilePath); ext != ".png" {
+		t.Errorf("second sticker stored as %q, want a lower case .png", ext)
+	}
+	if stickers := fake.Executed("INSERT INTO stickers"); len(stickers) != 2 {
+		t.Errorf("%d stickers stored, want 2", len(stickers))
+	}
+	if fake.Commits != 1 {
+		t.Errorf("%d commits, want the pack and its stickers in one transaction", fake.Commits)
+	}
+}
+
+func TestStickerHandlerCreatePackNameTakenConcurrently(t *testing.T) {
+	db, fake := fakedb.Open(t, func(query string, args []driver.Value) fakedb.Result {
+		switch {
+		case strings.Contains(query, "SELECT EXISTS"):
+			return fakedb.Row(false)
+		case strings.Contains(query, "INSERT INTO sticker_packs"):
+			return fakedb.Result{Err: &pq.Error{Code: "23505"}}
+		}
+		return fakedb.Result{Err: fmt.Errorf("unexpected query: %s", query)}
+	})
+	dir := t.TempDir()
+	handler := newTestStickerHandler(t, db, dir)
+
+	body, contentType := stickerPackForm(t, "cats", []string{"happy.webp"}, nil)
+	r := httptest.NewRequest(http.MethodPost, "/stickers/packs", body)
+	r.Header.Set("Content-Type", contentType)
+	w := serve(handler.CreatePack, r, 9, nil)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusUnprocessableEntity, w.Body)
+	}
+
+	var response struct {
+		Fields map[string]string `json:"fields"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := response.Fields["name"]; !ok {
+		t.Errorf("fields = %v, want an error for name", response.Fields)
+	}
+	if fake.Commits != 0 || fake.Rollbacks != 1 {
+		t.Errorf("%d commits and %d rollbacks, want the transaction rolled back", fake.Commits, fake.Rollbacks)
+	}
+	if files, _ := os.ReadDir(filepath.Join(dir, storage.StickersDir)); len(files) != 0 {
+		t.Errorf("%d sticker files left, want none", len(files))
+	}
+}
+
+func TestStickerHandlerCreatePackRejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		pack  string
+		files []string
+		field string
+	}{
+		{name: "taken name", pack: "taken", files: []string{"happy.webp"}, field: "name"},
+		{name: "invalid name", pack: "cat pack", files: []string{"happy.webp"}, field: "name"},
+		{name: "no stickers", pack: "cats", files: nil, field: "stickers"},
+		{name: "unsupported file", pack: "cats", files: []string{"happy.webp", "funny.gif"}, field: "stickers"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := fakedb.Open(t, func(query string, args []driver.Value) fakedb.Result {
+				if strings.Contains(query, "SELECT EXISTS") {
+					return fakedb.Row(args[0] == "taken")
+				}
+				return fakedb.Result{Err: fmt.Errorf("unexpected query: %s", query)}
+			})
+			dir := t.TempDir()
+			handler := newTestStickerHandler(t, db, dir)
+
+			body, contentType := stickerPackForm(t, tt.pack, tt.files, nil)
+			r := httptest.NewRequest(http.MethodPost, "/stickers/packs", body)
+			r.Header.Set("Content-Type", contentType)
+			w := serve(handler.CreatePack, r, 9, nil)
+			if w.Code != http.StatusUnprocessableEntity {
+				t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusUnprocessableEntity, w.Body)
+			}
+
+			var response struct {
+				Fields map[string]string `json:"fields"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := response.Fields[tt.field]; !ok {
+				t.Errorf("fields = %v, want an error for %s", response.Fields, tt.field)
+			}
+			if packs := fake.Executed("INSERT INTO sticker_packs"); len(packs) != 0 {
+				t.Errorf("%d packs stored, want none", len(packs))
+			}
+			if files, _ := os.ReadDir(filepath.Join(dir, storage.StickersDir)); len(files) != 0 {
+				t.Errorf("%d sticker files stored, want none", len(files))
+			}
+		})
+	}
+}
+
+func TestStickerHandlerInstall(t *testing.T) {
+	db, fake := fakedb.Open(t, func(query string, args []driver.Value) fakedb.Result {
+		switch {
+		case strings.Contains(query, "FROM sticker_packs"):
+			if args[0] != int64(5) {
+				return fakedb.Result{Columns: packColumns}
+			}
+			return packRows(5)
+		case strings.Contains(query, "FROM stickers"):
+			return stickerRows(5)
+		case strings.Contains(query, "INSERT INTO user_sticker_packs"):
+			return fakedb.Result{Affected: 1}
+		}
+		return fakedb.Result{Err: fmt.Errorf("unexpected query: %s", query)}
+	})
+	handler := newTestStickerHandler(t, db, t.TempDir())
+
+	w := serve(handler.Install, httptest.NewRequest(http.MethodPost, "/stickers/packs/5/install", nil), 9, map[string]string{"packId": "5"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	var response struct {
+		Data *models.StickerPack `json:"data"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Data.ID != 5 || len(response.Data.Stickers) != 1 {
+		t.Errorf("pack = %+v, want pack 5 with its sticker", response.Data)
+	}
+
+	installs := fake.Executed("INSERT INTO user_sticker_packs")
+	if len(installs) != 1 || fmt.Sprint(installs[0].Args) != fmt.Sprint([]driver.Value{int64(9), int64(5)}) {
+		t.Errorf("installs = %v, want pack 5 installed for user 9", installs)
+	}
+
+	w = serve(handler.Install, httptest.NewRequest(http.MethodPost, "/stickers/packs/6/install", nil), 9, map[string]string{"packId": "6"})
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unknown pack: status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+	if installs := fake.Executed("INSERT INTO user_sticker_packs"); len(installs) != 1 {
+		t.Errorf("unknown pack installed")
+	}
+}
+
+func TestStickerHandlerReorder(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		installed int64
+		status    int
+		commits   int
+	}{
+		{name: "installed packs", body: `{"packIds": [7, 5]}`, installed: 2, status: http.StatusOK, commits: 1},
+		{name: "pack not installed", body: `{"packIds": [7, 8]}`, installed: 1, status: http.StatusUnprocessableEntity},
+		{name: "no packs", body: `{"packIds": []}`, status: http.StatusUnprocessableEntity},
+		{name: "invalid pack ID", body: `{"packIds": [0]}`, status: http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := fakedb.Open(t, func(query string, args []driver.Value) fakedb.Result {
+				switch {
+				case strings.Contains(query, "UNNEST"):
+					return fakedb.Result{Affected: tt.installed}
+				case strings.Contains(query, "UPDATE user_sticker_packs"):
+					return fakedb.Result{Affected: 1}
+				case strings.Contains(query, "FROM user_sticker_packs up"):
+					return packRows(7, 5, 6)
+				case strings.Contains(query, "FROM stickers"):
+					return stickerRows(7, 5, 6)
+				}
+				return fakedb.Result{Err: fmt.Errorf("unexpected query: %s", query)}
+			})
+			handler := newTestStickerHandler(t, db, t.TempDir())
+
+			r := httptest.NewRequest(http.MethodPut, "/stickers/installed/order", strings.NewReader(tt.body))
+			w := serve(handler.Reorder, r, 9, nil)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+			if fake.Commits != tt.commits {
+				t.Errorf("%d commits, want %d", fake.Commits, tt.commits)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			// The listed packs go first, the others keep their order after them
+			updates := fake.Executed("UPDATE user_sticker_packs")
+			if len(updates) != 2 || updates[0].Args[1] != "{7,5}" || updates[1].Args[2] != int64(2) {
+				t.Errorf("updates = %v, want packs 7 and 5 first and the others from position 2", updates)
+			}
+
+			var response struct {
+				Data []*models.StickerPack `json:"data"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+			ids := make([]uint, 0, len(response.Data))
+			for _, pack := range response.Data {
+				ids = append(ids, pack.ID)
+			}
+			if fmt.Sprint(ids) != "[7 5 6]" {
+				t.Errorf("installed packs = %v, want [7 5 6]", ids)
+			}
+		})
+	}
+}
+
+func newTestStickerHandler(t *testing.T, db *sql.DB, dir string) *StickerHandler {
+	t.Helper()
+
+	storageInst, err := storage.NewStorage(&storage.Config{Type: storage.LocalStorageType, LocalPath: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stickerRepo := repository.NewStickerRepository(db)
+	return NewStickerHandler(
+		services.NewStickerService(db, stickerRepo, storageInst),
+		newTestMessageService(db),
+		stickerRepo,
+		repository.NewChatRepository(db),
+		storageInst,
+		newTestTranslator(),
+	)
+}
+
+// stickerPackForm builds the multipart form of a new pack with a small file for every file name.
+func stickerPackForm(t *testing.T, name string, files, emojis []string) (*bytes.Buffer, string) {
+	t.Helper()
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	writer.WriteField("name", name)
+	writer.WriteField("title", "Pack "+name)
+	for _, file := range files {
+		part, err := writer.CreateFormFile("stickers", file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write([]byte("sticker " + file))
+	}
+	for _, emoji := range emojis {
+		writer.WriteField("emojis", emoji)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return body, writer.FormDataContentType()
+}
+
+var packColumns = []string{"id", "name", "title", "author_id", "created_at", "updated_at"}
+
+// packRows answers a query for sticker packs with the packs of the given IDs, in that order.
+func packRows(ids ...int64) fakedb.Result {
+	result := fakedb.Result{Columns: packColumns}
+	for _, id := range ids {
+		name := fmt.Sprintf("pack%d", id)
+		result.Rows = append(result.Rows, []driver.Value{id, name, "Pack " + name, int64(1), time.Now(), time.Now()})
+	}
+	return result
+}
+
+// stickerRows answers a query for the stickers of packs with one sticker for every pack.
+func stickerRows(packIDs ...int64) fakedb.Result {
+	result := fakedb.Result{Columns: []string{"id", "pack_id", "file_path", "emojis", "position"}}
+	for _, packID := range packIDs {
+		result.Rows = append(result.Rows, []driver.Value{packID * 10, packID, fmt.Sprintf("%d.webp", packID), "{😀}", int64(0)})
+	}
+	return result
+}
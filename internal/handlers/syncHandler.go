@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"github.com/drTragger/messenger-backend/internal/models"
+	"github.com/drTragger/messenger-backend/internal/repository"
+	"github.com/drTragger/messenger-backend/internal/responses"
+	"github.com/drTragger/messenger-backend/internal/utils"
+	"net/http"
+	"strconv"
+)
+
+type SyncHandler struct {
+	UpdateRepo *repository.UpdateRepository
+	Trans      *utils.Translator
+}
+
+func NewSyncHandler(updateRepo *repository.UpdateRepository, trans *utils.Translator) *SyncHandler {
+	return &SyncHandler{
+		UpdateRepo: updateRepo,
+		Trans:      trans,
+	}
+}
+
+// GetDifference returns the updates the user missed after the sequence number given in "since".
+// When the updates are no longer kept, or the client is ahead of the server, it asks for a full resync.
+func (h *SyncHandler) GetDifference(w http.ResponseWriter, r *http.Request) {
+	sinceStr := r.URL.Query().Get("since")
+	if sinceStr == "" {
+		responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), map[string]string{
+			"since": h.Trans.Translate(r, "validation.required", nil),
+		})
+		return
+	}
+	since, err := strconv.ParseUint(sinceStr, 10, 64)
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), "Invalid since")
+		return
+	}
+
+	userID := r.Context().Value("user_id").(uint)
+
+	seq, err := h.UpdateRepo.GetSeq(userID)
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+
+	difference := &models.Difference{
+		Seq:     seq,
+		Updates: make([]*models.Update, 0),
+	}
+	if since > seq || seq-since > repository.UpdateLogSize {
+		difference.Resync = true
+		responses.SuccessResponse(w, http.StatusOK, h.Trans.Translate(r, "success.sync.resync", nil), difference)
+		return
+	}
+
+	updates, err := h.UpdateRepo.GetSince(userID, since)
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+	// The log is trimmed in batches, so the first missed update may already be gone
+	if since < seq && (len(updates) == 0 || updates[0].Seq != since+1) {
+		difference.Resync = true
+		responses.SuccessResponse(w, http.StatusOK, h.Trans.Translate(r, "success.sync.resync", nil), difference)
+		return
+	}
+
+	difference.Updates = updates
+	responses.SuccessResponse(w, http.StatusOK, h.Trans.Translate(r, "success.sync.get", nil), difference)
+}
@@ -232,3 +232,37 @@ func (h *UserHandler) ChangePersonalInfo(w http.ResponseWriter, r *http.Request)
 
 	responses.SuccessResponse(w, http.StatusOK, h.Trans.Translate(r, "success.user.change_personal_info", nil), user)
 }
+
+func (h *UserHandler) ChangePrivacy(w http.ResponseWriter, r *http.Request) {
+	var payload requests.ChangePrivacyRequest
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		responses.ErrorResponse(w, http.StatusBadRequest, h.Trans.Translate(r, "errors.input", nil), err.Error())
+		return
+	}
+
+	if err := utils.ValidateStruct(&payload); err != nil {
+		responses.ValidationResponse(w, h.Trans.Translate(r, "errors.validation", nil), utils.FormatValidationError(r, err, h.Trans))
+		return
+	}
+
+	userID := r.Context().Value("user_id").(uint)
+
+	user, err := h.UserRepo.GetUserByID(userID)
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+	if user == nil {
+		responses.ErrorResponse(w, http.StatusNotFound, h.Trans.Translate(r, "errors.not_found", nil), "User not found")
+		return
+	}
+
+	err = h.UserRepo.UpdateHideForwardSender(userID, *payload.HideForwardSender)
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Trans.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+	user.HideForwardSender = *payload.HideForwardSender
+
+	responses.SuccessResponse(w, http.StatusOK, h.Trans.Translate(r, "success.user.change_privacy", nil), user)
+}
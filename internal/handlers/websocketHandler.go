@@ -2,6 +2,8 @@ package handlers
 
 import (
 	"errors"
+	"github.com/drTragger/messenger-backend/internal/middleware"
+	"github.com/drTragger/messenger-backend/internal/models"
 	"github.com/drTragger/messenger-backend/internal/repository"
 	"github.com/drTragger/messenger-backend/internal/responses"
 	"github.com/drTragger/messenger-backend/internal/utils"
@@ -20,18 +22,26 @@ var upgrader = websocket.Upgrader{
 }
 
 type WebSocketHandler struct {
-	ClientManager *ws.ClientManager
-	TokenRepo     *repository.TokenRepository
-	Translator    *utils.Translator
-	Secret        string
+	ClientManager   *ws.ClientManager
+	TokenRepo       *repository.TokenRepository
+	RestrictionRepo *repository.RestrictionRepository
+	Translator      *utils.Translator
+	Secret          string
 }
 
-func NewWebSocketHandler(clientManager *ws.ClientManager, tokenRepo *repository.TokenRepository, translator *utils.Translator, secret string) *WebSocketHandler {
+func NewWebSocketHandler(
+	clientManager *ws.ClientManager,
+	tokenRepo *repository.TokenRepository,
+	restrictionRepo *repository.RestrictionRepository,
+	translator *utils.Translator,
+	secret string,
+) *WebSocketHandler {
 	return &WebSocketHandler{
-		ClientManager: clientManager,
-		TokenRepo:     tokenRepo,
-		Translator:    translator,
-		Secret:        secret,
+		ClientManager:   clientManager,
+		TokenRepo:       tokenRepo,
+		RestrictionRepo: restrictionRepo,
+		Translator:      translator,
+		Secret:          secret,
 	}
 }
 
@@ -43,6 +53,16 @@ func (h *WebSocketHandler) HandleWebSocket(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	suspension, err := h.RestrictionRepo.GetActive(userID, models.SuspensionRestriction)
+	if err != nil {
+		responses.ErrorResponse(w, http.StatusInternalServerError, h.Translator.Translate(r, "errors.server", nil), err.Error())
+		return
+	}
+	if suspension != nil {
+		middleware.RestrictedResponse(w, r, h.Translator, suspension)
+		return
+	}
+
 	// Upgrade to WebSocket
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"context"
+	"github.com/drTragger/messenger-backend/internal/models"
 	"github.com/drTragger/messenger-backend/internal/repository"
 	"github.com/drTragger/messenger-backend/internal/responses"
 	"github.com/drTragger/messenger-backend/internal/utils"
@@ -12,7 +13,13 @@ import (
 	"github.com/golang-jwt/jwt/v4"
 )
 
-func Auth(secret string, tokenRepo *repository.TokenRepository, userRepo *repository.UserRepository, trans *utils.Translator) func(http.Handler) http.Handler {
+func Auth(
+	secret string,
+	tokenRepo *repository.TokenRepository,
+	userRepo *repository.UserRepository,
+	restrictionRepo *repository.RestrictionRepository,
+	trans *utils.Translator,
+) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			tokenString := strings.TrimSpace(strings.Replace(r.Header.Get("Authorization"), "Bearer", "", 1))
@@ -45,6 +52,17 @@ func Auth(secret string, tokenRepo *repository.TokenRepository, userRepo *reposi
 				return
 			}
 
+			// Suspended users keep their tokens but can't use them until the suspension expires
+			suspension, err := restrictionRepo.GetActive(userID, models.SuspensionRestriction)
+			if err != nil {
+				responses.ErrorResponse(w, http.StatusInternalServerError, trans.Translate(r, "errors.server", nil), err.Error())
+				return
+			}
+			if suspension != nil {
+				RestrictedResponse(w, r, trans, suspension)
+				return
+			}
+
 			// Update last_seen in the database
 			err = userRepo.UpdateLastSeen(userID)
 			if err != nil {
@@ -0,0 +1,174 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/drTragger/messenger-backend/internal/models"
+	"github.com/drTragger/messenger-backend/internal/repository"
+	"github.com/drTragger/messenger-backend/internal/responses"
+	"github.com/drTragger/messenger-backend/internal/utils"
+	"hash"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+const (
+	IdempotencyHeader         = "Idempotency-Key"
+	IdempotencyReplayedHeader = "Idempotent-Replayed"
+	MaxIdempotencyKeyLength   = 255
+	// IdempotencyLockTimeout frees the key of a request that never finished, e.g. because the server stopped
+	IdempotencyLockTimeout = 10 * time.Minute
+)
+
+// Idempotency makes POST, PATCH and DELETE requests that carry an Idempotency-Key header safe to retry.
+// The response to the first request is stored for ttl and replayed for retries with the same key.
+// A retry with a different method, path or body, or one that arrives while the first request is still
+// in flight, gets 409. Server errors are not stored so that the request can be retried.
+// Keys are scoped to the user, so the middleware must run after Auth.
+func Idempotency(repo *repository.IdempotencyRepository, ttl time.Duration, trans *utils.Translator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyHeader)
+			if key == "" || !acceptsIdempotencyKey(r.Method) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > MaxIdempotencyKeyLength {
+				responses.ErrorResponse(w, http.StatusBadRequest, trans.Translate(r, "errors.idempotency.key", nil), "Idempotency key is too long")
+				return
+			}
+
+			userID := r.Context().Value("user_id").(uint)
+
+			stored, err := repo.Get(r.Context(), userID, key)
+			if err != nil {
+				responses.ErrorResponse(w, http.StatusInternalServerError, trans.Translate(r, "errors.server", nil), err.Error())
+				return
+			}
+			if stored != nil {
+				replay(w, r, stored, trans)
+				return
+			}
+
+			reserved, err := repo.Reserve(r.Context(), userID, key, IdempotencyLockTimeout)
+			if err != nil {
+				responses.ErrorResponse(w, http.StatusInternalServerError, trans.Translate(r, "errors.server", nil), err.Error())
+				return
+			}
+			if !reserved {
+				responses.ErrorResponse(w, http.StatusConflict, trans.Translate(r, "errors.idempotency.in_progress", nil), "Request is still in progress")
+				return
+			}
+
+			// The body is hashed while the handler reads it, so large uploads aren't buffered
+			hasher := newFingerprint(r)
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.TeeReader(r.Body, hasher), r.Body}
+			recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+
+			// The client may be gone by the time the handler is done, which is when the response matters most
+			ctx := context.Background()
+			saved := false
+			defer func() {
+				if !saved {
+					if err := repo.Release(ctx, userID, key); err != nil {
+						log.Printf("Error releasing idempotency key: %s", err)
+					}
+				}
+			}()
+
+			next.ServeHTTP(recorder, r)
+
+			if recorder.status >= http.StatusInternalServerError {
+				return
+			}
+			if _, err := io.Copy(io.Discard, r.Body); err != nil {
+				return
+			}
+
+			response := &models.IdempotentResponse{
+				Fingerprint: hex.EncodeToString(hasher.Sum(nil)),
+				Completed:   true,
+				Status:      recorder.status,
+				Header:      recorder.header,
+				Body:        recorder.body.Bytes(),
+			}
+			if err := repo.Save(ctx, userID, key, response, ttl); err != nil {
+				log.Printf("Error saving idempotent response: %s", err)
+				return
+			}
+			saved = true
+		})
+	}
+}
+
+// replay writes the stored response if the retry matches the original request.
+func replay(w http.ResponseWriter, r *http.Request, stored *models.IdempotentResponse, trans *utils.Translator) {
+	if !stored.Completed {
+		responses.ErrorResponse(w, http.StatusConflict, trans.Translate(r, "errors.idempotency.in_progress", nil), "Request is still in progress")
+		return
+	}
+
+	hasher := newFingerprint(r)
+	if _, err := io.Copy(hasher, r.Body); err != nil {
+		responses.ErrorResponse(w, http.StatusBadRequest, trans.Translate(r, "errors.input", nil), err.Error())
+		return
+	}
+	if hex.EncodeToString(hasher.Sum(nil)) != stored.Fingerprint {
+		responses.ErrorResponse(w, http.StatusConflict, trans.Translate(r, "errors.idempotency.conflict", nil), "Idempotency key was used for a different request")
+		return
+	}
+
+	for name, values := range stored.Header {
+		w.Header()[name] = values
+	}
+	w.Header().Set(IdempotencyReplayedHeader, "true")
+	w.WriteHeader(stored.Status)
+	if _, err := w.Write(stored.Body); err != nil {
+		log.Printf("Error replaying idempotent response: %s", err)
+	}
+}
+
+// newFingerprint starts a hash of the request with its method and path; the body is written to it afterwards.
+func newFingerprint(r *http.Request) hash.Hash {
+	hasher := sha256.New()
+	hasher.Write([]byte(r.Method + " " + r.URL.RequestURI() + "\n"))
+	return hasher
+}
+
+func acceptsIdempotencyKey(method string) bool {
+	return method == http.MethodPost || method == http.MethodPatch || method == http.MethodDelete
+}
+
+// responseRecorder copies the response to a buffer while it is written to the client.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	header      http.Header
+	body        bytes.Buffer
+	wroteHeader bool
+}
+
+func (rr *responseRecorder) WriteHeader(status int) {
+	if rr.wroteHeader {
+		return
+	}
+	rr.status = status
+	rr.header = rr.ResponseWriter.Header().Clone()
+	rr.wroteHeader = true
+	rr.ResponseWriter.WriteHeader(status)
+}
+
+func (rr *responseRecorder) Write(data []byte) (int, error) {
+	if !rr.wroteHeader {
+		rr.WriteHeader(http.StatusOK)
+	}
+	rr.body.Write(data)
+	return rr.ResponseWriter.Write(data)
+}
@@ -0,0 +1,177 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"github.com/drTragger/messenger-backend/internal/fakeredis"
+	"github.com/drTragger/messenger-backend/internal/repository"
+	"github.com/drTragger/messenger-backend/internal/utils"
+	"github.com/go-redis/redis/v8"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyReplaysStoredResponse(t *testing.T) {
+	handler := &countingHandler{status: http.StatusCreated}
+	server := newIdempotentServer(t, handler)
+
+	first := server.do(1, "key-1", `{"content": "hi"}`)
+	retry := server.do(1, "key-1", `{"content": "hi"}`)
+
+	if handler.calls.Load() != 1 {
+		t.Errorf("handler ran %d times, want 1", handler.calls.Load())
+	}
+	if retry.Code != http.StatusCreated || retry.Body.String() != first.Body.String() {
+		t.Errorf("retry = %d %s, want the first response %d %s", retry.Code, retry.Body, first.Code, first.Body)
+	}
+	if retry.Header().Get(IdempotencyReplayedHeader) != "true" || first.Header().Get(IdempotencyReplayedHeader) != "" {
+		t.Errorf("only the retry should be marked replayed")
+	}
+	if retry.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Content-Type = %q, want the stored header", retry.Header().Get("Content-Type"))
+	}
+}
+
+func TestIdempotencyRejectsDifferentRequest(t *testing.T) {
+	handler := &countingHandler{status: http.StatusCreated}
+	server := newIdempotentServer(t, handler)
+
+	server.do(1, "key-1", `{"content": "hi"}`)
+	retry := server.do(1, "key-1", `{"content": "bye"}`)
+
+	if retry.Code != http.StatusConflict {
+		t.Errorf("status = %d, want %d", retry.Code, http.StatusConflict)
+	}
+	if handler.calls.Load() != 1 {
+		t.Errorf("handler ran %d times, want 1", handler.calls.Load())
+	}
+}
+
+func TestIdempotencyRejectsRequestInFlight(t *testing.T) {
+	entered, release := make(chan struct{}), make(chan struct{})
+	handler := &countingHandler{status: http.StatusCreated, entered: entered, release: release}
+	server := newIdempotentServer(t, handler)
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- server.do(1, "key-1", `{"content": "hi"}`)
+	}()
+	<-entered
+
+	retry := server.do(1, "key-1", `{"content": "hi"}`)
+	if retry.Code != http.StatusConflict {
+		t.Errorf("retry while in flight: status = %d, want %d", retry.Code, http.StatusConflict)
+	}
+
+	close(release)
+	if first := <-done; first.Code != http.StatusCreated {
+		t.Errorf("first request: status = %d, want %d", first.Code, http.StatusCreated)
+	}
+	if retry := server.do(1, "key-1", `{"content": "hi"}`); retry.Header().Get(IdempotencyReplayedHeader) != "true" {
+		t.Errorf("retry after the first request finished was not replayed")
+	}
+}
+
+func TestIdempotencyReleasesKeyAfterServerError(t *testing.T) {
+	handler := &countingHandler{status: http.StatusInternalServerError}
+	server := newIdempotentServer(t, handler)
+
+	if failed := server.do(1, "key-1", `{"content": "hi"}`); failed.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want %d", failed.Code, http.StatusInternalServerError)
+	}
+
+	handler.status = http.StatusCreated
+	retry := server.do(1, "key-1", `{"content": "hi"}`)
+	if retry.Code != http.StatusCreated || retry.Header().Get(IdempotencyReplayedHeader) != "" {
+		t.Errorf("retry = %d replayed %q, want the request to run again", retry.Code, retry.Header().Get(IdempotencyReplayedHeader))
+	}
+	if handler.calls.Load() != 2 {
+		t.Errorf("handler ran %d times, want 2", handler.calls.Load())
+	}
+}
+
+func TestIdempotencyScopesKeysToUser(t *testing.T) {
+	handler := &countingHandler{status: http.StatusCreated}
+	server := newIdempotentServer(t, handler)
+
+	server.do(1, "key-1", `{"content": "hi"}`)
+	other := server.do(2, "key-1", `{"content": "hi"}`)
+
+	if other.Header().Get(IdempotencyReplayedHeader) != "" {
+		t.Errorf("another user got the stored response")
+	}
+	if handler.calls.Load() != 2 {
+		t.Errorf("handler ran %d times, want 2", handler.calls.Load())
+	}
+}
+
+func TestIdempotencyIgnoresRequestsWithoutKey(t *testing.T) {
+	handler := &countingHandler{status: http.StatusCreated}
+	server := newIdempotentServer(t, handler)
+
+	server.do(1, "", `{"content": "hi"}`)
+	server.do(1, "", `{"content": "hi"}`)
+	if handler.calls.Load() != 2 {
+		t.Errorf("handler ran %d times, want 2", handler.calls.Load())
+	}
+
+	if long := server.do(1, strings.Repeat("k", MaxIdempotencyKeyLength+1), `{}`); long.Code != http.StatusBadRequest {
+		t.Errorf("key too long: status = %d, want %d", long.Code, http.StatusBadRequest)
+	}
+}
+
+type idempotentServer struct {
+	handler http.Handler
+}
+
+func newIdempotentServer(t *testing.T, next http.Handler) *idempotentServer {
+	t.Helper()
+
+	client := redis.NewClient(&redis.Options{Addr: fakeredis.Start(t)})
+	t.Cleanup(func() { client.Close() })
+
+	repo := repository.NewIdempotencyRepository(client)
+	return &idempotentServer{
+		handler: Idempotency(repo, time.Hour, utils.NewTranslator("../.."))(next),
+	}
+}
+
+// do sends a POST request of the user through the middleware, with the key unless it is empty.
+func (s *idempotentServer) do(userID uint, key, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/chats/1/messages", strings.NewReader(body))
+	r = r.WithContext(context.WithValue(r.Context(), "user_id", userID))
+	if key != "" {
+		r.Header.Set(IdempotencyHeader, key)
+	}
+
+	w := httptest.NewRecorder()
+	s.handler.ServeHTTP(w, r)
+	return w
+}
+
+// countingHandler echoes the request body with its status and counts its calls. If entered is set,
+// it signals it and waits for release before answering.
+type countingHandler struct {
+	status  int
+	calls   atomic.Int32
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (h *countingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	call := h.calls.Add(1)
+	body, _ := io.ReadAll(r.Body)
+	if h.entered != nil && call == 1 {
+		close(h.entered)
+		<-h.release
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(h.status)
+	fmt.Fprintf(w, `{"call": %d, "body": %s}`, call, body)
+}
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"github.com/drTragger/messenger-backend/internal/repository"
+	"github.com/drTragger/messenger-backend/internal/responses"
+	"github.com/drTragger/messenger-backend/internal/utils"
+	"net/http"
+)
+
+// Moderator lets only moderators through. It must run after Auth.
+func Moderator(userRepo *repository.UserRepository, trans *utils.Translator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID := r.Context().Value("user_id").(uint)
+
+			isModerator, err := userRepo.IsModerator(userID)
+			if err != nil {
+				responses.ErrorResponse(w, http.StatusInternalServerError, trans.Translate(r, "errors.server", nil), err.Error())
+				return
+			}
+			if !isModerator {
+				responses.ErrorResponse(w, http.StatusForbidden, trans.Translate(r, "errors.forbidden", nil), "Moderator access required")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
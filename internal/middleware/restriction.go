@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"fmt"
+	"github.com/drTragger/messenger-backend/internal/models"
+	"github.com/drTragger/messenger-backend/internal/repository"
+	"github.com/drTragger/messenger-backend/internal/responses"
+	"github.com/drTragger/messenger-backend/internal/utils"
+	"net/http"
+	"time"
+)
+
+// Unrestricted rejects users with an active restriction of the given type, such as read-only users on the
+// routes that send messages. It must run after Auth.
+func Unrestricted(restrictionType string, restrictionRepo *repository.RestrictionRepository, trans *utils.Translator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID := r.Context().Value("user_id").(uint)
+
+			restriction, err := restrictionRepo.GetActive(userID, restrictionType)
+			if err != nil {
+				responses.ErrorResponse(w, http.StatusInternalServerError, trans.Translate(r, "errors.server", nil), err.Error())
+				return
+			}
+			if restriction != nil {
+				RestrictedResponse(w, r, trans, restriction)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RestrictedResponse tells a restricted user what they can't do, why and until when.
+func RestrictedResponse(w http.ResponseWriter, r *http.Request, trans *utils.Translator, restriction *models.Restriction) {
+	message := trans.Translate(r, "errors.restriction."+restriction.Type, map[string]interface{}{
+		"Reason":    restriction.Reason,
+		"ExpiresAt": restriction.ExpiresAt.UTC().Format(time.RFC1123),
+	})
+	responses.ErrorResponse(w, http.StatusForbidden, message, fmt.Sprintf("User is restricted (%s) until %s", restriction.Type, restriction.ExpiresAt.UTC().Format(time.RFC3339)))
+}
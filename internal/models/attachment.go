@@ -2,16 +2,26 @@ package models
 
 import "time"
 
+const (
+	FileAttachment  = "file"
+	VoiceAttachment = "voice"
+)
+
+// Attachment is a file sent with a message. Duration, Waveform and ListenedAt are only set for voice attachments.
 type Attachment struct {
-	ID          uint      `json:"id"`
-	MessageID   uint      `json:"messageId"`
-	FileName    string    `json:"fileName"`
-	FilePath    string    `json:"filePath"`
-	FileType    string    `json:"fileType"`
-	FileSize    int64     `json:"fileSize"`
-	ThumbnailID uint      `json:"thumbnailId"`
-	CreatedAt   time.Time `json:"createdAt"`
-	UpdatedAt   time.Time `json:"updatedAt"`
+	ID          uint       `json:"id"`
+	MessageID   uint       `json:"messageId"`
+	FileName    string     `json:"fileName"`
+	FilePath    string     `json:"filePath"`
+	FileType    string     `json:"fileType"`
+	FileSize    int64      `json:"fileSize"`
+	Type        string     `json:"type"`
+	Duration    *int       `json:"duration,omitempty"` // Milliseconds
+	Waveform    []int64    `json:"waveform,omitempty"`
+	ListenedAt  *time.Time `json:"listenedAt,omitempty"`
+	ThumbnailID uint       `json:"thumbnailId"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
 
 	Thumbnail *Thumbnail `json:"thumbnail"`
 }
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// Bookmark is a message the user starred to come back to later.
+type Bookmark struct {
+	ID        uint      `json:"id"`
+	UserID    uint      `json:"userId"`
+	MessageID uint      `json:"messageId"`
+	Tags      []string  `json:"tags"`
+	CreatedAt time.Time `json:"createdAt"`
+
+	Message *Message `json:"message,omitempty"`
+}
@@ -3,14 +3,21 @@ package models
 import "time"
 
 type Chat struct {
-	ID            uint      `json:"id"`
-	User1ID       uint      `json:"user1Id"`
-	User2ID       uint      `json:"user2Id"`
-	LastMessageID *uint     `json:"lastMessageId"`
-	CreatedAt     time.Time `json:"createdAt"`
-	UpdatedAt     time.Time `json:"updatedAt"`
+	ID                 uint      `json:"id"`
+	User1ID            uint      `json:"user1Id"`
+	User2ID            uint      `json:"user2Id"`
+	LastMessageID      *uint     `json:"lastMessageId"`
+	CreatedAt          time.Time `json:"createdAt"`
+	UpdatedAt          time.Time `json:"updatedAt"`
+	UnreadMentionCount int       `json:"unreadMentionCount"`
 
-	User1       *User    `json:"user1"`
-	User2       *User    `json:"user2"`
-	LastMessage *Message `json:"lastMessage"`
+	User1         *User          `json:"user1"`
+	User2         *User          `json:"user2"`
+	LastMessage   *Message       `json:"lastMessage"`
+	PinnedMessage *PinnedMessage `json:"pinnedMessage,omitempty"`
+}
+
+// IsSaved reports whether the chat is the user's Saved Messages, a chat with themselves.
+func (c *Chat) IsSaved() bool {
+	return c.User1ID == c.User2ID
 }
@@ -0,0 +1,15 @@
+package models
+
+// Contact is a contact card shared in a message. UserID and User are set
+// when the phone number belongs to a registered user.
+type Contact struct {
+	ID        uint    `json:"-"`
+	MessageID uint    `json:"messageId"`
+	FirstName string  `json:"firstName"`
+	LastName  *string `json:"lastName"`
+	Phone     string  `json:"phone"`
+	UserID    *uint   `json:"userId"`
+	VCard     *string `json:"vcard,omitempty"`
+
+	User *User `json:"user,omitempty"`
+}
@@ -0,0 +1,62 @@
+package models
+
+import "time"
+
+// Types of the ciphertexts in an EncryptedEnvelope
+const (
+	PreKeyCiphertext  = "prekey"  // Starts a session with a prekey bundle
+	MessageCiphertext = "message" // Continues an established session
+)
+
+// DeviceKeys are the public keys a device publishes so that others can start encrypted sessions with it.
+type DeviceKeys struct {
+	ID             uint          `json:"-"`
+	UserID         uint          `json:"userId"`
+	DeviceID       uint          `json:"deviceId"`
+	RegistrationID uint          `json:"registrationId"`
+	IdentityKey    string        `json:"identityKey"`
+	SignedPreKey   *SignedPreKey `json:"signedPreKey"`
+	PreKeyCount    int           `json:"preKeyCount"`
+	CreatedAt      time.Time     `json:"createdAt"`
+	UpdatedAt      time.Time     `json:"updatedAt"`
+}
+
+// SignedPreKey is a medium-term key signed with the identity key of its device.
+type SignedPreKey struct {
+	KeyID     uint   `json:"keyId"`
+	PublicKey string `json:"publicKey"`
+	Signature string `json:"signature"`
+}
+
+// PreKey is a one-time key. Each one is handed out in a single bundle and then deleted.
+type PreKey struct {
+	KeyID     uint   `json:"keyId"`
+	PublicKey string `json:"publicKey"`
+}
+
+// PreKeyBundle is what a sender needs to start a session with a device. PreKey is nil once the device
+// has run out of one-time keys, in which case the session is started with the signed prekey alone.
+type PreKeyBundle struct {
+	UserID         uint          `json:"userId"`
+	DeviceID       uint          `json:"deviceId"`
+	RegistrationID uint          `json:"registrationId"`
+	IdentityKey    string        `json:"identityKey"`
+	SignedPreKey   *SignedPreKey `json:"signedPreKey"`
+	PreKey         *PreKey       `json:"preKey"`
+	Remaining      int           `json:"-"` // One-time keys the device has left after this bundle
+}
+
+// EncryptedEnvelope is the content of an EncryptedMessage, which the server never reads: one ciphertext for each device of
+// the participants, the sender's other devices included.
+type EncryptedEnvelope struct {
+	SenderDeviceID uint                `json:"senderDeviceId"`
+	Ciphertexts    []*DeviceCiphertext `json:"ciphertexts"`
+}
+
+// DeviceCiphertext is the message encrypted for a single device.
+type DeviceCiphertext struct {
+	UserID   uint   `json:"userId"`
+	DeviceID uint   `json:"deviceId"`
+	Type     string `json:"type"`
+	Body     string `json:"body"`
+}
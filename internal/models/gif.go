@@ -0,0 +1,14 @@
+package models
+
+// Gif is an animation picked from a GIF search provider. The file itself stays at the provider.
+type Gif struct {
+	ID         uint    `json:"-"`
+	MessageID  uint    `json:"-"`
+	Provider   string  `json:"provider"`
+	ExternalID string  `json:"id"`
+	URL        string  `json:"url"`
+	PreviewURL *string `json:"previewUrl"`
+	Width      int     `json:"width"`
+	Height     int     `json:"height"`
+	Title      *string `json:"title"`
+}
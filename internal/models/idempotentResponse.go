@@ -0,0 +1,13 @@
+package models
+
+import "net/http"
+
+// IdempotentResponse is the response stored for an Idempotency-Key. Until the first request
+// finishes only a reservation is stored, with Completed set to false.
+type IdempotentResponse struct {
+	Fingerprint string      `json:"fingerprint"`
+	Completed   bool        `json:"completed"`
+	Status      int         `json:"status"`
+	Header      http.Header `json:"header"`
+	Body        []byte      `json:"body"`
+}
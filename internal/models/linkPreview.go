@@ -0,0 +1,10 @@
+package models
+
+type LinkPreview struct {
+	MessageID   uint    `json:"messageId"`
+	URL         string  `json:"url"`
+	Title       *string `json:"title"`
+	Description *string `json:"description"`
+	ImageURL    *string `json:"imageUrl"`
+	SiteName    *string `json:"siteName"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// Location is a static pin, or a live location when LivePeriod is set.
+// A live location keeps receiving updates until LiveUntil.
+type Location struct {
+	ID         uint       `json:"-"`
+	MessageID  uint       `json:"messageId"`
+	Latitude   float64    `json:"latitude"`
+	Longitude  float64    `json:"longitude"`
+	Accuracy   *float64   `json:"accuracy,omitempty"`
+	Heading    *int       `json:"heading,omitempty"`
+	LivePeriod *int       `json:"livePeriod,omitempty"`
+	LiveUntil  *time.Time `json:"liveUntil,omitempty"`
+	IsLive     bool       `json:"isLive"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+}
+
+// SetLiveState updates IsLive from the end of the sharing period.
+func (l *Location) SetLiveState() {
+	l.IsLive = l.LiveUntil != nil && l.LiveUntil.After(time.Now())
+}
@@ -2,20 +2,86 @@ package models
 
 import "time"
 
+const (
+	TextMessage      = "text"
+	PollMessage      = "poll"
+	LocationMessage  = "location"
+	ContactMessage   = "contact"
+	StickerMessage   = "sticker"
+	GifMessage       = "gif"
+	EncryptedMessage = "encrypted"
+)
+
+// Delivery statuses of a message, as seen by its sender
+const (
+	SentStatus      = "sent"
+	DeliveredStatus = "delivered"
+	ReadStatus      = "read"
+)
+
 type Message struct {
 	ID          uint       `json:"id"`
 	SenderID    uint       `json:"senderId"`
 	RecipientID uint       `json:"recipientId"`
 	Content     *string    `json:"content"`
+	Type        string     `json:"type"`
 	ReadAt      *time.Time `json:"readAt"`
+	DeliveredAt *time.Time `json:"deliveredAt"`
+	Status      string     `json:"status,omitempty"`
 	ChatID      uint       `json:"chatId"`
 	ParentID    *uint      `json:"parentId"`
+	ScheduledAt *time.Time `json:"scheduledAt,omitempty"`
+	ReplyCount  int        `json:"replyCount"`
+	LastReplyAt *time.Time `json:"lastReplyAt"`
 	CreatedAt   time.Time  `json:"createdAt"`
 	UpdatedAt   time.Time  `json:"updatedAt"`
+	Silent      bool       `json:"silent"`    // Delivered without a sound or a push notification
+	NoForward   bool       `json:"noForward"` // The message can't be forwarded
+	Shadowed    bool       `json:"-"`         // Sent by a shadow-banned user, so it is only shown to the sender
+
+	Sender        *User            `json:"sender,omitempty"`
+	Recipient     *User            `json:"recipient,omitempty"`
+	Chat          *Chat            `json:"chats,omitempty"`
+	Parent        *Message         `json:"parent,omitempty"`
+	Quote         *Quote           `json:"quote,omitempty"`
+	ForwardedFrom *ForwardedFrom   `json:"forwardedFrom,omitempty"`
+	Attachments   []*Attachment    `json:"attachments"`
+	Entities      []*MessageEntity `json:"entities"`
+	LinkPreview   *LinkPreview     `json:"linkPreview,omitempty"`
+	Poll          *Poll            `json:"poll,omitempty"`
+	Location      *Location        `json:"location,omitempty"`
+	Contact       *Contact         `json:"contact,omitempty"`
+	Sticker       *Sticker         `json:"sticker,omitempty"`
+	Gif           *Gif             `json:"gif,omitempty"`
+}
+
+// SetStatus derives Status from the delivery and read times.
+func (m *Message) SetStatus() {
+	switch {
+	case m.ReadAt != nil:
+		m.Status = ReadStatus
+	case m.DeliveredAt != nil:
+		m.Status = DeliveredStatus
+	default:
+		m.Status = SentStatus
+	}
+}
+
+// ForwardedFrom describes where a forwarded message originally came from.
+// MessageID, ChatID and SenderID are nil when the original sender hides their identity.
+type ForwardedFrom struct {
+	MessageID *uint     `json:"messageId"`
+	ChatID    *uint     `json:"chatId"`
+	SenderID  *uint     `json:"senderId"`
+	Date      time.Time `json:"date"`
+	Hidden    bool      `json:"hidden"`
+
+	Sender *User `json:"sender,omitempty"`
+}
 
-	Sender      *User         `json:"sender,omitempty"`
-	Recipient   *User         `json:"recipient,omitempty"`
-	Chat        *Chat         `json:"chats,omitempty"`
-	Parent      *Message      `json:"parent,omitempty"`
-	Attachments []*Attachment `json:"attachments"`
+// Quote is an excerpt of the parent message text that a reply refers to.
+// Offset is measured in UTF-16 code units.
+type Quote struct {
+	Text   string `json:"text"`
+	Offset int    `json:"offset"`
 }
@@ -0,0 +1,26 @@
+package models
+
+const (
+	MentionEntity       = "mention"
+	BoldEntity          = "bold"
+	ItalicEntity        = "italic"
+	UnderlineEntity     = "underline"
+	StrikethroughEntity = "strikethrough"
+	CodeEntity          = "code"
+	PreEntity           = "pre"
+	TextLinkEntity      = "text_link"
+	SpoilerEntity       = "spoiler"
+)
+
+// MessageEntity marks a part of the message content. Offset and Length are measured in UTF-16 code units.
+// Mentions are detected by the server, every other type describes formatting submitted by the client.
+type MessageEntity struct {
+	ID        uint    `json:"-"`
+	MessageID uint    `json:"-"`
+	Type      string  `json:"type"`
+	Offset    int     `json:"offset"`
+	Length    int     `json:"length"`
+	UserID    *uint   `json:"userId,omitempty"`
+	URL       *string `json:"url,omitempty"`
+	Language  *string `json:"language,omitempty"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+const (
+	AllowVerdict  = "allow"
+	FlagVerdict   = "flag"
+	RejectVerdict = "reject"
+
+	PendingFlag  = "pending"
+	ApprovedFlag = "approved"
+	RemovedFlag  = "removed"
+)
+
+// ModerationFlag puts a message that a moderation filter flagged in the review queue.
+// MessageID is nil once the message is deleted.
+type ModerationFlag struct {
+	ID         uint       `json:"id"`
+	MessageID  *uint      `json:"messageId"`
+	SenderID   uint       `json:"senderId"`
+	Filter     string     `json:"filter"`
+	Reason     string     `json:"reason"`
+	Details    *string    `json:"details,omitempty"`
+	Status     string     `json:"status"`
+	ReviewedBy *uint      `json:"reviewedBy,omitempty"`
+	ReviewedAt *time.Time `json:"reviewedAt,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+
+	Message *Message `json:"message,omitempty"`
+}
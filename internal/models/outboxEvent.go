@@ -0,0 +1,17 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// OutboxEvent is a realtime event stored in the same transaction as the change it reports.
+// The outbox relay publishes it to the user once the transaction is committed.
+type OutboxEvent struct {
+	ID        uint64          `json:"id"`
+	UserID    uint            `json:"userId"`
+	Event     string          `json:"event"`
+	Payload   json.RawMessage `json:"payload"`
+	Attempts  int             `json:"attempts"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
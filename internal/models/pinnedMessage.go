@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+type PinnedMessage struct {
+	ID        uint      `json:"id"`
+	ChatID    uint      `json:"chatId"`
+	MessageID uint      `json:"messageId"`
+	PinnedBy  uint      `json:"pinnedBy"`
+	Notified  bool      `json:"notified"`
+	PinnedAt  time.Time `json:"pinnedAt"`
+
+	Message *Message `json:"message,omitempty"`
+}
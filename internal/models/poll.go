@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+type Poll struct {
+	ID                    uint       `json:"id"`
+	MessageID             uint       `json:"messageId"`
+	Question              string     `json:"question"`
+	IsAnonymous           bool       `json:"isAnonymous"`
+	AllowsMultipleAnswers bool       `json:"allowsMultipleAnswers"`
+	IsQuiz                bool       `json:"isQuiz"`
+	CorrectOption         *int       `json:"correctOption,omitempty"`
+	TotalVoters           int        `json:"totalVoters"`
+	ClosesAt              *time.Time `json:"closesAt"`
+	ClosedAt              *time.Time `json:"closedAt"`
+	CreatedAt             time.Time  `json:"createdAt"`
+
+	Options []*PollOption `json:"options"`
+}
+
+// PollOption holds the tally of one answer. Voters is only filled for public polls.
+type PollOption struct {
+	ID        uint   `json:"id"`
+	PollID    uint   `json:"-"`
+	Position  int    `json:"position"`
+	Text      string `json:"text"`
+	VoteCount int    `json:"voteCount"`
+	Voters    []uint `json:"voters,omitempty"`
+}
+
+func (p *Poll) IsClosed() bool {
+	return p.ClosedAt != nil
+}
+
+// HideAnswer removes the correct option of a quiz that is still open, so it isn't revealed before voting.
+func (p *Poll) HideAnswer() {
+	if !p.IsClosed() {
+		p.CorrectOption = nil
+	}
+}
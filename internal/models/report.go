@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+const (
+	OpenReport      = "open"
+	ResolvedReport  = "resolved"
+	DismissedReport = "dismissed"
+
+	DeleteContentAction = "delete_content"
+	WarnAction          = "warn"
+	SuspendAction       = "suspend"
+	DismissAction       = "dismiss"
+)
+
+// Report is a complaint about a message or a user. Snapshot is a copy of the reported message taken
+// when the report was made, with its attachments pointing to copies of the files, so the evidence
+// survives the message being deleted.
+type Report struct {
+	ID             uint       `json:"id"`
+	ReporterID     uint       `json:"reporterId"`
+	ReportedUserID uint       `json:"reportedUserId"`
+	MessageID      *uint      `json:"messageId"`
+	Reason         string     `json:"reason"`
+	Comment        *string    `json:"comment"`
+	Snapshot       *Message   `json:"snapshot,omitempty"`
+	Status         string     `json:"status"`
+	AssigneeID     *uint      `json:"assigneeId"`
+	AssignedAt     *time.Time `json:"assignedAt"`
+	Action         *string    `json:"action"`
+	ResolutionNote *string    `json:"resolutionNote"`
+	ResolvedBy     *uint      `json:"resolvedBy"`
+	ResolvedAt     *time.Time `json:"resolvedAt"`
+	CreatedAt      time.Time  `json:"createdAt"`
+}
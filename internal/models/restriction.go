@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+const (
+	SuspensionRestriction = "suspension"
+	ReadOnlyRestriction   = "read_only"
+	ShadowBanRestriction  = "shadow_ban"
+)
+
+// Restriction limits what a user can do until it expires.
+type Restriction struct {
+	ID        uint      `json:"id"`
+	UserID    uint      `json:"userId"`
+	Type      string    `json:"type"`
+	Reason    string    `json:"reason"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	ReportID  *uint     `json:"reportId,omitempty"`
+	CreatedBy *uint     `json:"createdBy,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
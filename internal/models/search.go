@@ -0,0 +1,11 @@
+package models
+
+type MessageSearchResult struct {
+	Message *Message `json:"message"`
+	Snippet string   `json:"snippet"` // HTML-escaped matched fragments with terms wrapped in <mark></mark>
+}
+
+type MessageSearchPage struct {
+	Results    []*MessageSearchResult `json:"results"`
+	NextCursor *string                `json:"nextCursor"`
+}
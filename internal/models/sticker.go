@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+type StickerPack struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	Title     string    `json:"title"`
+	AuthorID  *uint     `json:"authorId"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+
+	Stickers []*Sticker `json:"stickers"`
+}
+
+type Sticker struct {
+	ID       uint     `json:"id"`
+	PackID   uint     `json:"packId"`
+	FilePath string   `json:"filePath"`
+	Emojis   []string `json:"emojis"`
+	Position int      `json:"position"`
+}
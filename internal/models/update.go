@@ -0,0 +1,22 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Update is an entry of a user's update log. It holds a websocket notification sent to the user.
+type Update struct {
+	Seq       uint64          `json:"seq"`
+	Event     string          `json:"event"`
+	Message   json.RawMessage `json:"message"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+// Difference holds the updates a client missed since the sequence number it last saw.
+// Resync is set when the updates are no longer available and the client has to reload its state.
+type Difference struct {
+	Seq     uint64    `json:"seq"`
+	Updates []*Update `json:"updates"`
+	Resync  bool      `json:"resync"`
+}
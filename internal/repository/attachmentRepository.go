@@ -2,11 +2,14 @@ package repository
 
 import (
 	"database/sql"
+	"errors"
 	"github.com/drTragger/messenger-backend/internal/models"
+	"github.com/lib/pq"
+	"time"
 )
 
 type AttachmentRepository struct {
-	DB *sql.DB
+	DB DBTX
 }
 
 func NewAttachmentRepository(db *sql.DB) *AttachmentRepository {
@@ -15,14 +18,34 @@ func NewAttachmentRepository(db *sql.DB) *AttachmentRepository {
 	}
 }
 
+// WithTx returns a copy of the repository that runs its queries in the given transaction.
+func (ar *AttachmentRepository) WithTx(tx *sql.Tx) *AttachmentRepository {
+	return &AttachmentRepository{
+		DB: tx,
+	}
+}
+
 func (ar *AttachmentRepository) Create(attachment *models.Attachment) (*models.Attachment, error) {
 	query := `
-		INSERT INTO attachments (message_id, file_name, file_path, file_type, file_size, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, NOW(), NOW())
+		INSERT INTO attachments (message_id, file_name, file_path, file_type, file_size, type, duration_ms, waveform, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW(), NOW())
 		RETURNING id, created_at, updated_at;
 	`
 
-	row := ar.DB.QueryRow(query, attachment.MessageID, attachment.FileName, attachment.FilePath, attachment.FileType, attachment.FileSize)
+	if attachment.Type == "" {
+		attachment.Type = models.FileAttachment
+	}
+
+	var waveform interface{}
+	if attachment.Waveform != nil {
+		waveform = pq.Array(attachment.Waveform)
+	}
+
+	row := ar.DB.QueryRow(
+		query,
+		attachment.MessageID, attachment.FileName, attachment.FilePath, attachment.FileType, attachment.FileSize,
+		attachment.Type, attachment.Duration, waveform,
+	)
 	err := row.Scan(&attachment.ID, &attachment.CreatedAt, &attachment.UpdatedAt)
 	if err != nil {
 		return nil, err
@@ -30,3 +53,65 @@ func (ar *AttachmentRepository) Create(attachment *models.Attachment) (*models.A
 
 	return attachment, nil
 }
+
+// LockFiles takes a transaction lock on every stored file path. Transactions that delete or copy
+// attachments lock their files first, so a file's references can't change until the transaction ends.
+// The paths are locked in order, so two transactions never wait for each other.
+func (ar *AttachmentRepository) LockFiles(filePaths []string) error {
+	query := `
+		SELECT pg_advisory_xact_lock(hashtext(path))
+		FROM (SELECT DISTINCT path FROM unnest($1::text[]) AS path ORDER BY path) AS paths
+	`
+
+	_, err := ar.DB.Exec(query, pq.Array(filePaths))
+	return err
+}
+
+// UnreferencedFiles returns the stored file paths no attachment refers to. Forwarded messages reuse
+// the files of their originals, so a file is only unreferenced once every copy is deleted.
+func (ar *AttachmentRepository) UnreferencedFiles(filePaths []string) ([]string, error) {
+	query := `
+		SELECT DISTINCT path
+		FROM unnest($1::text[]) AS path
+		WHERE NOT EXISTS (SELECT 1 FROM attachments WHERE file_path = path)
+	`
+
+	rows, err := ar.DB.Query(query, pq.Array(filePaths))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	unreferenced := make([]string, 0)
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			return nil, err
+		}
+		unreferenced = append(unreferenced, path)
+	}
+
+	return unreferenced, rows.Err()
+}
+
+// MarkAsListened marks the voice attachments of a message as played by the recipient.
+// It returns nil if the message has no voice attachments.
+func (ar *AttachmentRepository) MarkAsListened(messageID uint) (*time.Time, error) {
+	query := `
+		UPDATE attachments SET listened_at = COALESCE(listened_at, NOW())
+		WHERE message_id = $1 AND type = $2
+		RETURNING listened_at
+	`
+
+	var listenedAt time.Time
+
+	err := ar.DB.QueryRow(query, messageID, models.VoiceAttachment).Scan(&listenedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &listenedAt, nil
+}
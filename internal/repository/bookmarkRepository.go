@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"database/sql"
+	"github.com/drTragger/messenger-backend/internal/models"
+	"github.com/lib/pq"
+)
+
+const (
+	BookmarksLimit  = 20
+	BookmarksOffset = 0
+)
+
+type BookmarkRepository struct {
+	DB *sql.DB
+}
+
+func NewBookmarkRepository(db *sql.DB) *BookmarkRepository {
+	return &BookmarkRepository{
+		DB: db,
+	}
+}
+
+// Star bookmarks a message for the user. Starring an already starred message replaces its tags.
+func (br *BookmarkRepository) Star(bookmark *models.Bookmark) (*models.Bookmark, error) {
+	query := `
+		INSERT INTO bookmarks (user_id, message_id, tags, created_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (user_id, message_id)
+		DO UPDATE SET tags = EXCLUDED.tags
+		RETURNING id, created_at
+	`
+
+	err := br.DB.QueryRow(query, bookmark.UserID, bookmark.MessageID, pq.Array(bookmark.Tags)).Scan(&bookmark.ID, &bookmark.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return bookmark, nil
+}
+
+// Unstar removes a bookmark and reports whether the message was starred.
+func (br *BookmarkRepository) Unstar(userID, messageID uint) (bool, error) {
+	query := `DELETE FROM bookmarks WHERE user_id = $1 AND message_id = $2`
+
+	result, err := br.DB.Exec(query, userID, messageID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
@@ -15,7 +15,7 @@ const (
 )
 
 type ChatRepository struct {
-	DB *sql.DB
+	DB DBTX
 }
 
 func NewChatRepository(db *sql.DB) *ChatRepository {
@@ -24,6 +24,14 @@ func NewChatRepository(db *sql.DB) *ChatRepository {
 	}
 }
 
+// WithTx returns a copy of the repository that runs its queries in the given transaction.
+func (cr *ChatRepository) WithTx(tx *sql.Tx) *ChatRepository {
+	return &ChatRepository{
+		DB: tx,
+	}
+}
+
+// Create inserts a chat between two users. Both IDs are the same for the Saved Messages chat.
 func (cr *ChatRepository) Create(user1ID, user2ID uint, lastMessageID *uint) (*models.Chat, error) {
 	query := `
 		INSERT INTO chats (user1_id, user2_id, last_message_id, created_at, updated_at)
@@ -75,6 +83,7 @@ func (cr *ChatRepository) GetByID(chatID uint) (*models.Chat, error) {
 			m.recipient_id, 
 			LEFT(
     			CASE
+        			WHEN m.type = 'encrypted' THEN NULL
         			WHEN LENGTH(m.content) > $2 THEN CONCAT(SUBSTRING(m.content, 1, $2), '...')
         			ELSE m.content
     			END,
@@ -140,6 +149,22 @@ func (cr *ChatRepository) GetByID(chatID uint) (*models.Chat, error) {
 	return chat, nil
 }
 
+// GetSaved returns the Saved Messages chat of the user, or nil if it hasn't been created yet.
+func (cr *ChatRepository) GetSaved(userID uint) (*models.Chat, error) {
+	query := `SELECT id FROM chats WHERE user1_id = $1 AND user2_id = $1`
+
+	var chatID uint
+	err := cr.DB.QueryRow(query, userID).Scan(&chatID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return cr.GetByID(chatID)
+}
+
 func (cr *ChatRepository) GetForUser(userID uint, limit, offset int) ([]*models.Chat, error) {
 	query, err := db.LoadQuery("get_for_user.sql", "chats")
 	if err != nil {
@@ -184,6 +209,7 @@ func (cr *ChatRepository) GetForUser(userID uint, limit, offset int) ([]*models.
 			&user2.ID, &user2.Username, &user2.FirstName, &user2.LastName, &user2.Phone, &user2.LastSeen, &user2.ProfilePicture, &user2.CreatedAt, &user2.UpdatedAt,
 			&lastMessageID, &lastMessageSenderID, &lastMessageRecipientID, &lastMessageContent, &lastMessageReadAt, &lastMessageChatID, &lastMessageCreatedAt, &lastMessageUpdatedAt,
 			&lastAttachmentID, &lastAttachmentFileName, &lastAttachmentFilePath, &lastAttachmentFileType, &lastAttachmentFileSize, &lastAttachmentCreatedAt, &lastAttachmentUpdatedAt,
+			&chat.UnreadMentionCount,
 		)
 		if err != nil {
 			return nil, err
@@ -252,11 +278,13 @@ func (cr *ChatRepository) GetForUser(userID uint, limit, offset int) ([]*models.
 	return chats, nil
 }
 
+// UpdateLastMessage points a chat to its last message. Shadowed messages are ignored, so that the
+// recipient doesn't see them in the chat list.
 func (cr *ChatRepository) UpdateLastMessage(chatID, lastMessageID uint) error {
 	query := `
 		UPDATE chats
 		SET last_message_id = $1, updated_at = NOW()
-		WHERE id = $2
+		WHERE id = $2 AND NOT EXISTS (SELECT 1 FROM messages WHERE id = $1 AND shadowed)
 	`
 
 	_, err := cr.DB.Exec(query, lastMessageID, chatID)
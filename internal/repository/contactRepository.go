@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"database/sql"
+	"github.com/drTragger/messenger-backend/internal/models"
+)
+
+type ContactRepository struct {
+	DB DBTX
+}
+
+func NewContactRepository(db *sql.DB) *ContactRepository {
+	return &ContactRepository{
+		DB: db,
+	}
+}
+
+// WithTx returns a copy of the repository that runs its queries in the given transaction.
+func (cr *ContactRepository) WithTx(tx *sql.Tx) *ContactRepository {
+	return &ContactRepository{
+		DB: tx,
+	}
+}
+
+func (cr *ContactRepository) Create(contact *models.Contact) (*models.Contact, error) {
+	query := `
+		INSERT INTO contacts (message_id, first_name, last_name, phone, user_id, vcard, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		RETURNING id
+	`
+
+	err := cr.DB.QueryRow(
+		query,
+		contact.MessageID, contact.FirstName, contact.LastName, contact.Phone, contact.UserID, contact.VCard,
+	).Scan(&contact.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return contact, nil
+}
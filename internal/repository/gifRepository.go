@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"database/sql"
+	"github.com/drTragger/messenger-backend/internal/models"
+)
+
+type GifRepository struct {
+	DB DBTX
+}
+
+func NewGifRepository(db *sql.DB) *GifRepository {
+	return &GifRepository{
+		DB: db,
+	}
+}
+
+// WithTx returns a copy of the repository that runs its queries in the given transaction.
+func (gr *GifRepository) WithTx(tx *sql.Tx) *GifRepository {
+	return &GifRepository{
+		DB: tx,
+	}
+}
+
+func (gr *GifRepository) Create(gif *models.Gif) (*models.Gif, error) {
+	query := `
+		INSERT INTO gifs (message_id, provider, external_id, url, preview_url, width, height, title, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW())
+		RETURNING id
+	`
+
+	err := gr.DB.QueryRow(
+		query,
+		gif.MessageID, gif.Provider, gif.ExternalID, gif.URL, gif.PreviewURL, gif.Width, gif.Height, gif.Title,
+	).Scan(&gif.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return gif, nil
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/drTragger/messenger-backend/internal/models"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+type IdempotencyRepository struct {
+	Client *redis.Client
+}
+
+func NewIdempotencyRepository(client *redis.Client) *IdempotencyRepository {
+	return &IdempotencyRepository{Client: client}
+}
+
+// Get returns the response stored for the user's key, or nil if there is none.
+func (ir *IdempotencyRepository) Get(ctx context.Context, userID uint, key string) (*models.IdempotentResponse, error) {
+	data, err := ir.Client.Get(ctx, idempotencyKey(userID, key)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var response models.IdempotentResponse
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// Reserve marks the user's key as in flight. It returns false if the key is already taken.
+func (ir *IdempotencyRepository) Reserve(ctx context.Context, userID uint, key string, expiration time.Duration) (bool, error) {
+	data, err := json.Marshal(&models.IdempotentResponse{})
+	if err != nil {
+		return false, err
+	}
+	return ir.Client.SetNX(ctx, idempotencyKey(userID, key), data, expiration).Result()
+}
+
+// Save stores the completed response for the user's key, replacing the reservation.
+func (ir *IdempotencyRepository) Save(ctx context.Context, userID uint, key string, response *models.IdempotentResponse, expiration time.Duration) error {
+	data, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+	return ir.Client.Set(ctx, idempotencyKey(userID, key), data, expiration).Err()
+}
+
+// Release removes the user's key so that the request can be retried.
+func (ir *IdempotencyRepository) Release(ctx context.Context, userID uint, key string) error {
+	return ir.Client.Del(ctx, idempotencyKey(userID, key)).Err()
+}
+
+func idempotencyKey(userID uint, key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return fmt.Sprintf("user:%d:idempotency:%s", userID, hex.EncodeToString(hash[:]))
+}
@@ -0,0 +1,216 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/drTragger/messenger-backend/internal/models"
+	"github.com/lib/pq"
+)
+
+type KeyRepository struct {
+	DB DBTX
+}
+
+func NewKeyRepository(db *sql.DB) *KeyRepository {
+	return &KeyRepository{
+		DB: db,
+	}
+}
+
+// WithTx returns a copy of the repository that runs its queries in the given transaction.
+func (kr *KeyRepository) WithTx(tx *sql.Tx) *KeyRepository {
+	return &KeyRepository{
+		DB: tx,
+	}
+}
+
+// SaveDevice publishes the identity key and the signed prekey of a device, replacing the ones it had.
+func (kr *KeyRepository) SaveDevice(keys *models.DeviceKeys) error {
+	query := `
+		INSERT INTO device_keys (
+			user_id, device_id, registration_id, identity_key,
+			signed_prekey_id, signed_prekey, signed_prekey_signature, created_at, updated_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW(), NOW())
+		ON CONFLICT (user_id, device_id)
+		DO UPDATE SET
+			registration_id = EXCLUDED.registration_id,
+			identity_key = EXCLUDED.identity_key,
+			signed_prekey_id = EXCLUDED.signed_prekey_id,
+			signed_prekey = EXCLUDED.signed_prekey,
+			signed_prekey_signature = EXCLUDED.signed_prekey_signature,
+			updated_at = NOW()
+		RETURNING id, created_at, updated_at
+	`
+
+	return kr.DB.QueryRow(
+		query,
+		keys.UserID, keys.DeviceID, keys.RegistrationID, keys.IdentityKey,
+		keys.SignedPreKey.KeyID, keys.SignedPreKey.PublicKey, keys.SignedPreKey.Signature,
+	).Scan(&keys.ID, &keys.CreatedAt, &keys.UpdatedAt)
+}
+
+// GetDevice returns the keys of a device with the number of one-time prekeys it has left, or nil if it has none.
+func (kr *KeyRepository) GetDevice(userID, deviceID uint) (*models.DeviceKeys, error) {
+	query := `
+		SELECT 
+			d.id, d.user_id, d.device_id, d.registration_id, d.identity_key,
+			d.signed_prekey_id, d.signed_prekey, d.signed_prekey_signature,
+			(SELECT COUNT(*) FROM one_time_prekeys p WHERE p.device_key_id = d.id),
+			d.created_at, d.updated_at
+		FROM device_keys d
+		WHERE d.user_id = $1 AND d.device_id = $2
+	`
+
+	var keys models.DeviceKeys
+	var signedPreKey models.SignedPreKey
+
+	err := kr.DB.QueryRow(query, userID, deviceID).Scan(
+		&keys.ID, &keys.UserID, &keys.DeviceID, &keys.RegistrationID, &keys.IdentityKey,
+		&signedPreKey.KeyID, &signedPreKey.PublicKey, &signedPreKey.Signature,
+		&keys.PreKeyCount,
+		&keys.CreatedAt, &keys.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	keys.SignedPreKey = &signedPreKey
+	return &keys, nil
+}
+
+// GetDeviceIDs returns the IDs of the devices a user has published keys for.
+func (kr *KeyRepository) GetDeviceIDs(userID uint) ([]uint, error) {
+	query := `SELECT device_id FROM device_keys WHERE user_id = $1 ORDER BY device_id`
+
+	rows, err := kr.DB.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deviceIDs := make([]uint, 0)
+	for rows.Next() {
+		var deviceID uint
+		if err := rows.Scan(&deviceID); err != nil {
+			return nil, err
+		}
+		deviceIDs = append(deviceIDs, deviceID)
+	}
+
+	return deviceIDs, rows.Err()
+}
+
+// DeleteDevice removes the keys of a device and reports whether it had any.
+func (kr *KeyRepository) DeleteDevice(userID, deviceID uint) (bool, error) {
+	query := `DELETE FROM device_keys WHERE user_id = $1 AND device_id = $2`
+
+	result, err := kr.DB.Exec(query, userID, deviceID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// AddPreKeys stores one-time prekeys of a device. Keys with an ID the device already uses are ignored.
+func (kr *KeyRepository) AddPreKeys(deviceKeyID uint, preKeys []*models.PreKey) error {
+	if len(preKeys) == 0 {
+		return nil
+	}
+
+	keyIDs := make([]int64, 0, len(preKeys))
+	publicKeys := make([]string, 0, len(preKeys))
+	for _, preKey := range preKeys {
+		keyIDs = append(keyIDs, int64(preKey.KeyID))
+		publicKeys = append(publicKeys, preKey.PublicKey)
+	}
+
+	query := `
+		INSERT INTO one_time_prekeys (device_key_id, key_id, public_key, created_at)
+		SELECT $1, k.key_id, k.public_key, NOW()
+		FROM UNNEST($2::INT[], $3::TEXT[]) AS k (key_id, public_key)
+		ON CONFLICT (device_key_id, key_id) DO NOTHING
+	`
+
+	_, err := kr.DB.Exec(query, deviceKeyID, pq.Array(keyIDs), pq.Array(publicKeys))
+	return err
+}
+
+// DeletePreKeys removes every one-time prekey of a device.
+func (kr *KeyRepository) DeletePreKeys(deviceKeyID uint) error {
+	query := `DELETE FROM one_time_prekeys WHERE device_key_id = $1`
+
+	_, err := kr.DB.Exec(query, deviceKeyID)
+	return err
+}
+
+// ClaimBundles returns a prekey bundle for each device of a user. Every bundle takes one of the device's
+// one-time prekeys, which is deleted in the same statement, so concurrent requests never get the same key.
+func (kr *KeyRepository) ClaimBundles(userID uint) ([]*models.PreKeyBundle, error) {
+	query := `
+		WITH claimed AS (
+			DELETE FROM one_time_prekeys
+			WHERE id IN (
+				SELECT p.id
+				FROM device_keys d
+					CROSS JOIN LATERAL (
+						SELECT id FROM one_time_prekeys
+						WHERE device_key_id = d.id
+						ORDER BY id
+						LIMIT 1
+						FOR UPDATE SKIP LOCKED
+					) p
+				WHERE d.user_id = $1
+			)
+			RETURNING device_key_id, key_id, public_key
+		)
+		SELECT 
+			d.user_id, d.device_id, d.registration_id, d.identity_key,
+			d.signed_prekey_id, d.signed_prekey, d.signed_prekey_signature,
+			c.key_id, c.public_key,
+			(SELECT COUNT(*) FROM one_time_prekeys p WHERE p.device_key_id = d.id)
+		FROM device_keys d
+			LEFT JOIN claimed c ON c.device_key_id = d.id
+		WHERE d.user_id = $1
+		ORDER BY d.device_id
+	`
+
+	rows, err := kr.DB.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bundles := make([]*models.PreKeyBundle, 0)
+	for rows.Next() {
+		var bundle models.PreKeyBundle
+		var signedPreKey models.SignedPreKey
+		var preKeyID sql.NullInt64
+		var preKeyPublic sql.NullString
+
+		err := rows.Scan(
+			&bundle.UserID, &bundle.DeviceID, &bundle.RegistrationID, &bundle.IdentityKey,
+			&signedPreKey.KeyID, &signedPreKey.PublicKey, &signedPreKey.Signature,
+			&preKeyID, &preKeyPublic,
+			&bundle.Remaining,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		bundle.SignedPreKey = &signedPreKey
+		if preKeyID.Valid {
+			bundle.PreKey = &models.PreKey{KeyID: uint(preKeyID.Int64), PublicKey: preKeyPublic.String}
+			// The count comes from the snapshot the statement started with, which still has the claimed key
+			bundle.Remaining--
+		}
+		bundles = append(bundles, &bundle)
+	}
+
+	return bundles, rows.Err()
+}
@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/drTragger/messenger-backend/internal/models"
+	"github.com/go-redis/redis/v8"
+	"time"
+)
+
+type LinkPreviewRepository struct {
+	DB    DBTX
+	Cache *redis.Client
+}
+
+func NewLinkPreviewRepository(db *sql.DB, cache *redis.Client) *LinkPreviewRepository {
+	return &LinkPreviewRepository{
+		DB:    db,
+		Cache: cache,
+	}
+}
+
+// WithTx returns a copy of the repository that runs its queries in the given transaction.
+func (lr *LinkPreviewRepository) WithTx(tx *sql.Tx) *LinkPreviewRepository {
+	return &LinkPreviewRepository{
+		DB:    tx,
+		Cache: lr.Cache,
+	}
+}
+
+// Save stores the preview of a message, replacing the previous one.
+func (lr *LinkPreviewRepository) Save(preview *models.LinkPreview) error {
+	query := `
+		INSERT INTO link_previews (message_id, url, title, description, image_url, site_name, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW(), NOW())
+		ON CONFLICT (message_id)
+		DO UPDATE SET url = EXCLUDED.url, title = EXCLUDED.title, description = EXCLUDED.description,
+			image_url = EXCLUDED.image_url, site_name = EXCLUDED.site_name, updated_at = NOW()
+	`
+
+	_, err := lr.DB.Exec(query, preview.MessageID, preview.URL, preview.Title, preview.Description, preview.ImageURL, preview.SiteName)
+	return err
+}
+
+// Delete removes the preview of a message and reports whether there was one.
+func (lr *LinkPreviewRepository) Delete(messageID uint) (bool, error) {
+	query := `DELETE FROM link_previews WHERE message_id = $1`
+
+	result, err := lr.DB.Exec(query, messageID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// GetCached returns a cached preview for the URL. A cached nil preview means the URL has no preview.
+func (lr *LinkPreviewRepository) GetCached(ctx context.Context, url string) (preview *models.LinkPreview, found bool, err error) {
+	data, err := lr.Cache.Get(ctx, linkPreviewKey(url)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err := json.Unmarshal(data, &preview); err != nil {
+		return nil, false, err
+	}
+	return preview, true, nil
+}
+
+// CachePreview stores the preview for the URL. A nil preview is cached as well so that broken links aren't refetched.
+func (lr *LinkPreviewRepository) CachePreview(ctx context.Context, url string, preview *models.LinkPreview, expiration time.Duration) error {
+	data, err := json.Marshal(preview)
+	if err != nil {
+		return err
+	}
+	return lr.Cache.Set(ctx, linkPreviewKey(url), data, expiration).Err()
+}
+
+func linkPreviewKey(url string) string {
+	hash := sha256.Sum256([]byte(url))
+	return fmt.Sprintf("linkPreview:%s", hex.EncodeToString(hash[:]))
+}
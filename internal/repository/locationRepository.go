@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/drTragger/messenger-backend/internal/models"
+	"time"
+)
+
+const locationColumns = `
+	id, message_id, latitude, longitude, accuracy, heading, live_period, live_until, updated_at
+`
+
+type LocationRepository struct {
+	DB DBTX
+}
+
+func NewLocationRepository(db *sql.DB) *LocationRepository {
+	return &LocationRepository{
+		DB: db,
+	}
+}
+
+// WithTx returns a copy of the repository that runs its queries in the given transaction.
+func (lr *LocationRepository) WithTx(tx *sql.Tx) *LocationRepository {
+	return &LocationRepository{
+		DB: tx,
+	}
+}
+
+func (lr *LocationRepository) Create(location *models.Location) (*models.Location, error) {
+	query := `
+		INSERT INTO locations (message_id, latitude, longitude, accuracy, heading, live_period, live_until, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW(), NOW())
+		RETURNING id, updated_at
+	`
+
+	if location.LivePeriod != nil {
+		liveUntil := time.Now().Add(time.Duration(*location.LivePeriod) * time.Second)
+		location.LiveUntil = &liveUntil
+	}
+
+	err := lr.DB.QueryRow(
+		query,
+		location.MessageID, location.Latitude, location.Longitude, location.Accuracy, location.Heading, location.LivePeriod, location.LiveUntil,
+	).Scan(&location.ID, &location.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	location.SetLiveState()
+	return location, nil
+}
+
+func (lr *LocationRepository) GetByMessageID(messageID uint) (*models.Location, error) {
+	query := `SELECT ` + locationColumns + ` FROM locations WHERE message_id = $1`
+
+	location, err := scanLocation(lr.DB.QueryRow(query, messageID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return location, err
+}
+
+// Update moves a live location. It returns sql.ErrNoRows if the location is not live anymore.
+func (lr *LocationRepository) Update(location *models.Location) (*models.Location, error) {
+	query := `
+		UPDATE locations
+		SET latitude = $1, longitude = $2, accuracy = $3, heading = $4, updated_at = NOW()
+		WHERE message_id = $5 AND live_until > NOW()
+		RETURNING ` + locationColumns
+
+	return scanLocation(lr.DB.QueryRow(
+		query,
+		location.Latitude, location.Longitude, location.Accuracy, location.Heading, location.MessageID,
+	))
+}
+
+// Stop ends live sharing before the chosen period is over. It returns sql.ErrNoRows if the location is not live anymore.
+func (lr *LocationRepository) Stop(messageID uint) (*models.Location, error) {
+	query := `
+		UPDATE locations
+		SET live_until = NOW(), ended_at = NOW(), updated_at = NOW()
+		WHERE message_id = $1 AND live_until > NOW()
+		RETURNING ` + locationColumns
+
+	return scanLocation(lr.DB.QueryRow(query, messageID))
+}
+
+// EndExpired marks up to limit live locations whose sharing period is over as ended and returns them.
+// Rows locked by another instance are skipped, so the end of each location is reported exactly once.
+func (lr *LocationRepository) EndExpired(limit int) ([]*models.Location, error) {
+	query := `
+		UPDATE locations
+		SET ended_at = NOW()
+		WHERE id IN (
+			SELECT id
+			FROM locations
+			WHERE live_until IS NOT NULL AND live_until <= NOW() AND ended_at IS NULL
+			ORDER BY live_until
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + locationColumns
+
+	rows, err := lr.DB.Query(query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	locations := make([]*models.Location, 0)
+	for rows.Next() {
+		location, err := scanLocation(rows)
+		if err != nil {
+			return nil, err
+		}
+		locations = append(locations, location)
+	}
+
+	return locations, rows.Err()
+}
+
+func scanLocation(row interface{ Scan(...interface{}) error }) (*models.Location, error) {
+	var location models.Location
+	err := row.Scan(
+		&location.ID, &location.MessageID, &location.Latitude, &location.Longitude, &location.Accuracy, &location.Heading,
+		&location.LivePeriod, &location.LiveUntil, &location.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	location.SetLiveState()
+	return &location, nil
+}
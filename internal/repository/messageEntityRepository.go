@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"database/sql"
+	"github.com/drTragger/messenger-backend/internal/models"
+)
+
+type MessageEntityRepository struct {
+	DB DBTX
+}
+
+func NewMessageEntityRepository(db *sql.DB) *MessageEntityRepository {
+	return &MessageEntityRepository{
+		DB: db,
+	}
+}
+
+// WithTx returns a copy of the repository that runs its queries in the given transaction.
+func (er *MessageEntityRepository) WithTx(tx *sql.Tx) *MessageEntityRepository {
+	return &MessageEntityRepository{
+		DB: tx,
+	}
+}
+
+func (er *MessageEntityRepository) Create(messageID uint, entities []*models.MessageEntity) error {
+	query := `
+		INSERT INTO message_entities (message_id, type, "offset", length, user_id, url, language, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
+		RETURNING id
+	`
+
+	for _, entity := range entities {
+		entity.MessageID = messageID
+		err := er.DB.QueryRow(query, messageID, entity.Type, entity.Offset, entity.Length, entity.UserID, entity.URL, entity.Language).
+			Scan(&entity.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Replace replaces all entities attached to a message.
+func (er *MessageEntityRepository) Replace(messageID uint, entities []*models.MessageEntity) error {
+	query := `DELETE FROM message_entities WHERE message_id = $1`
+
+	if _, err := er.DB.Exec(query, messageID); err != nil {
+		return err
+	}
+
+	return er.Create(messageID, entities)
+}
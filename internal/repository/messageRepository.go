@@ -13,8 +13,15 @@ const (
 	MessagesOffset = 0
 )
 
+// replyStatsQuery counts the delivered replies of the message aliased as m.
+const replyStatsQuery = `
+	SELECT COUNT(r.id) AS reply_count, MAX(r.created_at) AS last_reply_at
+	FROM messages r
+	WHERE r.parent_id = m.id AND r.scheduled_at IS NULL AND NOT r.shadowed
+`
+
 type MessageRepository struct {
-	DB *sql.DB
+	DB DBTX
 }
 
 func NewMessageRepository(db *sql.DB) *MessageRepository {
@@ -23,28 +30,91 @@ func NewMessageRepository(db *sql.DB) *MessageRepository {
 	}
 }
 
+// WithTx returns a copy of the repository that runs its queries in the given transaction.
+func (mr *MessageRepository) WithTx(tx *sql.Tx) *MessageRepository {
+	return &MessageRepository{
+		DB: tx,
+	}
+}
+
+// Create inserts the message. Messages sent to oneself in Saved Messages are delivered and read right away.
 func (mr *MessageRepository) Create(msg *models.Message) (*models.Message, error) {
 	query := `
-		INSERT INTO messages (sender_id, recipient_id, content, chat_id, parent_id, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, NOW(), NOW())
-		RETURNING id, created_at, updated_at
+		INSERT INTO messages (
+			sender_id, recipient_id, content, chat_id, parent_id,
+			is_forwarded, forwarded_from_message_id, forwarded_from_chat_id, forwarded_from_user_id, forwarded_from_date,
+			scheduled_at, quote_text, quote_offset, type, sticker_id, silent, no_forward, shadowed,
+			delivered_at, read_at, created_at, updated_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, EXISTS (
+			SELECT 1 FROM user_restrictions WHERE user_id = $1 AND type = $18 AND expires_at > NOW()
+		),
+			CASE WHEN $1 = $2 THEN NOW() END, CASE WHEN $1 = $2 THEN NOW() END, NOW(), NOW())
+		RETURNING id, shadowed, delivered_at, read_at, created_at, updated_at
 	`
 
-	err := mr.DB.QueryRow(query, msg.SenderID, msg.RecipientID, msg.Content, msg.ChatID, msg.ParentID).
-		Scan(&msg.ID, &msg.CreatedAt, &msg.UpdatedAt)
+	if msg.Type == "" {
+		msg.Type = models.TextMessage
+	}
+
+	var stickerID *uint
+	if msg.Sticker != nil {
+		stickerID = &msg.Sticker.ID
+	}
+
+	var fwdMessageID, fwdChatID, fwdSenderID *uint
+	var fwdDate *time.Time
+	var quoteText *string
+	var quoteOffset *int
+	if msg.Quote != nil {
+		quoteText = &msg.Quote.Text
+		quoteOffset = &msg.Quote.Offset
+	}
+	if msg.ForwardedFrom != nil {
+		fwdMessageID = msg.ForwardedFrom.MessageID
+		fwdChatID = msg.ForwardedFrom.ChatID
+		fwdSenderID = msg.ForwardedFrom.SenderID
+		fwdDate = &msg.ForwardedFrom.Date
+	}
+
+	err := mr.DB.QueryRow(
+		query,
+		msg.SenderID, msg.RecipientID, msg.Content, msg.ChatID, msg.ParentID,
+		msg.ForwardedFrom != nil, fwdMessageID, fwdChatID, fwdSenderID, fwdDate,
+		msg.ScheduledAt, quoteText, quoteOffset, msg.Type, stickerID, msg.Silent, msg.NoForward, models.ShadowBanRestriction,
+	).Scan(&msg.ID, &msg.Shadowed, &msg.DeliveredAt, &msg.ReadAt, &msg.CreatedAt, &msg.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
+	msg.SetStatus()
 
 	return msg, nil
 }
 
+// LockUpdatedAt locks a message until the end of the transaction and returns when it was last updated.
+// It returns nil if the message doesn't exist.
+func (mr *MessageRepository) LockUpdatedAt(id uint) (*time.Time, error) {
+	query := `SELECT updated_at FROM messages WHERE id = $1 FOR UPDATE`
+
+	var updatedAt time.Time
+	err := mr.DB.QueryRow(query, id).Scan(&updatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &updatedAt, nil
+}
+
+// Edit changes the content of a sent message. Scheduled messages are changed with EditScheduled.
 func (mr *MessageRepository) Edit(id uint, content string) (*models.Message, error) {
 	query := `
 		UPDATE messages
 		SET content = $1, updated_at = NOW()
-		WHERE id = $2
-		RETURNING id, sender_id, recipient_id, content, read_at, chat_id, created_at, updated_at
+		WHERE id = $2 AND scheduled_at IS NULL
+		RETURNING id, sender_id, recipient_id, content, type, read_at, delivered_at, chat_id, created_at, updated_at, silent, no_forward, shadowed
 	`
 
 	var m models.Message
@@ -54,10 +124,15 @@ func (mr *MessageRepository) Edit(id uint, content string) (*models.Message, err
 		&m.SenderID,
 		&m.RecipientID,
 		&m.Content,
+		&m.Type,
 		&m.ReadAt,
+		&m.DeliveredAt,
 		&m.ChatID,
 		&m.CreatedAt,
 		&m.UpdatedAt,
+		&m.Silent,
+		&m.NoForward,
+		&m.Shadowed,
 	)
 	if err != nil {
 		return nil, err
@@ -77,53 +152,82 @@ func (mr *MessageRepository) Delete(id uint) error {
 	return nil
 }
 
-func (mr *MessageRepository) GetChatMessages(chatID uint, limit, offset int) ([]*models.Message, error) {
+// GetChatMessages returns the delivered messages of a chat, newest first. Messages of a shadow-banned
+// sender are only returned to the sender.
+func (mr *MessageRepository) GetChatMessages(chatID, viewerID uint, limit, offset int) ([]*models.Message, error) {
 	query := `
 		SELECT 
 			m.id, 
 			m.sender_id, 
 			m.recipient_id, 
 			m.content, 
+			m.type,
 			m.read_at, 
+			m.delivered_at,
 			m.chat_id, 
 			m.created_at, 
 			m.updated_at,
+			m.silent,
+			m.no_forward,
 			u1.id AS sender_id, 
 			u1.username AS sender_username,
 			u2.id AS recipient_id,
 			u2.username AS recipient_username,
 			p.id AS parent_id,
-			p.content AS parent_content
+			p.content AS parent_content,
+			m.is_forwarded,
+			m.forwarded_from_message_id,
+			m.forwarded_from_chat_id,
+			m.forwarded_from_user_id,
+			m.forwarded_from_date,
+			fu.username AS forwarded_from_username,
+			fu.first_name AS forwarded_from_first_name,
+			fu.last_name AS forwarded_from_last_name,
+			fu.profile_picture AS forwarded_from_profile_picture,
+			m.quote_text,
+			m.quote_offset,
+			rs.reply_count,
+			rs.last_reply_at
 		FROM messages m
 			JOIN chats c ON m.chat_id = c.id
 			JOIN users u1 ON m.sender_id = u1.id
 			JOIN users u2 ON m.recipient_id = u2.id
 			LEFT JOIN messages p ON m.parent_id = p.id
-		WHERE c.id = $1
+			LEFT JOIN users fu ON m.forwarded_from_user_id = fu.id
+			LEFT JOIN LATERAL (` + replyStatsQuery + `) rs ON true
+		WHERE c.id = $1 AND m.scheduled_at IS NULL AND (NOT m.shadowed OR m.sender_id = $4)
 		ORDER BY m.created_at DESC
 		LIMIT $2 OFFSET $3
 	`
 
-	rows, err := mr.DB.Query(query, chatID, limit, offset)
+	rows, err := mr.DB.Query(query, chatID, limit, offset, viewerID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
 	messages := make([]*models.Message, 0)
-	messageIDs := make([]uint, 0)
 
 	for rows.Next() {
 		var msg models.Message
 		var sender, recipient models.User
 		var parentMessage models.Message
 		var parentID sql.NullInt64
+		var fwd forwardedFromRow
+		var fwdUsername sql.NullString
+		var fwdUser models.User
+		var quoteText sql.NullString
+		var quoteOffset sql.NullInt64
 
 		err := rows.Scan(
-			&msg.ID, &msg.SenderID, &msg.RecipientID, &msg.Content, &msg.ReadAt, &msg.ChatID, &msg.CreatedAt, &msg.UpdatedAt,
+			&msg.ID, &msg.SenderID, &msg.RecipientID, &msg.Content, &msg.Type, &msg.ReadAt, &msg.DeliveredAt, &msg.ChatID, &msg.CreatedAt, &msg.UpdatedAt, &msg.Silent, &msg.NoForward,
 			&sender.ID, &sender.Username,
 			&recipient.ID, &recipient.Username,
 			&parentID, &parentMessage.Content,
+			&fwd.IsForwarded, &fwd.MessageID, &fwd.ChatID, &fwd.SenderID, &fwd.Date,
+			&fwdUsername, &fwdUser.FirstName, &fwdUser.LastName, &fwdUser.ProfilePicture,
+			&quoteText, &quoteOffset,
+			&msg.ReplyCount, &msg.LastReplyAt,
 		)
 		if err != nil {
 			return nil, err
@@ -134,43 +238,27 @@ func (mr *MessageRepository) GetChatMessages(chatID uint, limit, offset int) ([]
 			msg.Parent = &parentMessage
 		}
 
+		msg.Quote = buildQuote(quoteText, quoteOffset)
+		msg.ForwardedFrom = fwd.toModel()
+		if msg.ForwardedFrom != nil && fwd.SenderID.Valid && fwdUsername.Valid {
+			fwdUser.ID = uint(fwd.SenderID.Int64)
+			fwdUser.Username = fwdUsername.String
+			msg.ForwardedFrom.Sender = &fwdUser
+		}
+
 		msg.Sender = &sender
 		msg.Recipient = &recipient
+		msg.SetStatus()
 		messages = append(messages, &msg)
-		messageIDs = append(messageIDs, msg.ID)
 	}
 
 	if err = rows.Err(); err != nil {
 		return nil, err
 	}
 
-	attachmentsQuery := `
-		SELECT 
-			id, message_id, file_path, file_name, file_type, file_size
-		FROM attachments
-		WHERE message_id = ANY($1)
-	`
-	attachmentRows, err := mr.DB.Query(attachmentsQuery, pq.Array(messageIDs))
-	if err != nil {
+	if err = mr.hydrate(messages); err != nil {
 		return nil, err
 	}
-	defer attachmentRows.Close()
-
-	attachmentsMap := make(map[uint][]*models.Attachment)
-	for attachmentRows.Next() {
-		var attachment models.Attachment
-		err := attachmentRows.Scan(
-			&attachment.ID, &attachment.MessageID, &attachment.FilePath, &attachment.FileName, &attachment.FileType, &attachment.FileSize,
-		)
-		if err != nil {
-			return nil, err
-		}
-		attachmentsMap[attachment.MessageID] = append(attachmentsMap[attachment.MessageID], &attachment)
-	}
-
-	for _, msg := range messages {
-		msg.Attachments = attachmentsMap[msg.ID]
-	}
 
 	return messages, nil
 }
@@ -182,7 +270,9 @@ func (mr *MessageRepository) GetUserMessages(senderID uint, recipientID uint, li
 			m.sender_id, 
 			m.recipient_id, 
 			m.content, 
+			m.type,
 			m.read_at, 
+			m.delivered_at,
 			m.chat_id, 
 			m.created_at, 
 			m.updated_at,
@@ -191,7 +281,7 @@ func (mr *MessageRepository) GetUserMessages(senderID uint, recipientID uint, li
 			u.phone AS user_phone
 		FROM messages AS m
 		JOIN users AS u ON m.sender_id = u.id
-		WHERE m.sender_id = $1 AND m.recipient_id = $2
+		WHERE m.sender_id = $1 AND m.recipient_id = $2 AND m.scheduled_at IS NULL
 		ORDER BY m.created_at DESC 
 		LIMIT $3 OFFSET $4
 	`
@@ -203,14 +293,13 @@ func (mr *MessageRepository) GetUserMessages(senderID uint, recipientID uint, li
 	defer rows.Close()
 
 	messages := make([]*models.Message, 0)
-	messageIDs := make([]uint, 0)
 
 	for rows.Next() {
 		var msg models.Message
 		var user models.User
 
 		err := rows.Scan(
-			&msg.ID, &msg.SenderID, &msg.RecipientID, &msg.Content, &msg.ReadAt, &msg.ChatID, &msg.CreatedAt, &msg.UpdatedAt,
+			&msg.ID, &msg.SenderID, &msg.RecipientID, &msg.Content, &msg.Type, &msg.ReadAt, &msg.DeliveredAt, &msg.ChatID, &msg.CreatedAt, &msg.UpdatedAt,
 			&user.ID, &user.Username, &user.Phone,
 		)
 		if err != nil {
@@ -219,49 +308,24 @@ func (mr *MessageRepository) GetUserMessages(senderID uint, recipientID uint, li
 
 		msg.Sender = &user
 		messages = append(messages, &msg)
-		messageIDs = append(messageIDs, msg.ID)
 	}
 
 	if err = rows.Err(); err != nil {
 		return nil, err
 	}
 
-	attachmentsQuery := `
-		SELECT 
-			id, message_id, file_path, file_name, file_type, file_size
-		FROM attachments
-		WHERE message_id = ANY($1)
-	`
-	attachmentRows, err := mr.DB.Query(attachmentsQuery, pq.Array(messageIDs))
-	if err != nil {
+	if err = mr.hydrate(messages); err != nil {
 		return nil, err
 	}
-	defer attachmentRows.Close()
-
-	attachmentsMap := make(map[uint][]*models.Attachment)
-	for attachmentRows.Next() {
-		var attachment models.Attachment
-		err := attachmentRows.Scan(
-			&attachment.ID, &attachment.MessageID, &attachment.FilePath, &attachment.FileName, &attachment.FileType, &attachment.FileSize,
-		)
-		if err != nil {
-			return nil, err
-		}
-		attachmentsMap[attachment.MessageID] = append(attachmentsMap[attachment.MessageID], &attachment)
-	}
-
-	for _, msg := range messages {
-		msg.Attachments = attachmentsMap[msg.ID]
-	}
 
 	return messages, nil
 }
 
 func (mr *MessageRepository) GetLastMessageForChat(chatID uint) (*models.Message, error) {
 	query := `
-		SELECT id, sender_id, recipient_id, content, read_at, chat_id, created_at, updated_at
+		SELECT id, sender_id, recipient_id, content, type, read_at, delivered_at, chat_id, created_at, updated_at
 		FROM messages
-		WHERE chat_id = $1
+		WHERE chat_id = $1 AND scheduled_at IS NULL AND NOT shadowed
 		ORDER BY created_at DESC
 		LIMIT 1
 	`
@@ -273,7 +337,9 @@ func (mr *MessageRepository) GetLastMessageForChat(chatID uint) (*models.Message
 		&message.SenderID,
 		&message.RecipientID,
 		&message.Content,
+		&message.Type,
 		&message.ReadAt,
+		&message.DeliveredAt,
 		&message.ChatID,
 		&message.CreatedAt,
 		&message.UpdatedAt,
@@ -291,22 +357,41 @@ func (mr *MessageRepository) GetLastMessageForChat(chatID uint) (*models.Message
 
 func (mr *MessageRepository) GetById(id uint) (*models.Message, error) {
 	query := `
-		SELECT id, sender_id, recipient_id, content, read_at, chat_id, created_at, updated_at
+		SELECT id, sender_id, recipient_id, content, type, read_at, delivered_at, chat_id, parent_id, created_at, updated_at,
+			is_forwarded, forwarded_from_message_id, forwarded_from_chat_id, forwarded_from_user_id, forwarded_from_date,
+			scheduled_at, quote_text, quote_offset, silent, no_forward, shadowed
 		FROM messages
 		WHERE id = $1
 	`
 
 	var message models.Message
+	var fwd forwardedFromRow
+	var quoteText sql.NullString
+	var quoteOffset sql.NullInt64
 
 	err := mr.DB.QueryRow(query, id).Scan(
 		&message.ID,
 		&message.SenderID,
 		&message.RecipientID,
 		&message.Content,
+		&message.Type,
 		&message.ReadAt,
+		&message.DeliveredAt,
 		&message.ChatID,
+		&message.ParentID,
 		&message.CreatedAt,
 		&message.UpdatedAt,
+		&fwd.IsForwarded,
+		&fwd.MessageID,
+		&fwd.ChatID,
+		&fwd.SenderID,
+		&fwd.Date,
+		&message.ScheduledAt,
+		&quoteText,
+		&quoteOffset,
+		&message.Silent,
+		&message.NoForward,
+		&message.Shadowed,
 	)
 
 	if err != nil {
@@ -315,9 +400,11 @@ func (mr *MessageRepository) GetById(id uint) (*models.Message, error) {
 		}
 		return nil, err
 	}
+	message.ForwardedFrom = fwd.toModel()
+	message.Quote = buildQuote(quoteText, quoteOffset)
 
 	attachmentsQuery := `
-		SELECT id, message_id, file_name, file_path, file_type, file_size, created_at, updated_at
+		SELECT id, message_id, file_name, file_path, file_type, file_size, type, duration_ms, waveform, listened_at, created_at, updated_at
 		FROM attachments
 		WHERE message_id = $1
 	`
@@ -338,6 +425,10 @@ func (mr *MessageRepository) GetById(id uint) (*models.Message, error) {
 			&attachment.FilePath,
 			&attachment.FileType,
 			&attachment.FileSize,
+			&attachment.Type,
+			&attachment.Duration,
+			pq.Array(&attachment.Waveform),
+			&attachment.ListenedAt,
 			&attachment.CreatedAt,
 			&attachment.UpdatedAt,
 		)
@@ -349,12 +440,34 @@ func (mr *MessageRepository) GetById(id uint) (*models.Message, error) {
 
 	message.Attachments = attachments
 
+	if err = mr.loadEntities([]*models.Message{&message}); err != nil {
+		return nil, err
+	}
+	if err = mr.loadLinkPreviews([]*models.Message{&message}); err != nil {
+		return nil, err
+	}
+	if err = mr.loadPolls([]*models.Message{&message}); err != nil {
+		return nil, err
+	}
+	if err = mr.loadLocations([]*models.Message{&message}); err != nil {
+		return nil, err
+	}
+	if err = mr.loadContacts([]*models.Message{&message}); err != nil {
+		return nil, err
+	}
+	if err = mr.loadStickers([]*models.Message{&message}); err != nil {
+		return nil, err
+	}
+	if err = mr.loadGifs([]*models.Message{&message}); err != nil {
+		return nil, err
+	}
+
 	return &message, nil
 }
 
 func (mr *MessageRepository) MarkAsRead(id uint) (*time.Time, error) {
 	query := `
-		UPDATE messages SET read_at = NOW() WHERE id = $1
+		UPDATE messages SET read_at = NOW(), delivered_at = COALESCE(delivered_at, NOW()) WHERE id = $1
 		RETURNING read_at
 	`
 
@@ -363,3 +476,849 @@ func (mr *MessageRepository) MarkAsRead(id uint) (*time.Time, error) {
 	err := mr.DB.QueryRow(query, id).Scan(&readAt)
 	return &readAt, err
 }
+
+// MarkAsDelivered marks the given messages of a recipient as delivered, skipping the ones that already are.
+// It returns the delivery time of every message it marked.
+func (mr *MessageRepository) MarkAsDelivered(recipientID uint, messageIDs []uint) (map[uint]time.Time, error) {
+	query := `
+		UPDATE messages SET delivered_at = NOW()
+		WHERE id = ANY($1) AND recipient_id = $2 AND delivered_at IS NULL AND scheduled_at IS NULL
+		RETURNING id, delivered_at
+	`
+
+	rows, err := mr.DB.Query(query, pq.Array(messageIDs), recipientID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	delivered := make(map[uint]time.Time)
+	for rows.Next() {
+		var id uint
+		var deliveredAt time.Time
+		if err := rows.Scan(&id, &deliveredAt); err != nil {
+			return nil, err
+		}
+		delivered[id] = deliveredAt
+	}
+
+	return delivered, rows.Err()
+}
+
+// GetScheduled returns the pending scheduled messages of a sender in a chat, soonest first.
+func (mr *MessageRepository) GetScheduled(chatID, senderID uint) ([]*models.Message, error) {
+	query := `
+		SELECT id, sender_id, recipient_id, content, type, chat_id, parent_id, scheduled_at, created_at, updated_at
+		FROM messages
+		WHERE chat_id = $1 AND sender_id = $2 AND scheduled_at IS NOT NULL
+		ORDER BY scheduled_at
+	`
+
+	rows, err := mr.DB.Query(query, chatID, senderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := make([]*models.Message, 0)
+	for rows.Next() {
+		var msg models.Message
+		err := rows.Scan(
+			&msg.ID, &msg.SenderID, &msg.RecipientID, &msg.Content, &msg.Type, &msg.ChatID, &msg.ParentID, &msg.ScheduledAt, &msg.CreatedAt, &msg.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, &msg)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err = mr.hydrate(messages); err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// EditScheduled changes the content of a message that is still waiting to be delivered.
+// It returns sql.ErrNoRows if the message was already delivered.
+func (mr *MessageRepository) EditScheduled(id uint, content string) (*models.Message, error) {
+	query := `
+		UPDATE messages
+		SET content = $1, updated_at = NOW()
+		WHERE id = $2 AND scheduled_at IS NOT NULL
+		RETURNING id, sender_id, recipient_id, content, type, chat_id, parent_id, scheduled_at, created_at, updated_at
+	`
+
+	var m models.Message
+	err := mr.DB.QueryRow(query, content, id).Scan(
+		&m.ID, &m.SenderID, &m.RecipientID, &m.Content, &m.Type, &m.ChatID, &m.ParentID, &m.ScheduledAt, &m.CreatedAt, &m.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+// Reschedule moves the delivery time of a message that is still waiting to be delivered.
+// It returns sql.ErrNoRows if the message was already delivered.
+func (mr *MessageRepository) Reschedule(id uint, scheduledAt time.Time) (*models.Message, error) {
+	query := `
+		UPDATE messages
+		SET scheduled_at = $1, updated_at = NOW()
+		WHERE id = $2 AND scheduled_at IS NOT NULL
+		RETURNING id, sender_id, recipient_id, content, type, chat_id, parent_id, scheduled_at, created_at, updated_at
+	`
+
+	var m models.Message
+	err := mr.DB.QueryRow(query, scheduledAt, id).Scan(
+		&m.ID, &m.SenderID, &m.RecipientID, &m.Content, &m.Type, &m.ChatID, &m.ParentID, &m.ScheduledAt, &m.CreatedAt, &m.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+// DeleteScheduled deletes a message only if it hasn't been delivered yet and reports whether it was deleted.
+func (mr *MessageRepository) DeleteScheduled(id uint) (bool, error) {
+	query := `DELETE FROM messages WHERE id = $1 AND scheduled_at IS NOT NULL`
+
+	result, err := mr.DB.Exec(query, id)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// ClaimDueScheduled marks up to limit due scheduled messages as delivered and returns them.
+// Rows locked by another instance are skipped, so each message is claimed exactly once.
+// Messages of suspended or read-only senders stay scheduled until the restriction ends, and a message
+// is shadowed if its sender got shadow banned after scheduling it.
+func (mr *MessageRepository) ClaimDueScheduled(limit int) ([]*models.Message, error) {
+	query := `
+		UPDATE messages
+		SET scheduled_at = NULL, created_at = NOW(), updated_at = NOW(), shadowed = shadowed OR EXISTS (
+			SELECT 1 FROM user_restrictions WHERE user_id = messages.sender_id AND type = $4 AND expires_at > NOW()
+		)
+		WHERE id IN (
+			SELECT id
+			FROM messages
+			WHERE scheduled_at IS NOT NULL AND scheduled_at <= NOW() AND NOT EXISTS (
+				SELECT 1 FROM user_restrictions
+				WHERE user_id = messages.sender_id AND type IN ($2, $3) AND expires_at > NOW()
+			)
+			ORDER BY scheduled_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, sender_id, recipient_id, content, type, read_at, delivered_at, chat_id, parent_id, created_at, updated_at, silent, no_forward, shadowed
+	`
+
+	rows, err := mr.DB.Query(query, limit, models.SuspensionRestriction, models.ReadOnlyRestriction, models.ShadowBanRestriction)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := make([]*models.Message, 0)
+	for rows.Next() {
+		var msg models.Message
+		err := rows.Scan(
+			&msg.ID, &msg.SenderID, &msg.RecipientID, &msg.Content, &msg.Type, &msg.ReadAt, &msg.DeliveredAt, &msg.ChatID, &msg.ParentID, &msg.CreatedAt, &msg.UpdatedAt, &msg.Silent, &msg.NoForward, &msg.Shadowed,
+		)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, &msg)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err = mr.hydrate(messages); err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// GetReplies returns the delivered replies to a message in chronological order.
+// Replies of a shadow-banned sender are only returned to the sender.
+func (mr *MessageRepository) GetReplies(parentID, viewerID uint, limit, offset int) ([]*models.Message, error) {
+	query := `
+		SELECT 
+			m.id, 
+			m.sender_id, 
+			m.recipient_id, 
+			m.content, 
+			m.type,
+			m.read_at, 
+			m.delivered_at,
+			m.chat_id, 
+			m.parent_id,
+			m.quote_text,
+			m.quote_offset,
+			m.created_at, 
+			m.updated_at,
+			m.silent,
+			m.no_forward,
+			u.id AS sender_id,
+			u.username AS sender_username,
+			rs.reply_count,
+			rs.last_reply_at
+		FROM messages m
+			JOIN users u ON m.sender_id = u.id
+			LEFT JOIN LATERAL (` + replyStatsQuery + `) rs ON true
+		WHERE m.parent_id = $1 AND m.scheduled_at IS NULL AND (NOT m.shadowed OR m.sender_id = $4)
+		ORDER BY m.created_at
+		LIMIT $2 OFFSET $3
+	`
+
+	rows, err := mr.DB.Query(query, parentID, limit, offset, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := make([]*models.Message, 0)
+	for rows.Next() {
+		var msg models.Message
+		var sender models.User
+		var quoteText sql.NullString
+		var quoteOffset sql.NullInt64
+
+		err := rows.Scan(
+			&msg.ID, &msg.SenderID, &msg.RecipientID, &msg.Content, &msg.Type, &msg.ReadAt, &msg.DeliveredAt, &msg.ChatID, &msg.ParentID,
+			&quoteText, &quoteOffset, &msg.CreatedAt, &msg.UpdatedAt, &msg.Silent, &msg.NoForward,
+			&sender.ID, &sender.Username,
+			&msg.ReplyCount, &msg.LastReplyAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		msg.Quote = buildQuote(quoteText, quoteOffset)
+		msg.Sender = &sender
+		messages = append(messages, &msg)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err = mr.hydrate(messages); err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// GetReplyStats returns the number of delivered replies to a message and the time of the latest one.
+func (mr *MessageRepository) GetReplyStats(parentID uint) (int, *time.Time, error) {
+	query := `
+		SELECT COUNT(id), MAX(created_at)
+		FROM messages
+		WHERE parent_id = $1 AND scheduled_at IS NULL AND NOT shadowed
+	`
+
+	var count int
+	var lastReplyAt *time.Time
+	err := mr.DB.QueryRow(query, parentID).Scan(&count, &lastReplyAt)
+	return count, lastReplyAt, err
+}
+
+// GetMentions returns the most recent delivered messages that mention the user, across all of their chats.
+func (mr *MessageRepository) GetMentions(userID uint, limit, offset int) ([]*models.Message, error) {
+	query := `
+		SELECT 
+			m.id, 
+			m.sender_id, 
+			m.recipient_id, 
+			m.content, 
+			m.type,
+			m.read_at, 
+			m.delivered_at,
+			m.chat_id, 
+			m.parent_id,
+			m.created_at, 
+			m.updated_at,
+			m.silent,
+			m.no_forward,
+			u.id AS sender_id,
+			u.username AS sender_username
+		FROM messages m
+			JOIN users u ON m.sender_id = u.id
+		WHERE m.recipient_id = $1
+			AND m.scheduled_at IS NULL
+			AND NOT m.shadowed
+			AND EXISTS (
+				SELECT 1 FROM message_entities e 
+				WHERE e.message_id = m.id AND e.type = $2 AND e.user_id = $1
+			)
+		ORDER BY m.created_at DESC
+		LIMIT $3 OFFSET $4
+	`
+
+	rows, err := mr.DB.Query(query, userID, models.MentionEntity, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := make([]*models.Message, 0)
+	for rows.Next() {
+		var msg models.Message
+		var sender models.User
+
+		err := rows.Scan(
+			&msg.ID, &msg.SenderID, &msg.RecipientID, &msg.Content, &msg.Type, &msg.ReadAt, &msg.DeliveredAt, &msg.ChatID, &msg.ParentID, &msg.CreatedAt, &msg.UpdatedAt, &msg.Silent, &msg.NoForward,
+			&sender.ID, &sender.Username,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		msg.Sender = &sender
+		messages = append(messages, &msg)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err = mr.hydrate(messages); err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// GetStarred returns the user's bookmarks, most recently starred first, each with its message.
+// With a tag, only the bookmarks that have it are returned.
+func (mr *MessageRepository) GetStarred(userID uint, tag string, limit, offset int) ([]*models.Bookmark, error) {
+	query := `
+		SELECT 
+			b.id, b.user_id, b.message_id, b.tags, b.created_at,
+			m.id, 
+			m.sender_id, 
+			m.recipient_id, 
+			m.content, 
+			m.type,
+			m.read_at, 
+			m.delivered_at,
+			m.chat_id, 
+			m.parent_id,
+			m.created_at, 
+			m.updated_at,
+			m.silent,
+			m.no_forward,
+			m.is_forwarded,
+			m.forwarded_from_message_id,
+			m.forwarded_from_chat_id,
+			m.forwarded_from_user_id,
+			m.forwarded_from_date,
+			m.quote_text,
+			m.quote_offset,
+			u.id AS sender_id,
+			u.username AS sender_username,
+			u.first_name AS sender_first_name,
+			u.last_name AS sender_last_name,
+			u.profile_picture AS sender_profile_picture
+		FROM bookmarks b
+			JOIN messages m ON b.message_id = m.id
+			JOIN users u ON m.sender_id = u.id
+		WHERE b.user_id = $1 AND ($2 = '' OR $2 = ANY(b.tags))
+		ORDER BY b.created_at DESC
+		LIMIT $3 OFFSET $4
+	`
+
+	rows, err := mr.DB.Query(query, userID, tag, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bookmarks := make([]*models.Bookmark, 0)
+	messages := make([]*models.Message, 0)
+	for rows.Next() {
+		var bookmark models.Bookmark
+		var msg models.Message
+		var sender models.User
+		var fwd forwardedFromRow
+		var quoteText sql.NullString
+		var quoteOffset sql.NullInt64
+
+		err := rows.Scan(
+			&bookmark.ID, &bookmark.UserID, &bookmark.MessageID, pq.Array(&bookmark.Tags), &bookmark.CreatedAt,
+			&msg.ID, &msg.SenderID, &msg.RecipientID, &msg.Content, &msg.Type, &msg.ReadAt, &msg.DeliveredAt, &msg.ChatID, &msg.ParentID, &msg.CreatedAt, &msg.UpdatedAt, &msg.Silent, &msg.NoForward,
+			&fwd.IsForwarded, &fwd.MessageID, &fwd.ChatID, &fwd.SenderID, &fwd.Date,
+			&quoteText, &quoteOffset,
+			&sender.ID, &sender.Username, &sender.FirstName, &sender.LastName, &sender.ProfilePicture,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		msg.Quote = buildQuote(quoteText, quoteOffset)
+		msg.ForwardedFrom = fwd.toModel()
+		msg.Sender = &sender
+		msg.SetStatus()
+		bookmark.Message = &msg
+		bookmarks = append(bookmarks, &bookmark)
+		messages = append(messages, &msg)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err = mr.hydrate(messages); err != nil {
+		return nil, err
+	}
+
+	return bookmarks, nil
+}
+
+// forwardedFromRow holds the nullable forward columns of a messages row.
+type forwardedFromRow struct {
+	IsForwarded bool
+	MessageID   sql.NullInt64
+	ChatID      sql.NullInt64
+	SenderID    sql.NullInt64
+	Date        sql.NullTime
+}
+
+func (f *forwardedFromRow) toModel() *models.ForwardedFrom {
+	if !f.IsForwarded {
+		return nil
+	}
+
+	forwardedFrom := &models.ForwardedFrom{
+		MessageID: nullUint(f.MessageID),
+		ChatID:    nullUint(f.ChatID),
+		SenderID:  nullUint(f.SenderID),
+		Hidden:    !f.SenderID.Valid,
+	}
+	if f.Date.Valid {
+		forwardedFrom.Date = f.Date.Time
+	}
+
+	return forwardedFrom
+}
+
+func nullUint(v sql.NullInt64) *uint {
+	if !v.Valid {
+		return nil
+	}
+	u := uint(v.Int64)
+	return &u
+}
+
+// hydrate loads the attachments and entities of the given messages, along with the data of their message type.
+func (mr *MessageRepository) hydrate(messages []*models.Message) error {
+	if err := mr.loadAttachments(messages); err != nil {
+		return err
+	}
+	if err := mr.loadEntities(messages); err != nil {
+		return err
+	}
+	if err := mr.loadLinkPreviews(messages); err != nil {
+		return err
+	}
+	if err := mr.loadPolls(messages); err != nil {
+		return err
+	}
+	if err := mr.loadLocations(messages); err != nil {
+		return err
+	}
+	if err := mr.loadContacts(messages); err != nil {
+		return err
+	}
+	if err := mr.loadStickers(messages); err != nil {
+		return err
+	}
+	return mr.loadGifs(messages)
+}
+
+// loadAttachments fetches the attachments of all given messages with a single query.
+func (mr *MessageRepository) loadAttachments(messages []*models.Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	messageIDs := make([]uint, 0, len(messages))
+	for _, msg := range messages {
+		messageIDs = append(messageIDs, msg.ID)
+	}
+
+	query := `
+		SELECT 
+			id, message_id, file_path, file_name, file_type, file_size, type, duration_ms, waveform, listened_at
+		FROM attachments
+		WHERE message_id = ANY($1)
+	`
+	rows, err := mr.DB.Query(query, pq.Array(messageIDs))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	attachmentsMap := make(map[uint][]*models.Attachment)
+	for rows.Next() {
+		var attachment models.Attachment
+		err := rows.Scan(
+			&attachment.ID, &attachment.MessageID, &attachment.FilePath, &attachment.FileName, &attachment.FileType, &attachment.FileSize,
+			&attachment.Type, &attachment.Duration, pq.Array(&attachment.Waveform), &attachment.ListenedAt,
+		)
+		if err != nil {
+			return err
+		}
+		attachmentsMap[attachment.MessageID] = append(attachmentsMap[attachment.MessageID], &attachment)
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, msg := range messages {
+		msg.Attachments = attachmentsMap[msg.ID]
+	}
+
+	return nil
+}
+
+func buildQuote(text sql.NullString, offset sql.NullInt64) *models.Quote {
+	if !text.Valid {
+		return nil
+	}
+	return &models.Quote{Text: text.String, Offset: int(offset.Int64)}
+}
+
+// loadEntities fetches the entities of all given messages with a single query.
+func (mr *MessageRepository) loadEntities(messages []*models.Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	messageIDs := make([]uint, 0, len(messages))
+	for _, msg := range messages {
+		messageIDs = append(messageIDs, msg.ID)
+	}
+
+	query := `
+		SELECT id, message_id, type, "offset", length, user_id, url, language
+		FROM message_entities
+		WHERE message_id = ANY($1)
+		ORDER BY "offset"
+	`
+	rows, err := mr.DB.Query(query, pq.Array(messageIDs))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	entitiesMap := make(map[uint][]*models.MessageEntity)
+	for rows.Next() {
+		var entity models.MessageEntity
+		err := rows.Scan(&entity.ID, &entity.MessageID, &entity.Type, &entity.Offset, &entity.Length, &entity.UserID, &entity.URL, &entity.Language)
+		if err != nil {
+			return err
+		}
+		entitiesMap[entity.MessageID] = append(entitiesMap[entity.MessageID], &entity)
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, msg := range messages {
+		msg.Entities = entitiesMap[msg.ID]
+	}
+
+	return nil
+}
+
+// loadLinkPreviews fetches the link previews of all given messages with a single query.
+func (mr *MessageRepository) loadLinkPreviews(messages []*models.Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	messageIDs := make([]uint, 0, len(messages))
+	for _, msg := range messages {
+		messageIDs = append(messageIDs, msg.ID)
+	}
+
+	query := `
+		SELECT message_id, url, title, description, image_url, site_name
+		FROM link_previews
+		WHERE message_id = ANY($1)
+	`
+	rows, err := mr.DB.Query(query, pq.Array(messageIDs))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	previewsMap := make(map[uint]*models.LinkPreview)
+	for rows.Next() {
+		var preview models.LinkPreview
+		err := rows.Scan(&preview.MessageID, &preview.URL, &preview.Title, &preview.Description, &preview.ImageURL, &preview.SiteName)
+		if err != nil {
+			return err
+		}
+		previewsMap[preview.MessageID] = &preview
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, msg := range messages {
+		msg.LinkPreview = previewsMap[msg.ID]
+	}
+
+	return nil
+}
+
+// loadPolls fetches the polls of the poll messages among the given ones, with open quiz answers hidden.
+func (mr *MessageRepository) loadPolls(messages []*models.Message) error {
+	messageIDs := make([]uint, 0)
+	for _, msg := range messages {
+		if msg.Type == models.PollMessage {
+			messageIDs = append(messageIDs, msg.ID)
+		}
+	}
+	if len(messageIDs) == 0 {
+		return nil
+	}
+
+	query := `
+		SELECT ` + pollColumns + `
+		FROM polls
+		WHERE message_id = ANY($1)
+	`
+	polls, err := queryPolls(mr.DB, query, pq.Array(messageIDs))
+	if err != nil {
+		return err
+	}
+
+	pollsMap := make(map[uint]*models.Poll)
+	for _, poll := range polls {
+		poll.HideAnswer()
+		pollsMap[poll.MessageID] = poll
+	}
+
+	for _, msg := range messages {
+		if poll, ok := pollsMap[msg.ID]; ok {
+			msg.Poll = poll
+		}
+	}
+
+	return nil
+}
+
+// loadLocations fetches the latest positions of the location messages among the given ones.
+func (mr *MessageRepository) loadLocations(messages []*models.Message) error {
+	messageIDs := make([]uint, 0)
+	for _, msg := range messages {
+		if msg.Type == models.LocationMessage {
+			messageIDs = append(messageIDs, msg.ID)
+		}
+	}
+	if len(messageIDs) == 0 {
+		return nil
+	}
+
+	query := `SELECT ` + locationColumns + ` FROM locations WHERE message_id = ANY($1)`
+	rows, err := mr.DB.Query(query, pq.Array(messageIDs))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	locationsMap := make(map[uint]*models.Location)
+	for rows.Next() {
+		location, err := scanLocation(rows)
+		if err != nil {
+			return err
+		}
+		locationsMap[location.MessageID] = location
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, msg := range messages {
+		if location, ok := locationsMap[msg.ID]; ok {
+			msg.Location = location
+		}
+	}
+
+	return nil
+}
+
+// loadContacts fetches the contact cards of the contact messages among the given ones, with the linked users.
+func (mr *MessageRepository) loadContacts(messages []*models.Message) error {
+	messageIDs := make([]uint, 0)
+	for _, msg := range messages {
+		if msg.Type == models.ContactMessage {
+			messageIDs = append(messageIDs, msg.ID)
+		}
+	}
+	if len(messageIDs) == 0 {
+		return nil
+	}
+
+	query := `
+		SELECT 
+			c.id, c.message_id, c.first_name, c.last_name, c.phone, c.user_id, c.vcard,
+			u.username, u.first_name, u.last_name, u.profile_picture
+		FROM contacts c
+			LEFT JOIN users u ON c.user_id = u.id
+		WHERE c.message_id = ANY($1)
+	`
+	rows, err := mr.DB.Query(query, pq.Array(messageIDs))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	contactsMap := make(map[uint]*models.Contact)
+	for rows.Next() {
+		var contact models.Contact
+		var username sql.NullString
+		var user models.User
+
+		err := rows.Scan(
+			&contact.ID, &contact.MessageID, &contact.FirstName, &contact.LastName, &contact.Phone, &contact.UserID, &contact.VCard,
+			&username, &user.FirstName, &user.LastName, &user.ProfilePicture,
+		)
+		if err != nil {
+			return err
+		}
+
+		if contact.UserID != nil && username.Valid {
+			user.ID = *contact.UserID
+			user.Username = username.String
+			contact.User = &user
+		}
+		contactsMap[contact.MessageID] = &contact
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, msg := range messages {
+		if contact, ok := contactsMap[msg.ID]; ok {
+			msg.Contact = contact
+		}
+	}
+
+	return nil
+}
+
+// loadStickers fetches the stickers of the sticker messages among the given ones.
+func (mr *MessageRepository) loadStickers(messages []*models.Message) error {
+	messageIDs := make([]uint, 0)
+	for _, msg := range messages {
+		if msg.Type == models.StickerMessage {
+			messageIDs = append(messageIDs, msg.ID)
+		}
+	}
+	if len(messageIDs) == 0 {
+		return nil
+	}
+
+	query := `
+		SELECT m.id, s.id, s.pack_id, s.file_path, s.emojis, s.position
+		FROM messages m
+			JOIN stickers s ON m.sticker_id = s.id
+		WHERE m.id = ANY($1)
+	`
+	rows, err := mr.DB.Query(query, pq.Array(messageIDs))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	stickersMap := make(map[uint]*models.Sticker)
+	for rows.Next() {
+		var messageID uint
+		var sticker models.Sticker
+		err := rows.Scan(&messageID, &sticker.ID, &sticker.PackID, &sticker.FilePath, pq.Array(&sticker.Emojis), &sticker.Position)
+		if err != nil {
+			return err
+		}
+		stickersMap[messageID] = &sticker
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, msg := range messages {
+		if sticker, ok := stickersMap[msg.ID]; ok {
+			msg.Sticker = sticker
+		}
+	}
+
+	return nil
+}
+
+// loadGifs fetches the GIFs of the GIF messages among the given ones.
+func (mr *MessageRepository) loadGifs(messages []*models.Message) error {
+	messageIDs := make([]uint, 0)
+	for _, msg := range messages {
+		if msg.Type == models.GifMessage {
+			messageIDs = append(messageIDs, msg.ID)
+		}
+	}
+	if len(messageIDs) == 0 {
+		return nil
+	}
+
+	query := `
+		SELECT id, message_id, provider, external_id, url, preview_url, width, height, title
+		FROM gifs
+		WHERE message_id = ANY($1)
+	`
+	rows, err := mr.DB.Query(query, pq.Array(messageIDs))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	gifsMap := make(map[uint]*models.Gif)
+	for rows.Next() {
+		var gif models.Gif
+		err := rows.Scan(&gif.ID, &gif.MessageID, &gif.Provider, &gif.ExternalID, &gif.URL, &gif.PreviewURL, &gif.Width, &gif.Height, &gif.Title)
+		if err != nil {
+			return err
+		}
+		gifsMap[gif.MessageID] = &gif
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, msg := range messages {
+		if gif, ok := gifsMap[msg.ID]; ok {
+			msg.Gif = gif
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,201 @@
+package repository
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/drTragger/messenger-backend/internal/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SearchLimit         = 20
+	DefaultSearchConfig = "messenger_en"
+)
+
+// SearchConfigs maps supported locales to the text search configurations created by the migrations.
+var SearchConfigs = map[string]string{
+	"en": "messenger_en",
+	"uk": "messenger_uk",
+	"pl": "messenger_pl",
+}
+
+// escapedContent is the message content with the HTML special characters escaped. Snippets are highlighted
+// with <mark> tags, so the content is escaped first, or a snippet could carry markup written by the sender.
+// The text search parser reads the escapes as entities, so they never match a query.
+const escapedContent = `REPLACE(REPLACE(REPLACE(REPLACE(REPLACE(COALESCE(m.content, ''),
+	'&', '&amp;'), '<', '&lt;'), '>', '&gt;'), '"', '&quot;'), '''', '&#39;')`
+
+// MessageSearchFilter describes a full-text search over the chats of UserID.
+type MessageSearchFilter struct {
+	UserID         uint
+	ChatID         *uint
+	Query          string
+	Config         string
+	SenderID       *uint
+	From           *time.Time
+	To             *time.Time
+	HasAttachments *bool
+	Cursor         *MessageCursor
+	Limit          int
+}
+
+// MessageCursor points at the last message of a search page. Results are ordered from newest to oldest.
+type MessageCursor struct {
+	CreatedAt time.Time
+	ID        uint
+}
+
+func (c *MessageCursor) Encode() string {
+	raw := fmt.Sprintf("%s|%d", c.CreatedAt.UTC().Format(time.RFC3339Nano), c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func DecodeMessageCursor(cursor string) (*MessageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := strings.SplitN(string(raw), "|", 2)
+	if len(parts) != 2 {
+		return nil, errors.New("malformed cursor")
+	}
+
+	createdAt, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MessageCursor{CreatedAt: createdAt, ID: uint(id)}, nil
+}
+
+// Search runs a full-text search over delivered messages. Only chats the user participates in are searched.
+func (mr *MessageRepository) Search(filter *MessageSearchFilter) (*models.MessageSearchPage, error) {
+	config := filter.Config
+	if config == "" {
+		config = DefaultSearchConfig
+	}
+
+	args := []interface{}{config, filter.Query, filter.UserID}
+	conditions := []string{
+		"m.search_vector @@ websearch_to_tsquery($1::regconfig, $2)",
+		"(c.user1_id = $3 OR c.user2_id = $3)",
+		"m.scheduled_at IS NULL",
+		"(NOT m.shadowed OR m.sender_id = $3)",
+	}
+	addArg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if filter.ChatID != nil {
+		conditions = append(conditions, "m.chat_id = "+addArg(*filter.ChatID))
+	}
+	if filter.SenderID != nil {
+		conditions = append(conditions, "m.sender_id = "+addArg(*filter.SenderID))
+	}
+	if filter.From != nil {
+		conditions = append(conditions, "m.created_at >= "+addArg(*filter.From))
+	}
+	if filter.To != nil {
+		conditions = append(conditions, "m.created_at <= "+addArg(*filter.To))
+	}
+	if filter.HasAttachments != nil {
+		exists := "EXISTS (SELECT 1 FROM attachments a WHERE a.message_id = m.id)"
+		if !*filter.HasAttachments {
+			exists = "NOT " + exists
+		}
+		conditions = append(conditions, exists)
+	}
+	if filter.Cursor != nil {
+		createdAt := addArg(filter.Cursor.CreatedAt)
+		id := addArg(filter.Cursor.ID)
+		conditions = append(conditions, fmt.Sprintf("(m.created_at, m.id) < (%s, %s)", createdAt, id))
+	}
+
+	// Fetch one extra row to know whether there is a next page
+	limit := addArg(filter.Limit + 1)
+
+	query := `
+		SELECT 
+			m.id, 
+			m.sender_id, 
+			m.recipient_id, 
+			m.content, 
+			m.type,
+			m.read_at, 
+			m.delivered_at,
+			m.chat_id, 
+			m.parent_id,
+			m.created_at, 
+			m.updated_at,
+			m.silent,
+			m.no_forward,
+			u.id AS sender_id,
+			u.username AS sender_username,
+			ts_headline(
+				$1::regconfig, ` + escapedContent + `, websearch_to_tsquery($1::regconfig, $2),
+				'StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=20, MinWords=5'
+			) AS snippet
+		FROM messages m
+			JOIN chats c ON m.chat_id = c.id
+			JOIN users u ON m.sender_id = u.id
+		WHERE ` + strings.Join(conditions, " AND ") + `
+		ORDER BY m.created_at DESC, m.id DESC
+		LIMIT ` + limit
+
+	rows, err := mr.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	page := &models.MessageSearchPage{Results: make([]*models.MessageSearchResult, 0)}
+	messages := make([]*models.Message, 0)
+	for rows.Next() {
+		var msg models.Message
+		var sender models.User
+		var result models.MessageSearchResult
+
+		err := rows.Scan(
+			&msg.ID, &msg.SenderID, &msg.RecipientID, &msg.Content, &msg.Type, &msg.ReadAt, &msg.DeliveredAt, &msg.ChatID, &msg.ParentID, &msg.CreatedAt, &msg.UpdatedAt, &msg.Silent, &msg.NoForward,
+			&sender.ID, &sender.Username,
+			&result.Snippet,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		msg.Sender = &sender
+		result.Message = &msg
+		page.Results = append(page.Results, &result)
+		messages = append(messages, &msg)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(page.Results) > filter.Limit {
+		page.Results = page.Results[:filter.Limit]
+		messages = messages[:filter.Limit]
+
+		last := messages[len(messages)-1]
+		cursor := (&MessageCursor{CreatedAt: last.CreatedAt, ID: last.ID}).Encode()
+		page.NextCursor = &cursor
+	}
+
+	if err = mr.hydrate(messages); err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/drTragger/messenger-backend/internal/models"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	ModerationFlagsLimit  = 20
+	ModerationFlagsOffset = 0
+)
+
+type ModerationRepository struct {
+	DB    DBTX
+	Cache *redis.Client
+}
+
+func NewModerationRepository(db *sql.DB, cache *redis.Client) *ModerationRepository {
+	return &ModerationRepository{
+		DB:    db,
+		Cache: cache,
+	}
+}
+
+// WithTx returns a copy of the repository that runs its queries in the given transaction.
+func (mr *ModerationRepository) WithTx(tx *sql.Tx) *ModerationRepository {
+	return &ModerationRepository{
+		DB:    tx,
+		Cache: mr.Cache,
+	}
+}
+
+// CreateFlag puts a flagged message in the review queue.
+func (mr *ModerationRepository) CreateFlag(flag *models.ModerationFlag) error {
+	query := `
+		INSERT INTO moderation_flags (message_id, sender_id, filter, reason, details, status, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		RETURNING id, status, created_at
+	`
+
+	return mr.DB.QueryRow(query, flag.MessageID, flag.SenderID, flag.Filter, flag.Reason, flag.Details, models.PendingFlag).
+		Scan(&flag.ID, &flag.Status, &flag.CreatedAt)
+}
+
+// GetFlags returns the flags with the given status, oldest first.
+func (mr *ModerationRepository) GetFlags(status string, limit, offset int) ([]*models.ModerationFlag, error) {
+	query := `
+		SELECT id, message_id, sender_id, filter, reason, details, status, reviewed_by, reviewed_at, created_at
+		FROM moderation_flags
+		WHERE status = $1
+		ORDER BY created_at, id
+		LIMIT $2 OFFSET $3
+	`
+
+	rows, err := mr.DB.Query(query, status, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	flags := make([]*models.ModerationFlag, 0)
+	for rows.Next() {
+		flag, err := scanModerationFlag(rows)
+		if err != nil {
+			return nil, err
+		}
+		flags = append(flags, flag)
+	}
+	return flags, rows.Err()
+}
+
+// GetFlagByID returns a flag, or nil if it doesn't exist.
+func (mr *ModerationRepository) GetFlagByID(id uint) (*models.ModerationFlag, error) {
+	query := `
+		SELECT id, message_id, sender_id, filter, reason, details, status, reviewed_by, reviewed_at, created_at
+		FROM moderation_flags
+		WHERE id = $1
+	`
+
+	flag, err := scanModerationFlag(mr.DB.QueryRow(query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return flag, err
+}
+
+// ReviewFlag resolves a pending flag. Removing the message also resolves the other pending flags of the message.
+func (mr *ModerationRepository) ReviewFlag(id uint, status string, reviewerID uint) error {
+	query := `
+		UPDATE moderation_flags
+		SET status = $2, reviewed_by = $3, reviewed_at = NOW()
+		WHERE status = 'pending'
+		  AND (id = $1 OR ($2 = 'removed' AND message_id = (SELECT message_id FROM moderation_flags WHERE id = $1)))
+	`
+
+	_, err := mr.DB.Exec(query, id, status, reviewerID)
+	return err
+}
+
+// CountRepeats counts how many times the user sent the same text within the window, including this time.
+func (mr *ModerationRepository) CountRepeats(ctx context.Context, userID uint, text string, window time.Duration) (int64, error) {
+	hash := sha256.Sum256([]byte(text))
+	key := fmt.Sprintf("user:%d:repeats:%s", userID, hex.EncodeToString(hash[:]))
+
+	count, err := mr.Cache.Incr(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	// The window starts with the first message, so a steady stream of repeats can't keep it open
+	if count == 1 {
+		if err := mr.Cache.Expire(ctx, key, window).Err(); err != nil {
+			return 0, err
+		}
+	}
+	return count, nil
+}
+
+func scanModerationFlag(row interface{ Scan(...interface{}) error }) (*models.ModerationFlag, error) {
+	var flag models.ModerationFlag
+	var messageID, reviewedBy sql.NullInt64
+	err := row.Scan(&flag.ID, &messageID, &flag.SenderID, &flag.Filter, &flag.Reason, &flag.Details, &flag.Status, &reviewedBy, &flag.ReviewedAt, &flag.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	flag.MessageID = nullUint(messageID)
+	flag.ReviewedBy = nullUint(reviewedBy)
+	return &flag, nil
+}
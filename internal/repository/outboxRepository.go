@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"github.com/drTragger/messenger-backend/internal/models"
+	"sort"
+	"time"
+)
+
+type OutboxRepository struct {
+	DB DBTX
+}
+
+func NewOutboxRepository(db *sql.DB) *OutboxRepository {
+	return &OutboxRepository{
+		DB: db,
+	}
+}
+
+// WithTx returns a copy of the repository that runs its queries in the given transaction.
+func (or *OutboxRepository) WithTx(tx *sql.Tx) *OutboxRepository {
+	return &OutboxRepository{
+		DB: tx,
+	}
+}
+
+// Create stores an event for a user. Run it in the transaction of the change the event reports.
+func (or *OutboxRepository) Create(userID uint, event string, message interface{}) error {
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO outbox_events (user_id, event, payload, next_attempt_at, created_at)
+		VALUES ($1, $2, $3, NOW(), NOW())
+	`
+
+	_, err = or.DB.Exec(query, userID, event, payload)
+	return err
+}
+
+// Claim leases up to limit pending events that are due for publishing. Claimed events are not due
+// again until the lease expires, and rows locked by another relay are skipped, so several relays
+// never publish the same event at once. Events are returned in the order they were created.
+func (or *OutboxRepository) Claim(limit int, lease time.Duration) ([]*models.OutboxEvent, error) {
+	query := `
+		UPDATE outbox_events
+		SET next_attempt_at = NOW() + $2 * INTERVAL '1 millisecond'
+		WHERE id IN (
+			SELECT id
+			FROM outbox_events
+			WHERE delivered_at IS NULL AND failed_at IS NULL AND next_attempt_at <= NOW()
+			ORDER BY id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, user_id, event, payload, attempts, created_at
+	`
+
+	rows, err := or.DB.Query(query, limit, lease.Milliseconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := make([]*models.OutboxEvent, 0)
+	for rows.Next() {
+		var event models.OutboxEvent
+		var payload []byte
+		if err := rows.Scan(&event.ID, &event.UserID, &event.Event, &payload, &event.Attempts, &event.CreatedAt); err != nil {
+			return nil, err
+		}
+		event.Payload = payload
+		events = append(events, &event)
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].ID < events[j].ID
+	})
+
+	return events, rows.Err()
+}
+
+func (or *OutboxRepository) MarkDelivered(id uint64) error {
+	query := `UPDATE outbox_events SET delivered_at = NOW(), attempts = attempts + 1 WHERE id = $1`
+
+	_, err := or.DB.Exec(query, id)
+	return err
+}
+
+// MarkFailed records a failed attempt. The event is retried at nextAttemptAt unless giveUp is set.
+func (or *OutboxRepository) MarkFailed(id uint64, reason string, nextAttemptAt time.Time, giveUp bool) error {
+	query := `
+		UPDATE outbox_events
+		SET attempts = attempts + 1,
+			last_error = $2,
+			next_attempt_at = $3,
+			failed_at = CASE WHEN $4 THEN NOW() END
+		WHERE id = $1
+	`
+
+	_, err := or.DB.Exec(query, id, reason, nextAttemptAt, giveUp)
+	return err
+}
+
+// DeleteDelivered removes events delivered before the given time.
+func (or *OutboxRepository) DeleteDelivered(before time.Time) error {
+	query := `DELETE FROM outbox_events WHERE delivered_at < $1`
+
+	_, err := or.DB.Exec(query, before)
+	return err
+}
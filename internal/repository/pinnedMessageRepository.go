@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/drTragger/messenger-backend/internal/models"
+)
+
+type PinnedMessageRepository struct {
+	DB DBTX
+}
+
+func NewPinnedMessageRepository(db *sql.DB) *PinnedMessageRepository {
+	return &PinnedMessageRepository{
+		DB: db,
+	}
+}
+
+// WithTx returns a copy of the repository that runs its queries in the given transaction.
+func (pr *PinnedMessageRepository) WithTx(tx *sql.Tx) *PinnedMessageRepository {
+	return &PinnedMessageRepository{
+		DB: tx,
+	}
+}
+
+// Pin pins a message in a chat. Pinning an already pinned message moves it to the top.
+func (pr *PinnedMessageRepository) Pin(pin *models.PinnedMessage) (*models.PinnedMessage, error) {
+	query := `
+		INSERT INTO pinned_messages (chat_id, message_id, pinned_by, notified, pinned_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (chat_id, message_id)
+		DO UPDATE SET pinned_by = EXCLUDED.pinned_by, notified = EXCLUDED.notified, pinned_at = NOW()
+		RETURNING id, pinned_at
+	`
+
+	err := pr.DB.QueryRow(query, pin.ChatID, pin.MessageID, pin.PinnedBy, pin.Notified).Scan(&pin.ID, &pin.PinnedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return pin, nil
+}
+
+// Unpin removes a pin and reports whether the message was pinned and whether it is shadowed.
+func (pr *PinnedMessageRepository) Unpin(chatID, messageID uint) (bool, bool, error) {
+	query := `
+		DELETE FROM pinned_messages p
+		USING messages m
+		WHERE p.chat_id = $1 AND p.message_id = $2 AND m.id = p.message_id
+		RETURNING m.shadowed
+	`
+
+	var shadowed bool
+	err := pr.DB.QueryRow(query, chatID, messageID).Scan(&shadowed)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, false, nil
+		}
+		return false, false, err
+	}
+
+	return true, shadowed, nil
+}
+
+// UnpinAll removes every pin of a chat and returns how many of the unpinned messages are not shadowed.
+func (pr *PinnedMessageRepository) UnpinAll(chatID uint) (int, error) {
+	query := `
+		DELETE FROM pinned_messages p
+		USING messages m
+		WHERE p.chat_id = $1 AND m.id = p.message_id
+		RETURNING m.shadowed
+	`
+
+	rows, err := pr.DB.Query(query, chatID)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	visible := 0
+	for rows.Next() {
+		var shadowed bool
+		if err := rows.Scan(&shadowed); err != nil {
+			return 0, err
+		}
+		if !shadowed {
+			visible++
+		}
+	}
+
+	return visible, rows.Err()
+}
+
+// GetForChat returns the pinned messages of a chat, most recently pinned first.
+// Pinned messages of a shadow-banned sender are only returned to the sender.
+func (pr *PinnedMessageRepository) GetForChat(chatID, viewerID uint) ([]*models.PinnedMessage, error) {
+	query := `
+		SELECT 
+			p.id, p.chat_id, p.message_id, p.pinned_by, p.notified, p.pinned_at,
+			m.id, m.sender_id, m.recipient_id, m.content, m.type, m.read_at, m.delivered_at, m.chat_id, m.created_at, m.updated_at
+		FROM pinned_messages p
+			JOIN messages m ON p.message_id = m.id
+		WHERE p.chat_id = $1 AND (NOT m.shadowed OR m.sender_id = $2)
+		ORDER BY p.pinned_at DESC
+	`
+
+	rows, err := pr.DB.Query(query, chatID, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pins := make([]*models.PinnedMessage, 0)
+	for rows.Next() {
+		var pin models.PinnedMessage
+		var message models.Message
+
+		err := rows.Scan(
+			&pin.ID, &pin.ChatID, &pin.MessageID, &pin.PinnedBy, &pin.Notified, &pin.PinnedAt,
+			&message.ID, &message.SenderID, &message.RecipientID, &message.Content, &message.Type, &message.ReadAt, &message.DeliveredAt, &message.ChatID, &message.CreatedAt, &message.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		pin.Message = &message
+		pins = append(pins, &pin)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return pins, nil
+}
+
+// GetLatestForChat returns the most recently pinned message of a chat or nil if nothing is pinned.
+// Pinned messages of a shadow-banned sender are only returned to the sender.
+func (pr *PinnedMessageRepository) GetLatestForChat(chatID, viewerID uint) (*models.PinnedMessage, error) {
+	query := `
+		SELECT 
+			p.id, p.chat_id, p.message_id, p.pinned_by, p.notified, p.pinned_at,
+			m.id, m.sender_id, m.recipient_id, m.content, m.type, m.read_at, m.delivered_at, m.chat_id, m.created_at, m.updated_at
+		FROM pinned_messages p
+			JOIN messages m ON p.message_id = m.id
+		WHERE p.chat_id = $1 AND (NOT m.shadowed OR m.sender_id = $2)
+		ORDER BY p.pinned_at DESC
+		LIMIT 1
+	`
+
+	var pin models.PinnedMessage
+	var message models.Message
+
+	err := pr.DB.QueryRow(query, chatID, viewerID).Scan(
+		&pin.ID, &pin.ChatID, &pin.MessageID, &pin.PinnedBy, &pin.Notified, &pin.PinnedAt,
+		&message.ID, &message.SenderID, &message.RecipientID, &message.Content, &message.Type, &message.ReadAt, &message.DeliveredAt, &message.ChatID, &message.CreatedAt, &message.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	pin.Message = &message
+	return &pin, nil
+}
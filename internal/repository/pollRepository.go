@@ -0,0 +1,324 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/drTragger/messenger-backend/internal/models"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrPollClosed   = errors.New("poll is closed")
+	ErrPollOption   = errors.New("option does not belong to the poll")
+	ErrQuizAnswered = errors.New("quiz answers cannot be changed")
+)
+
+const pollColumns = `
+	id, message_id, question, is_anonymous, allows_multiple_answers, is_quiz, correct_option, closes_at, closed_at, created_at
+`
+
+type PollRepository struct {
+	DB DBTX
+}
+
+func NewPollRepository(db *sql.DB) *PollRepository {
+	return &PollRepository{
+		DB: db,
+	}
+}
+
+// WithTx returns a copy of the repository that runs its queries in the given transaction.
+func (pr *PollRepository) WithTx(tx *sql.Tx) *PollRepository {
+	return &PollRepository{
+		DB: tx,
+	}
+}
+
+// Create stores a poll together with its options. Run it in a transaction, see WithTx.
+func (pr *PollRepository) Create(poll *models.Poll) (*models.Poll, error) {
+	query := `
+		INSERT INTO polls (
+			message_id, question, is_anonymous, allows_multiple_answers, is_quiz, correct_option, closes_at, created_at
+		)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
+		RETURNING id, created_at
+	`
+
+	err := pr.DB.QueryRow(
+		query,
+		poll.MessageID, poll.Question, poll.IsAnonymous, poll.AllowsMultipleAnswers, poll.IsQuiz, poll.CorrectOption, poll.ClosesAt,
+	).Scan(&poll.ID, &poll.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	optionQuery := `
+		INSERT INTO poll_options (poll_id, position, text)
+		VALUES ($1, $2, $3)
+		RETURNING id
+	`
+	for _, option := range poll.Options {
+		option.PollID = poll.ID
+		if err := pr.DB.QueryRow(optionQuery, poll.ID, option.Position, option.Text).Scan(&option.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	return poll, nil
+}
+
+// GetByID returns a poll with its current results, including the correct option of a quiz.
+func (pr *PollRepository) GetByID(id uint) (*models.Poll, error) {
+	query := `
+		SELECT ` + pollColumns + `
+		FROM polls
+		WHERE id = $1
+	`
+
+	polls, err := queryPolls(pr.DB, query, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(polls) == 0 {
+		return nil, nil
+	}
+
+	return polls[0], nil
+}
+
+// Vote replaces the votes of a user with the given options. The poll row is locked until the end of
+// the transaction, so concurrent votes and closing the poll are applied one after another.
+// Run it in a transaction, see WithTx.
+func (pr *PollRepository) Vote(pollID, userID uint, optionIDs []uint) error {
+	if err := lockOpenPoll(pr.DB, pollID, userID); err != nil {
+		return err
+	}
+
+	if _, err := pr.DB.Exec(`DELETE FROM poll_votes WHERE poll_id = $1 AND user_id = $2`, pollID, userID); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO poll_votes (poll_id, option_id, user_id, created_at)
+		SELECT poll_id, id, $3, NOW()
+		FROM poll_options
+		WHERE poll_id = $1 AND id = ANY($2)
+	`
+	result, err := pr.DB.Exec(query, pollID, pq.Array(optionIDs), userID)
+	if err != nil {
+		return err
+	}
+
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if int(inserted) != len(optionIDs) {
+		return ErrPollOption
+	}
+
+	return nil
+}
+
+// Retract removes the votes of a user and reports whether the user had voted.
+// Run it in a transaction, see WithTx.
+func (pr *PollRepository) Retract(pollID, userID uint) (bool, error) {
+	if err := lockOpenPoll(pr.DB, pollID, userID); err != nil {
+		return false, err
+	}
+
+	result, err := pr.DB.Exec(`DELETE FROM poll_votes WHERE poll_id = $1 AND user_id = $2`, pollID, userID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// Close locks the results of a poll and reports whether the poll was still open.
+func (pr *PollRepository) Close(pollID uint) (bool, error) {
+	query := `UPDATE polls SET closed_at = NOW() WHERE id = $1 AND closed_at IS NULL`
+
+	result, err := pr.DB.Exec(query, pollID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// CloseDue closes up to limit polls whose close time has passed and returns the IDs of their messages.
+func (pr *PollRepository) CloseDue(limit int) ([]uint, error) {
+	query := `
+		UPDATE polls
+		SET closed_at = NOW()
+		WHERE id IN (
+			SELECT id
+			FROM polls
+			WHERE closes_at IS NOT NULL AND closes_at <= NOW() AND closed_at IS NULL
+			ORDER BY closes_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING message_id
+	`
+
+	rows, err := pr.DB.Query(query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messageIDs := make([]uint, 0)
+	for rows.Next() {
+		var messageID uint
+		if err := rows.Scan(&messageID); err != nil {
+			return nil, err
+		}
+		messageIDs = append(messageIDs, messageID)
+	}
+
+	return messageIDs, rows.Err()
+}
+
+// lockOpenPoll locks the poll row and checks that the user may still change their vote.
+func lockOpenPoll(tx DBTX, pollID, userID uint) error {
+	var closedAt sql.NullTime
+	var isQuiz bool
+	err := tx.QueryRow(`SELECT closed_at, is_quiz FROM polls WHERE id = $1 FOR UPDATE`, pollID).Scan(&closedAt, &isQuiz)
+	if err != nil {
+		return err
+	}
+	if closedAt.Valid {
+		return ErrPollClosed
+	}
+
+	if isQuiz {
+		var voted bool
+		query := `SELECT EXISTS (SELECT 1 FROM poll_votes WHERE poll_id = $1 AND user_id = $2)`
+		if err := tx.QueryRow(query, pollID, userID).Scan(&voted); err != nil {
+			return err
+		}
+		if voted {
+			return ErrQuizAnswered
+		}
+	}
+
+	return nil
+}
+
+// queryPolls runs a query selecting pollColumns and loads the options and results of the returned polls.
+func queryPolls(db DBTX, query string, args ...interface{}) ([]*models.Poll, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	polls := make([]*models.Poll, 0)
+	for rows.Next() {
+		var poll models.Poll
+		err := rows.Scan(
+			&poll.ID, &poll.MessageID, &poll.Question, &poll.IsAnonymous, &poll.AllowsMultipleAnswers, &poll.IsQuiz,
+			&poll.CorrectOption, &poll.ClosesAt, &poll.ClosedAt, &poll.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		polls = append(polls, &poll)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err = loadPollResults(db, polls); err != nil {
+		return nil, err
+	}
+
+	return polls, nil
+}
+
+// loadPollResults fetches the options of the given polls with their vote counts, and the voters of public polls.
+func loadPollResults(db DBTX, polls []*models.Poll) error {
+	if len(polls) == 0 {
+		return nil
+	}
+
+	pollIDs := make([]uint, 0, len(polls))
+	pollsMap := make(map[uint]*models.Poll)
+	for _, poll := range polls {
+		pollIDs = append(pollIDs, poll.ID)
+		pollsMap[poll.ID] = poll
+		poll.Options = make([]*models.PollOption, 0)
+	}
+
+	query := `
+		SELECT o.id, o.poll_id, o.position, o.text, COUNT(v.id)
+		FROM poll_options o
+			LEFT JOIN poll_votes v ON v.option_id = o.id
+		WHERE o.poll_id = ANY($1)
+		GROUP BY o.id
+		ORDER BY o.poll_id, o.position
+	`
+	rows, err := db.Query(query, pq.Array(pollIDs))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	optionsMap := make(map[uint]*models.PollOption)
+	for rows.Next() {
+		var option models.PollOption
+		if err := rows.Scan(&option.ID, &option.PollID, &option.Position, &option.Text, &option.VoteCount); err != nil {
+			return err
+		}
+		optionsMap[option.ID] = &option
+		pollsMap[option.PollID].Options = append(pollsMap[option.PollID].Options, &option)
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	query = `
+		SELECT v.poll_id, v.option_id, v.user_id, p.is_anonymous
+		FROM poll_votes v
+			JOIN polls p ON v.poll_id = p.id
+		WHERE v.poll_id = ANY($1)
+		ORDER BY v.created_at
+	`
+	voteRows, err := db.Query(query, pq.Array(pollIDs))
+	if err != nil {
+		return err
+	}
+	defer voteRows.Close()
+
+	voters := make(map[uint]map[uint]bool)
+	for voteRows.Next() {
+		var pollID, optionID, userID uint
+		var isAnonymous bool
+		if err := voteRows.Scan(&pollID, &optionID, &userID, &isAnonymous); err != nil {
+			return err
+		}
+
+		if voters[pollID] == nil {
+			voters[pollID] = make(map[uint]bool)
+		}
+		voters[pollID][userID] = true
+
+		if !isAnonymous {
+			optionsMap[optionID].Voters = append(optionsMap[optionID].Voters, userID)
+		}
+	}
+	if err = voteRows.Err(); err != nil {
+		return err
+	}
+
+	for pollID, users := range voters {
+		pollsMap[pollID].TotalVoters = len(users)
+	}
+
+	return nil
+}
@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/drTragger/messenger-backend/internal/models"
+)
+
+const (
+	ReportsLimit  = 20
+	ReportsOffset = 0
+)
+
+type ReportRepository struct {
+	DB DBTX
+}
+
+func NewReportRepository(db *sql.DB) *ReportRepository {
+	return &ReportRepository{
+		DB: db,
+	}
+}
+
+// WithTx returns a copy of the repository that runs its queries in the given transaction.
+func (rr *ReportRepository) WithTx(tx *sql.Tx) *ReportRepository {
+	return &ReportRepository{
+		DB: tx,
+	}
+}
+
+// Create stores a new open report along with the snapshot of the reported message.
+func (rr *ReportRepository) Create(report *models.Report) error {
+	var snapshot []byte
+	if report.Snapshot != nil {
+		var err error
+		snapshot, err = json.Marshal(report.Snapshot)
+		if err != nil {
+			return err
+		}
+	}
+
+	query := `
+		INSERT INTO reports (reporter_id, reported_user_id, message_id, reason, comment, snapshot, status, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
+		RETURNING id, status, created_at
+	`
+
+	return rr.DB.QueryRow(query, report.ReporterID, report.ReportedUserID, report.MessageID, report.Reason, report.Comment, snapshot, models.OpenReport).
+		Scan(&report.ID, &report.Status, &report.CreatedAt)
+}
+
+// GetByID returns a report, or nil if it doesn't exist.
+func (rr *ReportRepository) GetByID(id uint) (*models.Report, error) {
+	query := `
+		SELECT id, reporter_id, reported_user_id, message_id, reason, comment, snapshot, status, assignee_id, assigned_at,
+			action, resolution_note, resolved_by, resolved_at, created_at
+		FROM reports
+		WHERE id = $1
+	`
+
+	report, err := scanReport(rr.DB.QueryRow(query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return report, err
+}
+
+// GetQueue returns the reports with the given status, oldest first. A nil assignee returns reports
+// assigned to anyone, an assignee of 0 returns the unassigned ones.
+func (rr *ReportRepository) GetQueue(status string, assignee *uint, limit, offset int) ([]*models.Report, error) {
+	query := `
+		SELECT id, reporter_id, reported_user_id, message_id, reason, comment, snapshot, status, assignee_id, assigned_at,
+			action, resolution_note, resolved_by, resolved_at, created_at
+		FROM reports
+		WHERE status = $1
+		  AND ($2::INT IS NULL OR ($2 = 0 AND assignee_id IS NULL) OR assignee_id = $2)
+		ORDER BY created_at, id
+		LIMIT $3 OFFSET $4
+	`
+
+	rows, err := rr.DB.Query(query, status, assignee, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reports := make([]*models.Report, 0)
+	for rows.Next() {
+		report, err := scanReport(rows)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+	return reports, rows.Err()
+}
+
+// Assign gives an open report to a moderator. It returns false if the report is no longer open.
+func (rr *ReportRepository) Assign(id, moderatorID uint) (bool, error) {
+	query := `
+		UPDATE reports
+		SET assignee_id = $2, assigned_at = NOW()
+		WHERE id = $1 AND status = 'open'
+	`
+
+	result, err := rr.DB.Exec(query, id, moderatorID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// Resolve closes an open report with the action taken. It returns false if the report is no longer open.
+func (rr *ReportRepository) Resolve(id uint, status, action string, note *string, moderatorID uint) (bool, error) {
+	query := `
+		UPDATE reports
+		SET status = $2, action = $3, resolution_note = $4, resolved_by = $5, resolved_at = NOW()
+		WHERE id = $1 AND status = 'open'
+	`
+
+	result, err := rr.DB.Exec(query, id, status, action, note, moderatorID)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func scanReport(row interface{ Scan(...interface{}) error }) (*models.Report, error) {
+	var report models.Report
+	var messageID, assigneeID, resolvedBy sql.NullInt64
+	var snapshot []byte
+	err := row.Scan(&report.ID, &report.ReporterID, &report.ReportedUserID, &messageID, &report.Reason, &report.Comment, &snapshot,
+		&report.Status, &assigneeID, &report.AssignedAt, &report.Action, &report.ResolutionNote, &resolvedBy, &report.ResolvedAt, &report.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	report.MessageID = nullUint(messageID)
+	report.AssigneeID = nullUint(assigneeID)
+	report.ResolvedBy = nullUint(resolvedBy)
+
+	if snapshot != nil {
+		if err := json.Unmarshal(snapshot, &report.Snapshot); err != nil {
+			return nil, err
+		}
+	}
+	return &report, nil
+}
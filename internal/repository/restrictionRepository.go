@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/drTragger/messenger-backend/internal/models"
+)
+
+type RestrictionRepository struct {
+	DB DBTX
+}
+
+func NewRestrictionRepository(db *sql.DB) *RestrictionRepository {
+	return &RestrictionRepository{
+		DB: db,
+	}
+}
+
+// WithTx returns a copy of the repository that runs its queries in the given transaction.
+func (rr *RestrictionRepository) WithTx(tx *sql.Tx) *RestrictionRepository {
+	return &RestrictionRepository{
+		DB: tx,
+	}
+}
+
+// Create restricts a user until the restriction expires.
+func (rr *RestrictionRepository) Create(restriction *models.Restriction) error {
+	query := `
+		INSERT INTO user_restrictions (user_id, type, reason, expires_at, report_id, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		RETURNING id, created_at
+	`
+
+	return rr.DB.QueryRow(query, restriction.UserID, restriction.Type, restriction.Reason, restriction.ExpiresAt, restriction.ReportID, restriction.CreatedBy).
+		Scan(&restriction.ID, &restriction.CreatedAt)
+}
+
+// GetActive returns the unexpired restriction of a type that lasts the longest, or nil if the user has none.
+func (rr *RestrictionRepository) GetActive(userID uint, restrictionType string) (*models.Restriction, error) {
+	query := `
+		SELECT id, user_id, type, reason, expires_at, report_id, created_by, created_at
+		FROM user_restrictions
+		WHERE user_id = $1 AND type = $2 AND expires_at > NOW()
+		ORDER BY expires_at DESC
+		LIMIT 1
+	`
+
+	restriction, err := scanRestriction(rr.DB.QueryRow(query, userID, restrictionType))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return restriction, err
+}
+
+// GetForUser returns the restrictions of a user, including the expired ones, newest first.
+func (rr *RestrictionRepository) GetForUser(userID uint) ([]*models.Restriction, error) {
+	query := `
+		SELECT id, user_id, type, reason, expires_at, report_id, created_by, created_at
+		FROM user_restrictions
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`
+
+	rows, err := rr.DB.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	restrictions := make([]*models.Restriction, 0)
+	for rows.Next() {
+		restriction, err := scanRestriction(rows)
+		if err != nil {
+			return nil, err
+		}
+		restrictions = append(restrictions, restriction)
+	}
+
+	return restrictions, rows.Err()
+}
+
+func (rr *RestrictionRepository) GetByID(id uint) (*models.Restriction, error) {
+	query := `
+		SELECT id, user_id, type, reason, expires_at, report_id, created_by, created_at
+		FROM user_restrictions
+		WHERE id = $1
+	`
+
+	restriction, err := scanRestriction(rr.DB.QueryRow(query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return restriction, err
+}
+
+// Lift ends a restriction early by expiring it now. The restriction is kept for the user's history.
+func (rr *RestrictionRepository) Lift(id uint) error {
+	query := `
+		UPDATE user_restrictions
+		SET expires_at = NOW()
+		WHERE id = $1 AND expires_at > NOW()
+	`
+
+	_, err := rr.DB.Exec(query, id)
+	return err
+}
+
+func scanRestriction(row interface{ Scan(...interface{}) error }) (*models.Restriction, error) {
+	var restriction models.Restriction
+	var reportID, createdBy sql.NullInt64
+
+	err := row.Scan(
+		&restriction.ID,
+		&restriction.UserID,
+		&restriction.Type,
+		&restriction.Reason,
+		&restriction.ExpiresAt,
+		&reportID,
+		&createdBy,
+		&restriction.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	restriction.ReportID = nullUint(reportID)
+	restriction.CreatedBy = nullUint(createdBy)
+	return &restriction, nil
+}
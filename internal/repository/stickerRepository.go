@@ -0,0 +1,293 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/drTragger/messenger-backend/internal/models"
+	"github.com/lib/pq"
+)
+
+const (
+	StickerPacksLimit  = 20
+	StickerPacksOffset = 0
+	RecentStickersMax  = 20
+)
+
+var ErrPackNotInstalled = errors.New("sticker pack is not installed")
+
+type StickerRepository struct {
+	DB DBTX
+}
+
+func NewStickerRepository(db *sql.DB) *StickerRepository {
+	return &StickerRepository{
+		DB: db,
+	}
+}
+
+// WithTx returns a copy of the repository that runs its queries in the given transaction.
+func (sr *StickerRepository) WithTx(tx *sql.Tx) *StickerRepository {
+	return &StickerRepository{
+		DB: tx,
+	}
+}
+
+// CreatePack stores a sticker pack together with its stickers. Run it in a transaction,
+// so a pack is never stored without some of its stickers.
+func (sr *StickerRepository) CreatePack(pack *models.StickerPack) (*models.StickerPack, error) {
+	query := `
+		INSERT INTO sticker_packs (name, title, author_id, created_at, updated_at)
+		VALUES ($1, $2, $3, NOW(), NOW())
+		RETURNING id, created_at, updated_at
+	`
+
+	err := sr.DB.QueryRow(query, pack.Name, pack.Title, pack.AuthorID).Scan(&pack.ID, &pack.CreatedAt, &pack.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	stickerQuery := `
+		INSERT INTO stickers (pack_id, file_path, emojis, position, created_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		RETURNING id
+	`
+	for _, sticker := range pack.Stickers {
+		sticker.PackID = pack.ID
+		err := sr.DB.QueryRow(stickerQuery, pack.ID, sticker.FilePath, pq.Array(sticker.Emojis), sticker.Position).Scan(&sticker.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return pack, nil
+}
+
+func (sr *StickerRepository) PackNameExists(name string) (bool, error) {
+	var exists bool
+	err := sr.DB.QueryRow(`SELECT EXISTS (SELECT 1 FROM sticker_packs WHERE name = $1)`, name).Scan(&exists)
+	return exists, err
+}
+
+func (sr *StickerRepository) GetPack(id uint) (*models.StickerPack, error) {
+	query := `
+		SELECT id, name, title, author_id, created_at, updated_at
+		FROM sticker_packs
+		WHERE id = $1
+	`
+
+	packs, err := sr.queryPacks(query, id)
+	if err != nil || len(packs) == 0 {
+		return nil, err
+	}
+
+	return packs[0], nil
+}
+
+// GetPacks browses the sticker packs, optionally filtered by a part of their name or title.
+func (sr *StickerRepository) GetPacks(search string, limit, offset int) ([]*models.StickerPack, error) {
+	query := `
+		SELECT id, name, title, author_id, created_at, updated_at
+		FROM sticker_packs
+		WHERE $1 = '' OR name ILIKE '%' || $1 || '%' OR title ILIKE '%' || $1 || '%'
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3
+	`
+
+	return sr.queryPacks(query, search, limit, offset)
+}
+
+// GetInstalled returns the packs installed by a user in the order the user arranged them.
+func (sr *StickerRepository) GetInstalled(userID uint) ([]*models.StickerPack, error) {
+	query := `
+		SELECT p.id, p.name, p.title, p.author_id, p.created_at, p.updated_at
+		FROM user_sticker_packs up
+			JOIN sticker_packs p ON up.pack_id = p.id
+		WHERE up.user_id = $1
+		ORDER BY up.position
+	`
+
+	return sr.queryPacks(query, userID)
+}
+
+// Install adds a pack to the end of the user's packs. Installing an installed pack does nothing.
+func (sr *StickerRepository) Install(userID, packID uint) error {
+	query := `
+		INSERT INTO user_sticker_packs (user_id, pack_id, position, installed_at)
+		SELECT $1, $2, COALESCE(MAX(position), -1) + 1, NOW()
+		FROM user_sticker_packs
+		WHERE user_id = $1
+		ON CONFLICT (user_id, pack_id) DO NOTHING
+	`
+
+	_, err := sr.DB.Exec(query, userID, packID)
+	return err
+}
+
+// Uninstall removes a pack from the user's packs and reports whether it was installed.
+func (sr *StickerRepository) Uninstall(userID, packID uint) (bool, error) {
+	query := `DELETE FROM user_sticker_packs WHERE user_id = $1 AND pack_id = $2`
+
+	result, err := sr.DB.Exec(query, userID, packID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// Reorder arranges the user's packs in the given order. Every given pack must be installed,
+// installed packs that are not listed keep their relative order after the listed ones.
+// Run it in a transaction, so nothing is moved when a pack is not installed.
+func (sr *StickerRepository) Reorder(userID uint, packIDs []uint) error {
+	query := `
+		UPDATE user_sticker_packs up
+		SET position = o.position
+		FROM (
+			SELECT pack_id, ordinality - 1 AS position
+			FROM UNNEST($2::int[]) WITH ORDINALITY AS t(pack_id, ordinality)
+		) o
+		WHERE up.user_id = $1 AND up.pack_id = o.pack_id
+	`
+	result, err := sr.DB.Exec(query, userID, pq.Array(packIDs))
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if int(affected) != len(packIDs) {
+		return ErrPackNotInstalled
+	}
+
+	query = `
+		UPDATE user_sticker_packs up
+		SET position = $3 + o.position
+		FROM (
+			SELECT pack_id, ROW_NUMBER() OVER (ORDER BY position) - 1 AS position
+			FROM user_sticker_packs
+			WHERE user_id = $1 AND NOT pack_id = ANY($2)
+		) o
+		WHERE up.user_id = $1 AND up.pack_id = o.pack_id
+	`
+	_, err = sr.DB.Exec(query, userID, pq.Array(packIDs), len(packIDs))
+	return err
+}
+
+func (sr *StickerRepository) GetSticker(id uint) (*models.Sticker, error) {
+	query := `SELECT id, pack_id, file_path, emojis, position FROM stickers WHERE id = $1`
+
+	var sticker models.Sticker
+	err := sr.DB.QueryRow(query, id).Scan(&sticker.ID, &sticker.PackID, &sticker.FilePath, pq.Array(&sticker.Emojis), &sticker.Position)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &sticker, nil
+}
+
+// AddRecent moves a sticker to the top of the user's recently used stickers and drops the oldest ones.
+func (sr *StickerRepository) AddRecent(userID, stickerID uint) error {
+	query := `
+		INSERT INTO recent_stickers (user_id, sticker_id, used_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (user_id, sticker_id) DO UPDATE SET used_at = NOW()
+	`
+	if _, err := sr.DB.Exec(query, userID, stickerID); err != nil {
+		return err
+	}
+
+	query = `
+		DELETE FROM recent_stickers
+		WHERE user_id = $1 AND sticker_id NOT IN (
+			SELECT sticker_id FROM recent_stickers WHERE user_id = $1 ORDER BY used_at DESC LIMIT $2
+		)
+	`
+	_, err := sr.DB.Exec(query, userID, RecentStickersMax)
+	return err
+}
+
+// GetRecent returns the stickers the user sent most recently.
+func (sr *StickerRepository) GetRecent(userID uint) ([]*models.Sticker, error) {
+	query := `
+		SELECT s.id, s.pack_id, s.file_path, s.emojis, s.position
+		FROM recent_stickers r
+			JOIN stickers s ON r.sticker_id = s.id
+		WHERE r.user_id = $1
+		ORDER BY r.used_at DESC
+	`
+
+	rows, err := sr.DB.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stickers := make([]*models.Sticker, 0)
+	for rows.Next() {
+		var sticker models.Sticker
+		if err := rows.Scan(&sticker.ID, &sticker.PackID, &sticker.FilePath, pq.Array(&sticker.Emojis), &sticker.Position); err != nil {
+			return nil, err
+		}
+		stickers = append(stickers, &sticker)
+	}
+
+	return stickers, rows.Err()
+}
+
+// queryPacks runs a query selecting sticker pack columns and loads the stickers of the returned packs.
+func (sr *StickerRepository) queryPacks(query string, args ...interface{}) ([]*models.StickerPack, error) {
+	rows, err := sr.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	packs := make([]*models.StickerPack, 0)
+	packsMap := make(map[uint]*models.StickerPack)
+	packIDs := make([]uint, 0)
+	for rows.Next() {
+		var pack models.StickerPack
+		if err := rows.Scan(&pack.ID, &pack.Name, &pack.Title, &pack.AuthorID, &pack.CreatedAt, &pack.UpdatedAt); err != nil {
+			return nil, err
+		}
+		pack.Stickers = make([]*models.Sticker, 0)
+		packs = append(packs, &pack)
+		packsMap[pack.ID] = &pack
+		packIDs = append(packIDs, pack.ID)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(packs) == 0 {
+		return packs, nil
+	}
+
+	stickerQuery := `
+		SELECT id, pack_id, file_path, emojis, position
+		FROM stickers
+		WHERE pack_id = ANY($1)
+		ORDER BY pack_id, position
+	`
+	stickerRows, err := sr.DB.Query(stickerQuery, pq.Array(packIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer stickerRows.Close()
+
+	for stickerRows.Next() {
+		var sticker models.Sticker
+		if err := stickerRows.Scan(&sticker.ID, &sticker.PackID, &sticker.FilePath, pq.Array(&sticker.Emojis), &sticker.Position); err != nil {
+			return nil, err
+		}
+		packsMap[sticker.PackID].Stickers = append(packsMap[sticker.PackID].Stickers, &sticker)
+	}
+
+	return packs, stickerRows.Err()
+}
@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/drTragger/messenger-backend/internal/models"
+)
+
+// UpdateLogSize is the number of most recent updates kept per user.
+// Clients that missed more updates have to resync.
+const UpdateLogSize = 1000
+
+type UpdateRepository struct {
+	DB *sql.DB
+}
+
+func NewUpdateRepository(db *sql.DB) *UpdateRepository {
+	return &UpdateRepository{
+		DB: db,
+	}
+}
+
+// Create appends an update to the user's log and returns its sequence number.
+// The upsert locks the counter row, so concurrent updates of a user get consecutive numbers.
+func (ur *UpdateRepository) Create(userID uint, event string, payload []byte) (uint64, error) {
+	query := `
+		WITH counter AS (
+			INSERT INTO user_update_seqs (user_id, seq)
+			VALUES ($1, 1)
+			ON CONFLICT (user_id) DO UPDATE SET seq = user_update_seqs.seq + 1
+			RETURNING seq
+		)
+		INSERT INTO user_updates (user_id, seq, event, payload, created_at)
+		SELECT $1, seq, $2, $3, NOW() FROM counter
+		RETURNING seq
+	`
+
+	var seq uint64
+	if err := ur.DB.QueryRow(query, userID, event, payload).Scan(&seq); err != nil {
+		return 0, err
+	}
+
+	// Trim the log every hundred updates instead of on every one
+	if seq%100 == 0 && seq > UpdateLogSize {
+		if _, err := ur.DB.Exec(`DELETE FROM user_updates WHERE user_id = $1 AND seq <= $2`, userID, seq-UpdateLogSize); err != nil {
+			return seq, err
+		}
+	}
+
+	return seq, nil
+}
+
+// GetSeq returns the sequence number of the latest update of a user.
+func (ur *UpdateRepository) GetSeq(userID uint) (uint64, error) {
+	var seq uint64
+	err := ur.DB.QueryRow(`SELECT seq FROM user_update_seqs WHERE user_id = $1`, userID).Scan(&seq)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return seq, err
+}
+
+// GetSince returns the updates of a user after the given sequence number, oldest first.
+func (ur *UpdateRepository) GetSince(userID uint, since uint64) ([]*models.Update, error) {
+	query := `
+		SELECT seq, event, payload, created_at
+		FROM user_updates
+		WHERE user_id = $1 AND seq > $2
+		ORDER BY seq
+		LIMIT $3
+	`
+
+	rows, err := ur.DB.Query(query, userID, since, UpdateLogSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	updates := make([]*models.Update, 0)
+	for rows.Next() {
+		var update models.Update
+		var payload []byte
+		if err := rows.Scan(&update.Seq, &update.Event, &payload, &update.CreatedAt); err != nil {
+			return nil, err
+		}
+		update.Message = payload
+		updates = append(updates, &update)
+	}
+
+	return updates, rows.Err()
+}
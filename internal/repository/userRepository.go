@@ -31,7 +31,7 @@ func (ur *UserRepository) CreateUser(user *models.User) error {
 // GetUserByPhone fetches a user by phone
 func (ur *UserRepository) GetUserByPhone(phone string) (*models.User, error) {
 	query := `
-		SELECT id, username, first_name, last_name, phone, password, last_seen, profile_picture, created_at, updated_at, phone_verified_at 
+		SELECT id, username, first_name, last_name, phone, password, last_seen, profile_picture, hide_forward_sender, created_at, updated_at, phone_verified_at 
 		FROM users 
 		WHERE phone = $1
 	`
@@ -39,7 +39,7 @@ func (ur *UserRepository) GetUserByPhone(phone string) (*models.User, error) {
 	row := ur.DB.QueryRow(query, phone)
 
 	user := &models.User{}
-	err := row.Scan(&user.ID, &user.Username, &user.FirstName, &user.LastName, &user.Phone, &user.Password, &user.LastSeen, &user.ProfilePicture, &user.CreatedAt, &user.UpdatedAt, &user.PhoneVerifiedAt)
+	err := row.Scan(&user.ID, &user.Username, &user.FirstName, &user.LastName, &user.Phone, &user.Password, &user.LastSeen, &user.ProfilePicture, &user.HideForwardSender, &user.CreatedAt, &user.UpdatedAt, &user.PhoneVerifiedAt)
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, nil // User not found
 	}
@@ -49,7 +49,7 @@ func (ur *UserRepository) GetUserByPhone(phone string) (*models.User, error) {
 // GetUserByID fetches a user by ID
 func (ur *UserRepository) GetUserByID(userID uint) (*models.User, error) {
 	query := `
-		SELECT id, username, first_name, last_name, phone, last_seen, profile_picture, created_at, updated_at, phone_verified_at 
+		SELECT id, username, first_name, last_name, phone, last_seen, profile_picture, hide_forward_sender, created_at, updated_at, phone_verified_at 
 		FROM users 
 		WHERE id = $1
 	`
@@ -57,7 +57,7 @@ func (ur *UserRepository) GetUserByID(userID uint) (*models.User, error) {
 	row := ur.DB.QueryRow(query, userID)
 
 	user := &models.User{}
-	err := row.Scan(&user.ID, &user.Username, &user.FirstName, &user.LastName, &user.Phone, &user.LastSeen, &user.ProfilePicture, &user.CreatedAt, &user.UpdatedAt, &user.PhoneVerifiedAt)
+	err := row.Scan(&user.ID, &user.Username, &user.FirstName, &user.LastName, &user.Phone, &user.LastSeen, &user.ProfilePicture, &user.HideForwardSender, &user.CreatedAt, &user.UpdatedAt, &user.PhoneVerifiedAt)
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, nil // User not found
 	}
@@ -66,7 +66,7 @@ func (ur *UserRepository) GetUserByID(userID uint) (*models.User, error) {
 
 func (ur *UserRepository) GetUserByUsername(username string) (*models.User, error) {
 	query := `
-		SELECT id, username, first_name, last_name, phone, last_seen, profile_picture, created_at, updated_at, phone_verified_at 
+		SELECT id, username, first_name, last_name, phone, last_seen, profile_picture, hide_forward_sender, created_at, updated_at, phone_verified_at 
 		FROM users 
 		WHERE username = $1
 	`
@@ -74,7 +74,7 @@ func (ur *UserRepository) GetUserByUsername(username string) (*models.User, erro
 	row := ur.DB.QueryRow(query, username)
 
 	user := &models.User{}
-	err := row.Scan(&user.ID, &user.Username, &user.FirstName, &user.LastName, &user.Phone, &user.LastSeen, &user.ProfilePicture, &user.CreatedAt, &user.UpdatedAt, &user.PhoneVerifiedAt)
+	err := row.Scan(&user.ID, &user.Username, &user.FirstName, &user.LastName, &user.Phone, &user.LastSeen, &user.ProfilePicture, &user.HideForwardSender, &user.CreatedAt, &user.UpdatedAt, &user.PhoneVerifiedAt)
 	if errors.Is(err, sql.ErrNoRows) {
 		return nil, nil // User not found
 	}
@@ -157,3 +157,26 @@ func (ur *UserRepository) ChangePersonalInfo(id uint, firstName string, lastName
 	_, err := ur.DB.Exec(query, firstName, lastName, id)
 	return err
 }
+
+func (ur *UserRepository) UpdateHideForwardSender(id uint, hide bool) error {
+	query := `
+		UPDATE users SET hide_forward_sender = $1, updated_at = NOW() WHERE id = $2;
+	`
+
+	_, err := ur.DB.Exec(query, hide, id)
+	return err
+}
+
+// IsModerator reports whether the user can review flagged content.
+func (ur *UserRepository) IsModerator(id uint) (bool, error) {
+	query := `
+		SELECT is_moderator FROM users WHERE id = $1;
+	`
+
+	var isModerator bool
+	err := ur.DB.QueryRow(query, id).Scan(&isModerator)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return isModerator, err
+}
@@ -0,0 +1,6 @@
+package requests
+
+// AssignReportRequest defines the payload for the assign report endpoint. Without a moderator, the caller takes the report.
+type AssignReportRequest struct {
+	ModeratorID *uint `json:"moderatorId"`
+}
@@ -0,0 +1,5 @@
+package requests
+
+type ChangePrivacyRequest struct {
+	HideForwardSender *bool `json:"hideForwardSender" validate:"required"`
+}
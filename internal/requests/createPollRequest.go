@@ -0,0 +1,15 @@
+package requests
+
+import "time"
+
+// CreatePollRequest defines the payload for the create poll endpoint.
+// IsAnonymous defaults to true, CorrectOption is the position of the right answer in quiz mode.
+type CreatePollRequest struct {
+	Question              string     `json:"question" validate:"required,min=1,max=300"`
+	Options               []string   `json:"options" validate:"required,min=2,max=10,dive,required,max=100"`
+	IsAnonymous           *bool      `json:"isAnonymous"`
+	AllowsMultipleAnswers bool       `json:"allowsMultipleAnswers"`
+	IsQuiz                bool       `json:"isQuiz"`
+	CorrectOption         *int       `json:"correctOption" validate:"required_if=IsQuiz true,omitempty,gte=0"`
+	ClosesAt              *time.Time `json:"closesAt"`
+}
@@ -0,0 +1,9 @@
+package requests
+
+// CreateReportRequest defines the payload for the create report endpoint. A report is about a message or a user.
+type CreateReportRequest struct {
+	MessageID *uint   `json:"messageId" validate:"required_without=UserID"`
+	UserID    *uint   `json:"userId" validate:"required_without=MessageID"`
+	Reason    string  `json:"reason" validate:"required,oneof=spam harassment violence hate_speech sexual_content scam other"`
+	Comment   *string `json:"comment" validate:"omitempty,max=1000"`
+}
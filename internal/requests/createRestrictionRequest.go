@@ -0,0 +1,10 @@
+package requests
+
+import "time"
+
+// CreateRestrictionRequest defines the payload for the create restriction endpoint
+type CreateRestrictionRequest struct {
+	Type      string    `json:"type" validate:"required,oneof=suspension read_only shadow_ban"`
+	Reason    string    `json:"reason" validate:"required,max=1000"`
+	ExpiresAt time.Time `json:"expiresAt" validate:"required"`
+}
@@ -0,0 +1,7 @@
+package requests
+
+// CreateStickerPackRequest defines the form fields of the create sticker pack endpoint
+type CreateStickerPackRequest struct {
+	Name  string `json:"name" validate:"required,min=3,max=64,alphanum"`
+	Title string `json:"title" validate:"required,min=1,max=100"`
+}
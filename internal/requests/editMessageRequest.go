@@ -1,5 +1,7 @@
 package requests
 
 type EditMessageRequest struct {
-	Content string `json:"content" validate:"required,min=1,max=5000"`
+	Content   string                  `json:"content" validate:"required,min=1,max=5000"`
+	Entities  []*MessageEntityRequest `json:"entities" validate:"omitempty,max=100,dive"`
+	ParseMode *string                 `json:"parseMode" validate:"omitempty,oneof=markdown"`
 }
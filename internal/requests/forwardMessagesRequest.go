@@ -0,0 +1,6 @@
+package requests
+
+// ForwardMessagesRequest defines the payload for the forward messages endpoint
+type ForwardMessagesRequest struct {
+	MessageIDs []uint `json:"messageIds" validate:"required,min=1,max=100,dive,gt=0"`
+}
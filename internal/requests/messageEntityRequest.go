@@ -0,0 +1,10 @@
+package requests
+
+// MessageEntityRequest describes a formatting entity submitted with a message
+type MessageEntityRequest struct {
+	Type     string  `json:"type" validate:"required,oneof=bold italic underline strikethrough code pre text_link spoiler"`
+	Offset   int     `json:"offset" validate:"gte=0"`
+	Length   int     `json:"length" validate:"gt=0"`
+	URL      *string `json:"url" validate:"required_if=Type text_link,omitempty,url,max=2000"`
+	Language *string `json:"language" validate:"omitempty,max=50"`
+}
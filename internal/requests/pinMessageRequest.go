@@ -0,0 +1,7 @@
+package requests
+
+// PinMessageRequest defines the payload for the pin message endpoint
+type PinMessageRequest struct {
+	MessageID uint `json:"messageId" validate:"required,gt=0"`
+	Notify    bool `json:"notify"`
+}
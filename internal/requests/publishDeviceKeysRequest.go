@@ -0,0 +1,20 @@
+package requests
+
+// PublishDeviceKeysRequest defines the payload for the publish device keys endpoint. Keys are base64 encoded.
+type PublishDeviceKeysRequest struct {
+	RegistrationID uint                 `json:"registrationId" validate:"required,gt=0"`
+	IdentityKey    string               `json:"identityKey" validate:"required,base64,max=256"`
+	SignedPreKey   *SignedPreKeyRequest `json:"signedPreKey" validate:"required"`
+	PreKeys        []*PreKeyRequest     `json:"preKeys" validate:"omitempty,max=100,dive"`
+}
+
+type SignedPreKeyRequest struct {
+	KeyID     uint   `json:"keyId"`
+	PublicKey string `json:"publicKey" validate:"required,base64,max=256"`
+	Signature string `json:"signature" validate:"required,base64,max=256"`
+}
+
+type PreKeyRequest struct {
+	KeyID     uint   `json:"keyId"`
+	PublicKey string `json:"publicKey" validate:"required,base64,max=256"`
+}
@@ -0,0 +1,6 @@
+package requests
+
+// ReorderStickerPacksRequest defines the payload for the reorder sticker packs endpoint
+type ReorderStickerPacksRequest struct {
+	PackIDs []uint `json:"packIds" validate:"required,min=1,dive,gt=0"`
+}
@@ -0,0 +1,8 @@
+package requests
+
+import "time"
+
+// RescheduleMessageRequest defines the payload for the reschedule message endpoint
+type RescheduleMessageRequest struct {
+	ScheduledAt time.Time `json:"scheduledAt" validate:"required"`
+}
@@ -0,0 +1,10 @@
+package requests
+
+import "time"
+
+// ResolveReportRequest defines the payload for the resolve report endpoint
+type ResolveReportRequest struct {
+	Action       string     `json:"action" validate:"required,oneof=delete_content warn suspend dismiss"`
+	Note         *string    `json:"note" validate:"omitempty,max=1000"`
+	SuspendUntil *time.Time `json:"suspendUntil" validate:"required_if=Action suspend"`
+}
@@ -0,0 +1,6 @@
+package requests
+
+// ReviewFlagRequest defines the payload for the review flag endpoint
+type ReviewFlagRequest struct {
+	Status string `json:"status" validate:"required,oneof=approved removed"`
+}
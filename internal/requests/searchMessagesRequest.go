@@ -0,0 +1,7 @@
+package requests
+
+// SearchMessagesRequest defines the query parameters of the message search endpoints
+type SearchMessagesRequest struct {
+	Query string `json:"query" validate:"required,min=2,max=200"`
+	Limit int    `json:"limit" validate:"gt=0,lte=100"`
+}
@@ -0,0 +1,10 @@
+package requests
+
+// SendContactRequest defines the payload for the send contact endpoint.
+// The fields can be omitted when a vCard is given, explicit fields take precedence over the vCard.
+type SendContactRequest struct {
+	FirstName *string `json:"firstName" validate:"required_without=VCard,omitempty,min=1,max=100"`
+	LastName  *string `json:"lastName" validate:"omitempty,max=100"`
+	Phone     *string `json:"phone" validate:"required_without=VCard,omitempty,max=32"`
+	VCard     *string `json:"vcard" validate:"omitempty,max=10000"`
+}
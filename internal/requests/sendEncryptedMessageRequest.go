@@ -0,0 +1,16 @@
+package requests
+
+// SendEncryptedMessageRequest defines the payload for the send encrypted message endpoint.
+// The server relays the ciphertexts without reading them.
+type SendEncryptedMessageRequest struct {
+	SenderDeviceID uint                 `json:"senderDeviceId" validate:"required,gt=0"`
+	ParentID       *uint                `json:"parentId" validate:"omitempty,gt=0"`
+	Ciphertexts    []*CiphertextRequest `json:"ciphertexts" validate:"required,min=1,max=50,dive"`
+}
+
+type CiphertextRequest struct {
+	UserID   uint   `json:"userId" validate:"required,gt=0"`
+	DeviceID uint   `json:"deviceId" validate:"required,gt=0"`
+	Type     string `json:"type" validate:"required,oneof=prekey message"`
+	Body     string `json:"body" validate:"required,base64,max=65536"`
+}
@@ -0,0 +1,6 @@
+package requests
+
+// SendGifRequest defines the payload for the send GIF endpoint
+type SendGifRequest struct {
+	GifID string `json:"gifId" validate:"required,max=100"`
+}
@@ -0,0 +1,11 @@
+package requests
+
+// SendLocationRequest defines the payload for the send location endpoint.
+// LivePeriod turns the location into a live location shared for the given number of seconds.
+type SendLocationRequest struct {
+	Latitude   *float64 `json:"latitude" validate:"required,gte=-90,lte=90"`
+	Longitude  *float64 `json:"longitude" validate:"required,gte=-180,lte=180"`
+	Accuracy   *float64 `json:"accuracy" validate:"omitempty,gte=0,lte=1500"`
+	Heading    *int     `json:"heading" validate:"omitempty,gte=0,lte=359"`
+	LivePeriod *int     `json:"livePeriod" validate:"omitempty,gte=60,lte=86400"`
+}
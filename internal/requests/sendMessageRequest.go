@@ -1,8 +1,17 @@
 package requests
 
+import "time"
+
 // SendMessageRequest defines the payload for the send message endpoint
 type SendMessageRequest struct {
-	RecipientID uint    `json:"recipientId" validate:"required,gt=0"`
-	ParentID    *uint   `json:"parentId" validate:"omitempty,gt=0"`
-	Content     *string `json:"content" validate:"omitempty,min=1,max=5000"`
+	RecipientID uint                    `json:"recipientId" validate:"required,gt=0"`
+	ParentID    *uint                   `json:"parentId" validate:"omitempty,gt=0"`
+	Content     *string                 `json:"content" validate:"omitempty,min=1,max=5000"`
+	ScheduledAt *time.Time              `json:"scheduledAt"`
+	QuoteText   *string                 `json:"quoteText" validate:"omitempty,min=1,max=1000"`
+	QuoteOffset *int                    `json:"quoteOffset" validate:"omitempty,gte=0"`
+	Entities    []*MessageEntityRequest `json:"entities" validate:"omitempty,max=100,dive"`
+	ParseMode   *string                 `json:"parseMode" validate:"omitempty,oneof=markdown"`
+	Silent      bool                    `json:"silent"`
+	NoForward   bool                    `json:"noForward"`
 }
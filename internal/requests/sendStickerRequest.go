@@ -0,0 +1,6 @@
+package requests
+
+// SendStickerRequest defines the payload for the send sticker endpoint
+type SendStickerRequest struct {
+	StickerID uint `json:"stickerId" validate:"required,gt=0"`
+}
@@ -0,0 +1,7 @@
+package requests
+
+// StarMessageRequest defines the payload for the star message endpoint
+type StarMessageRequest struct {
+	MessageID uint     `json:"messageId" validate:"required,gt=0"`
+	Tags      []string `json:"tags" validate:"omitempty,max=10,dive,required,max=32"`
+}
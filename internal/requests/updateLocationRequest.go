@@ -0,0 +1,9 @@
+package requests
+
+// UpdateLocationRequest defines the payload for the live location update endpoint
+type UpdateLocationRequest struct {
+	Latitude  *float64 `json:"latitude" validate:"required,gte=-90,lte=90"`
+	Longitude *float64 `json:"longitude" validate:"required,gte=-180,lte=180"`
+	Accuracy  *float64 `json:"accuracy" validate:"omitempty,gte=0,lte=1500"`
+	Heading   *int     `json:"heading" validate:"omitempty,gte=0,lte=359"`
+}
@@ -0,0 +1,6 @@
+package requests
+
+// UploadPreKeysRequest defines the payload for the upload prekeys endpoint
+type UploadPreKeysRequest struct {
+	PreKeys []*PreKeyRequest `json:"preKeys" validate:"required,min=1,max=100,dive"`
+}
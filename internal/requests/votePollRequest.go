@@ -0,0 +1,6 @@
+package requests
+
+// VotePollRequest defines the payload for the poll vote endpoint
+type VotePollRequest struct {
+	OptionIDs []uint `json:"optionIds" validate:"required,min=1,max=10,dive,gt=0"`
+}
@@ -3,6 +3,7 @@ package responses
 import (
 	"encoding/json"
 	"log"
+	"mime"
 	"net/http"
 )
 
@@ -36,6 +37,14 @@ func ServeFileResponse(w http.ResponseWriter, r *http.Request, filePath string)
 	http.ServeFile(w, r, filePath)
 }
 
+// DownloadResponse sends generated content as a file download
+func DownloadResponse(w http.ResponseWriter, contentType string, fileName string, data []byte) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": fileName}))
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
 // ErrorResponse sends an error JSON response
 func ErrorResponse(w http.ResponseWriter, statusCode int, message string, err string) {
 	log.Println(err)
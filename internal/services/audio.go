@@ -0,0 +1,381 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	WaveformBuckets = 100
+	WaveformPeak    = 255
+
+	opusSampleRate = 48000
+)
+
+var ErrInvalidAudio = errors.New("invalid or unsupported audio file")
+
+// voiceFormats maps the extensions accepted for voice messages to their container parsers.
+// Each parser returns the duration and a loudness estimate for every audio frame.
+var voiceFormats = map[string]func(data []byte) (time.Duration, []float64, error){
+	".ogg":  parseOggOpus,
+	".oga":  parseOggOpus,
+	".opus": parseOggOpus,
+	".m4a":  parseM4A,
+	".mp3":  parseMP3,
+}
+
+// AudioInfo holds what is shown for a voice message before it is played.
+type AudioInfo struct {
+	Duration time.Duration
+	Waveform []int64
+}
+
+// IsVoiceFile reports whether a file is sent as a voice message, based on its extension.
+func IsVoiceFile(fileName string) bool {
+	_, ok := voiceFormats[strings.ToLower(filepath.Ext(fileName))]
+	return ok
+}
+
+// ParseAudio reads the container of an OGG/Opus, M4A or MP3 file to compute its duration and waveform.
+// The audio is not decoded: the waveform is estimated from the size of compressed frames, or from
+// the global gain of MP3 frames, which follow the loudness closely enough for a preview.
+func ParseAudio(fileName string, data []byte) (*AudioInfo, error) {
+	parse, ok := voiceFormats[strings.ToLower(filepath.Ext(fileName))]
+	if !ok {
+		return nil, ErrInvalidAudio
+	}
+
+	duration, levels, err := parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AudioInfo{
+		Duration: duration,
+		Waveform: buildWaveform(levels),
+	}, nil
+}
+
+// buildWaveform averages the frame levels into WaveformBuckets buckets scaled to 0..WaveformPeak.
+func buildWaveform(levels []float64) []int64 {
+	waveform := make([]int64, WaveformBuckets)
+	if len(levels) == 0 {
+		return waveform
+	}
+
+	sums := make([]float64, WaveformBuckets)
+	counts := make([]int, WaveformBuckets)
+	for i, level := range levels {
+		bucket := i * WaveformBuckets / len(levels)
+		sums[bucket] += level
+		counts[bucket]++
+	}
+
+	low, high := math.Inf(1), math.Inf(-1)
+	for i := range sums {
+		if counts[i] > 0 {
+			sums[i] /= float64(counts[i])
+		} else if i > 0 {
+			// Short recordings have fewer frames than buckets
+			sums[i] = sums[i-1]
+		}
+		low = math.Min(low, sums[i])
+		high = math.Max(high, sums[i])
+	}
+	if high == low {
+		return waveform
+	}
+
+	for i, sum := range sums {
+		waveform[i] = int64(math.Round((sum - low) / (high - low) * WaveformPeak))
+	}
+	return waveform
+}
+
+// parseOggOpus walks the pages of an Ogg stream. The duration comes from the granule position of the
+// last page, which counts 48 kHz samples including the pre-skip declared in the OpusHead packet.
+func parseOggOpus(data []byte) (time.Duration, []float64, error) {
+	var (
+		head       []byte
+		packetSize int
+		packets    int
+		granule    uint64
+		levels     []float64
+	)
+
+	for pos := 0; pos+27 <= len(data); {
+		page := data[pos:]
+		if string(page[:4]) != "OggS" {
+			return 0, nil, ErrInvalidAudio
+		}
+
+		segments := int(page[26])
+		if len(page) < 27+segments {
+			break
+		}
+		// A granule position of -1 means no packet ends on this page
+		if g := binary.LittleEndian.Uint64(page[6:14]); g != math.MaxUint64 {
+			granule = g
+		}
+
+		offset := 27 + segments
+		for _, lacing := range page[27 : 27+segments] {
+			size := int(lacing)
+			if offset+size > len(page) {
+				return 0, nil, ErrInvalidAudio
+			}
+			if packets == 0 {
+				head = append(head, page[offset:offset+size]...)
+			}
+			packetSize += size
+			offset += size
+
+			// A lacing value below 255 ends the packet
+			if lacing < 255 {
+				if packets == 0 && (len(head) < 19 || string(head[:8]) != "OpusHead") {
+					return 0, nil, ErrInvalidAudio
+				}
+				// The second packet holds the OpusTags comments
+				if packets > 1 {
+					levels = append(levels, float64(packetSize))
+				}
+				packets++
+				packetSize = 0
+			}
+		}
+		pos += offset
+	}
+
+	if packets < 2 {
+		return 0, nil, ErrInvalidAudio
+	}
+
+	preSkip := uint64(binary.LittleEndian.Uint16(head[10:12]))
+	if granule < preSkip {
+		granule = preSkip
+	}
+
+	return samplesDuration(granule-preSkip, opusSampleRate), levels, nil
+}
+
+// parseM4A reads the first sound track of an MP4 container. The duration comes from the media header
+// and the levels from the sample size table.
+func parseM4A(data []byte) (time.Duration, []float64, error) {
+	moov := findMP4Box(data, "moov")
+	for _, trak := range findMP4Boxes(moov, "trak") {
+		mdia := findMP4Box(trak, "mdia")
+		hdlr := findMP4Box(mdia, "hdlr")
+		if len(hdlr) < 12 || string(hdlr[8:12]) != "soun" {
+			continue
+		}
+
+		mdhd := findMP4Box(mdia, "mdhd")
+		var timescale, duration uint64
+		switch {
+		case len(mdhd) >= 32 && mdhd[0] == 1:
+			timescale = uint64(binary.BigEndian.Uint32(mdhd[20:24]))
+			duration = binary.BigEndian.Uint64(mdhd[24:32])
+		case len(mdhd) >= 20 && mdhd[0] == 0:
+			timescale = uint64(binary.BigEndian.Uint32(mdhd[12:16]))
+			duration = uint64(binary.BigEndian.Uint32(mdhd[16:20]))
+		default:
+			return 0, nil, ErrInvalidAudio
+		}
+		if timescale == 0 {
+			return 0, nil, ErrInvalidAudio
+		}
+
+		stsz := findMP4Box(findMP4Box(findMP4Box(mdia, "minf"), "stbl"), "stsz")
+		if len(stsz) < 12 {
+			return 0, nil, ErrInvalidAudio
+		}
+		sampleSize := binary.BigEndian.Uint32(stsz[4:8])
+		sampleCount := int(binary.BigEndian.Uint32(stsz[8:12]))
+
+		// A fixed sample size carries no loudness information, which leaves the waveform flat
+		levels := make([]float64, 0)
+		if sampleSize == 0 {
+			for i := 0; i < sampleCount && 16+i*4 <= len(stsz); i++ {
+				levels = append(levels, float64(binary.BigEndian.Uint32(stsz[12+i*4:16+i*4])))
+			}
+		}
+
+		return samplesDuration(duration, timescale), levels, nil
+	}
+
+	return 0, nil, ErrInvalidAudio
+}
+
+// findMP4Box returns the payload of the first child box of the given type.
+func findMP4Box(data []byte, boxType string) []byte {
+	boxes := findMP4Boxes(data, boxType)
+	if len(boxes) == 0 {
+		return nil
+	}
+	return boxes[0]
+}
+
+// findMP4Boxes returns the payloads of all child boxes of the given type.
+func findMP4Boxes(data []byte, boxType string) [][]byte {
+	boxes := make([][]byte, 0)
+	for pos := 0; pos+8 <= len(data); {
+		size := uint64(binary.BigEndian.Uint32(data[pos : pos+4]))
+		header := uint64(8)
+		switch size {
+		case 0:
+			// The box extends to the end of the data
+			size = uint64(len(data) - pos)
+		case 1:
+			if pos+16 > len(data) {
+				return boxes
+			}
+			size = binary.BigEndian.Uint64(data[pos+8 : pos+16])
+			header = 16
+		}
+		// Compared against the remaining length, as pos+size can overflow for a crafted 64-bit size
+		if size < header || size > uint64(len(data)-pos) {
+			return boxes
+		}
+
+		if string(data[pos+4:pos+8]) == boxType {
+			boxes = append(boxes, data[uint64(pos)+header:uint64(pos)+size])
+		}
+		pos += int(size)
+	}
+	return boxes
+}
+
+var (
+	mp3Bitrates = [2][15]int{
+		{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320}, // MPEG-1
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},     // MPEG-2 and 2.5
+	}
+	mp3SampleRates = [3]int{44100, 48000, 32000}
+)
+
+// parseMP3 walks the Layer III frames of an MP3 file, skipping ID3 tags and anything else
+// between frames. Each frame contributes the mean global gain of its granules as its level.
+func parseMP3(data []byte) (time.Duration, []float64, error) {
+	pos := 0
+	if len(data) >= 10 && string(data[:3]) == "ID3" {
+		// ID3v2 sizes are syncsafe integers with 7 bits per byte
+		size := int(data[6]&0x7F)<<21 | int(data[7]&0x7F)<<14 | int(data[8]&0x7F)<<7 | int(data[9]&0x7F)
+		pos = 10 + size
+		if data[5]&0x10 != 0 {
+			pos += 10
+		}
+	}
+
+	var (
+		sampleRate int
+		samples    uint64
+		levels     []float64
+		frames     int
+	)
+
+	for pos+4 <= len(data) {
+		header := binary.BigEndian.Uint32(data[pos : pos+4])
+		version := header >> 19 & 3
+		layer := header >> 17 & 3
+		bitrateIndex := header >> 12 & 0xF
+		rateIndex := header >> 10 & 3
+
+		// Skip to the next frame sync if this is not a valid Layer III header
+		if header>>21 != 0x7FF || version == 1 || layer != 1 || bitrateIndex == 0 || bitrateIndex == 15 || rateIndex == 3 {
+			pos++
+			continue
+		}
+
+		mpeg1 := version == 3
+		rate := mp3SampleRates[rateIndex]
+		bitrates, frameSamples := mp3Bitrates[1], 576
+		switch version {
+		case 3:
+			bitrates, frameSamples = mp3Bitrates[0], 1152
+		case 2:
+			rate /= 2
+		case 0:
+			rate /= 4
+		}
+
+		frameLength := frameSamples/8*bitrates[bitrateIndex]*1000/rate + int(header>>9&1)
+		if pos+frameLength > len(data) {
+			break
+		}
+
+		frame := data[pos : pos+frameLength]
+		mono := header>>6&3 == 3
+		// The first frame of VBR files carries a Xing/Info or VBRI header instead of audio
+		isInfoFrame := frames == 0 && (bytes.Contains(frame, []byte("Xing")) ||
+			bytes.Contains(frame, []byte("Info")) || bytes.Contains(frame, []byte("VBRI")))
+		if !isInfoFrame {
+			levels = append(levels, mp3GlobalGain(frame, mpeg1, mono, header>>16&1 == 0))
+			samples += uint64(frameSamples)
+			sampleRate = rate
+		}
+
+		frames++
+		pos += frameLength
+	}
+
+	if sampleRate == 0 {
+		return 0, nil, ErrInvalidAudio
+	}
+
+	return samplesDuration(samples, uint64(sampleRate)), levels, nil
+}
+
+// mp3GlobalGain returns the mean global gain of the granules of a frame, read from its side information.
+func mp3GlobalGain(frame []byte, mpeg1, mono, crc bool) float64 {
+	channels := 2
+	if mono {
+		channels = 1
+	}
+
+	offset := 32
+	if crc {
+		offset += 16
+	}
+
+	// Side information layout: main_data_begin, private bits, scfsi, then a block per granule and channel
+	granules, blockSize := 1, 63
+	if mpeg1 {
+		granules, blockSize = 2, 59
+		offset += 9 + 4*channels
+		if mono {
+			offset += 5
+		} else {
+			offset += 3
+		}
+	} else {
+		offset += 8 + channels
+	}
+
+	var sum float64
+	for i := 0; i < granules*channels; i++ {
+		// global_gain follows part2_3_length (12 bits) and big_values (9 bits)
+		sum += float64(readBits(frame, offset+i*blockSize+21, 8))
+	}
+	return sum / float64(granules*channels)
+}
+
+// readBits reads n bits starting at a bit offset, most significant bit first.
+func readBits(data []byte, offset, n int) uint {
+	var value uint
+	for i := offset; i < offset+n; i++ {
+		value <<= 1
+		if i/8 < len(data) && data[i/8]&(0x80>>(i%8)) != 0 {
+			value |= 1
+		}
+	}
+	return value
+}
+
+func samplesDuration(samples, rate uint64) time.Duration {
+	return time.Duration(float64(samples) / float64(rate) * float64(time.Second))
+}
@@ -0,0 +1,223 @@
+package services
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestParseAudio(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileName string
+		data     []byte
+		duration time.Duration
+	}{
+		{name: "ogg opus", fileName: "voice.ogg", data: buildOggOpus(312, 2*opusSampleRate, 50), duration: 2 * time.Second},
+		{name: "opus extension", fileName: "voice.OPUS", data: buildOggOpus(0, opusSampleRate/2, 10), duration: 500 * time.Millisecond},
+		{name: "m4a", fileName: "voice.m4a", data: buildM4A(0, 1000, 3500, 40), duration: 3500 * time.Millisecond},
+		{name: "m4a with a 64-bit media header", fileName: "voice.m4a", data: buildM4A(1, 44100, 44100, 40), duration: time.Second},
+		{name: "mp3", fileName: "voice.mp3", data: buildMP3(125, false), duration: samplesDuration(125*1152, 44100)},
+		{name: "mp3 with an ID3 tag", fileName: "voice.mp3", data: buildMP3(125, true), duration: samplesDuration(125*1152, 44100)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := ParseAudio(tt.fileName, tt.data)
+			if err != nil {
+				t.Fatalf("ParseAudio() error = %v", err)
+			}
+			if diff := info.Duration - tt.duration; diff < -time.Millisecond || diff > time.Millisecond {
+				t.Errorf("Duration = %s, want %s", info.Duration, tt.duration)
+			}
+			if len(info.Waveform) != WaveformBuckets {
+				t.Fatalf("len(Waveform) = %d, want %d", len(info.Waveform), WaveformBuckets)
+			}
+
+			// The samples get louder over time, so the waveform rises from silence to the peak
+			if info.Waveform[0] != 0 || info.Waveform[WaveformBuckets-1] != WaveformPeak {
+				t.Errorf("Waveform = %v, want it to rise from 0 to %d", info.Waveform, WaveformPeak)
+			}
+		})
+	}
+}
+
+func TestParseAudioRejectsInvalidFiles(t *testing.T) {
+	// A 64-bit box size that overflows the offset of the box once added to it
+	overflowingBox := make([]byte, 24)
+	binary.BigEndian.PutUint32(overflowingBox[0:4], 8)
+	copy(overflowingBox[4:8], "free")
+	binary.BigEndian.PutUint32(overflowingBox[8:12], 1)
+	copy(overflowingBox[12:16], "moov")
+	binary.BigEndian.PutUint64(overflowingBox[16:24], math.MaxUint64-7)
+
+	noOpusHead := buildOggOpus(312, opusSampleRate, 10)
+	copy(noOpusHead[28:36], "OpusTail")
+
+	tests := []struct {
+		name     string
+		fileName string
+		data     []byte
+	}{
+		{name: "unsupported extension", fileName: "voice.wav", data: buildOggOpus(0, opusSampleRate, 10)},
+		{name: "empty ogg", fileName: "voice.ogg", data: nil},
+		{name: "ogg without OpusHead", fileName: "voice.ogg", data: noOpusHead},
+		{name: "ogg with a bad capture pattern", fileName: "voice.ogg", data: []byte("OggX" + string(make([]byte, 40)))},
+		{name: "m4a in an ogg file", fileName: "voice.ogg", data: buildM4A(0, 1000, 1000, 10)},
+		{name: "m4a with an overflowing box size", fileName: "voice.m4a", data: overflowingBox},
+		{name: "m4a without a sound track", fileName: "voice.m4a", data: mp4Box("moov", mp4Box("trak", mp4Box("mdia")))},
+		{name: "m4a with a zero timescale", fileName: "voice.m4a", data: buildM4A(0, 0, 1000, 10)},
+		{name: "mp3 without frames", fileName: "voice.mp3", data: make([]byte, 1024)},
+		{name: "mp3 with a huge ID3 tag", fileName: "voice.mp3", data: []byte{'I', 'D', '3', 4, 0, 0x10, 0x7F, 0x7F, 0x7F, 0x7F}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseAudio(tt.fileName, tt.data); !errors.Is(err, ErrInvalidAudio) {
+				t.Errorf("ParseAudio() error = %v, want ErrInvalidAudio", err)
+			}
+		})
+	}
+}
+
+func TestParseAudioTruncatedFiles(t *testing.T) {
+	samples := map[string][]byte{
+		"voice.ogg": buildOggOpus(312, opusSampleRate, 20),
+		"voice.m4a": buildM4A(1, 1000, 1000, 20),
+		"voice.mp3": buildMP3(10, true),
+	}
+
+	for fileName, data := range samples {
+		t.Run(fileName, func(t *testing.T) {
+			for size := 0; size < len(data); size++ {
+				func() {
+					defer func() {
+						if r := recover(); r != nil {
+							t.Fatalf("ParseAudio() of the first %d bytes panicked: %v", size, r)
+						}
+					}()
+					ParseAudio(fileName, data[:size])
+				}()
+			}
+		})
+	}
+}
+
+// buildOggOpus builds an Ogg Opus stream with the given pre-skip and length in 48 kHz samples.
+// Audio packets grow in size, so the loudness estimate rises.
+func buildOggOpus(preSkip uint16, samples uint64, packets int) []byte {
+	head := make([]byte, 19)
+	copy(head, "OpusHead")
+	head[8] = 1
+	head[9] = 1
+	binary.LittleEndian.PutUint16(head[10:12], preSkip)
+	binary.LittleEndian.PutUint32(head[12:16], opusSampleRate)
+
+	data := oggPage(0, head)
+	data = append(data, oggPage(0, []byte("OpusTags\x00\x00\x00\x00\x00\x00\x00\x00"))...)
+	for i := 0; i < packets; i++ {
+		granule := uint64(preSkip) + samples*uint64(i+1)/uint64(packets)
+		// 300 bytes and more take several lacing values
+		data = append(data, oggPage(granule, make([]byte, 100+i*10))...)
+	}
+	return data
+}
+
+func oggPage(granule uint64, packet []byte) []byte {
+	lacing := make([]byte, 0)
+	size := len(packet)
+	for ; size >= 255; size -= 255 {
+		lacing = append(lacing, 255)
+	}
+	lacing = append(lacing, byte(size))
+
+	page := make([]byte, 27)
+	copy(page, "OggS")
+	binary.LittleEndian.PutUint64(page[6:14], granule)
+	page[26] = byte(len(lacing))
+	page = append(page, lacing...)
+	return append(page, packet...)
+}
+
+// buildM4A builds an MP4 container with a sound track whose sample sizes grow.
+func buildM4A(version byte, timescale uint32, duration uint64, samples int) []byte {
+	var mdhd []byte
+	if version == 1 {
+		mdhd = make([]byte, 36)
+		binary.BigEndian.PutUint32(mdhd[20:24], timescale)
+		binary.BigEndian.PutUint64(mdhd[24:32], duration)
+	} else {
+		mdhd = make([]byte, 24)
+		binary.BigEndian.PutUint32(mdhd[12:16], timescale)
+		binary.BigEndian.PutUint32(mdhd[16:20], uint32(duration))
+	}
+	mdhd[0] = version
+
+	hdlr := make([]byte, 24)
+	copy(hdlr[8:12], "soun")
+
+	stsz := make([]byte, 12+4*samples)
+	binary.BigEndian.PutUint32(stsz[8:12], uint32(samples))
+	for i := 0; i < samples; i++ {
+		binary.BigEndian.PutUint32(stsz[12+i*4:16+i*4], uint32(200+i*5))
+	}
+
+	video := mp4Box("trak", mp4Box("mdia", mp4Box("hdlr", make([]byte, 8), []byte("vide"), make([]byte, 12))))
+	sound := mp4Box("trak", mp4Box("mdia",
+		mp4Box("mdhd", mdhd),
+		mp4Box("hdlr", hdlr),
+		mp4Box("minf", mp4Box("stbl", mp4Box("stsz", stsz))),
+	))
+
+	return append(mp4Box("ftyp", []byte("M4A \x00\x00\x00\x00")), mp4Box("moov", video, sound)...)
+}
+
+func mp4Box(boxType string, payloads ...[]byte) []byte {
+	box := make([]byte, 8)
+	copy(box[4:8], boxType)
+	for _, payload := range payloads {
+		box = append(box, payload...)
+	}
+	binary.BigEndian.PutUint32(box[0:4], uint32(len(box)))
+	return box
+}
+
+// buildMP3 builds MPEG-1 Layer III frames at 128 kbps and 44.1 kHz, stereo and without CRC,
+// whose global gain grows. A Xing frame and an ID3 tag can be put in front.
+func buildMP3(frames int, tagged bool) []byte {
+	const frameLength = 1152 / 8 * 128000 / 44100
+	header := []byte{0xFF, 0xFB, 0x90, 0x00}
+
+	data := make([]byte, 0)
+	if tagged {
+		data = append(data, 'I', 'D', '3', 3, 0, 0, 0, 0, 0, 20)
+		data = append(data, make([]byte, 20)...)
+
+		info := make([]byte, frameLength)
+		copy(info, header)
+		copy(info[36:], "Xing")
+		data = append(data, info...)
+	}
+
+	for i := 0; i < frames; i++ {
+		frame := make([]byte, frameLength)
+		copy(frame, header)
+		// The side information starts after the header; see mp3GlobalGain for the layout
+		for block := 0; block < 4; block++ {
+			writeBits(frame, 52+block*59+21, 8, uint(100+i))
+		}
+		data = append(data, frame...)
+	}
+	return data
+}
+
+func writeBits(data []byte, offset, n int, value uint) {
+	for i := 0; i < n; i++ {
+		bit := offset + i
+		if value&(1<<(n-1-i)) != 0 {
+			data[bit/8] |= 0x80 >> (bit % 8)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package services
+
+import (
+	"database/sql"
+	"github.com/drTragger/messenger-backend/internal/models"
+	"github.com/drTragger/messenger-backend/internal/repository"
+	"github.com/drTragger/messenger-backend/internal/websocket"
+)
+
+// ChatService creates chats and tells the other participant about them through the outbox.
+type ChatService struct {
+	DB         *sql.DB
+	ChatRepo   *repository.ChatRepository
+	OutboxRepo *repository.OutboxRepository
+}
+
+func NewChatService(db *sql.DB, chatRepo *repository.ChatRepository, outboxRepo *repository.OutboxRepository) *ChatService {
+	return &ChatService{
+		DB:         db,
+		ChatRepo:   chatRepo,
+		OutboxRepo: outboxRepo,
+	}
+}
+
+// Create stores a chat between two users and puts a NewChatEvent for the second one in the outbox,
+// in the same transaction. The error of the chat repository is returned as is, so a unique violation
+// can still be recognized.
+func (s *ChatService) Create(user1, user2 *models.User) (*models.Chat, error) {
+	var chat *models.Chat
+	err := repository.RunInTx(s.DB, func(tx *sql.Tx) error {
+		var err error
+		chat, err = s.ChatRepo.WithTx(tx).Create(user1.ID, user2.ID, nil)
+		if err != nil {
+			return err
+		}
+		chat.User1 = user1
+		chat.User2 = user2
+
+		return s.OutboxRepo.WithTx(tx).Create(user2.ID, string(websocket.NewChatEvent), chat)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return chat, nil
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"github.com/drTragger/messenger-backend/internal/models"
+	"github.com/drTragger/messenger-backend/internal/repository"
+	"github.com/drTragger/messenger-backend/internal/websocket"
+	"log"
+	"time"
+)
+
+// DeliveryService tracks when messages reach a device of their recipient and tells the senders about it
+// through the outbox.
+type DeliveryService struct {
+	DB         *sql.DB
+	MsgRepo    *repository.MessageRepository
+	OutboxRepo *repository.OutboxRepository
+}
+
+func NewDeliveryService(db *sql.DB, msgRepo *repository.MessageRepository, outboxRepo *repository.OutboxRepository) *DeliveryService {
+	return &DeliveryService{
+		DB:         db,
+		MsgRepo:    msgRepo,
+		OutboxRepo: outboxRepo,
+	}
+}
+
+// HandleDelivered marks a new message as delivered once it was written to the recipient's socket.
+// It is registered as the delivery hook of the client manager.
+func (s *DeliveryService) HandleDelivered(userID uint, notification *websocket.Notification) {
+	if notification.Event != websocket.NewMessageEvent {
+		return
+	}
+	var message *models.Message
+	switch payload := notification.Message.(type) {
+	case *models.Message:
+		message = payload
+	case json.RawMessage:
+		// Events published by the outbox relay carry the encoded message
+		if err := json.Unmarshal(payload, &message); err != nil {
+			log.Printf("Error decoding delivered message: %s", err)
+			return
+		}
+	}
+	if message == nil || message.RecipientID != userID {
+		return
+	}
+
+	// The message may still be used by the request that sent it, so it is not updated here
+	if _, err := s.markDelivered(userID, []*models.Message{message}); err != nil {
+		log.Printf("Error marking message %d as delivered: %s", message.ID, err)
+	}
+}
+
+// MarkDelivered marks the messages that the recipient fetched as delivered and updates their status.
+func (s *DeliveryService) MarkDelivered(recipientID uint, messages []*models.Message) error {
+	delivered, err := s.markDelivered(recipientID, messages)
+	if err != nil {
+		return err
+	}
+
+	for _, message := range messages {
+		if deliveredAt, ok := delivered[message.ID]; ok {
+			message.DeliveredAt = &deliveredAt
+			message.SetStatus()
+		}
+	}
+	return nil
+}
+
+func (s *DeliveryService) markDelivered(recipientID uint, messages []*models.Message) (map[uint]time.Time, error) {
+	pending := make([]uint, 0)
+	for _, message := range messages {
+		if message.RecipientID == recipientID && message.DeliveredAt == nil {
+			pending = append(pending, message.ID)
+		}
+	}
+	if len(pending) == 0 {
+		return nil, nil
+	}
+
+	var delivered map[uint]time.Time
+	err := repository.RunInTx(s.DB, func(tx *sql.Tx) error {
+		var err error
+		delivered, err = s.MsgRepo.WithTx(tx).MarkAsDelivered(recipientID, pending)
+		if err != nil {
+			return err
+		}
+
+		outboxRepo := s.OutboxRepo.WithTx(tx)
+		for _, message := range messages {
+			deliveredAt, ok := delivered[message.ID]
+			if !ok {
+				continue
+			}
+			event := websocket.NewDelivery(message.ID, message.ChatID, deliveredAt)
+			if err := outboxRepo.Create(message.SenderID, string(websocket.DeliveredMessageEvent), event); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return delivered, nil
+}
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"database/sql/driver"
+	"github.com/drTragger/messenger-backend/internal/fakedb"
+	"github.com/drTragger/messenger-backend/internal/models"
+	"github.com/drTragger/messenger-backend/internal/repository"
+	"github.com/drTragger/messenger-backend/internal/websocket"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeliveryServiceMarkDelivered(t *testing.T) {
+	deliveredAt := time.Now()
+	db, fake := fakedb.Open(t, func(query string, args []driver.Value) fakedb.Result {
+		if strings.Contains(query, "SET delivered_at") {
+			// Message 2 was delivered by a concurrent request
+			return fakedb.Row(int64(1), deliveredAt)
+		}
+		return fakedb.Result{Affected: 1}
+	})
+	service := NewDeliveryService(db, repository.NewMessageRepository(db), repository.NewOutboxRepository(db))
+
+	messages := []*models.Message{
+		{ID: 1, SenderID: 5, RecipientID: 9, ChatID: 3},
+		{ID: 2, SenderID: 5, RecipientID: 9, ChatID: 3},
+		{ID: 3, SenderID: 9, RecipientID: 5, ChatID: 3},
+	}
+	if err := service.MarkDelivered(9, messages); err != nil {
+		t.Fatalf("MarkDelivered() error = %v", err)
+	}
+
+	if messages[0].DeliveredAt == nil || messages[1].DeliveredAt != nil || messages[2].DeliveredAt != nil {
+		t.Errorf("delivered = %v, %v, %v, want only message 1", messages[0].DeliveredAt, messages[1].DeliveredAt, messages[2].DeliveredAt)
+	}
+	updates := fake.Executed("SET delivered_at")
+	if len(updates) != 1 || updates[0].Args[0] != "{1,2}" {
+		t.Errorf("updates = %v, want messages 1 and 2 of the recipient", updates)
+	}
+	events := fake.Executed("INSERT INTO outbox_events")
+	if len(events) != 1 || events[0].Args[0] != int64(5) || events[0].Args[1] != string(websocket.DeliveredMessageEvent) {
+		t.Errorf("outbox events = %v, want a DeliveredMessageEvent for user 5", events)
+	}
+	if fake.Commits != 1 {
+		t.Errorf("%d commits, want the update and the event in one transaction", fake.Commits)
+	}
+}
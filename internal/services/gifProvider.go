@@ -0,0 +1,194 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/drTragger/messenger-backend/internal/models"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	GifSearchLimit  = 25
+	GifSearchOffset = 0
+
+	giphyBaseURL = "https://api.giphy.com/v1/gifs"
+	giphyTimeout = 5 * time.Second
+)
+
+var ErrGifNotFound = errors.New("gif not found")
+
+// GifProvider searches GIFs in an external catalogue. Implementations return GIFs
+// with Provider set to their Name, so a picked GIF can be resolved again by its ID.
+type GifProvider interface {
+	Name() string
+	Search(ctx context.Context, query string, limit, offset int) ([]*models.Gif, error)
+	Get(ctx context.Context, id string) (*models.Gif, error)
+}
+
+// GiphyProvider searches GIFs through the GIPHY API.
+type GiphyProvider struct {
+	APIKey string
+	Client *http.Client
+}
+
+func NewGiphyProvider(apiKey string) *GiphyProvider {
+	return &GiphyProvider{
+		APIKey: apiKey,
+		Client: &http.Client{Timeout: giphyTimeout},
+	}
+}
+
+type giphyGif struct {
+	ID     string `json:"id"`
+	Title  string `json:"title"`
+	Images struct {
+		Original struct {
+			URL    string `json:"url"`
+			Width  string `json:"width"`
+			Height string `json:"height"`
+		} `json:"original"`
+		FixedWidthSmall struct {
+			URL string `json:"url"`
+		} `json:"fixed_width_small"`
+	} `json:"images"`
+}
+
+func (p *GiphyProvider) Name() string {
+	return "giphy"
+}
+
+func (p *GiphyProvider) Search(ctx context.Context, query string, limit, offset int) ([]*models.Gif, error) {
+	params := url.Values{}
+	params.Set("q", query)
+	params.Set("limit", strconv.Itoa(limit))
+	params.Set("offset", strconv.Itoa(offset))
+
+	var response struct {
+		Data []giphyGif `json:"data"`
+	}
+	if err := p.get(ctx, "/search", params, &response); err != nil {
+		return nil, err
+	}
+
+	gifs := make([]*models.Gif, 0, len(response.Data))
+	for _, gif := range response.Data {
+		gifs = append(gifs, p.toModel(&gif))
+	}
+
+	return gifs, nil
+}
+
+func (p *GiphyProvider) Get(ctx context.Context, id string) (*models.Gif, error) {
+	var response struct {
+		Data *giphyGif `json:"data"`
+	}
+	if err := p.get(ctx, "/"+url.PathEscape(id), url.Values{}, &response); err != nil {
+		return nil, err
+	}
+	if response.Data == nil || response.Data.ID == "" {
+		return nil, ErrGifNotFound
+	}
+
+	return p.toModel(response.Data), nil
+}
+
+func (p *GiphyProvider) get(ctx context.Context, path string, params url.Values, out interface{}) error {
+	params.Set("api_key", p.APIKey)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, giphyBaseURL+path+"?"+params.Encode(), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrGifNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("giphy responded with status %d", resp.StatusCode)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (p *GiphyProvider) toModel(gif *giphyGif) *models.Gif {
+	width, _ := strconv.Atoi(gif.Images.Original.Width)
+	height, _ := strconv.Atoi(gif.Images.Original.Height)
+
+	result := &models.Gif{
+		Provider:   p.Name(),
+		ExternalID: gif.ID,
+		URL:        gif.Images.Original.URL,
+		Width:      width,
+		Height:     height,
+	}
+	if gif.Images.FixedWidthSmall.URL != "" {
+		result.PreviewURL = &gif.Images.FixedWidthSmall.URL
+	}
+	if gif.Title != "" {
+		result.Title = &gif.Title
+	}
+
+	return result
+}
+
+// LocalGifProvider searches a fixed in-memory catalogue by title.
+// It stands in for a real provider in development and tests.
+type LocalGifProvider struct {
+	Gifs []*models.Gif
+}
+
+func NewLocalGifProvider(gifs ...*models.Gif) *LocalGifProvider {
+	for _, gif := range gifs {
+		gif.Provider = "local"
+	}
+	return &LocalGifProvider{
+		Gifs: gifs,
+	}
+}
+
+func (p *LocalGifProvider) Name() string {
+	return "local"
+}
+
+func (p *LocalGifProvider) Search(_ context.Context, query string, limit, offset int) ([]*models.Gif, error) {
+	query = strings.ToLower(query)
+
+	matches := make([]*models.Gif, 0)
+	for _, gif := range p.Gifs {
+		if gif.Title != nil && strings.Contains(strings.ToLower(*gif.Title), query) {
+			matches = append(matches, gif)
+		}
+	}
+
+	if offset >= len(matches) {
+		return []*models.Gif{}, nil
+	}
+	matches = matches[offset:]
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+
+	return matches, nil
+}
+
+func (p *LocalGifProvider) Get(_ context.Context, id string) (*models.Gif, error) {
+	for _, gif := range p.Gifs {
+		if gif.ExternalID == id {
+			found := *gif
+			return &found, nil
+		}
+	}
+	return nil, ErrGifNotFound
+}
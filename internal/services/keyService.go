@@ -0,0 +1,119 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/drTragger/messenger-backend/internal/models"
+	"github.com/drTragger/messenger-backend/internal/repository"
+	"github.com/drTragger/messenger-backend/internal/websocket"
+)
+
+// PreKeysLowThreshold is the number of one-time prekeys below which a device is asked to upload more
+const PreKeysLowThreshold = 10
+
+var ErrInvalidEnvelope = errors.New("ciphertext is addressed to an unknown device")
+
+// KeyService distributes the public keys that devices use to set up end-to-end encrypted sessions.
+// The server only ever sees public keys and ciphertext.
+type KeyService struct {
+	DB         *sql.DB
+	KeyRepo    *repository.KeyRepository
+	OutboxRepo *repository.OutboxRepository
+}
+
+func NewKeyService(db *sql.DB, keyRepo *repository.KeyRepository, outboxRepo *repository.OutboxRepository) *KeyService {
+	return &KeyService{
+		DB:         db,
+		KeyRepo:    keyRepo,
+		OutboxRepo: outboxRepo,
+	}
+}
+
+// PublishDevice stores the keys of a device in one transaction. The one-time prekeys replace the ones
+// the device had, since they belong to its previous identity key if it was reinstalled.
+func (s *KeyService) PublishDevice(keys *models.DeviceKeys, preKeys []*models.PreKey) (*models.DeviceKeys, error) {
+	err := repository.RunInTx(s.DB, func(tx *sql.Tx) error {
+		keyRepo := s.KeyRepo.WithTx(tx)
+		if err := keyRepo.SaveDevice(keys); err != nil {
+			return err
+		}
+		if err := keyRepo.DeletePreKeys(keys.ID); err != nil {
+			return err
+		}
+		return keyRepo.AddPreKeys(keys.ID, preKeys)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.KeyRepo.GetDevice(keys.UserID, keys.DeviceID)
+}
+
+// ClaimBundles hands out a prekey bundle for each device of a user and warns the user about the devices
+// that are running out of one-time prekeys. The warnings go to the outbox in the transaction that claims the keys.
+func (s *KeyService) ClaimBundles(userID uint) ([]*models.PreKeyBundle, error) {
+	var bundles []*models.PreKeyBundle
+	err := repository.RunInTx(s.DB, func(tx *sql.Tx) error {
+		var err error
+		bundles, err = s.KeyRepo.WithTx(tx).ClaimBundles(userID)
+		if err != nil {
+			return err
+		}
+
+		outboxRepo := s.OutboxRepo.WithTx(tx)
+		for _, bundle := range bundles {
+			if bundle.Remaining >= PreKeysLowThreshold {
+				continue
+			}
+			event := websocket.NewPreKeysLow(bundle.DeviceID, bundle.Remaining)
+			if err := outboxRepo.Create(userID, string(websocket.PreKeysLowEvent), event); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return bundles, nil
+}
+
+// EncodeEnvelope checks that every ciphertext of the envelope is addressed to a device of a participant
+// and returns the envelope as the content of an encrypted message.
+func (s *KeyService) EncodeEnvelope(envelope *models.EncryptedEnvelope, senderID, recipientID uint) (string, error) {
+	devices := make(map[uint]map[uint]bool, 2)
+	for _, userID := range []uint{senderID, recipientID} {
+		deviceIDs, err := s.KeyRepo.GetDeviceIDs(userID)
+		if err != nil {
+			return "", err
+		}
+		devices[userID] = make(map[uint]bool, len(deviceIDs))
+		for _, deviceID := range deviceIDs {
+			devices[userID][deviceID] = true
+		}
+	}
+
+	if !devices[senderID][envelope.SenderDeviceID] {
+		return "", ErrInvalidEnvelope
+	}
+	addressesRecipient := false
+	for _, ciphertext := range envelope.Ciphertexts {
+		if !devices[ciphertext.UserID][ciphertext.DeviceID] {
+			return "", ErrInvalidEnvelope
+		}
+		if ciphertext.UserID == recipientID {
+			addressesRecipient = true
+		}
+	}
+	if !addressesRecipient {
+		return "", ErrInvalidEnvelope
+	}
+
+	content, err := json.Marshal(envelope)
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
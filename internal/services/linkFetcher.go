@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+const (
+	LinkFetchTimeout     = 10 * time.Second
+	LinkDialTimeout      = 5 * time.Second
+	LinkMaxBodySize      = 512 << 10 // 512 KB is plenty for the <head> of a page
+	LinkMaxRedirects     = 5
+	LinkPreviewUserAgent = "MessengerLinkPreview/1.0"
+)
+
+var ErrBlockedAddress = errors.New("address is not allowed")
+
+// FetchedPage is the result of fetching a link. Body is truncated to the fetcher's size limit.
+type FetchedPage struct {
+	URL         string
+	ContentType string
+	Body        []byte
+}
+
+// LinkFetcher downloads pages for link previews.
+type LinkFetcher interface {
+	Fetch(ctx context.Context, url string) (*FetchedPage, error)
+}
+
+// HTTPFetcher fetches pages over HTTP(S). Connections to private, loopback and other
+// non-public addresses are refused at dial time, so redirects and DNS tricks can't reach them.
+type HTTPFetcher struct {
+	Client      *http.Client
+	MaxBodySize int64
+}
+
+func NewHTTPFetcher() *HTTPFetcher {
+	return &HTTPFetcher{
+		Client:      newLinkClient(blockNonPublicAddresses),
+		MaxBodySize: LinkMaxBodySize,
+	}
+}
+
+// newLinkClient builds the HTTP client of the fetcher. Control runs before every connection is made.
+func newLinkClient(control func(network, address string, c syscall.RawConn) error) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: LinkDialTimeout,
+		Control: control,
+	}
+
+	transport := &http.Transport{
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   LinkDialTimeout,
+		ResponseHeaderTimeout: LinkDialTimeout,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       30 * time.Second,
+	}
+
+	return &http.Client{
+		Timeout:   LinkFetchTimeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= LinkMaxRedirects {
+				return fmt.Errorf("stopped after %d redirects", LinkMaxRedirects)
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("unsupported redirect scheme: %s", req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+}
+
+func (f *HTTPFetcher) Fetch(ctx context.Context, url string) (*FetchedPage, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", LinkPreviewUserAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status: %d", resp.StatusCode)
+	}
+
+	contentType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || (contentType != "text/html" && contentType != "application/xhtml+xml") {
+		return nil, fmt.Errorf("unsupported content type: %s", resp.Header.Get("Content-Type"))
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, f.MaxBodySize))
+	if err != nil {
+		return nil, err
+	}
+
+	return &FetchedPage{
+		URL:         resp.Request.URL.String(),
+		ContentType: contentType,
+		Body:        body,
+	}, nil
+}
+
+// sharedAddressSpace is the carrier-grade NAT range (RFC 6598), which net.IP.IsPrivate doesn't cover.
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+func blockNonPublicAddresses(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || !isPublicIP(ip) {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, address)
+	}
+
+	return nil
+}
+
+func isPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return false
+	}
+
+	if ip4 := ip.To4(); ip4 != nil {
+		// 0.0.0.0/8 and the broadcast address
+		if ip4[0] == 0 || ip4.Equal(net.IPv4bcast) {
+			return false
+		}
+		if sharedAddressSpace.Contains(ip4) {
+			return false
+		}
+	}
+
+	return true
+}
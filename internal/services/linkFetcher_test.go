@@ -0,0 +1,187 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestFetcher returns a fetcher that is allowed to reach the local test servers.
+func newTestFetcher() *HTTPFetcher {
+	return &HTTPFetcher{
+		Client:      newLinkClient(nil),
+		MaxBodySize: LinkMaxBodySize,
+	}
+}
+
+func TestHTTPFetcherFetchesPage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("User-Agent") != LinkPreviewUserAgent {
+			t.Errorf("User-Agent = %q, want %q", r.Header.Get("User-Agent"), LinkPreviewUserAgent)
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte("<html><head><title>Hello</title></head></html>"))
+	}))
+	defer server.Close()
+
+	page, err := newTestFetcher().Fetch(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if page.ContentType != "text/html" {
+		t.Errorf("ContentType = %q, want text/html", page.ContentType)
+	}
+	if page.URL != server.URL {
+		t.Errorf("URL = %q, want %q", page.URL, server.URL)
+	}
+	if !strings.Contains(string(page.Body), "<title>Hello</title>") {
+		t.Errorf("Body = %q", page.Body)
+	}
+}
+
+func TestHTTPFetcherFollowsRedirects(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/new", http.StatusFound)
+	})
+	mux.HandleFunc("/new", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<title>New</title>"))
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	page, err := newTestFetcher().Fetch(context.Background(), server.URL+"/old")
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if page.URL != server.URL+"/new" {
+		t.Errorf("URL = %q, want the redirect target", page.URL)
+	}
+
+	if _, err := newTestFetcher().Fetch(context.Background(), server.URL+"/loop"); err == nil {
+		t.Error("Fetch() of a redirect loop succeeded")
+	}
+}
+
+func TestHTTPFetcherRejectsResponses(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		contentType string
+	}{
+		{name: "not found", status: http.StatusNotFound, contentType: "text/html"},
+		{name: "image", status: http.StatusOK, contentType: "image/png"},
+		{name: "json", status: http.StatusOK, contentType: "application/json"},
+		{name: "no content type", status: http.StatusOK, contentType: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header()["Content-Type"] = []string{tt.contentType}
+				w.WriteHeader(tt.status)
+				w.Write([]byte("<title>Page</title>"))
+			}))
+			defer server.Close()
+
+			if _, err := newTestFetcher().Fetch(context.Background(), server.URL); err == nil {
+				t.Error("Fetch() succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestHTTPFetcherLimitsBodySize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(strings.Repeat("a", 4096)))
+	}))
+	defer server.Close()
+
+	fetcher := newTestFetcher()
+	fetcher.MaxBodySize = 1024
+
+	page, err := fetcher.Fetch(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if len(page.Body) != 1024 {
+		t.Errorf("len(Body) = %d, want 1024", len(page.Body))
+	}
+}
+
+func TestHTTPFetcherTimesOut(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	fetcher := newTestFetcher()
+	fetcher.Client.Timeout = 100 * time.Millisecond
+
+	start := time.Now()
+	if _, err := fetcher.Fetch(context.Background(), server.URL); err == nil {
+		t.Fatal("Fetch() of a hanging server succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Fetch() returned after %s, want the client timeout", elapsed)
+	}
+}
+
+func TestHTTPFetcherRefusesLoopback(t *testing.T) {
+	requested := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = true
+	}))
+	defer server.Close()
+
+	_, err := NewHTTPFetcher().Fetch(context.Background(), server.URL)
+	if !errors.Is(err, ErrBlockedAddress) {
+		t.Errorf("Fetch() error = %v, want ErrBlockedAddress", err)
+	}
+	if requested {
+		t.Error("the loopback server was reached")
+	}
+}
+
+func TestIsPublicIP(t *testing.T) {
+	tests := []struct {
+		ip     string
+		public bool
+	}{
+		{ip: "93.184.216.34", public: true},
+		{ip: "2606:2800:220:1:248:1893:25c8:1946", public: true},
+		{ip: "127.0.0.1", public: false},
+		{ip: "::1", public: false},
+		{ip: "10.0.0.1", public: false},
+		{ip: "172.16.5.4", public: false},
+		{ip: "192.168.1.1", public: false},
+		{ip: "169.254.169.254", public: false},
+		{ip: "100.64.0.1", public: false},
+		{ip: "0.0.0.0", public: false},
+		{ip: "0.1.2.3", public: false},
+		{ip: "255.255.255.255", public: false},
+		{ip: "fc00::1", public: false},
+		{ip: "fe80::1", public: false},
+		{ip: "224.0.0.1", public: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			if got := isPublicIP(net.ParseIP(tt.ip)); got != tt.public {
+				t.Errorf("isPublicIP(%s) = %v, want %v", tt.ip, got, tt.public)
+			}
+		})
+	}
+}
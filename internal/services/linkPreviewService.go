@@ -0,0 +1,296 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"github.com/drTragger/messenger-backend/internal/models"
+	"github.com/drTragger/messenger-backend/internal/repository"
+	"github.com/drTragger/messenger-backend/internal/websocket"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	LinkPreviewCacheTTL      = 24 * time.Hour
+	LinkPreviewEmptyCacheTTL = 1 * time.Hour
+	LinkPreviewTitleMax      = 300
+	LinkPreviewDescMax       = 1000
+	LinkPreviewSiteNameMax   = 200
+)
+
+var linkRegex = regexp.MustCompile(`https?://[^\s<>"'\x60]+`)
+
+// LinkPreviewService builds previews for the first link of a message and pushes them to the chat when ready.
+type LinkPreviewService struct {
+	DB          *sql.DB
+	PreviewRepo *repository.LinkPreviewRepository
+	MsgRepo     *repository.MessageRepository
+	OutboxRepo  *repository.OutboxRepository
+	Fetcher     LinkFetcher
+}
+
+func NewLinkPreviewService(
+	db *sql.DB,
+	previewRepo *repository.LinkPreviewRepository,
+	msgRepo *repository.MessageRepository,
+	outboxRepo *repository.OutboxRepository,
+	fetcher LinkFetcher,
+) *LinkPreviewService {
+	return &LinkPreviewService{
+		DB:          db,
+		PreviewRepo: previewRepo,
+		MsgRepo:     msgRepo,
+		OutboxRepo:  outboxRepo,
+		Fetcher:     fetcher,
+	}
+}
+
+// Process updates the link preview of a delivered message. It is meant to run in its own goroutine.
+// If the message is edited or deleted meanwhile, the preview is left to the run for the edit.
+func (s *LinkPreviewService) Process(message *models.Message) {
+	// The content of an encrypted message is ciphertext, so there is nothing to preview
+	if message.Type == models.EncryptedMessage {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), LinkFetchTimeout+LinkDialTimeout)
+	defer cancel()
+
+	link := ""
+	if message.Content != nil {
+		link = ExtractLink(*message.Content)
+	}
+
+	if link == "" {
+		s.deletePreview(message)
+		return
+	}
+
+	if message.LinkPreview != nil && message.LinkPreview.URL == link {
+		return
+	}
+
+	preview, err := s.getPreview(ctx, link)
+	if err != nil {
+		log.Printf("Error building link preview for %s: %s", link, err)
+	}
+
+	if preview == nil {
+		s.deletePreview(message)
+		return
+	}
+
+	preview.MessageID = message.ID
+	err = repository.RunInTx(s.DB, func(tx *sql.Tx) error {
+		current, err := s.isCurrent(tx, message)
+		if err != nil || !current {
+			return err
+		}
+		if err := s.PreviewRepo.WithTx(tx).Save(preview); err != nil {
+			return err
+		}
+		return s.announceUpdate(tx, message.ID)
+	})
+	if err != nil {
+		log.Printf("Error saving link preview of message %d: %s", message.ID, err)
+	}
+}
+
+// deletePreview removes the preview of a message and announces the change if there was one.
+func (s *LinkPreviewService) deletePreview(message *models.Message) {
+	err := repository.RunInTx(s.DB, func(tx *sql.Tx) error {
+		current, err := s.isCurrent(tx, message)
+		if err != nil || !current {
+			return err
+		}
+		deleted, err := s.PreviewRepo.WithTx(tx).Delete(message.ID)
+		if err != nil || !deleted {
+			return err
+		}
+		return s.announceUpdate(tx, message.ID)
+	})
+	if err != nil {
+		log.Printf("Error deleting link preview of message %d: %s", message.ID, err)
+	}
+}
+
+// isCurrent locks the message until the end of the transaction and reports whether it is still
+// the version the preview was built for, so an edit can't land between the check and the change.
+func (s *LinkPreviewService) isCurrent(tx *sql.Tx, message *models.Message) (bool, error) {
+	updatedAt, err := s.MsgRepo.WithTx(tx).LockUpdatedAt(message.ID)
+	if err != nil || updatedAt == nil {
+		return false, err
+	}
+	return updatedAt.Equal(message.UpdatedAt), nil
+}
+
+// getPreview returns the preview for a link from the cache or fetches it. It returns nil if the page has no usable metadata.
+func (s *LinkPreviewService) getPreview(ctx context.Context, link string) (*models.LinkPreview, error) {
+	cached, found, err := s.PreviewRepo.GetCached(ctx, link)
+	if err != nil {
+		log.Printf("Error reading link preview cache: %s", err)
+	}
+	if found {
+		return cached, nil
+	}
+
+	page, err := s.Fetcher.Fetch(ctx, link)
+	if err != nil {
+		if cacheErr := s.PreviewRepo.CachePreview(ctx, link, nil, LinkPreviewEmptyCacheTTL); cacheErr != nil {
+			log.Printf("Error caching link preview: %s", cacheErr)
+		}
+		return nil, err
+	}
+
+	preview := ParseLinkPreview(page)
+	ttl := LinkPreviewCacheTTL
+	if preview == nil {
+		ttl = LinkPreviewEmptyCacheTTL
+	} else {
+		preview.URL = link
+	}
+
+	if err := s.PreviewRepo.CachePreview(ctx, link, preview, ttl); err != nil {
+		log.Printf("Error caching link preview: %s", err)
+	}
+
+	return preview, nil
+}
+
+// announceUpdate puts the refreshed message in the outbox of both chat participants as an editMessage event.
+// The recipient doesn't get it if the message is shadowed.
+func (s *LinkPreviewService) announceUpdate(tx *sql.Tx, messageID uint) error {
+	message, err := s.MsgRepo.WithTx(tx).GetById(messageID)
+	if err != nil || message == nil {
+		return err
+	}
+
+	outboxRepo := s.OutboxRepo.WithTx(tx)
+	if !message.Shadowed {
+		if err := outboxRepo.Create(message.RecipientID, string(websocket.EditMessageEvent), message); err != nil {
+			return err
+		}
+	}
+	return outboxRepo.Create(message.SenderID, string(websocket.EditMessageEvent), message)
+}
+
+// ExtractLink returns the first http(s) link in the content, without trailing punctuation.
+func ExtractLink(content string) string {
+	link := linkRegex.FindString(content)
+	link = strings.TrimRight(link, ".,;:!?)]}")
+	if link == "" {
+		return ""
+	}
+
+	parsed, err := url.Parse(link)
+	if err != nil || parsed.Host == "" {
+		return ""
+	}
+
+	return parsed.String()
+}
+
+// ParseLinkPreview reads OpenGraph and Twitter card tags from the page, falling back to <title>
+// and the description meta tag. It returns nil if the page has no title and no description.
+func ParseLinkPreview(page *FetchedPage) *models.LinkPreview {
+	meta := make(map[string]string)
+	title := ""
+
+	tokenizer := html.NewTokenizer(bytes.NewReader(page.Body))
+parse:
+	for {
+		tokenType := tokenizer.Next()
+		switch tokenType {
+		case html.ErrorToken:
+			break parse
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			switch token.DataAtom {
+			case atom.Body:
+				break parse
+			case atom.Title:
+				if tokenizer.Next() == html.TextToken && title == "" {
+					title = strings.TrimSpace(html.UnescapeString(string(tokenizer.Text())))
+				}
+			case atom.Meta:
+				var key, content string
+				for _, attr := range token.Attr {
+					switch strings.ToLower(attr.Key) {
+					case "property", "name":
+						key = strings.ToLower(strings.TrimSpace(attr.Val))
+					case "content":
+						content = strings.TrimSpace(attr.Val)
+					}
+				}
+				if key != "" && content != "" {
+					if _, exists := meta[key]; !exists {
+						meta[key] = content
+					}
+				}
+			}
+		case html.EndTagToken:
+			if tokenizer.Token().DataAtom == atom.Head {
+				break parse
+			}
+		}
+	}
+
+	preview := &models.LinkPreview{
+		URL:         page.URL,
+		Title:       firstNonEmpty(LinkPreviewTitleMax, meta["og:title"], meta["twitter:title"], title),
+		Description: firstNonEmpty(LinkPreviewDescMax, meta["og:description"], meta["twitter:description"], meta["description"]),
+		SiteName:    firstNonEmpty(LinkPreviewSiteNameMax, meta["og:site_name"], meta["twitter:site"]),
+	}
+
+	if image := firstNonEmpty(0, meta["og:image"], meta["og:image:url"], meta["twitter:image"], meta["twitter:image:src"]); image != nil {
+		preview.ImageURL = resolveURL(page.URL, *image)
+	}
+
+	if preview.Title == nil && preview.Description == nil {
+		return nil
+	}
+
+	return preview
+}
+
+// firstNonEmpty returns the first non-empty value truncated to maxLen runes (0 means no limit).
+func firstNonEmpty(maxLen int, values ...string) *string {
+	for _, value := range values {
+		if value == "" {
+			continue
+		}
+		if maxLen > 0 && utf8.RuneCountInString(value) > maxLen {
+			value = string([]rune(value)[:maxLen])
+		}
+		return &value
+	}
+	return nil
+}
+
+// resolveURL resolves a possibly relative reference against the page URL and keeps only http(s) results.
+func resolveURL(base, ref string) *string {
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return nil
+	}
+
+	refURL, err := url.Parse(ref)
+	if err != nil {
+		return nil
+	}
+
+	resolved := baseURL.ResolveReference(refURL)
+	if resolved.Scheme != "http" && resolved.Scheme != "https" {
+		return nil
+	}
+
+	result := resolved.String()
+	return &result
+}
@@ -0,0 +1,256 @@
+package services
+
+import (
+	"context"
+	"database/sql/driver"
+	"github.com/drTragger/messenger-backend/internal/fakedb"
+	"github.com/drTragger/messenger-backend/internal/fakeredis"
+	"github.com/drTragger/messenger-backend/internal/models"
+	"github.com/drTragger/messenger-backend/internal/repository"
+	"github.com/go-redis/redis/v8"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestExtractLink(t *testing.T) {
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{content: "see https://example.com/page.", want: "https://example.com/page"},
+		{content: "(http://example.com/a?b=c)", want: "http://example.com/a?b=c"},
+		{content: "first https://one.example then https://two.example", want: "https://one.example"},
+		{content: "ftp://example.com is not a link", want: ""},
+		{content: "no links here", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := ExtractLink(tt.content); got != tt.want {
+			t.Errorf("ExtractLink(%q) = %q, want %q", tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestParseLinkPreview(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		title       string
+		description string
+		siteName    string
+		image       string
+	}{
+		{
+			name: "open graph",
+			body: `<html><head>
+				<meta property="og:title" content="OG title">
+				<meta property="og:description" content="OG description">
+				<meta property="og:site_name" content="Example">
+				<meta property="og:image" content="/images/cover.png">
+				<meta name="twitter:title" content="Twitter title">
+				<title>Page title</title>
+			</head></html>`,
+			title:       "OG title",
+			description: "OG description",
+			siteName:    "Example",
+			image:       "https://example.com/images/cover.png",
+		},
+		{
+			name: "twitter card",
+			body: `<head>
+				<meta name="twitter:title" content="Twitter title">
+				<meta name="twitter:description" content="Twitter description">
+				<meta name="twitter:site" content="@example">
+				<meta name="twitter:image" content="https://cdn.example.com/card.jpg">
+			</head>`,
+			title:       "Twitter title",
+			description: "Twitter description",
+			siteName:    "@example",
+			image:       "https://cdn.example.com/card.jpg",
+		},
+		{
+			name:        "title and description fallback",
+			body:        `<head><title> Plain &amp; simple </title><meta name="description" content="About the page"></head>`,
+			title:       "Plain & simple",
+			description: "About the page",
+		},
+		{
+			name:  "image with an unsupported scheme",
+			body:  `<head><title>Title</title><meta property="og:image" content="javascript:alert(1)"></head>`,
+			title: "Title",
+		},
+		{
+			name:  "tags in the body are ignored",
+			body:  `<head><title>Head</title></head><body><meta property="og:title" content="Body"></body>`,
+			title: "Head",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			preview := ParseLinkPreview(&FetchedPage{URL: "https://example.com/articles/1", Body: []byte(tt.body)})
+			if preview == nil {
+				t.Fatal("ParseLinkPreview() = nil")
+			}
+			assertOptional(t, "Title", preview.Title, tt.title)
+			assertOptional(t, "Description", preview.Description, tt.description)
+			assertOptional(t, "SiteName", preview.SiteName, tt.siteName)
+			assertOptional(t, "ImageURL", preview.ImageURL, tt.image)
+		})
+	}
+}
+
+func TestParseLinkPreviewWithoutMetadata(t *testing.T) {
+	page := &FetchedPage{URL: "https://example.com", Body: []byte(`<head><meta property="og:image" content="/a.png"></head>`)}
+	if preview := ParseLinkPreview(page); preview != nil {
+		t.Errorf("ParseLinkPreview() = %+v, want nil", preview)
+	}
+}
+
+func TestParseLinkPreviewTruncatesFields(t *testing.T) {
+	title := strings.Repeat("я", LinkPreviewTitleMax+50)
+	page := &FetchedPage{URL: "https://example.com", Body: []byte(`<head><meta property="og:title" content="` + title + `"></head>`)}
+
+	preview := ParseLinkPreview(page)
+	if preview == nil || preview.Title == nil {
+		t.Fatal("ParseLinkPreview() has no title")
+	}
+	if n := utf8.RuneCountInString(*preview.Title); n != LinkPreviewTitleMax {
+		t.Errorf("title has %d runes, want %d", n, LinkPreviewTitleMax)
+	}
+}
+
+func TestGetPreviewUsesCache(t *testing.T) {
+	var fetches int32
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<head><meta property="og:title" content="Cached"></head>`))
+	}))
+	defer page.Close()
+
+	cache := redis.NewClient(&redis.Options{Addr: fakeredis.Start(t)})
+	defer cache.Close()
+
+	service := &LinkPreviewService{
+		PreviewRepo: &repository.LinkPreviewRepository{Cache: cache},
+		Fetcher:     newTestFetcher(),
+	}
+
+	for i := 0; i < 2; i++ {
+		preview, err := service.getPreview(context.Background(), page.URL)
+		if err != nil {
+			t.Fatalf("getPreview() error = %v", err)
+		}
+		if preview == nil || preview.Title == nil || *preview.Title != "Cached" {
+			t.Fatalf("getPreview() = %+v, want the page title", preview)
+		}
+		if preview.URL != page.URL {
+			t.Errorf("URL = %q, want %q", preview.URL, page.URL)
+		}
+	}
+
+	if fetches := atomic.LoadInt32(&fetches); fetches != 1 {
+		t.Errorf("the page was fetched %d times, want 1", fetches)
+	}
+}
+
+func TestGetPreviewCachesBrokenLinks(t *testing.T) {
+	var fetches int32
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer page.Close()
+
+	cache := redis.NewClient(&redis.Options{Addr: fakeredis.Start(t)})
+	defer cache.Close()
+
+	service := &LinkPreviewService{
+		PreviewRepo: &repository.LinkPreviewRepository{Cache: cache},
+		Fetcher:     newTestFetcher(),
+	}
+
+	if _, err := service.getPreview(context.Background(), page.URL); err == nil {
+		t.Error("first getPreview() of a broken link succeeded")
+	}
+	preview, err := service.getPreview(context.Background(), page.URL)
+	if err != nil || preview != nil {
+		t.Errorf("second getPreview() = %+v, %v, want the cached empty preview", preview, err)
+	}
+
+	if fetches := atomic.LoadInt32(&fetches); fetches != 1 {
+		t.Errorf("the page was fetched %d times, want 1", fetches)
+	}
+}
+
+func TestProcessSkipsChangedMessage(t *testing.T) {
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<head><meta property="og:title" content="Page"></head>`))
+	}))
+	defer page.Close()
+
+	sentAt := time.Now().Truncate(time.Microsecond)
+	tests := []struct {
+		name      string
+		updatedAt fakedb.Result
+		saved     bool
+	}{
+		{name: "unchanged message", updatedAt: fakedb.Row(sentAt), saved: true},
+		{name: "edited message", updatedAt: fakedb.Row(sentAt.Add(time.Second))},
+		{name: "deleted message", updatedAt: fakedb.Result{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := fakedb.Open(t, func(query string, args []driver.Value) fakedb.Result {
+				if strings.Contains(query, "FOR UPDATE") {
+					return tt.updatedAt
+				}
+				// The announced message isn't needed, so GetById finds nothing
+				if strings.Contains(query, "FROM messages") {
+					return fakedb.Result{}
+				}
+				return fakedb.Result{Affected: 1}
+			})
+			cache := redis.NewClient(&redis.Options{Addr: fakeredis.Start(t)})
+			defer cache.Close()
+
+			service := NewLinkPreviewService(
+				db,
+				repository.NewLinkPreviewRepository(db, cache),
+				repository.NewMessageRepository(db),
+				repository.NewOutboxRepository(db),
+				newTestFetcher(),
+			)
+
+			content := "see " + page.URL
+			service.Process(&models.Message{ID: 40, Content: &content, Type: models.TextMessage, UpdatedAt: sentAt})
+
+			if saved := len(fake.Executed("INSERT INTO link_previews")) == 1; saved != tt.saved {
+				t.Errorf("preview saved = %t, want %t", saved, tt.saved)
+			}
+			if locks := fake.Executed("FOR UPDATE"); len(locks) != 1 || locks[0].Args[0] != int64(40) {
+				t.Errorf("locks = %v, want message 40 locked before the save", locks)
+			}
+		})
+	}
+}
+
+func assertOptional(t *testing.T, field string, got *string, want string) {
+	t.Helper()
+	switch {
+	case want == "" && got != nil:
+		t.Errorf("%s = %q, want nil", field, *got)
+	case want != "" && got == nil:
+		t.Errorf("%s = nil, want %q", field, want)
+	case want != "" && *got != want:
+		t.Errorf("%s = %q, want %q", field, *got, want)
+	}
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+const (
+	LocationExpiryInterval = 5 * time.Second
+	LocationExpiryBatch    = 100
+)
+
+// LocationExpirer ends live locations once their sharing period is over and pushes the final location to the chat.
+type LocationExpirer struct {
+	LocationService *LocationService
+	Interval        time.Duration
+}
+
+func NewLocationExpirer(locationService *LocationService) *LocationExpirer {
+	return &LocationExpirer{
+		LocationService: locationService,
+		Interval:        LocationExpiryInterval,
+	}
+}
+
+// Run ends expired live locations every Interval until the context is cancelled.
+func (e *LocationExpirer) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			e.endExpired()
+		}
+	}
+}
+
+func (e *LocationExpirer) endExpired() {
+	for {
+		// The final locations are put in the outbox in the transaction that ends them
+		ended, err := e.LocationService.EndExpired(LocationExpiryBatch)
+		if err != nil {
+			log.Printf("Error ending expired live locations: %s", err)
+			return
+		}
+
+		if ended < LocationExpiryBatch {
+			return
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"database/sql"
+	"github.com/drTragger/messenger-backend/internal/models"
+	"github.com/drTragger/messenger-backend/internal/repository"
+	"github.com/drTragger/messenger-backend/internal/websocket"
+)
+
+// LocationService moves, stops and ends live locations. Every change puts a LocationUpdateEvent
+// for the recipient in the outbox, in the transaction of the change, unless the message is shadowed.
+type LocationService struct {
+	DB           *sql.DB
+	LocationRepo *repository.LocationRepository
+	MsgRepo      *repository.MessageRepository
+	OutboxRepo   *repository.OutboxRepository
+}
+
+func NewLocationService(
+	db *sql.DB,
+	locationRepo *repository.LocationRepository,
+	msgRepo *repository.MessageRepository,
+	outboxRepo *repository.OutboxRepository,
+) *LocationService {
+	return &LocationService{
+		DB:           db,
+		LocationRepo: locationRepo,
+		MsgRepo:      msgRepo,
+		OutboxRepo:   outboxRepo,
+	}
+}
+
+// Update moves the live location of the message. It returns sql.ErrNoRows if the location is not live anymore.
+func (s *LocationService) Update(message *models.Message, location *models.Location) (*models.Location, error) {
+	location.MessageID = message.ID
+	return s.change(message, func(locationRepo *repository.LocationRepository) (*models.Location, error) {
+		return locationRepo.Update(location)
+	})
+}
+
+// Stop ends the live location of the message. It returns sql.ErrNoRows if the location is not live anymore.
+func (s *LocationService) Stop(message *models.Message) (*models.Location, error) {
+	return s.change(message, func(locationRepo *repository.LocationRepository) (*models.Location, error) {
+		return locationRepo.Stop(message.ID)
+	})
+}
+
+// EndExpired ends up to limit live locations whose sharing period is over in one transaction
+// and returns how many it ended. Nobody stopped them, so the sender is told as well as the recipient.
+func (s *LocationService) EndExpired(limit int) (int, error) {
+	ended := 0
+	err := repository.RunInTx(s.DB, func(tx *sql.Tx) error {
+		locations, err := s.LocationRepo.WithTx(tx).EndExpired(limit)
+		if err != nil {
+			return err
+		}
+		ended = len(locations)
+
+		msgRepo := s.MsgRepo.WithTx(tx)
+		outboxRepo := s.OutboxRepo.WithTx(tx)
+		for _, location := range locations {
+			message, err := msgRepo.GetById(location.MessageID)
+			if err != nil {
+				return err
+			}
+			// The message was deleted together with its location in the meantime
+			if message == nil {
+				continue
+			}
+
+			if err := outboxRepo.Create(message.SenderID, string(websocket.LocationUpdateEvent), location); err != nil {
+				return err
+			}
+			if message.RecipientID == message.SenderID || message.Shadowed {
+				continue
+			}
+			if err := outboxRepo.Create(message.RecipientID, string(websocket.LocationUpdateEvent), location); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return ended, err
+}
+
+func (s *LocationService) change(message *models.Message, apply func(locationRepo *repository.LocationRepository) (*models.Location, error)) (*models.Location, error) {
+	var location *models.Location
+	err := repository.RunInTx(s.DB, func(tx *sql.Tx) error {
+		var err error
+		location, err = apply(s.LocationRepo.WithTx(tx))
+		if err != nil || message.Shadowed {
+			return err
+		}
+		return s.OutboxRepo.WithTx(tx).Create(message.RecipientID, string(websocket.LocationUpdateEvent), location)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return location, nil
+}
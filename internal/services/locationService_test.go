@@ -0,0 +1,83 @@
+package services
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"github.com/drTragger/messenger-backend/internal/fakedb"
+	"github.com/drTragger/messenger-backend/internal/models"
+	"github.com/drTragger/messenger-backend/internal/repository"
+	"github.com/drTragger/messenger-backend/internal/websocket"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLocationServiceEndExpired(t *testing.T) {
+	tests := []struct {
+		name     string
+		shadowed bool
+		deleted  bool
+		events   []string
+	}{
+		{name: "live location", events: []string{"1 false", "2 false"}},
+		{name: "shadowed message", shadowed: true, events: []string{"1 false"}},
+		{name: "deleted message", deleted: true, events: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := fakedb.Open(t, func(query string, args []driver.Value) fakedb.Result {
+				switch {
+				case strings.Contains(query, "SET ended_at"):
+					liveUntil := time.Now().Add(-time.Minute)
+					return fakedb.Row(int64(3), int64(40), 50.45, 30.52, nil, nil, int64(900), liveUntil, liveUntil)
+				case strings.Contains(query, "FROM messages") && strings.Contains(query, "WHERE id = $1"):
+					if tt.deleted {
+						return fakedb.Result{}
+					}
+					now := time.Now()
+					return fakedb.Row(
+						int64(40), int64(1), int64(2), nil, string(models.LocationMessage), nil, nil, int64(7), nil, now, now,
+						false, nil, nil, nil, nil,
+						nil, nil, nil, false, false, tt.shadowed,
+					)
+				}
+				return fakedb.Result{Affected: 1}
+			})
+			service := NewLocationService(db, repository.NewLocationRepository(db), repository.NewMessageRepository(db), repository.NewOutboxRepository(db))
+
+			ended, err := service.EndExpired(LocationExpiryBatch)
+			if err != nil {
+				t.Fatalf("EndExpired() error = %v", err)
+			}
+			if ended != 1 {
+				t.Errorf("EndExpired() = %d, want 1", ended)
+			}
+
+			claims := fake.Executed("SET ended_at")
+			if len(claims) != 1 || claims[0].Args[0] != int64(LocationExpiryBatch) {
+				t.Errorf("claims = %v, want a batch of %d", claims, LocationExpiryBatch)
+			}
+
+			// Every event carries the final location, which isn't live anymore
+			events := make([]string, 0)
+			for _, event := range fake.Executed("INSERT INTO outbox_events") {
+				if event.Args[1] != string(websocket.LocationUpdateEvent) {
+					t.Errorf("event = %v, want %s", event.Args[1], websocket.LocationUpdateEvent)
+				}
+				var location models.Location
+				if err := json.Unmarshal(event.Args[2].([]byte), &location); err != nil {
+					t.Fatal(err)
+				}
+				events = append(events, fmt.Sprintf("%v %t", event.Args[0], location.IsLive))
+			}
+			if fmt.Sprint(events) != fmt.Sprint(tt.events) {
+				t.Errorf("outbox events = %v, want %v", events, tt.events)
+			}
+			if fake.Commits != 1 {
+				t.Errorf("%d commits, want the locations and their events in one transaction", fake.Commits)
+			}
+		})
+	}
+}
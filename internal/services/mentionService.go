@@ -0,0 +1,139 @@
+package services
+
+import (
+	"github.com/drTragger/messenger-backend/internal/models"
+	"github.com/drTragger/messenger-backend/internal/repository"
+	"github.com/drTragger/messenger-backend/internal/utils"
+	"regexp"
+	"unicode"
+	"unicode/utf8"
+)
+
+var mentionRegex = regexp.MustCompile(`@([\p{L}\p{N}_]{3,50})`)
+
+type MentionService struct {
+	UserRepo *repository.UserRepository
+}
+
+func NewMentionService(userRepo *repository.UserRepository) *MentionService {
+	return &MentionService{
+		UserRepo: userRepo,
+	}
+}
+
+// Resolve finds @username references in the content and returns a mention entity
+// for every reference that belongs to a registered user. References inside code, pre
+// and text link entities or inside links of the text are not mentions.
+func (s *MentionService) Resolve(content *string, formatting []*models.MessageEntity) ([]*models.MessageEntity, error) {
+	if content == nil {
+		return nil, nil
+	}
+
+	users := make(map[string]*models.User)
+	entities := make([]*models.MessageEntity, 0)
+	excluded := excludedMentionRanges(*content, formatting)
+
+	for _, match := range mentionRegex.FindAllStringSubmatchIndex(*content, -1) {
+		start, end := match[0], match[1]
+
+		// Skip e-mail addresses and similar words glued to the @ sign
+		if start > 0 {
+			prev, _ := utf8.DecodeLastRuneInString((*content)[:start])
+			if isUsernameRune(prev) || prev == '@' {
+				continue
+			}
+		}
+		// Skip usernames that go on past the longest possible one
+		if next, _ := utf8.DecodeRuneInString((*content)[end:]); isUsernameRune(next) {
+			continue
+		}
+
+		offset := utils.UTF16Length((*content)[:start])
+		length := utils.UTF16Length((*content)[start:end])
+		if overlapsRange(excluded, offset, length) {
+			continue
+		}
+
+		username := (*content)[match[2]:match[3]]
+		user, ok := users[username]
+		if !ok {
+			var err error
+			user, err = s.UserRepo.GetUserByUsername(username)
+			if err != nil {
+				return nil, err
+			}
+			users[username] = user
+		}
+		if user == nil {
+			continue
+		}
+
+		userID := user.ID
+		entities = append(entities, &models.MessageEntity{
+			Type:   models.MentionEntity,
+			Offset: offset,
+			Length: length,
+			UserID: &userID,
+		})
+	}
+
+	return entities, nil
+}
+
+// excludedMentionRanges returns the UTF-16 ranges of the content that can't hold mentions:
+// code, pre and text link entities, and the links of the text.
+func excludedMentionRanges(content string, formatting []*models.MessageEntity) [][2]int {
+	ranges := make([][2]int, 0)
+	for _, entity := range formatting {
+		switch entity.Type {
+		case models.CodeEntity, models.PreEntity, models.TextLinkEntity:
+			ranges = append(ranges, [2]int{entity.Offset, entity.Offset + entity.Length})
+		}
+	}
+	for _, link := range linkRegex.FindAllStringIndex(content, -1) {
+		start := utils.UTF16Length(content[:link[0]])
+		ranges = append(ranges, [2]int{start, start + utils.UTF16Length(content[link[0]:link[1]])})
+	}
+	return ranges
+}
+
+func overlapsRange(ranges [][2]int, offset, length int) bool {
+	for _, r := range ranges {
+		if offset < r[1] && r[0] < offset+length {
+			return true
+		}
+	}
+	return false
+}
+
+func isUsernameRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// MentionedRecipients returns the chat participants mentioned in the message, except the sender
+// and users that were already mentioned in the previous entities.
+func MentionedRecipients(message *models.Message, previous []*models.MessageEntity) []uint {
+	notified := make(map[uint]bool)
+	for _, entity := range previous {
+		if entity.Type == models.MentionEntity && entity.UserID != nil {
+			notified[*entity.UserID] = true
+		}
+	}
+
+	recipients := make([]uint, 0)
+	for _, entity := range message.Entities {
+		if entity.Type != models.MentionEntity || entity.UserID == nil {
+			continue
+		}
+
+		userID := *entity.UserID
+		if notified[userID] || userID == message.SenderID || userID != message.RecipientID {
+			continue
+		}
+
+		notified[userID] = true
+		recipients = append(recipients, userID)
+	}
+
+	return recipients
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"github.com/drTragger/messenger-backend/internal/fakedb"
+	"github.com/drTragger/messenger-backend/internal/models"
+	"github.com/drTragger/messenger-backend/internal/repository"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMentionServiceResolve(t *testing.T) {
+	link := "https://example.com"
+	longName := strings.Repeat("a", 51)
+
+	tests := []struct {
+		name       string
+		content    string
+		formatting []*models.MessageEntity
+		mentions   []string
+	}{
+		{name: "mention", content: "Hi @alice!", mentions: []string{"3 6 1"}},
+		{name: "several mentions", content: "@alice and @bob_1", mentions: []string{"0 6 1", "11 6 2"}},
+		{name: "offset after an emoji", content: "😀 @alice", mentions: []string{"3 6 1"}},
+		{name: "unknown user", content: "Hi @carol", mentions: []string{}},
+		{name: "e-mail address", content: "mail alice@alice.com", mentions: []string{}},
+		{name: "too short", content: "@al", mentions: []string{}},
+		{name: "longer than a username", content: "@" + longName, mentions: []string{}},
+		{name: "link", content: "see https://medium.com/@alice", mentions: []string{}},
+		{name: "code", content: "run @alice", formatting: []*models.MessageEntity{{Type: models.CodeEntity, Offset: 4, Length: 6}}, mentions: []string{}},
+		{name: "pre", content: "@alice\n@bob_1", formatting: []*models.MessageEntity{{Type: models.PreEntity, Offset: 7, Length: 6}}, mentions: []string{"0 6 1"}},
+		{name: "text link", content: "@alice", formatting: []*models.MessageEntity{{Type: models.TextLinkEntity, Offset: 0, Length: 6, URL: &link}}, mentions: []string{}},
+		{name: "bold", content: "@alice", formatting: []*models.MessageEntity{{Type: models.BoldEntity, Offset: 0, Length: 6}}, mentions: []string{"0 6 1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, _ := fakedb.Open(t, func(query string, args []driver.Value) fakedb.Result {
+				// The 51 character name would be found if its first 50 characters were taken for a username
+				users := map[string]int64{"alice": 1, "bob_1": 2, longName[:50]: 3}
+				if id, ok := users[args[0].(string)]; ok {
+					now := time.Now()
+					return fakedb.Row(id, args[0], "First", nil, "+380000000001", nil, nil, false, now, now, nil)
+				}
+				return fakedb.Result{}
+			})
+			service := NewMentionService(repository.NewUserRepository(db))
+
+			entities, err := service.Resolve(&tt.content, tt.formatting)
+			if err != nil {
+				t.Fatalf("Resolve() error = %v", err)
+			}
+
+			mentions := make([]string, 0, len(entities))
+			for _, entity := range entities {
+				if entity.Type != models.MentionEntity || entity.UserID == nil {
+					t.Fatalf("entity = %+v, want a mention of a user", entity)
+				}
+				mentions = append(mentions, fmt.Sprintf("%d %d %d", entity.Offset, entity.Length, *entity.UserID))
+			}
+			if fmt.Sprint(mentions) != fmt.Sprint(tt.mentions) {
+				t.Errorf("mentions = %v, want %v", mentions, tt.mentions)
+			}
+		})
+	}
+}
+
+func TestMentionServiceResolveLooksUpEveryUsernameOnce(t *testing.T) {
+	db, fake := fakedb.Open(t, func(query string, args []driver.Value) fakedb.Result {
+		now := time.Now()
+		return fakedb.Row(int64(1), "alice", "Alice", nil, "+380000000001", nil, nil, false, now, now, nil)
+	})
+	service := NewMentionService(repository.NewUserRepository(db))
+
+	content := "@alice @alice @alice"
+	entities, err := service.Resolve(&content, nil)
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if len(entities) != 3 {
+		t.Errorf("%d mentions, want 3", len(entities))
+	}
+	if lookups := fake.Executed("FROM users"); len(lookups) != 1 {
+		t.Errorf("%d user lookups, want 1", len(lookups))
+	}
+}
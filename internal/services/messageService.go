@@ -1,36 +1,609 @@
 package services
 
 import (
+	"bytes"
+	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/drTragger/messenger-backend/internal/models"
 	"github.com/drTragger/messenger-backend/internal/repository"
 	"github.com/drTragger/messenger-backend/internal/storage"
+	"github.com/drTragger/messenger-backend/internal/websocket"
+	"io"
 	"log"
 	"mime/multipart"
-	"net/http"
 	"runtime"
 	"sync"
 )
 
+// ErrForwardedMessageDeleted is returned when a message is deleted while it is being forwarded.
+var ErrForwardedMessageDeleted = errors.New("forwarded message was deleted")
+
 type MessageService struct {
+	DB             *sql.DB
+	MsgRepo        *repository.MessageRepository
+	ChatRepo       *repository.ChatRepository
+	EntityRepo     *repository.MessageEntityRepository
 	AttachmentRepo *repository.AttachmentRepository
+	OutboxRepo     *repository.OutboxRepository
+	ModerationRepo *repository.ModerationRepository
+	Moderation     *ModerationService
 	Storage        storage.Storage
 }
 
 func NewMessageService(
+	db *sql.DB,
+	msgRepo *repository.MessageRepository,
+	chatRepo *repository.ChatRepository,
+	entityRepo *repository.MessageEntityRepository,
 	attachmentRepo *repository.AttachmentRepository,
+	outboxRepo *repository.OutboxRepository,
+	moderationRepo *repository.ModerationRepository,
+	moderation *ModerationService,
 	storage storage.Storage,
 ) *MessageService {
 	return &MessageService{
+		DB:             db,
+		MsgRepo:        msgRepo,
+		ChatRepo:       chatRepo,
+		EntityRepo:     entityRepo,
 		AttachmentRepo: attachmentRepo,
+		OutboxRepo:     outboxRepo,
+		ModerationRepo: moderationRepo,
+		Moderation:     moderation,
 		Storage:        storage,
 	}
 }
 
-func (s *MessageService) ProcessAttachments(r *http.Request, message *models.Message) error {
-	files := r.MultipartForm.File["attachments"]
-	if len(files) == 0 {
+// Send runs the moderation filters, stores the files, then creates the message with its attachments and
+// entities in one transaction. A chat without an ID is created in the same transaction. Unless the message
+// is scheduled, it becomes the last message of its chat and is announced to the recipient through the outbox.
+// A rejected message returns a *ModerationError, a flagged one is put in the review queue.
+// If anything fails, nothing is written and the stored files are deleted.
+func (s *MessageService) Send(chat *models.Chat, message *models.Message, files []*multipart.FileHeader, entities []*models.MessageEntity, locale string) (*models.Message, error) {
+	flags, err := s.moderate(message, files, entities, locale)
+	if err != nil {
+		return nil, err
+	}
+
+	attachments, err := s.SaveAttachments(files)
+	if err != nil {
+		return nil, err
+	}
+
+	err = repository.RunInTx(s.DB, func(tx *sql.Tx) error {
+		if chat.ID == 0 {
+			created, err := s.ChatRepo.WithTx(tx).Create(chat.User1ID, chat.User2ID, nil)
+			if err != nil {
+				return err
+			}
+			*chat = *created
+		}
+		message.ChatID = chat.ID
+
+		if _, err := s.MsgRepo.WithTx(tx).Create(message); err != nil {
+			return err
+		}
+
+		attachmentRepo := s.AttachmentRepo.WithTx(tx)
+		for _, attachment := range attachments {
+			attachment.MessageID = message.ID
+			if _, err := attachmentRepo.Create(attachment); err != nil {
+				return fmt.Errorf("failed to save attachment record: %w", err)
+			}
+		}
+
+		if err := s.EntityRepo.WithTx(tx).Create(message.ID, entities); err != nil {
+			return err
+		}
+
+		if err := s.storeFlags(tx, message, flags); err != nil {
+			return err
+		}
+		message.Attachments = attachments
+		message.Entities = entities
+
+		// Scheduled messages are announced by the ScheduledMessageDispatcher once they are due,
+		// and messages of a shadow-banned sender are never announced
+		if message.ScheduledAt != nil || message.Shadowed {
+			return nil
+		}
+		if err := s.ChatRepo.WithTx(tx).UpdateLastMessage(chat.ID, message.ID); err != nil {
+			return err
+		}
+		return s.announce(tx, message)
+	})
+	if err != nil {
+		s.deleteFiles(attachments)
+		return nil, err
+	}
+
+	return message, nil
+}
+
+// SendTyped creates a message whose content is kept in a table of its own, such as a poll or a location,
+// in one transaction. store saves that content once the message has its ID; it may be nil if the message
+// row holds everything, and should set the content on the message so that it is announced with it.
+// text is what the moderation filters check, such as the question and options of a poll, and is empty
+// for content they can't read. The message then becomes the last message of its chat and is announced
+// to the recipient through the outbox.
+func (s *MessageService) SendTyped(message *models.Message, text, locale string, store func(tx *sql.Tx) error) error {
+	flags, err := s.moderateText(message.SenderID, text, nil, locale)
+	if err != nil {
+		return err
+	}
+
+	return repository.RunInTx(s.DB, func(tx *sql.Tx) error {
+		if _, err := s.MsgRepo.WithTx(tx).Create(message); err != nil {
+			return err
+		}
+
+		if store != nil {
+			if err := store(tx); err != nil {
+				return err
+			}
+		}
+
+		if err := s.storeFlags(tx, message, flags); err != nil {
+			return err
+		}
+
+		if err := s.ChatRepo.WithTx(tx).UpdateLastMessage(message.ChatID, message.ID); err != nil {
+			return err
+		}
+		return s.announce(tx, message)
+	})
+}
+
+// Forward creates the copies of the forwarded messages with the attachments and entities of their originals
+// in one transaction. The copies are given in the same order as their originals, and the last one
+// becomes the last message of the chat. A NewMessageEvent is put in the outbox for every copy.
+// The moderation filters check every copy as a message of the forwarding user, and a rejected copy
+// returns a *ModerationError without forwarding anything. It returns ErrForwardedMessageDeleted if an
+// original with attachments is deleted before its files are copied.
+func (s *MessageService) Forward(chatID uint, copies []*models.Message, originals []*models.Message, locale string) error {
+	flags := make([][]*ModerationVerdict, len(copies))
+	for i, message := range copies {
+		var err error
+		flags[i], err = s.moderate(message, nil, originals[i].Entities, locale)
+		if err != nil {
+			return err
+		}
+	}
+
+	return repository.RunInTx(s.DB, func(tx *sql.Tx) error {
+		msgRepo := s.MsgRepo.WithTx(tx)
+		attachmentRepo := s.AttachmentRepo.WithTx(tx)
+		entityRepo := s.EntityRepo.WithTx(tx)
+		outboxRepo := s.OutboxRepo.WithTx(tx)
+
+		// Once the files are locked, no deletion can remove them before the copies refer to them too
+		filePaths := make([]string, 0)
+		for _, original := range originals {
+			filePaths = append(filePaths, attachmentPaths(original.Attachments)...)
+		}
+		if err := attachmentRepo.LockFiles(filePaths); err != nil {
+			return err
+		}
+		unreferenced, err := attachmentRepo.UnreferencedFiles(filePaths)
+		if err != nil {
+			return err
+		}
+		if len(unreferenced) > 0 {
+			return ErrForwardedMessageDeleted
+		}
+
+		for i, message := range copies {
+			message.ChatID = chatID
+			if _, err := msgRepo.Create(message); err != nil {
+				return err
+			}
+
+			attachments, err := copyAttachments(attachmentRepo, originals[i].Attachments, message.ID)
+			if err != nil {
+				return err
+			}
+			message.Attachments = attachments
+
+			message.Entities = copyEntities(originals[i].Entities)
+			if err := entityRepo.Create(message.ID, message.Entities); err != nil {
+				return err
+			}
+
+			if err := s.storeFlags(tx, message, flags[i]); err != nil {
+				return err
+			}
+
+			// Mentions are not announced again, the mentioned users were notified about the original
+			if !message.Shadowed {
+				if err := outboxRepo.Create(message.RecipientID, string(websocket.NewMessageEvent), message); err != nil {
+					return err
+				}
+			}
+		}
+
+		return s.ChatRepo.WithTx(tx).UpdateLastMessage(chatID, copies[len(copies)-1].ID)
+	})
+}
+
+// Edit replaces the content and the entities of a delivered message in one transaction, and puts an
+// EditMessageEvent for the recipient and a MentionEvent for every newly mentioned user in the outbox.
+// previous is the message before the edit. The new content goes through the moderation filters like
+// a new message. It returns sql.ErrNoRows if the message doesn't exist.
+func (s *MessageService) Edit(previous *models.Message, content string, entities []*models.MessageEntity, locale string) (*models.Message, error) {
+	flags, err := s.moderateText(previous.SenderID, content, entities, locale)
+	if err != nil {
+		return nil, err
+	}
+
+	var message *models.Message
+	err = repository.RunInTx(s.DB, func(tx *sql.Tx) error {
+		var err error
+		message, err = s.MsgRepo.WithTx(tx).Edit(previous.ID, content)
+		if err != nil {
+			return err
+		}
+
+		message.Entities = entities
+		if err := s.EntityRepo.WithTx(tx).Replace(message.ID, entities); err != nil {
+			return err
+		}
+
+		if err := s.storeFlags(tx, message, flags); err != nil {
+			return err
+		}
+
+		message.Chat, err = s.ChatRepo.WithTx(tx).GetByID(message.ChatID)
+		if err != nil {
+			return err
+		}
+
+		if message.Shadowed {
+			return nil
+		}
+		outboxRepo := s.OutboxRepo.WithTx(tx)
+		if err := outboxRepo.Create(message.RecipientID, string(websocket.EditMessageEvent), message); err != nil {
+			return err
+		}
+		return s.announceMentions(outboxRepo, message, previous.Entities)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return message, nil
+}
+
+// EditScheduled replaces the content and the entities of a scheduled message in one transaction.
+// Nothing is announced, the recipient sees the message once it is due. The new content goes through
+// the moderation filters like a new message. It returns sql.ErrNoRows if the message is not scheduled anymore.
+func (s *MessageService) EditScheduled(scheduled *models.Message, content string, entities []*models.MessageEntity, locale string) (*models.Message, error) {
+	flags, err := s.moderateText(scheduled.SenderID, content, entities, locale)
+	if err != nil {
+		return nil, err
+	}
+
+	var message *models.Message
+	err = repository.RunInTx(s.DB, func(tx *sql.Tx) error {
+		var err error
+		message, err = s.MsgRepo.WithTx(tx).EditScheduled(scheduled.ID, content)
+		if err != nil {
+			return err
+		}
+
+		message.Entities = entities
+		if err := s.EntityRepo.WithTx(tx).Replace(message.ID, entities); err != nil {
+			return err
+		}
+		return s.storeFlags(tx, message, flags)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	message.Attachments = scheduled.Attachments
+	return message, nil
+}
+
+// MarkRead marks a message as read by its recipient and puts a ReadMessageEvent for the sender in the outbox,
+// in one transaction.
+func (s *MessageService) MarkRead(message *models.Message) error {
+	return repository.RunInTx(s.DB, func(tx *sql.Tx) error {
+		readAt, err := s.MsgRepo.WithTx(tx).MarkAsRead(message.ID)
+		if err != nil {
+			return err
+		}
+		message.ReadAt = readAt
+		// Reading a message also delivers it
+		if message.DeliveredAt == nil {
+			message.DeliveredAt = readAt
+		}
+		message.SetStatus()
+
+		return s.OutboxRepo.WithTx(tx).Create(message.SenderID, string(websocket.ReadMessageEvent), message)
+	})
+}
+
+// MarkListened marks the voice attachments of a message as played by its recipient and puts a
+// ListenedMessageEvent for the sender in the outbox, in one transaction. It returns false if the
+// message has no voice attachments.
+func (s *MessageService) MarkListened(message *models.Message) (bool, error) {
+	listened := false
+	err := repository.RunInTx(s.DB, func(tx *sql.Tx) error {
+		listenedAt, err := s.AttachmentRepo.WithTx(tx).MarkAsListened(message.ID)
+		if err != nil || listenedAt == nil {
+			return err
+		}
+		for _, attachment := range message.Attachments {
+			if attachment.Type == models.VoiceAttachment {
+				attachment.ListenedAt = listenedAt
+			}
+		}
+
+		listened = true
+		return s.OutboxRepo.WithTx(tx).Create(message.SenderID, string(websocket.ListenedMessageEvent), message)
+	})
+	return listened, err
+}
+
+// DispatchDue delivers up to limit due scheduled messages in one transaction. Each message becomes the
+// last message of its chat and is announced to its recipient through the outbox. It returns the
+// delivered messages.
+func (s *MessageService) DispatchDue(limit int) ([]*models.Message, error) {
+	var messages []*models.Message
+	err := repository.RunInTx(s.DB, func(tx *sql.Tx) error {
+		var err error
+		messages, err = s.MsgRepo.WithTx(tx).ClaimDueScheduled(limit)
+		if err != nil {
+			return err
+		}
+
+		chatRepo := s.ChatRepo.WithTx(tx)
+		for _, message := range messages {
+			if err := chatRepo.UpdateLastMessage(message.ChatID, message.ID); err != nil {
+				return err
+			}
+			if err := s.announce(tx, message); err != nil {
+				return err
+			}
+		}
 		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// Delete deletes a message, points its chat to the message before it and puts a DeleteMessageEvent
+// in the outbox for the recipient, in one transaction.
+// The files of the message are only deleted after the transaction is committed.
+func (s *MessageService) Delete(message *models.Message) error {
+	var files []string
+	err := repository.RunInTx(s.DB, func(tx *sql.Tx) error {
+		var err error
+		files, err = s.delete(tx, message)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	s.deleteUnreferencedFiles(files)
+	return nil
+}
+
+// delete deletes a message in the given transaction and returns the files no other message refers to.
+// The caller deletes them with deleteUnreferencedFiles once the transaction is committed.
+func (s *MessageService) delete(tx *sql.Tx, message *models.Message) ([]string, error) {
+	msgRepo := s.MsgRepo.WithTx(tx)
+	attachmentRepo := s.AttachmentRepo.WithTx(tx)
+
+	filePaths := attachmentPaths(message.Attachments)
+	if err := attachmentRepo.LockFiles(filePaths); err != nil {
+		return nil, err
+	}
+	if err := msgRepo.Delete(message.ID); err != nil {
+		return nil, err
+	}
+	files, err := attachmentRepo.UnreferencedFiles(filePaths)
+	if err != nil {
+		return nil, err
+	}
+
+	lastMessage, err := msgRepo.GetLastMessageForChat(message.ChatID)
+	if err != nil {
+		return nil, err
+	}
+
+	lastMessageID := uint(0)
+	if lastMessage != nil {
+		lastMessageID = lastMessage.ID
+	}
+	if err := s.ChatRepo.WithTx(tx).UpdateLastMessage(message.ChatID, lastMessageID); err != nil {
+		return nil, err
+	}
+
+	// The recipient never saw a shadowed message
+	if message.Shadowed {
+		return files, nil
+	}
+	event := map[string]*models.Message{"deleted": message, "last": lastMessage}
+	return files, s.OutboxRepo.WithTx(tx).Create(message.RecipientID, string(websocket.DeleteMessageEvent), event)
+}
+
+// DeleteScheduled deletes a message that hasn't been delivered yet and reports whether it was deleted.
+// Its files are deleted after the transaction is committed, like those of a delivered message.
+func (s *MessageService) DeleteScheduled(message *models.Message) (bool, error) {
+	deleted := false
+	var files []string
+	err := repository.RunInTx(s.DB, func(tx *sql.Tx) error {
+		attachmentRepo := s.AttachmentRepo.WithTx(tx)
+
+		filePaths := attachmentPaths(message.Attachments)
+		if err := attachmentRepo.LockFiles(filePaths); err != nil {
+			return err
+		}
+
+		var err error
+		deleted, err = s.MsgRepo.WithTx(tx).DeleteScheduled(message.ID)
+		if err != nil || !deleted {
+			return err
+		}
+
+		files, err = attachmentRepo.UnreferencedFiles(filePaths)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+
+	s.deleteUnreferencedFiles(files)
+	return deleted, nil
+}
+
+// deleteUnreferencedFiles deletes stored files of deleted attachments and logs the files it fails to delete.
+func (s *MessageService) deleteUnreferencedFiles(filePaths []string) {
+	var wg sync.WaitGroup
+	for _, filePath := range filePaths {
+		wg.Add(1)
+		go func(filePath string) {
+			defer wg.Done()
+			if err := s.Storage.DeleteFile(storage.MessageAttachmentsDir, filePath); err != nil {
+				log.Printf("Error deleting file %s: %s", filePath, err.Error())
+			}
+		}(filePath)
+	}
+	wg.Wait()
+}
+
+// announce puts the events about a new message in the outbox: a NewMessageEvent for the recipient, a
+// NewReplyEvent with the updated reply counter if it is a reply, and a MentionEvent for every mentioned user.
+// The events are stored in this order, so a client never hears about a reply or a mention before the message.
+// Messages of a shadow-banned sender are never announced.
+func (s *MessageService) announce(tx *sql.Tx, message *models.Message) error {
+	if message.Shadowed {
+		return nil
+	}
+
+	outboxRepo := s.OutboxRepo.WithTx(tx)
+	if err := outboxRepo.Create(message.RecipientID, string(websocket.NewMessageEvent), recipientView(message)); err != nil {
+		return err
+	}
+
+	if message.ParentID != nil {
+		replyCount, lastReplyAt, err := s.MsgRepo.WithTx(tx).GetReplyStats(*message.ParentID)
+		if err != nil {
+			return err
+		}
+		reply := websocket.NewNewReply(*message.ParentID, recipientView(message), replyCount, lastReplyAt)
+		if err := outboxRepo.Create(message.RecipientID, string(websocket.NewReplyEvent), reply); err != nil {
+			return err
+		}
+	}
+
+	return s.announceMentions(outboxRepo, message, nil)
+}
+
+// announceMentions puts a MentionEvent in the outbox for every user newly mentioned in the message.
+func (s *MessageService) announceMentions(outboxRepo *repository.OutboxRepository, message *models.Message, previous []*models.MessageEntity) error {
+	for _, userID := range MentionedRecipients(message, previous) {
+		if err := outboxRepo.Create(userID, string(websocket.MentionEvent), message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recipientView returns the message as its recipient may see it: the answer of a quiz stays hidden
+// until the poll is closed.
+func recipientView(message *models.Message) *models.Message {
+	if message.Poll == nil {
+		return message
+	}
+
+	view := *message
+	poll := *message.Poll
+	poll.HideAnswer()
+	view.Poll = &poll
+	return &view
+}
+
+// moderate checks the text of the message and each file before anything is stored and returns the raised flags.
+func (s *MessageService) moderate(message *models.Message, files []*multipart.FileHeader, entities []*models.MessageEntity, locale string) ([]*ModerationVerdict, error) {
+	text := ""
+	if message.Content != nil {
+		text = *message.Content
+	}
+	flags, err := s.moderateText(message.SenderID, text, entities, locale)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, fh := range files {
+		fileFlags, err := s.Moderation.Check(&ModerationSubject{
+			SenderID: message.SenderID,
+			Locale:   locale,
+			File:     fh,
+		})
+		var moderationErr *ModerationError
+		if errors.As(err, &moderationErr) {
+			moderationErr.Verdict.Details = &fh.Filename
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, verdict := range fileFlags {
+			verdict.Details = &fh.Filename
+		}
+		flags = append(flags, fileFlags...)
+	}
+	return flags, nil
+}
+
+// moderateText checks a text and the links of its text_link entities and returns the raised flags.
+func (s *MessageService) moderateText(senderID uint, text string, entities []*models.MessageEntity, locale string) ([]*ModerationVerdict, error) {
+	subject := &ModerationSubject{
+		SenderID: senderID,
+		Locale:   locale,
+		Text:     text,
+	}
+	for _, entity := range entities {
+		if entity.Type == models.TextLinkEntity && entity.URL != nil {
+			subject.URLs = append(subject.URLs, *entity.URL)
+		}
+	}
+
+	if subject.Text == "" && len(subject.URLs) == 0 {
+		return make([]*ModerationVerdict, 0), nil
+	}
+	return s.Moderation.Check(subject)
+}
+
+// storeFlags puts the flags raised for a message in the review queue.
+func (s *MessageService) storeFlags(tx *sql.Tx, message *models.Message, flags []*ModerationVerdict) error {
+	moderationRepo := s.ModerationRepo.WithTx(tx)
+	for _, verdict := range flags {
+		err := moderationRepo.CreateFlag(&models.ModerationFlag{
+			MessageID: &message.ID,
+			SenderID:  message.SenderID,
+			Filter:    verdict.Filter,
+			Reason:    verdict.Reason,
+			Details:   verdict.Details,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SaveAttachments stores the uploaded files in parallel and returns attachments that are not saved to the database yet.
+// If a file can't be stored, the files stored so far are deleted.
+func (s *MessageService) SaveAttachments(files []*multipart.FileHeader) ([]*models.Attachment, error) {
+	if len(files) == 0 {
+		return nil, nil
 	}
 
 	numWorkers := runtime.NumCPU()
@@ -47,7 +620,7 @@ func (s *MessageService) ProcessAttachments(r *http.Request, message *models.Mes
 
 	worker := func() {
 		for job := range jobChan {
-			attachment, err := s.processSingleAttachment(job.file, message.ID)
+			attachment, err := s.safeSaveAttachment(job.file)
 			if err != nil {
 				errChan <- err
 				continue
@@ -87,62 +660,122 @@ func (s *MessageService) ProcessAttachments(r *http.Request, message *models.Mes
 		}
 	}
 
-	message.Attachments = attachments
-	return combinedErr
+	if combinedErr != nil {
+		s.deleteFiles(attachments)
+		return nil, combinedErr
+	}
+	return attachments, nil
 }
 
-func (s *MessageService) DeleteAttachments(attachments []*models.Attachment) error {
-	var wg sync.WaitGroup
-	errorChan := make(chan error, len(attachments))
+// copyAttachments creates attachment records for a message that point to already stored files.
+func copyAttachments(attachmentRepo *repository.AttachmentRepository, attachments []*models.Attachment, messageID uint) ([]*models.Attachment, error) {
+	copied := make([]*models.Attachment, 0, len(attachments))
+	for _, src := range attachments {
+		attachment := &models.Attachment{
+			MessageID: messageID,
+			FileName:  src.FileName,
+			FilePath:  src.FilePath,
+			FileType:  src.FileType,
+			FileSize:  src.FileSize,
+			Type:      src.Type,
+			Duration:  src.Duration,
+			Waveform:  src.Waveform,
+		}
 
-	for _, attachment := range attachments {
-		wg.Add(1)
-		go func(attachment *models.Attachment) {
-			defer wg.Done()
-			if err := s.Storage.DeleteFile(storage.MessageAttachmentsDir, attachment.FilePath); err != nil {
-				log.Printf("Error deleting file %s: %s", attachment.FilePath, err.Error())
-				errorChan <- err
-			}
-		}(attachment)
+		if _, err := attachmentRepo.Create(attachment); err != nil {
+			return nil, fmt.Errorf("failed to copy attachment record: %w", err)
+		}
+		copied = append(copied, attachment)
 	}
 
-	wg.Wait()
-	close(errorChan)
+	return copied, nil
+}
 
-	var combinedErr error
-	for err := range errorChan {
-		if combinedErr == nil {
-			combinedErr = err
-		} else {
-			combinedErr = fmt.Errorf("%v; %w", combinedErr, err)
-		}
+// copyEntities returns copies of the entities that aren't attached to any message yet.
+func copyEntities(entities []*models.MessageEntity) []*models.MessageEntity {
+	copied := make([]*models.MessageEntity, 0, len(entities))
+	for _, src := range entities {
+		entity := *src
+		entity.ID = 0
+		entity.MessageID = 0
+		copied = append(copied, &entity)
+	}
+	return copied
+}
+
+// attachmentPaths returns the stored file paths of the attachments.
+func attachmentPaths(attachments []*models.Attachment) []string {
+	paths := make([]string, 0, len(attachments))
+	for _, attachment := range attachments {
+		paths = append(paths, attachment.FilePath)
 	}
-	return combinedErr
+	return paths
 }
 
-func (s *MessageService) processSingleAttachment(fh *multipart.FileHeader, messageID uint) (*models.Attachment, error) {
+// safeSaveAttachment runs saveAttachment, turning a panic while parsing an uploaded file into an error,
+// so a malformed file can't take the server down.
+func (s *MessageService) safeSaveAttachment(fh *multipart.FileHeader) (attachment *models.Attachment, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Recovered from panic while saving %s: %v", fh.Filename, r)
+			attachment, err = nil, fmt.Errorf("failed to process file %s", fh.Filename)
+		}
+	}()
+
+	return s.saveAttachment(fh)
+}
+
+// saveAttachment stores a single file. Voice messages are parsed first, so invalid audio is never stored.
+func (s *MessageService) saveAttachment(fh *multipart.FileHeader) (*models.Attachment, error) {
 	file, err := fh.Open()
 	if err != nil {
 		return nil, fmt.Errorf("failed to open file: %w", err)
 	}
 	defer file.Close()
 
-	storagePath, err := s.Storage.SaveFile(storage.MessageAttachmentsDir, fh.Filename, file)
-	if err != nil {
-		return nil, fmt.Errorf("failed to save file: %w", err)
+	attachment := &models.Attachment{
+		FileName: fh.Filename,
+		FileType: fh.Header.Get("Content-Type"),
+		FileSize: fh.Size,
+		Type:     models.FileAttachment,
 	}
 
-	attachment := &models.Attachment{
-		MessageID: messageID,
-		FileName:  fh.Filename,
-		FilePath:  storagePath,
-		FileType:  fh.Header.Get("Content-Type"),
-		FileSize:  fh.Size,
+	var fileData io.Reader = file
+	if IsVoiceFile(fh.Filename) {
+		data, err := io.ReadAll(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file: %w", err)
+		}
+
+		info, err := ParseAudio(fh.Filename, data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse voice message %s: %w", fh.Filename, err)
+		}
+
+		duration := int(info.Duration.Milliseconds())
+		attachment.Type = models.VoiceAttachment
+		attachment.Duration = &duration
+		attachment.Waveform = info.Waveform
+		fileData = bytes.NewReader(data)
 	}
 
-	if _, err := s.AttachmentRepo.Create(attachment); err != nil {
-		return nil, fmt.Errorf("failed to save attachment record: %w", err)
+	storagePath, err := s.Storage.SaveFile(storage.MessageAttachmentsDir, fh.Filename, fileData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save file: %w", err)
 	}
+	attachment.FilePath = storagePath
 
 	return attachment, nil
 }
+
+// deleteFiles removes stored files of attachments that never made it to the database.
+func (s *MessageService) deleteFiles(attachments []*models.Attachment) {
+	for _, attachment := range attachments {
+		if attachment == nil {
+			continue
+		}
+		if err := s.Storage.DeleteFile(storage.MessageAttachmentsDir, attachment.FilePath); err != nil {
+			log.Printf("Error deleting file %s: %s", attachment.FilePath, err.Error())
+		}
+	}
+}
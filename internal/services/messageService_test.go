@@ -0,0 +1,119 @@
+package services
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"github.com/drTragger/messenger-backend/internal/fakedb"
+	"github.com/drTragger/messenger-backend/internal/models"
+	"github.com/drTragger/messenger-backend/internal/repository"
+	"github.com/drTragger/messenger-backend/internal/storage"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMessageServiceDeleteKeepsSharedFiles(t *testing.T) {
+	steps := make([]string, 0)
+	db, fake := fakedb.Open(t, func(query string, args []driver.Value) fakedb.Result {
+		switch {
+		case strings.Contains(query, "pg_advisory_xact_lock"):
+			steps = append(steps, "lock")
+		case strings.Contains(query, "DELETE FROM messages"):
+			steps = append(steps, "delete")
+		case strings.Contains(query, "WHERE file_path = path"):
+			steps = append(steps, "references")
+			// The second file is still referenced by a forwarded copy
+			return fakedb.Row("own.jpg")
+		}
+		return fakedb.Result{Affected: 1}
+	})
+	service, dir := newTestMessageService(t, db)
+	for _, name := range []string{"own.jpg", "shared.jpg"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("image"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	message := &models.Message{ID: 40, SenderID: 1, RecipientID: 2, ChatID: 7, Attachments: []*models.Attachment{
+		{MessageID: 40, FilePath: "own.jpg"},
+		{MessageID: 40, FilePath: "shared.jpg"},
+	}}
+	if err := service.Delete(message); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	// The files are locked before the delete, so a concurrent forward or delete can't change their references
+	if fmt.Sprint(steps) != "[lock delete references]" {
+		t.Errorf("steps = %v, want [lock delete references]", steps)
+	}
+	if locks := fake.Executed("pg_advisory_xact_lock"); len(locks) != 1 || locks[0].Args[0] != "{\"own.jpg\",\"shared.jpg\"}" {
+		t.Errorf("locks = %v, want both files locked", locks)
+	}
+	if fake.Commits != 1 {
+		t.Errorf("%d commits, want the check in the delete transaction", fake.Commits)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "own.jpg")); !os.IsNotExist(err) {
+		t.Errorf("own.jpg wasn't deleted")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "shared.jpg")); err != nil {
+		t.Errorf("shared.jpg was deleted: %v", err)
+	}
+}
+
+func TestMessageServiceForwardOfDeletedMessage(t *testing.T) {
+	db, fake := fakedb.Open(t, func(query string, args []driver.Value) fakedb.Result {
+		if strings.Contains(query, "WHERE file_path = path") {
+			// The original was deleted before the forward locked its file
+			return fakedb.Row("photo.jpg")
+		}
+		return fakedb.Result{Affected: 1}
+	})
+	service, _ := newTestMessageService(t, db)
+
+	content := "Look"
+	original := &models.Message{ID: 40, SenderID: 1, RecipientID: 2, ChatID: 7, Content: &content, Type: models.TextMessage,
+		Attachments: []*models.Attachment{{MessageID: 40, FilePath: "photo.jpg"}}}
+	copies := []*models.Message{{SenderID: 2, RecipientID: 3, Content: &content}}
+
+	if err := service.Forward(9, copies, []*models.Message{original}, "en"); !errors.Is(err, ErrForwardedMessageDeleted) {
+		t.Fatalf("Forward() error = %v, want %v", err, ErrForwardedMessageDeleted)
+	}
+	if messages := fake.Executed("INSERT INTO messages"); len(messages) != 0 {
+		t.Errorf("%d messages stored, want none", len(messages))
+	}
+	if fake.Rollbacks != 1 {
+		t.Errorf("%d rollbacks, want 1", fake.Rollbacks)
+	}
+}
+
+// newTestMessageService returns a message service with local storage in a temporary directory,
+// and the directory of the message attachments.
+func newTestMessageService(t *testing.T, db *sql.DB) (*MessageService, string) {
+	t.Helper()
+
+	base := t.TempDir()
+	storageInst, err := storage.NewStorage(&storage.Config{Type: storage.LocalStorageType, LocalPath: base})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(base, storage.MessageAttachmentsDir)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	service := NewMessageService(
+		db,
+		repository.NewMessageRepository(db),
+		repository.NewChatRepository(db),
+		repository.NewMessageEntityRepository(db),
+		repository.NewAttachmentRepository(db),
+		repository.NewOutboxRepository(db),
+		repository.NewModerationRepository(db, nil),
+		NewModerationService(),
+		storageInst,
+	)
+	return service, dir
+}
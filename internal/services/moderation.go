@@ -0,0 +1,327 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/drTragger/messenger-backend/internal/models"
+	"github.com/drTragger/messenger-backend/internal/repository"
+	"log"
+	"mime/multipart"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	ModerationTimeout = 5 * time.Second
+
+	SpamWindow          = 10 * time.Minute
+	SpamFlagThreshold   = 5
+	SpamRejectThreshold = 10
+
+	// AllLocales holds the keyword rules applied whatever the sender's language is
+	AllLocales = "*"
+)
+
+// ModerationSubject is what the filters check: either the text of a message or one of its attachments.
+type ModerationSubject struct {
+	SenderID uint
+	Locale   string
+	Text     string
+	URLs     []string // Links that aren't part of the text, such as text_link entities
+	File     *multipart.FileHeader
+}
+
+// ModerationVerdict is the decision of a filter. A nil verdict allows the subject.
+type ModerationVerdict struct {
+	Action  string
+	Filter  string
+	Reason  string
+	Details *string
+}
+
+// ModerationError is returned when a filter rejects a message.
+type ModerationError struct {
+	Verdict *ModerationVerdict
+}
+
+func (e *ModerationError) Error() string {
+	return fmt.Sprintf("message rejected by the %s filter: %s", e.Verdict.Filter, e.Verdict.Reason)
+}
+
+// ModerationFilter checks a message text or a single attachment and allows, flags or rejects it.
+// External classifiers plug into the chain by implementing it.
+type ModerationFilter interface {
+	Name() string
+	Check(ctx context.Context, subject *ModerationSubject) (*ModerationVerdict, error)
+}
+
+// ModerationService runs the filter chain before a message is stored.
+type ModerationService struct {
+	Filters []ModerationFilter
+	Timeout time.Duration
+}
+
+func NewModerationService(filters ...ModerationFilter) *ModerationService {
+	return &ModerationService{
+		Filters: filters,
+		Timeout: ModerationTimeout,
+	}
+}
+
+// Check runs every filter and returns the flags they raised. The first rejection stops the chain and
+// is returned as a *ModerationError. A filter that fails is skipped, so an unavailable classifier
+// doesn't stop people from messaging.
+func (s *ModerationService) Check(subject *ModerationSubject) ([]*ModerationVerdict, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.Timeout)
+	defer cancel()
+
+	flags := make([]*ModerationVerdict, 0)
+	for _, filter := range s.Filters {
+		verdict, err := filter.Check(ctx, subject)
+		if err != nil {
+			log.Printf("Error running the %s moderation filter: %s", filter.Name(), err)
+			continue
+		}
+		if verdict == nil || verdict.Action == models.AllowVerdict {
+			continue
+		}
+		if verdict.Filter == "" {
+			verdict.Filter = filter.Name()
+		}
+
+		switch verdict.Action {
+		case models.RejectVerdict:
+			return nil, &ModerationError{Verdict: verdict}
+		case models.FlagVerdict:
+			flags = append(flags, verdict)
+		}
+	}
+	return flags, nil
+}
+
+// KeywordRule matches message texts against a regular expression.
+type KeywordRule struct {
+	Pattern *regexp.Regexp
+	Action  string
+	Reason  string
+}
+
+// KeywordFilter checks texts against the rules for every locale and the rules for the sender's language.
+type KeywordFilter struct {
+	Rules map[string][]*KeywordRule
+}
+
+func NewKeywordFilter(rules map[string][]*KeywordRule) *KeywordFilter {
+	return &KeywordFilter{
+		Rules: rules,
+	}
+}
+
+func (f *KeywordFilter) Name() string {
+	return "keyword"
+}
+
+func (f *KeywordFilter) Check(_ context.Context, subject *ModerationSubject) (*ModerationVerdict, error) {
+	if subject.Text == "" {
+		return nil, nil
+	}
+
+	var flag *ModerationVerdict
+	rules := make([]*KeywordRule, 0)
+	rules = append(rules, f.Rules[AllLocales]...)
+	rules = append(rules, f.Rules[baseLanguage(subject.Locale)]...)
+	for _, rule := range rules {
+		if !rule.Pattern.MatchString(subject.Text) {
+			continue
+		}
+		verdict := &ModerationVerdict{Action: rule.Action, Reason: rule.Reason}
+		if rule.Action == models.RejectVerdict {
+			return verdict, nil
+		}
+		if flag == nil {
+			flag = verdict
+		}
+	}
+	return flag, nil
+}
+
+// LinkRule matches links to a domain and its subdomains.
+type LinkRule struct {
+	Domain string
+	Action string
+	Reason string
+}
+
+// LinkFilter checks the links in texts and text_link entities against blocked domains.
+type LinkFilter struct {
+	Rules []*LinkRule
+}
+
+func NewLinkFilter(rules []*LinkRule) *LinkFilter {
+	return &LinkFilter{
+		Rules: rules,
+	}
+}
+
+func (f *LinkFilter) Name() string {
+	return "link"
+}
+
+func (f *LinkFilter) Check(_ context.Context, subject *ModerationSubject) (*ModerationVerdict, error) {
+	links := append(linkRegex.FindAllString(subject.Text, -1), subject.URLs...)
+
+	var flag *ModerationVerdict
+	for _, link := range links {
+		if !strings.Contains(link, "://") {
+			link = "http://" + link
+		}
+		parsed, err := url.Parse(link)
+		if err != nil {
+			continue
+		}
+		host := strings.ToLower(strings.TrimSuffix(parsed.Hostname(), "."))
+
+		for _, rule := range f.Rules {
+			if host != rule.Domain && !strings.HasSuffix(host, "."+rule.Domain) {
+				continue
+			}
+			verdict := &ModerationVerdict{Action: rule.Action, Reason: rule.Reason}
+			if rule.Action == models.RejectVerdict {
+				return verdict, nil
+			}
+			if flag == nil {
+				flag = verdict
+			}
+		}
+	}
+	return flag, nil
+}
+
+// SpamFilter flags a user who keeps sending the same text and rejects the text once it is repeated too often.
+type SpamFilter struct {
+	ModerationRepo  *repository.ModerationRepository
+	Window          time.Duration
+	FlagThreshold   int64
+	RejectThreshold int64
+}
+
+func NewSpamFilter(moderationRepo *repository.ModerationRepository) *SpamFilter {
+	return &SpamFilter{
+		ModerationRepo:  moderationRepo,
+		Window:          SpamWindow,
+		FlagThreshold:   SpamFlagThreshold,
+		RejectThreshold: SpamRejectThreshold,
+	}
+}
+
+func (f *SpamFilter) Name() string {
+	return "spam"
+}
+
+func (f *SpamFilter) Check(ctx context.Context, subject *ModerationSubject) (*ModerationVerdict, error) {
+	// Case and spacing are ignored so that small variations still count as a repeat
+	text := strings.Join(strings.Fields(strings.ToLower(subject.Text)), " ")
+	if text == "" {
+		return nil, nil
+	}
+
+	count, err := f.ModerationRepo.CountRepeats(ctx, subject.SenderID, text, f.Window)
+	if err != nil {
+		return nil, err
+	}
+
+	reason := fmt.Sprintf("Same text sent %d times within %s", count, f.Window)
+	switch {
+	case count >= f.RejectThreshold:
+		return &ModerationVerdict{Action: models.RejectVerdict, Reason: reason}, nil
+	case count == f.FlagThreshold:
+		// Only the message that crosses the threshold is queued, not every repeat after it
+		return &ModerationVerdict{Action: models.FlagVerdict, Reason: reason}, nil
+	}
+	return nil, nil
+}
+
+// ModerationRules are the keyword and link rules loaded from the rules file.
+type ModerationRules struct {
+	Keywords map[string][]*KeywordRule
+	Links    []*LinkRule
+}
+
+// LoadModerationRules reads the rules file. Keyword rules are grouped by language, with "*" for every language:
+//
+//	{"keywords": {"en": [{"pattern": "...", "action": "flag", "reason": "..."}]},
+//	 "links": [{"domain": "example.com", "action": "reject", "reason": "..."}]}
+func LoadModerationRules(path string) (*ModerationRules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file struct {
+		Keywords map[string][]struct {
+			Pattern string `json:"pattern"`
+			Action  string `json:"action"`
+			Reason  string `json:"reason"`
+		} `json:"keywords"`
+		Links []struct {
+			Domain string `json:"domain"`
+			Action string `json:"action"`
+			Reason string `json:"reason"`
+		} `json:"links"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+
+	rules := &ModerationRules{
+		Keywords: make(map[string][]*KeywordRule),
+		Links:    make([]*LinkRule, 0, len(file.Links)),
+	}
+	for locale, keywords := range file.Keywords {
+		for _, keyword := range keywords {
+			if err := validateModerationAction(keyword.Action); err != nil {
+				return nil, err
+			}
+			pattern, err := regexp.Compile(keyword.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid keyword pattern %q: %w", keyword.Pattern, err)
+			}
+			rules.Keywords[locale] = append(rules.Keywords[locale], &KeywordRule{
+				Pattern: pattern,
+				Action:  keyword.Action,
+				Reason:  keyword.Reason,
+			})
+		}
+	}
+	for _, link := range file.Links {
+		if err := validateModerationAction(link.Action); err != nil {
+			return nil, err
+		}
+		rules.Links = append(rules.Links, &LinkRule{
+			Domain: strings.ToLower(link.Domain),
+			Action: link.Action,
+			Reason: link.Reason,
+		})
+	}
+	return rules, nil
+}
+
+func validateModerationAction(action string) error {
+	if action != models.FlagVerdict && action != models.RejectVerdict {
+		return fmt.Errorf("invalid moderation action %q", action)
+	}
+	return nil
+}
+
+// baseLanguage turns an Accept-Language value such as "uk-UA,uk;q=0.9" into "uk".
+func baseLanguage(locale string) string {
+	locale = strings.ToLower(strings.TrimSpace(locale))
+	if i := strings.IndexAny(locale, ",;-_"); i >= 0 {
+		locale = locale[:i]
+	}
+	return locale
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/drTragger/messenger-backend/internal/models"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	classifierTimeout = 3 * time.Second
+	// Larger files are sent to the classifier without their content
+	classifierMaxFileSize = 10 << 20
+)
+
+// HTTPClassifier sends texts and attachments to an external classification service,
+// which answers with {"action": "allow|flag|reject", "reason": "..."}.
+type HTTPClassifier struct {
+	URL    string
+	Client *http.Client
+}
+
+func NewHTTPClassifier(url string) *HTTPClassifier {
+	return &HTTPClassifier{
+		URL:    url,
+		Client: &http.Client{Timeout: classifierTimeout},
+	}
+}
+
+type classifierRequest struct {
+	SenderID uint    `json:"senderId"`
+	Locale   string  `json:"locale"`
+	Text     string  `json:"text,omitempty"`
+	FileName *string `json:"fileName,omitempty"`
+	FileType *string `json:"fileType,omitempty"`
+	FileSize *int64  `json:"fileSize,omitempty"`
+	File     []byte  `json:"file,omitempty"`
+}
+
+func (c *HTTPClassifier) Name() string {
+	return "classifier"
+}
+
+func (c *HTTPClassifier) Check(ctx context.Context, subject *ModerationSubject) (*ModerationVerdict, error) {
+	payload := classifierRequest{
+		SenderID: subject.SenderID,
+		Locale:   subject.Locale,
+		Text:     subject.Text,
+	}
+	if subject.File != nil {
+		fileType := subject.File.Header.Get("Content-Type")
+		payload.FileName = &subject.File.Filename
+		payload.FileType = &fileType
+		payload.FileSize = &subject.File.Size
+
+		if subject.File.Size <= classifierMaxFileSize {
+			file, err := subject.File.Open()
+			if err != nil {
+				return nil, err
+			}
+			payload.File, err = io.ReadAll(file)
+			file.Close()
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("classifier responded with status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Action string `json:"action"`
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return &ModerationVerdict{Action: result.Action, Reason: result.Reason}, nil
+}
+
+// LocalClassifier gives a fixed verdict for texts that contain one of its phrases.
+// It stands in for a real classifier in development and tests.
+type LocalClassifier struct {
+	Verdicts map[string]string
+}
+
+// NewLocalClassifier takes the action to return for each phrase, such as {"spam": models.FlagVerdict}.
+func NewLocalClassifier(verdicts map[string]string) *LocalClassifier {
+	return &LocalClassifier{
+		Verdicts: verdicts,
+	}
+}
+
+func (c *LocalClassifier) Name() string {
+	return "classifier"
+}
+
+func (c *LocalClassifier) Check(_ context.Context, subject *ModerationSubject) (*ModerationVerdict, error) {
+	text := strings.ToLower(subject.Text)
+
+	var verdict *ModerationVerdict
+	for phrase, action := range c.Verdicts {
+		if !strings.Contains(text, strings.ToLower(phrase)) {
+			continue
+		}
+		if verdict == nil || action == models.RejectVerdict {
+			verdict = &ModerationVerdict{Action: action, Reason: fmt.Sprintf("Contains %q", phrase)}
+		}
+	}
+	return verdict, nil
+}
@@ -0,0 +1,284 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/drTragger/messenger-backend/internal/fakeredis"
+	"github.com/drTragger/messenger-backend/internal/models"
+	"github.com/drTragger/messenger-backend/internal/repository"
+	"github.com/go-redis/redis/v8"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestModerationServiceCheck(t *testing.T) {
+	moderation := NewModerationService(
+		failingFilter{},
+		NewLocalClassifier(map[string]string{
+			"buy now": models.FlagVerdict,
+			"casino":  models.FlagVerdict,
+			"scam":    models.RejectVerdict,
+		}),
+		NewLinkFilter([]*LinkRule{{Domain: "ads.example", Action: models.FlagVerdict, Reason: "Advertising"}}),
+	)
+
+	tests := []struct {
+		name   string
+		text   string
+		flags  []string
+		reject string
+	}{
+		{name: "allow", text: "See you tomorrow"},
+		{name: "flag", text: "Buy now!", flags: []string{"classifier"}},
+		{name: "flags of several filters", text: "Casino at https://ads.example/win", flags: []string{"classifier", "link"}},
+		{name: "reject", text: "Casino scam", reject: "classifier"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flags, err := moderation.Check(&ModerationSubject{SenderID: 1, Locale: "en", Text: tt.text})
+
+			if tt.reject != "" {
+				var moderationErr *ModerationError
+				if !errors.As(err, &moderationErr) {
+					t.Fatalf("Check() error = %v, want a *ModerationError", err)
+				}
+				if moderationErr.Verdict.Filter != tt.reject || moderationErr.Verdict.Action != models.RejectVerdict {
+					t.Errorf("Verdict = %+v, want a rejection by the %s filter", moderationErr.Verdict, tt.reject)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Check() error = %v", err)
+			}
+			if got := verdictFilters(flags); !equalStrings(got, tt.flags) {
+				t.Errorf("flags raised by %v, want %v", got, tt.flags)
+			}
+		})
+	}
+}
+
+func TestKeywordFilterLocales(t *testing.T) {
+	rules := writeModerationRules(t, `{
+		"keywords": {
+			"*": [{"pattern": "(?i)\\bforbidden\\b", "action": "reject", "reason": "Forbidden everywhere"}],
+			"en": [{"pattern": "(?i)\\bidiot\\b", "action": "flag", "reason": "Insult"}],
+			"uk": [{"pattern": "(?i)дурень", "action": "flag", "reason": "Образа"}]
+		}
+	}`)
+	filter := NewKeywordFilter(rules.Keywords)
+
+	tests := []struct {
+		name   string
+		locale string
+		text   string
+		action string
+		reason string
+	}{
+		{name: "rule of the sender's language", locale: "en-US", text: "You idiot", action: models.FlagVerdict, reason: "Insult"},
+		{name: "Accept-Language header", locale: "uk-UA,uk;q=0.9", text: "Ти дурень", action: models.FlagVerdict, reason: "Образа"},
+		{name: "rule of another language", locale: "uk", text: "You idiot"},
+		{name: "unknown language", locale: "pl", text: "Ти дурень"},
+		{name: "rule of every language", locale: "pl", text: "This is FORBIDDEN", action: models.RejectVerdict, reason: "Forbidden everywhere"},
+		{name: "rejection wins over a flag", locale: "en", text: "Forbidden idiot", action: models.RejectVerdict, reason: "Forbidden everywhere"},
+		{name: "no match", locale: "en", text: "Hello"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verdict, err := filter.Check(context.Background(), &ModerationSubject{Locale: tt.locale, Text: tt.text})
+			if err != nil {
+				t.Fatalf("Check() error = %v", err)
+			}
+			assertVerdict(t, verdict, tt.action, tt.reason)
+		})
+	}
+}
+
+func TestLoadModerationRulesRejectsInvalidRules(t *testing.T) {
+	tests := map[string]string{
+		"unknown action":  `{"keywords": {"en": [{"pattern": "spam", "action": "ban"}]}}`,
+		"invalid pattern": `{"keywords": {"en": [{"pattern": "(", "action": "flag"}]}}`,
+		"link action":     `{"links": [{"domain": "example.com", "action": "allow"}]}`,
+	}
+
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "moderation.json")
+			if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := LoadModerationRules(path); err == nil {
+				t.Error("LoadModerationRules() error = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestLinkFilter(t *testing.T) {
+	rules := writeModerationRules(t, `{
+		"links": [
+			{"domain": "Phishing.example", "action": "reject", "reason": "Phishing"},
+			{"domain": "ads.example", "action": "flag", "reason": "Advertising"}
+		]
+	}`)
+	filter := NewLinkFilter(rules.Links)
+
+	tests := []struct {
+		name   string
+		text   string
+		urls   []string
+		action string
+		reason string
+	}{
+		{name: "blocked domain", text: "Log in at https://phishing.example/login", action: models.RejectVerdict, reason: "Phishing"},
+		{name: "subdomain", text: "https://secure.PHISHING.example.", action: models.RejectVerdict, reason: "Phishing"},
+		{name: "flagged domain", text: "Deals on http://ads.example", action: models.FlagVerdict, reason: "Advertising"},
+		{name: "text link entity", urls: []string{"ads.example/deal"}, action: models.FlagVerdict, reason: "Advertising"},
+		{name: "rejection wins over a flag", text: "https://ads.example https://phishing.example", action: models.RejectVerdict, reason: "Phishing"},
+		{name: "similar domain", text: "https://notphishing.example https://phishing.example.org"},
+		{name: "domain without a link", text: "phishing.example is a bad site"},
+		{name: "no links", text: "Hello"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verdict, err := filter.Check(context.Background(), &ModerationSubject{Text: tt.text, URLs: tt.urls})
+			if err != nil {
+				t.Fatalf("Check() error = %v", err)
+			}
+			assertVerdict(t, verdict, tt.action, tt.reason)
+		})
+	}
+}
+
+func TestSpamFilter(t *testing.T) {
+	cache := redis.NewClient(&redis.Options{Addr: fakeredis.Start(t)})
+	defer cache.Close()
+
+	filter := NewSpamFilter(repository.NewModerationRepository(nil, cache))
+	filter.FlagThreshold = 3
+	filter.RejectThreshold = 5
+
+	check := func(senderID uint, text string) *ModerationVerdict {
+		t.Helper()
+		verdict, err := filter.Check(context.Background(), &ModerationSubject{SenderID: senderID, Text: text})
+		if err != nil {
+			t.Fatalf("Check() error = %v", err)
+		}
+		return verdict
+	}
+
+	// Case and spacing don't make a text new
+	texts := []string{"Free coins", "free  coins", "FREE COINS ", "free coins", "Free coins"}
+	actions := []string{"", "", models.FlagVerdict, "", models.RejectVerdict}
+	for i, text := range texts {
+		verdict := check(1, text)
+		if action := verdictAction(verdict); action != actions[i] {
+			t.Errorf("repeat %d: action = %q, want %q", i+1, action, actions[i])
+		}
+	}
+
+	if verdict := check(2, "Free coins"); verdict != nil {
+		t.Errorf("first text of another sender: verdict = %+v, want nil", verdict)
+	}
+	if verdict := check(1, "Something else"); verdict != nil {
+		t.Errorf("another text: verdict = %+v, want nil", verdict)
+	}
+	if verdict := check(1, "   "); verdict != nil {
+		t.Errorf("blank text: verdict = %+v, want nil", verdict)
+	}
+}
+
+func TestLocalClassifier(t *testing.T) {
+	classifier := NewLocalClassifier(map[string]string{
+		"Lottery": models.FlagVerdict,
+		"wire me": models.RejectVerdict,
+	})
+
+	tests := []struct {
+		text   string
+		action string
+	}{
+		{text: "Hello"},
+		{text: "You won the LOTTERY", action: models.FlagVerdict},
+		{text: "Lottery win, wire me the fee", action: models.RejectVerdict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			verdict, err := classifier.Check(context.Background(), &ModerationSubject{Text: tt.text})
+			if err != nil {
+				t.Fatalf("Check() error = %v", err)
+			}
+			if action := verdictAction(verdict); action != tt.action {
+				t.Errorf("action = %q, want %q", action, tt.action)
+			}
+		})
+	}
+}
+
+// failingFilter stands in for a classifier that is down.
+type failingFilter struct{}
+
+func (failingFilter) Name() string {
+	return "failing"
+}
+
+func (failingFilter) Check(context.Context, *ModerationSubject) (*ModerationVerdict, error) {
+	return nil, errors.New("classifier unavailable")
+}
+
+func writeModerationRules(t *testing.T, content string) *ModerationRules {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "moderation.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	rules, err := LoadModerationRules(path)
+	if err != nil {
+		t.Fatalf("LoadModerationRules() error = %v", err)
+	}
+	return rules
+}
+
+func assertVerdict(t *testing.T, verdict *ModerationVerdict, action, reason string) {
+	t.Helper()
+
+	if verdictAction(verdict) != action {
+		t.Fatalf("verdict = %+v, want action %q", verdict, action)
+	}
+	if verdict != nil && verdict.Reason != reason {
+		t.Errorf("Reason = %q, want %q", verdict.Reason, reason)
+	}
+}
+
+func verdictAction(verdict *ModerationVerdict) string {
+	if verdict == nil {
+		return ""
+	}
+	return verdict.Action
+}
+
+func verdictFilters(verdicts []*ModerationVerdict) []string {
+	filters := make([]string, 0, len(verdicts))
+	for _, verdict := range verdicts {
+		filters = append(filters, verdict.Filter)
+	}
+	return filters
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"github.com/drTragger/messenger-backend/internal/models"
+	"github.com/drTragger/messenger-backend/internal/repository"
+	"log"
+	"time"
+)
+
+const (
+	OutboxRelayInterval  = time.Second
+	OutboxRelayBatch     = 100
+	OutboxLease          = 30 * time.Second
+	OutboxMaxAttempts    = 10
+	OutboxRetryBaseDelay = time.Second
+	OutboxRetryMaxDelay  = 5 * time.Minute
+	OutboxRetention      = 24 * time.Hour
+)
+
+// EventSink publishes outbox events somewhere outside the database, such as the websocket clients.
+type EventSink interface {
+	Publish(event *models.OutboxEvent) error
+}
+
+// OutboxRelay publishes the events stored in the outbox to every sink. Failed events are retried with
+// exponential backoff until OutboxMaxAttempts is reached. Events are leased when they are claimed,
+// so several instances can run at once; an event is delivered at least once.
+type OutboxRelay struct {
+	OutboxRepo *repository.OutboxRepository
+	Sinks      []EventSink
+	Interval   time.Duration
+}
+
+func NewOutboxRelay(outboxRepo *repository.OutboxRepository, sinks ...EventSink) *OutboxRelay {
+	return &OutboxRelay{
+		OutboxRepo: outboxRepo,
+		Sinks:      sinks,
+		Interval:   OutboxRelayInterval,
+	}
+}
+
+// Run publishes due events every Interval and drops old delivered events every hour until the context is cancelled.
+func (r *OutboxRelay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+	cleanup := time.NewTicker(time.Hour)
+	defer cleanup.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.relay()
+		case <-cleanup.C:
+			if err := r.OutboxRepo.DeleteDelivered(time.Now().Add(-OutboxRetention)); err != nil {
+				log.Printf("Error deleting delivered outbox events: %s", err)
+			}
+		}
+	}
+}
+
+func (r *OutboxRelay) relay() {
+	for {
+		events, err := r.OutboxRepo.Claim(OutboxRelayBatch, OutboxLease)
+		if err != nil {
+			log.Printf("Error claiming outbox events: %s", err)
+			return
+		}
+
+		for _, event := range events {
+			r.publish(event)
+		}
+
+		if len(events) < OutboxRelayBatch {
+			return
+		}
+	}
+}
+
+func (r *OutboxRelay) publish(event *models.OutboxEvent) {
+	for _, sink := range r.Sinks {
+		if err := sink.Publish(event); err != nil {
+			giveUp := event.Attempts+1 >= OutboxMaxAttempts
+			if giveUp {
+				log.Printf("Giving up on outbox event %d after %d attempts: %s", event.ID, event.Attempts+1, err)
+			}
+			if err := r.OutboxRepo.MarkFailed(event.ID, err.Error(), time.Now().Add(retryDelay(event.Attempts)), giveUp); err != nil {
+				log.Printf("Error marking outbox event %d as failed: %s", event.ID, err)
+			}
+			return
+		}
+	}
+
+	if err := r.OutboxRepo.MarkDelivered(event.ID); err != nil {
+		log.Printf("Error marking outbox event %d as delivered: %s", event.ID, err)
+	}
+}
+
+// retryDelay doubles the delay with every failed attempt, up to OutboxRetryMaxDelay.
+func retryDelay(attempts int) time.Duration {
+	delay := OutboxRetryBaseDelay
+	for i := 0; i < attempts && delay < OutboxRetryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > OutboxRetryMaxDelay {
+		delay = OutboxRetryMaxDelay
+	}
+	return delay
+}
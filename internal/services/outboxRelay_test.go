@@ -0,0 +1,152 @@
+package services
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"github.com/drTragger/messenger-backend/internal/fakedb"
+	"github.com/drTragger/messenger-backend/internal/models"
+	"github.com/drTragger/messenger-backend/internal/repository"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		delay    time.Duration
+	}{
+		{attempts: 0, delay: time.Second},
+		{attempts: 1, delay: 2 * time.Second},
+		{attempts: 3, delay: 8 * time.Second},
+		{attempts: 8, delay: 256 * time.Second},
+		{attempts: 9, delay: OutboxRetryMaxDelay},
+		{attempts: 100, delay: OutboxRetryMaxDelay},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.attempts), func(t *testing.T) {
+			if delay := retryDelay(tt.attempts); delay != tt.delay {
+				t.Errorf("retryDelay(%d) = %s, want %s", tt.attempts, delay, tt.delay)
+			}
+		})
+	}
+}
+
+func TestOutboxRelayPublish(t *testing.T) {
+	db, fake := fakedb.Open(t, func(query string, args []driver.Value) fakedb.Result {
+		return fakedb.Result{Affected: 1}
+	})
+	first, second := &recordingSink{}, &recordingSink{}
+	relay := NewOutboxRelay(repository.NewOutboxRepository(db), first, second)
+
+	relay.publish(&models.OutboxEvent{ID: 4, UserID: 2, Event: "newMessage"})
+
+	if len(first.published) != 1 || len(second.published) != 1 {
+		t.Errorf("sinks got %d and %d events, want 1 each", len(first.published), len(second.published))
+	}
+	delivered := fake.Executed("SET delivered_at")
+	if len(delivered) != 1 || delivered[0].Args[0] != int64(4) {
+		t.Errorf("delivered = %v, want event 4 marked delivered", delivered)
+	}
+	if failed := fake.Executed("last_error"); len(failed) != 0 {
+		t.Errorf("failed = %v, want none", failed)
+	}
+}
+
+func TestOutboxRelayPublishSinkFailure(t *testing.T) {
+	tests := []struct {
+		name     string
+		attempts int
+		delay    time.Duration
+		giveUp   bool
+	}{
+		{name: "retried", attempts: 2, delay: 4 * time.Second},
+		{name: "last attempt", attempts: OutboxMaxAttempts - 1, delay: OutboxRetryMaxDelay, giveUp: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := fakedb.Open(t, func(query string, args []driver.Value) fakedb.Result {
+				return fakedb.Result{Affected: 1}
+			})
+			failing, next := &recordingSink{err: errors.New("update log unavailable")}, &recordingSink{}
+			relay := NewOutboxRelay(repository.NewOutboxRepository(db), failing, next)
+
+			start := time.Now()
+			relay.publish(&models.OutboxEvent{ID: 4, UserID: 2, Event: "newMessage", Attempts: tt.attempts})
+
+			if len(next.published) != 0 {
+				t.Errorf("the sink after the failing one got %d events, want none", len(next.published))
+			}
+			if delivered := fake.Executed("SET delivered_at"); len(delivered) != 0 {
+				t.Errorf("delivered = %v, want none", delivered)
+			}
+
+			failed := fake.Executed("last_error")
+			if len(failed) != 1 {
+				t.Fatalf("%d failures recorded, want 1", len(failed))
+			}
+			args := failed[0].Args
+			if args[0] != int64(4) || args[1] != "update log unavailable" || args[3] != tt.giveUp {
+				t.Errorf("failure recorded with %v, want event 4, the sink error and giveUp %t", args, tt.giveUp)
+			}
+			nextAttempt, _ := args[2].(time.Time)
+			if delay := nextAttempt.Sub(start); delay < tt.delay || delay > tt.delay+time.Second {
+				t.Errorf("next attempt in %s, want %s", delay, tt.delay)
+			}
+		})
+	}
+}
+
+func TestOutboxRelayClaimsDueEventsInOrder(t *testing.T) {
+	db, fake := fakedb.Open(t, func(query string, args []driver.Value) fakedb.Result {
+		if strings.Contains(query, "RETURNING id, user_id") {
+			result := fakedb.Result{Columns: []string{"id", "user_id", "event", "payload", "attempts", "created_at"}}
+			// RETURNING doesn't keep the order of the subquery
+			for _, id := range []int64{3, 1, 2} {
+				result.Rows = append(result.Rows, []driver.Value{id, int64(7), "newMessage", []byte(`{}`), int64(0), time.Now()})
+			}
+			return result
+		}
+		return fakedb.Result{Affected: 1}
+	})
+	sink := &recordingSink{}
+	relay := NewOutboxRelay(repository.NewOutboxRepository(db), sink)
+
+	relay.relay()
+
+	claims := fake.Executed("FOR UPDATE SKIP LOCKED")
+	if len(claims) != 1 {
+		t.Fatalf("%d claims, want 1 since the batch wasn't full", len(claims))
+	}
+	if claims[0].Args[0] != int64(OutboxRelayBatch) || claims[0].Args[1] != OutboxLease.Milliseconds() {
+		t.Errorf("claimed with %v, want a batch of %d leased for %s", claims[0].Args, OutboxRelayBatch, OutboxLease)
+	}
+
+	ids := make([]uint64, 0, len(sink.published))
+	for _, event := range sink.published {
+		ids = append(ids, event.ID)
+	}
+	if fmt.Sprint(ids) != "[1 2 3]" {
+		t.Errorf("published %v, want [1 2 3]", ids)
+	}
+	if delivered := fake.Executed("SET delivered_at"); len(delivered) != 3 {
+		t.Errorf("%d events marked delivered, want 3", len(delivered))
+	}
+}
+
+// recordingSink keeps the events it publishes, or fails with err.
+type recordingSink struct {
+	err       error
+	published []*models.OutboxEvent
+}
+
+func (s *recordingSink) Publish(event *models.OutboxEvent) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.published = append(s.published, event)
+	return nil
+}
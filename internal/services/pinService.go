@@ -0,0 +1,93 @@
+package services
+
+import (
+	"database/sql"
+	"github.com/drTragger/messenger-backend/internal/models"
+	"github.com/drTragger/messenger-backend/internal/repository"
+	"github.com/drTragger/messenger-backend/internal/websocket"
+)
+
+// PinService pins and unpins messages. Every change puts a PinChangeEvent for the other participant
+// of the chat in the outbox, in the transaction of the change.
+type PinService struct {
+	DB         *sql.DB
+	PinRepo    *repository.PinnedMessageRepository
+	OutboxRepo *repository.OutboxRepository
+}
+
+func NewPinService(db *sql.DB, pinRepo *repository.PinnedMessageRepository, outboxRepo *repository.OutboxRepository) *PinService {
+	return &PinService{
+		DB:         db,
+		PinRepo:    pinRepo,
+		OutboxRepo: outboxRepo,
+	}
+}
+
+// Pin pins a message of the chat on behalf of userID.
+func (s *PinService) Pin(chat *models.Chat, userID uint, message *models.Message, notify bool) (*models.PinnedMessage, error) {
+	var pin *models.PinnedMessage
+	err := repository.RunInTx(s.DB, func(tx *sql.Tx) error {
+		var err error
+		pin, err = s.PinRepo.WithTx(tx).Pin(&models.PinnedMessage{
+			ChatID:    chat.ID,
+			MessageID: message.ID,
+			PinnedBy:  userID,
+			Notified:  notify,
+		})
+		if err != nil {
+			return err
+		}
+		pin.Message = message
+
+		// The other participant never saw a shadowed message, so they don't hear about it being pinned
+		if message.Shadowed {
+			return nil
+		}
+		change := websocket.NewPinChange(chat.ID, websocket.PinAction, &message.ID, pin, pin.Notified)
+		return s.announce(tx, chat, userID, change)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pin, nil
+}
+
+// Unpin unpins a message of the chat and reports whether it was pinned.
+func (s *PinService) Unpin(chat *models.Chat, userID, messageID uint) (bool, error) {
+	unpinned := false
+	err := repository.RunInTx(s.DB, func(tx *sql.Tx) error {
+		var shadowed bool
+		var err error
+		unpinned, shadowed, err = s.PinRepo.WithTx(tx).Unpin(chat.ID, messageID)
+		if err != nil || !unpinned || shadowed {
+			return err
+		}
+
+		return s.announce(tx, chat, userID, websocket.NewPinChange(chat.ID, websocket.UnpinAction, &messageID, nil, false))
+	})
+	return unpinned, err
+}
+
+// UnpinAll unpins every message of the chat. The other participant only hears about it
+// if one of the pins was of a message they can see.
+func (s *PinService) UnpinAll(chat *models.Chat, userID uint) error {
+	return repository.RunInTx(s.DB, func(tx *sql.Tx) error {
+		visible, err := s.PinRepo.WithTx(tx).UnpinAll(chat.ID)
+		if err != nil || visible == 0 {
+			return err
+		}
+
+		return s.announce(tx, chat, userID, websocket.NewPinChange(chat.ID, websocket.UnpinAllAction, nil, nil, false))
+	})
+}
+
+// announce puts a pin change made by userID in the outbox for the other participant of the chat.
+func (s *PinService) announce(tx *sql.Tx, chat *models.Chat, userID uint, change *websocket.PinChange) error {
+	recipientID := chat.User1ID
+	if recipientID == userID {
+		recipientID = chat.User2ID
+	}
+
+	return s.OutboxRepo.WithTx(tx).Create(recipientID, string(websocket.PinChangeEvent), change)
+}
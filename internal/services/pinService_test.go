@@ -0,0 +1,137 @@
+package services
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"github.com/drTragger/messenger-backend/internal/fakedb"
+	"github.com/drTragger/messenger-backend/internal/models"
+	"github.com/drTragger/messenger-backend/internal/repository"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPinServicePin(t *testing.T) {
+	tests := []struct {
+		name     string
+		notify   bool
+		shadowed bool
+		events   []string
+	}{
+		{name: "with a notification", notify: true, events: []string{"2 notify true silent false"}},
+		{name: "without a notification", events: []string{"2 notify false silent true"}},
+		{name: "shadowed message", notify: true, shadowed: true, events: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := fakedb.Open(t, func(query string, args []driver.Value) fakedb.Result {
+				if strings.Contains(query, "INSERT INTO pinned_messages") {
+					return fakedb.Row(int64(3), time.Now())
+				}
+				return fakedb.Result{Affected: 1}
+			})
+			service := NewPinService(db, repository.NewPinnedMessageRepository(db), repository.NewOutboxRepository(db))
+
+			chat := &models.Chat{ID: 7, User1ID: 1, User2ID: 2}
+			message := &models.Message{ID: 40, SenderID: 1, RecipientID: 2, ChatID: 7, Shadowed: tt.shadowed}
+			if _, err := service.Pin(chat, 1, message, tt.notify); err != nil {
+				t.Fatalf("Pin() error = %v", err)
+			}
+
+			if events := pinEvents(t, fake); fmt.Sprint(events) != fmt.Sprint(tt.events) {
+				t.Errorf("outbox events = %v, want %v", events, tt.events)
+			}
+		})
+	}
+}
+
+func TestPinServiceUnpin(t *testing.T) {
+	tests := []struct {
+		name     string
+		result   fakedb.Result
+		unpinned bool
+		events   []string
+	}{
+		{name: "pinned message", result: fakedb.Row(false), unpinned: true, events: []string{"2 notify false silent true"}},
+		{name: "shadowed message", result: fakedb.Row(true), unpinned: true, events: []string{}},
+		{name: "message that isn't pinned", result: fakedb.Result{}, events: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := fakedb.Open(t, func(query string, args []driver.Value) fakedb.Result {
+				if strings.Contains(query, "DELETE FROM pinned_messages") {
+					return tt.result
+				}
+				return fakedb.Result{Affected: 1}
+			})
+			service := NewPinService(db, repository.NewPinnedMessageRepository(db), repository.NewOutboxRepository(db))
+
+			unpinned, err := service.Unpin(&models.Chat{ID: 7, User1ID: 1, User2ID: 2}, 1, 40)
+			if err != nil {
+				t.Fatalf("Unpin() error = %v", err)
+			}
+			if unpinned != tt.unpinned {
+				t.Errorf("Unpin() = %t, want %t", unpinned, tt.unpinned)
+			}
+			if events := pinEvents(t, fake); fmt.Sprint(events) != fmt.Sprint(tt.events) {
+				t.Errorf("outbox events = %v, want %v", events, tt.events)
+			}
+		})
+	}
+}
+
+func TestPinServiceUnpinAll(t *testing.T) {
+	tests := []struct {
+		name     string
+		shadowed []bool
+		events   []string
+	}{
+		{name: "some shadowed messages", shadowed: []bool{true, false}, events: []string{"2 notify false silent true"}},
+		{name: "only shadowed messages", shadowed: []bool{true, true}, events: []string{}},
+		{name: "nothing pinned", events: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := fakedb.Open(t, func(query string, args []driver.Value) fakedb.Result {
+				if strings.Contains(query, "DELETE FROM pinned_messages") {
+					result := fakedb.Result{Columns: []string{"shadowed"}}
+					for _, shadowed := range tt.shadowed {
+						result.Rows = append(result.Rows, []driver.Value{shadowed})
+					}
+					return result
+				}
+				return fakedb.Result{Affected: 1}
+			})
+			service := NewPinService(db, repository.NewPinnedMessageRepository(db), repository.NewOutboxRepository(db))
+
+			if err := service.UnpinAll(&models.Chat{ID: 7, User1ID: 1, User2ID: 2}, 1); err != nil {
+				t.Fatalf("UnpinAll() error = %v", err)
+			}
+			if events := pinEvents(t, fake); fmt.Sprint(events) != fmt.Sprint(tt.events) {
+				t.Errorf("outbox events = %v, want %v", events, tt.events)
+			}
+		})
+	}
+}
+
+// pinEvents describes the pin changes put in the outbox by their user and their notify and silent flags.
+func pinEvents(t *testing.T, fake *fakedb.Database) []string {
+	t.Helper()
+
+	events := make([]string, 0)
+	for _, event := range fake.Executed("INSERT INTO outbox_events") {
+		var change struct {
+			Notify bool `json:"notify"`
+			Silent bool `json:"silent"`
+		}
+		if err := json.Unmarshal(event.Args[2].([]byte), &change); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, fmt.Sprintf("%v notify %t silent %t", event.Args[0], change.Notify, change.Silent))
+	}
+	return events
+}
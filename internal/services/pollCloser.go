@@ -0,0 +1,55 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+const (
+	PollCloseInterval = 5 * time.Second
+	PollCloseBatch    = 100
+)
+
+// PollCloser locks the results of polls once their close time has passed and pushes the final results to the chat.
+type PollCloser struct {
+	PollService *PollService
+	Interval    time.Duration
+}
+
+func NewPollCloser(pollService *PollService) *PollCloser {
+	return &PollCloser{
+		PollService: pollService,
+		Interval:    PollCloseInterval,
+	}
+}
+
+// Run closes due polls every Interval until the context is cancelled.
+func (c *PollCloser) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.closeDue()
+		}
+	}
+}
+
+func (c *PollCloser) closeDue() {
+	for {
+		// The final results are put in the outbox in the transaction that closes the polls
+		closed, err := c.PollService.CloseDue(PollCloseBatch)
+		if err != nil {
+			log.Printf("Error closing due polls: %s", err)
+			return
+		}
+
+		if closed < PollCloseBatch {
+			return
+		}
+	}
+}
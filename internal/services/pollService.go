@@ -0,0 +1,156 @@
+package services
+
+import (
+	"database/sql"
+	"github.com/drTragger/messenger-backend/internal/models"
+	"github.com/drTragger/messenger-backend/internal/repository"
+	"github.com/drTragger/messenger-backend/internal/websocket"
+)
+
+// PollService creates polls and changes their votes, each in a transaction of its own.
+// Every change puts a PollUpdateEvent for both chat participants in the outbox.
+type PollService struct {
+	DB         *sql.DB
+	PollRepo   *repository.PollRepository
+	MsgRepo    *repository.MessageRepository
+	OutboxRepo *repository.OutboxRepository
+	MsgService *MessageService
+}
+
+func NewPollService(
+	db *sql.DB,
+	pollRepo *repository.PollRepository,
+	msgRepo *repository.MessageRepository,
+	outboxRepo *repository.OutboxRepository,
+	msgService *MessageService,
+) *PollService {
+	return &PollService{
+		DB:         db,
+		PollRepo:   pollRepo,
+		MsgRepo:    msgRepo,
+		OutboxRepo: outboxRepo,
+		MsgService: msgService,
+	}
+}
+
+// Create sends a poll message. The message, the poll and its options are stored in one transaction,
+// after the question and the options went through the moderation filters.
+func (s *PollService) Create(message *models.Message, poll *models.Poll, locale string) error {
+	text := poll.Question
+	for _, option := range poll.Options {
+		text += "\n" + option.Text
+	}
+
+	return s.MsgService.SendTyped(message, text, locale, func(tx *sql.Tx) error {
+		poll.MessageID = message.ID
+		if _, err := s.PollRepo.WithTx(tx).Create(poll); err != nil {
+			return err
+		}
+		message.Poll = poll
+		return nil
+	})
+}
+
+// Vote replaces the votes of a user in the poll of the message and returns the updated poll.
+func (s *PollService) Vote(message *models.Message, userID uint, optionIDs []uint) (*models.Poll, error) {
+	var poll *models.Poll
+	err := repository.RunInTx(s.DB, func(tx *sql.Tx) error {
+		pollRepo := s.PollRepo.WithTx(tx)
+		if err := pollRepo.Vote(message.Poll.ID, userID, optionIDs); err != nil {
+			return err
+		}
+
+		var err error
+		poll, err = pollRepo.GetByID(message.Poll.ID)
+		if err != nil {
+			return err
+		}
+		return s.announceUpdate(tx, message, poll)
+	})
+	return poll, err
+}
+
+// Retract removes the votes of a user from the poll of the message and returns the updated poll,
+// or nil if the user had not voted.
+func (s *PollService) Retract(message *models.Message, userID uint) (*models.Poll, error) {
+	var poll *models.Poll
+	err := repository.RunInTx(s.DB, func(tx *sql.Tx) error {
+		pollRepo := s.PollRepo.WithTx(tx)
+		retracted, err := pollRepo.Retract(message.Poll.ID, userID)
+		if err != nil || !retracted {
+			return err
+		}
+
+		poll, err = pollRepo.GetByID(message.Poll.ID)
+		if err != nil {
+			return err
+		}
+		return s.announceUpdate(tx, message, poll)
+	})
+	return poll, err
+}
+
+// Close locks the results of the poll of the message and returns the final poll, or nil if it was already closed.
+func (s *PollService) Close(message *models.Message) (*models.Poll, error) {
+	var poll *models.Poll
+	err := repository.RunInTx(s.DB, func(tx *sql.Tx) error {
+		pollRepo := s.PollRepo.WithTx(tx)
+		closed, err := pollRepo.Close(message.Poll.ID)
+		if err != nil || !closed {
+			return err
+		}
+
+		poll, err = pollRepo.GetByID(message.Poll.ID)
+		if err != nil {
+			return err
+		}
+		return s.announceUpdate(tx, message, poll)
+	})
+	return poll, err
+}
+
+// CloseDue closes up to limit polls whose close time has passed in one transaction and returns how many it closed.
+func (s *PollService) CloseDue(limit int) (int, error) {
+	closed := 0
+	err := repository.RunInTx(s.DB, func(tx *sql.Tx) error {
+		messageIDs, err := s.PollRepo.WithTx(tx).CloseDue(limit)
+		if err != nil {
+			return err
+		}
+		closed = len(messageIDs)
+
+		msgRepo := s.MsgRepo.WithTx(tx)
+		for _, messageID := range messageIDs {
+			message, err := msgRepo.GetById(messageID)
+			if err != nil {
+				return err
+			}
+			// The message was deleted together with its poll in the meantime
+			if message == nil || message.Poll == nil {
+				continue
+			}
+			if err := s.announceUpdate(tx, message, message.Poll); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return closed, err
+}
+
+// announceUpdate puts the results of a poll in the outbox for both participants of the chat of its message,
+// or only for the sender if the message is shadowed. The answer of an open quiz stays hidden, voters get it
+// in the response to their vote.
+func (s *PollService) announceUpdate(tx *sql.Tx, message *models.Message, poll *models.Poll) error {
+	update := *poll
+	update.HideAnswer()
+
+	outboxRepo := s.OutboxRepo.WithTx(tx)
+	if err := outboxRepo.Create(message.SenderID, string(websocket.PollUpdateEvent), &update); err != nil {
+		return err
+	}
+	if message.RecipientID == message.SenderID || message.Shadowed {
+		return nil
+	}
+	return outboxRepo.Create(message.RecipientID, string(websocket.PollUpdateEvent), &update)
+}
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/drTragger/messenger-backend/internal/models"
+	"github.com/drTragger/messenger-backend/internal/repository"
+	"github.com/drTragger/messenger-backend/internal/storage"
+	"github.com/drTragger/messenger-backend/internal/websocket"
+	"log"
+	"time"
+)
+
+var ErrReportClosed = errors.New("report is no longer open")
+
+// ReportService files reports with a snapshot of the reported message and carries out the moderators' decisions.
+type ReportService struct {
+	DB              *sql.DB
+	ReportRepo      *repository.ReportRepository
+	RestrictionRepo *repository.RestrictionRepository
+	MsgRepo         *repository.MessageRepository
+	MsgService      *MessageService
+	OutboxRepo      *repository.OutboxRepository
+	ClientManager   *websocket.ClientManager
+	Storage         storage.Storage
+}
+
+func NewReportService(
+	db *sql.DB,
+	reportRepo *repository.ReportRepository,
+	restrictionRepo *repository.RestrictionRepository,
+	msgRepo *repository.MessageRepository,
+	msgService *MessageService,
+	outboxRepo *repository.OutboxRepository,
+	clientManager *websocket.ClientManager,
+	storage storage.Storage,
+) *ReportService {
+	return &ReportService{
+		DB:              db,
+		ReportRepo:      reportRepo,
+		RestrictionRepo: restrictionRepo,
+		MsgRepo:         msgRepo,
+		MsgService:      msgService,
+		OutboxRepo:      outboxRepo,
+		ClientManager:   clientManager,
+		Storage:         storage,
+	}
+}
+
+// Create stores a report. For a reported message, its files are copied to the evidence directory
+// and the snapshot points to the copies, since the originals are deleted with the message.
+func (s *ReportService) Create(report *models.Report, message *models.Message) error {
+	if message != nil {
+		snapshot := *message
+		attachments, err := s.copyEvidence(message.Attachments)
+		if err != nil {
+			return err
+		}
+		snapshot.Attachments = attachments
+		report.Snapshot = &snapshot
+	}
+
+	if err := s.ReportRepo.Create(report); err != nil {
+		if report.Snapshot != nil {
+			s.deleteEvidence(report.Snapshot.Attachments)
+		}
+		return err
+	}
+	return nil
+}
+
+// Resolve carries out the action on an open report and tells the reporter about it.
+// The report is claimed first and the action is carried out in the same transaction, so two moderators
+// can't act on the same report and a report is never closed with the content still up.
+// The warning and the resolution go to the outbox in that transaction too.
+func (s *ReportService) Resolve(report *models.Report, action string, note *string, suspendUntil *time.Time, moderatorID uint) error {
+	if report.Status != models.OpenReport {
+		return ErrReportClosed
+	}
+
+	status := models.ResolvedReport
+	if action == models.DismissAction {
+		status = models.DismissedReport
+	}
+
+	var files []string
+	err := repository.RunInTx(s.DB, func(tx *sql.Tx) error {
+		resolved, err := s.ReportRepo.WithTx(tx).Resolve(report.ID, status, action, note, moderatorID)
+		if err != nil {
+			return err
+		}
+		if !resolved {
+			return ErrReportClosed
+		}
+
+		switch action {
+		case models.DeleteContentAction:
+			if report.MessageID == nil {
+				break
+			}
+			message, err := s.MsgRepo.WithTx(tx).GetById(*report.MessageID)
+			if err != nil {
+				return err
+			}
+			// The sender may have deleted the message already
+			if message == nil {
+				break
+			}
+			files, err = s.MsgService.delete(tx, message)
+			if err != nil {
+				return err
+			}
+		case models.SuspendAction:
+			reason := report.Reason
+			if note != nil {
+				reason = *note
+			}
+			err := s.RestrictionRepo.WithTx(tx).Create(&models.Restriction{
+				UserID:    report.ReportedUserID,
+				Type:      models.SuspensionRestriction,
+				Reason:    reason,
+				ExpiresAt: *suspendUntil,
+				ReportID:  &report.ID,
+				CreatedBy: &moderatorID,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		outboxRepo := s.OutboxRepo.WithTx(tx)
+		if action == models.WarnAction {
+			warning := websocket.NewWarning(report.Reason, note, now)
+			if err := outboxRepo.Create(report.ReportedUserID, string(websocket.WarningEvent), warning); err != nil {
+				return err
+			}
+		}
+		resolution := websocket.NewReportResolution(report.ID, status, now)
+		return outboxRepo.Create(report.ReporterID, string(websocket.ReportResolvedEvent), resolution)
+	})
+	if err != nil {
+		return err
+	}
+
+	s.MsgService.deleteUnreferencedFiles(files)
+	if action == models.SuspendAction {
+		s.ClientManager.RemoveClient(report.ReportedUserID)
+	}
+
+	return nil
+}
+
+// copyEvidence copies the files of the attachments to the evidence directory.
+func (s *ReportService) copyEvidence(attachments []*models.Attachment) ([]*models.Attachment, error) {
+	copies := make([]*models.Attachment, 0, len(attachments))
+	for _, attachment := range attachments {
+		evidencePath, err := s.Storage.CopyFile(storage.MessageAttachmentsDir, attachment.FilePath, storage.ReportEvidenceDir)
+		if err != nil {
+			s.deleteEvidence(copies)
+			return nil, fmt.Errorf("failed to copy file: %w", err)
+		}
+
+		evidence := *attachment
+		evidence.FilePath = evidencePath
+		copies = append(copies, &evidence)
+	}
+	return copies, nil
+}
+
+func (s *ReportService) deleteEvidence(attachments []*models.Attachment) {
+	for _, attachment := range attachments {
+		if err := s.Storage.DeleteFile(storage.ReportEvidenceDir, attachment.FilePath); err != nil {
+			log.Printf("Error deleting evidence file %s: %s", attachment.FilePath, err.Error())
+		}
+	}
+}
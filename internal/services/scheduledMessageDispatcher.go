@@ -0,0 +1,62 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+const (
+	ScheduledDispatchInterval = 5 * time.Second
+	ScheduledDispatchBatch    = 100
+)
+
+// ScheduledMessageDispatcher delivers scheduled messages once they are due.
+// Messages are claimed with FOR UPDATE SKIP LOCKED, so several instances can run at once.
+type ScheduledMessageDispatcher struct {
+	MsgService     *MessageService
+	PreviewService *LinkPreviewService
+	Interval       time.Duration
+}
+
+func NewScheduledMessageDispatcher(msgService *MessageService, previewService *LinkPreviewService) *ScheduledMessageDispatcher {
+	return &ScheduledMessageDispatcher{
+		MsgService:     msgService,
+		PreviewService: previewService,
+		Interval:       ScheduledDispatchInterval,
+	}
+}
+
+// Run dispatches due messages every Interval until the context is cancelled.
+func (d *ScheduledMessageDispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.dispatch()
+		}
+	}
+}
+
+func (d *ScheduledMessageDispatcher) dispatch() {
+	for {
+		// The messages are announced through the outbox in the transaction that claims them
+		messages, err := d.MsgService.DispatchDue(ScheduledDispatchBatch)
+		if err != nil {
+			log.Printf("Error dispatching scheduled messages: %s", err)
+			return
+		}
+
+		for _, message := range messages {
+			go d.PreviewService.Process(message)
+		}
+
+		if len(messages) < ScheduledDispatchBatch {
+			return
+		}
+	}
+}
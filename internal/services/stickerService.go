@@ -0,0 +1,120 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/drTragger/messenger-backend/internal/models"
+	"github.com/drTragger/messenger-backend/internal/repository"
+	"github.com/drTragger/messenger-backend/internal/storage"
+	"log"
+	"mime/multipart"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	MaxStickersPerPack = 120
+	MaxStickerSize     = 512 << 10 // 512 KB
+)
+
+var stickerExtensions = map[string]bool{
+	".webp": true,
+	".png":  true,
+	".webm": true,
+	".tgs":  true,
+}
+
+var (
+	ErrStickerFile   = errors.New("stickers must be WEBP, PNG, WEBM or TGS files of up to 512 KB")
+	ErrPackNameTaken = errors.New("sticker pack name is already taken")
+)
+
+type StickerService struct {
+	DB          *sql.DB
+	StickerRepo *repository.StickerRepository
+	Storage     storage.Storage
+}
+
+func NewStickerService(db *sql.DB, stickerRepo *repository.StickerRepository, storage storage.Storage) *StickerService {
+	return &StickerService{
+		DB:          db,
+		StickerRepo: stickerRepo,
+		Storage:     storage,
+	}
+}
+
+// CreatePack stores the sticker files and creates the pack. emojis[i] lists the emojis of files[i]
+// separated by spaces. Stored files are removed again if the pack can't be created.
+// It returns ErrPackNameTaken if another pack got the name first.
+func (s *StickerService) CreatePack(pack *models.StickerPack, files []*multipart.FileHeader, emojis []string) (*models.StickerPack, error) {
+	for _, fh := range files {
+		if !stickerExtensions[strings.ToLower(filepath.Ext(fh.Filename))] || fh.Size > MaxStickerSize {
+			return nil, ErrStickerFile
+		}
+	}
+
+	pack.Stickers = make([]*models.Sticker, 0, len(files))
+	for i, fh := range files {
+		filePath, err := s.saveSticker(fh)
+		if err != nil {
+			s.deleteStickers(pack.Stickers)
+			return nil, err
+		}
+
+		sticker := &models.Sticker{
+			FilePath: filePath,
+			Emojis:   make([]string, 0),
+			Position: i,
+		}
+		if i < len(emojis) {
+			sticker.Emojis = strings.Fields(emojis[i])
+		}
+		pack.Stickers = append(pack.Stickers, sticker)
+	}
+
+	err := repository.RunInTx(s.DB, func(tx *sql.Tx) error {
+		_, err := s.StickerRepo.WithTx(tx).CreatePack(pack)
+		return err
+	})
+	if err != nil {
+		s.deleteStickers(pack.Stickers)
+		if repository.IsUniqueViolation(err) {
+			return nil, ErrPackNameTaken
+		}
+		return nil, err
+	}
+
+	return pack, nil
+}
+
+// Reorder arranges the user's installed packs in the given order.
+// It returns repository.ErrPackNotInstalled, and moves nothing, if a pack is not installed.
+func (s *StickerService) Reorder(userID uint, packIDs []uint) error {
+	return repository.RunInTx(s.DB, func(tx *sql.Tx) error {
+		return s.StickerRepo.WithTx(tx).Reorder(userID, packIDs)
+	})
+}
+
+func (s *StickerService) saveSticker(fh *multipart.FileHeader) (string, error) {
+	file, err := fh.Open()
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	filePath, err := s.Storage.SaveFile(storage.StickersDir, strings.ToLower(fh.Filename), file)
+	if err != nil {
+		return "", fmt.Errorf("failed to save file: %w", err)
+	}
+
+	return filePath, nil
+}
+
+func (s *StickerService) deleteStickers(stickers []*models.Sticker) {
+	for _, sticker := range stickers {
+		if err := s.Storage.DeleteFile(storage.StickersDir, sticker.FilePath); err != nil {
+			log.Printf("Error deleting sticker file %s: %s", sticker.FilePath, err)
+		}
+	}
+}
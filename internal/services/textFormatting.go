@@ -0,0 +1,250 @@
+package services
+
+import (
+	"errors"
+	"github.com/drTragger/messenger-backend/internal/models"
+	"github.com/drTragger/messenger-backend/internal/utils"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+const MarkdownParseMode = "markdown"
+
+var (
+	ErrEntityOutOfRange = errors.New("entity is out of the text range")
+	ErrEntityType       = errors.New("unsupported entity type")
+	ErrEntityURL        = errors.New("text link entity requires an http or https URL")
+	ErrEntityLanguage   = errors.New("only pre entities can have a language")
+)
+
+var markdownLinkRegex = regexp.MustCompile(`^\[([^\]\n]+)\]\(([^)\s]+)\)`)
+
+// markdownMarkers lists the inline markers of the supported Markdown subset.
+// Two-character markers come first so that ** is not read as two italic markers.
+var markdownMarkers = []struct {
+	marker     string
+	entityType string
+}{
+	{"**", models.BoldEntity},
+	{"__", models.UnderlineEntity},
+	{"~~", models.StrikethroughEntity},
+	{"||", models.SpoilerEntity},
+	{"*", models.ItalicEntity},
+	{"_", models.ItalicEntity},
+}
+
+const markdownEscapable = "\\*_~|`[]()"
+
+var formattingEntityTypes = map[string]bool{
+	models.BoldEntity:          true,
+	models.ItalicEntity:        true,
+	models.UnderlineEntity:     true,
+	models.StrikethroughEntity: true,
+	models.CodeEntity:          true,
+	models.PreEntity:           true,
+	models.TextLinkEntity:      true,
+	models.SpoilerEntity:       true,
+}
+
+// ParseMarkdown converts text written in the supported Markdown subset into plain text and formatting entities:
+// **bold**, *italic* or _italic_, __underline__, ~~strikethrough~~, ||spoiler||, `code`,
+// ```language\npre```, and [text](url). Markers can be escaped with a backslash,
+// and markers that are never closed are kept as plain text.
+func ParseMarkdown(input string) (string, []*models.MessageEntity) {
+	var out strings.Builder
+	pos := 0
+	entities := make([]*models.MessageEntity, 0)
+	open := make(map[string]int)
+
+	emit := func(s string) {
+		out.WriteString(s)
+		pos += utils.UTF16Length(s)
+	}
+	addEntity := func(entityType string, start int) *models.MessageEntity {
+		if pos <= start {
+			return nil
+		}
+		entity := &models.MessageEntity{Type: entityType, Offset: start, Length: pos - start}
+		entities = append(entities, entity)
+		return entity
+	}
+
+	for i := 0; i < len(input); {
+		rest := input[i:]
+
+		switch {
+		case rest[0] == '\\' && len(rest) > 1 && strings.IndexByte(markdownEscapable, rest[1]) >= 0:
+			emit(rest[1:2])
+			i += 2
+
+		case strings.HasPrefix(rest, "```"):
+			end := strings.Index(rest[3:], "```")
+			if end < 0 {
+				emit("```")
+				i += 3
+				continue
+			}
+
+			block := rest[3 : 3+end]
+			var language *string
+			if nl := strings.IndexByte(block, '\n'); nl >= 0 {
+				header := strings.TrimSpace(block[:nl])
+				if !strings.ContainsAny(header, " \t") {
+					if header != "" {
+						language = &header
+					}
+					block = block[nl+1:]
+				}
+			}
+			block = strings.TrimSuffix(block, "\n")
+
+			start := pos
+			emit(block)
+			if entity := addEntity(models.PreEntity, start); entity != nil {
+				entity.Language = language
+			}
+			i += end + 6
+
+		case rest[0] == '`':
+			end := strings.IndexByte(rest[1:], '`')
+			if end <= 0 {
+				emit("`")
+				i++
+				continue
+			}
+
+			start := pos
+			emit(rest[1 : 1+end])
+			addEntity(models.CodeEntity, start)
+			i += end + 2
+
+		case rest[0] == '[' && markdownLinkRegex.MatchString(rest):
+			match := markdownLinkRegex.FindStringSubmatch(rest)
+			start := pos
+			emit(match[1])
+			if entity := addEntity(models.TextLinkEntity, start); entity != nil {
+				link := match[2]
+				entity.URL = &link
+			}
+			i += len(match[0])
+
+		default:
+			marker, entityType := matchMarkdownMarker(rest)
+			if marker == "" {
+				_, size := utf8.DecodeRuneInString(rest)
+				emit(rest[:size])
+				i += size
+				continue
+			}
+
+			if start, ok := open[marker]; ok {
+				delete(open, marker)
+				addEntity(entityType, start)
+			} else if strings.Contains(rest[len(marker):], marker) {
+				open[marker] = pos
+			} else {
+				emit(marker)
+			}
+			i += len(marker)
+		}
+	}
+
+	text := out.String()
+	if len(open) > 0 {
+		text = restoreMarkers(text, open, entities)
+	}
+
+	sortEntities(entities)
+	return text, entities
+}
+
+func matchMarkdownMarker(s string) (string, string) {
+	for _, m := range markdownMarkers {
+		if strings.HasPrefix(s, m.marker) {
+			return m.marker, m.entityType
+		}
+	}
+	return "", ""
+}
+
+// restoreMarkers puts markers that turned out to be unclosed back into the text and shifts the entities accordingly.
+func restoreMarkers(text string, open map[string]int, entities []*models.MessageEntity) string {
+	markers := make([]string, 0, len(open))
+	for marker := range open {
+		markers = append(markers, marker)
+	}
+	// Insert from the end, so earlier insertions do not move the positions of later ones
+	sort.Slice(markers, func(i, j int) bool {
+		return open[markers[i]] > open[markers[j]]
+	})
+
+	units := utf16.Encode([]rune(text))
+	for _, marker := range markers {
+		offset := open[marker]
+		inserted := utf16.Encode([]rune(marker))
+
+		units = append(units[:offset], append(inserted, units[offset:]...)...)
+		for _, entity := range entities {
+			if entity.Offset >= offset {
+				entity.Offset += len(inserted)
+			} else if entity.Offset+entity.Length > offset {
+				entity.Length += len(inserted)
+			}
+		}
+	}
+
+	return string(utf16.Decode(units))
+}
+
+// ValidateEntities checks that the formatting entities submitted by a client fit into the text
+// and carry the data their type requires.
+func ValidateEntities(text string, entities []*models.MessageEntity) error {
+	length := utils.UTF16Length(text)
+
+	for _, entity := range entities {
+		if !formattingEntityTypes[entity.Type] {
+			return ErrEntityType
+		}
+		if entity.Offset < 0 || entity.Length <= 0 || entity.Offset+entity.Length > length {
+			return ErrEntityOutOfRange
+		}
+		if entity.Type == models.TextLinkEntity {
+			if entity.URL == nil {
+				return ErrEntityURL
+			}
+			link, err := url.Parse(*entity.URL)
+			if err != nil || (link.Scheme != "http" && link.Scheme != "https") || link.Host == "" {
+				return ErrEntityURL
+			}
+		} else {
+			entity.URL = nil
+		}
+		if entity.Language != nil && entity.Type != models.PreEntity {
+			return ErrEntityLanguage
+		}
+	}
+
+	return nil
+}
+
+// MergeEntities joins formatting entities with the mentions detected by the server, ordered by offset.
+func MergeEntities(formatting, mentions []*models.MessageEntity) []*models.MessageEntity {
+	entities := make([]*models.MessageEntity, 0, len(formatting)+len(mentions))
+	entities = append(entities, formatting...)
+	entities = append(entities, mentions...)
+	sortEntities(entities)
+	return entities
+}
+
+func sortEntities(entities []*models.MessageEntity) {
+	sort.SliceStable(entities, func(i, j int) bool {
+		if entities[i].Offset != entities[j].Offset {
+			return entities[i].Offset < entities[j].Offset
+		}
+		return entities[i].Length > entities[j].Length
+	})
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"github.com/drTragger/messenger-backend/internal/models"
+	"testing"
+)
+
+func TestParseMarkdown(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		text     string
+		entities []string
+	}{
+		{name: "plain text", input: "Hello", text: "Hello", entities: []string{}},
+		{name: "inline markers", input: "**bold** and _it_", text: "bold and it", entities: []string{"bold 0 4", "italic 9 2"}},
+		{name: "every marker", input: "__u__ ~~s~~ ||x|| *i*", text: "u s x i", entities: []string{"underline 0 1", "strikethrough 2 1", "spoiler 4 1", "italic 6 1"}},
+		{name: "offsets after an emoji", input: "😀 **hi**", text: "😀 hi", entities: []string{"bold 3 2"}},
+		{name: "astral characters inside", input: "**𝕏😀**", text: "𝕏😀", entities: []string{"bold 0 4"}},
+		{name: "emoji with a skin tone", input: "👍🏽 ||yes||", text: "👍🏽 yes", entities: []string{"spoiler 5 3"}},
+		{name: "nested", input: "**bold _both_**", text: "bold both", entities: []string{"bold 0 9", "italic 5 4"}},
+		{name: "marker without a closing one", input: "2 * 3 = 6", text: "2 * 3 = 6", entities: []string{}},
+		{name: "unclosed marker inside an entity", input: "**a *b**", text: "a *b", entities: []string{"bold 0 4"}},
+		{name: "unclosed marker after an emoji", input: "😀 _a **b**", text: "😀 _a b", entities: []string{"bold 6 1"}},
+		{name: "empty entity", input: "****", text: "", entities: []string{}},
+		{name: "escapes", input: `\*not italic\* \[x\]`, text: "*not italic* [x]", entities: []string{}},
+		{name: "backslash before a letter", input: `a\b`, text: `a\b`, entities: []string{}},
+		{name: "code keeps markers", input: "`a*b*` *c*", text: "a*b* c", entities: []string{"code 0 4", "italic 5 1"}},
+		{name: "unclosed code", input: "`a", text: "`a", entities: []string{}},
+		{name: "pre with a language", input: "```go\nfmt.Println()\n```", text: "fmt.Println()", entities: []string{"pre 0 13 go"}},
+		{name: "pre without a language", input: "```x := 1```", text: "x := 1", entities: []string{"pre 0 6"}},
+		{name: "link", input: "see [site](https://example.com)", text: "see site", entities: []string{"text_link 4 4 https://example.com"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, entities := ParseMarkdown(tt.input)
+			if text != tt.text {
+				t.Errorf("text = %q, want %q", text, tt.text)
+			}
+			if got := describeEntities(entities); fmt.Sprint(got) != fmt.Sprint(tt.entities) {
+				t.Errorf("entities = %v, want %v", got, tt.entities)
+			}
+		})
+	}
+}
+
+func TestValidateEntities(t *testing.T) {
+	link := "https://example.com"
+	ftp := "ftp://example.com"
+	language := "go"
+
+	// "Hello 😀" is 8 UTF-16 code units long
+	tests := []struct {
+		name     string
+		entities []*models.MessageEntity
+		err      error
+	}{
+		{name: "entity ending at the emoji", entities: []*models.MessageEntity{{Type: models.BoldEntity, Offset: 6, Length: 2}}},
+		{name: "overlapping entities", entities: []*models.MessageEntity{
+			{Type: models.BoldEntity, Offset: 0, Length: 5},
+			{Type: models.ItalicEntity, Offset: 2, Length: 6},
+		}},
+		{name: "past the end", entities: []*models.MessageEntity{{Type: models.BoldEntity, Offset: 6, Length: 3}}, err: ErrEntityOutOfRange},
+		{name: "empty", entities: []*models.MessageEntity{{Type: models.BoldEntity, Offset: 1, Length: 0}}, err: ErrEntityOutOfRange},
+		{name: "negative offset", entities: []*models.MessageEntity{{Type: models.BoldEntity, Offset: -1, Length: 2}}, err: ErrEntityOutOfRange},
+		{name: "mention", entities: []*models.MessageEntity{{Type: models.MentionEntity, Offset: 0, Length: 5}}, err: ErrEntityType},
+		{name: "text link", entities: []*models.MessageEntity{{Type: models.TextLinkEntity, Offset: 0, Length: 5, URL: &link}}},
+		{name: "text link without a URL", entities: []*models.MessageEntity{{Type: models.TextLinkEntity, Offset: 0, Length: 5}}, err: ErrEntityURL},
+		{name: "text link to another scheme", entities: []*models.MessageEntity{{Type: models.TextLinkEntity, Offset: 0, Length: 5, URL: &ftp}}, err: ErrEntityURL},
+		{name: "pre with a language", entities: []*models.MessageEntity{{Type: models.PreEntity, Offset: 0, Length: 5, Language: &language}}},
+		{name: "code with a language", entities: []*models.MessageEntity{{Type: models.CodeEntity, Offset: 0, Length: 5, Language: &language}}, err: ErrEntityLanguage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateEntities("Hello 😀", tt.entities); !errors.Is(err, tt.err) {
+				t.Errorf("ValidateEntities() error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestValidateEntitiesDropsURLOfOtherTypes(t *testing.T) {
+	link := "https://example.com"
+	entity := &models.MessageEntity{Type: models.BoldEntity, Offset: 0, Length: 5, URL: &link}
+
+	if err := ValidateEntities("Hello", []*models.MessageEntity{entity}); err != nil {
+		t.Fatalf("ValidateEntities() error = %v", err)
+	}
+	if entity.URL != nil {
+		t.Errorf("URL = %q, want nil for a bold entity", *entity.URL)
+	}
+}
+
+func TestMergeEntities(t *testing.T) {
+	formatting := []*models.MessageEntity{
+		{Type: models.ItalicEntity, Offset: 8, Length: 2},
+		{Type: models.BoldEntity, Offset: 0, Length: 5},
+	}
+	mentions := []*models.MessageEntity{
+		{Type: models.MentionEntity, Offset: 3, Length: 4},
+		{Type: models.MentionEntity, Offset: 0, Length: 3},
+	}
+
+	merged := describeEntities(MergeEntities(formatting, mentions))
+	want := []string{"bold 0 5", "mention 0 3", "mention 3 4", "italic 8 2"}
+	if fmt.Sprint(merged) != fmt.Sprint(want) {
+		t.Errorf("MergeEntities() = %v, want %v", merged, want)
+	}
+}
+
+// describeEntities writes every entity as its type, offset and length, followed by its URL or language.
+func describeEntities(entities []*models.MessageEntity) []string {
+	described := make([]string, 0, len(entities))
+	for _, entity := range entities {
+		description := fmt.Sprintf("%s %d %d", entity.Type, entity.Offset, entity.Length)
+		if entity.URL != nil {
+			description += " " + *entity.URL
+		}
+		if entity.Language != nil {
+			description += " " + *entity.Language
+		}
+		described = append(described, description)
+	}
+	return described
+}
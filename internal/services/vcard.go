@@ -0,0 +1,210 @@
+package services
+
+import (
+	"errors"
+	"github.com/drTragger/messenger-backend/internal/models"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	VCard3 = "3.0"
+	VCard4 = "4.0"
+
+	// vCardLineLimit is the maximum length of a content line in octets before it has to be folded
+	vCardLineLimit = 75
+)
+
+var (
+	ErrInvalidVCard     = errors.New("invalid vCard")
+	ErrUnsupportedVCard = errors.New("unsupported vCard version")
+)
+
+// ParseVCard reads the name and the phone number of a vCard 3.0 or 4.0.
+// A mobile number is preferred when the card lists several phones.
+func ParseVCard(data string) (*models.Contact, error) {
+	lines := unfoldVCard(data)
+	if len(lines) < 2 || !strings.EqualFold(lines[0], "BEGIN:VCARD") || !strings.EqualFold(lines[len(lines)-1], "END:VCARD") {
+		return nil, ErrInvalidVCard
+	}
+
+	var version, formattedName, phone string
+	var firstName, lastName string
+	var phoneIsCell bool
+
+	for _, line := range lines[1 : len(lines)-1] {
+		name, params, value, ok := splitVCardLine(line)
+		if !ok {
+			continue
+		}
+
+		switch name {
+		case "VERSION":
+			version = value
+		case "FN":
+			formattedName = unescapeVCard(value)
+		case "N":
+			parts := splitVCardValue(value)
+			lastName = unescapeVCard(parts[0])
+			if len(parts) > 1 {
+				firstName = unescapeVCard(parts[1])
+			}
+		case "TEL":
+			isCell := strings.Contains(strings.ToUpper(params), "CELL")
+			if phone == "" || (isCell && !phoneIsCell) {
+				phone = value
+				if strings.HasPrefix(strings.ToLower(phone), "tel:") {
+					phone = phone[len("tel:"):]
+				}
+				phoneIsCell = isCell
+			}
+		}
+	}
+
+	if version != VCard3 && version != VCard4 {
+		return nil, ErrUnsupportedVCard
+	}
+
+	if firstName == "" && lastName == "" {
+		firstName = formattedName
+	}
+	if firstName == "" {
+		firstName, lastName = lastName, ""
+	}
+	if firstName == "" || phone == "" {
+		return nil, ErrInvalidVCard
+	}
+
+	contact := &models.Contact{
+		FirstName: firstName,
+		Phone:     phone,
+	}
+	if lastName != "" {
+		contact.LastName = &lastName
+	}
+
+	return contact, nil
+}
+
+// GenerateVCard builds a vCard of the given version (3.0 or 4.0) from a contact.
+func GenerateVCard(contact *models.Contact, version string) (string, error) {
+	if version != VCard3 && version != VCard4 {
+		return "", ErrUnsupportedVCard
+	}
+
+	lastName := ""
+	if contact.LastName != nil {
+		lastName = *contact.LastName
+	}
+	formattedName := strings.TrimSpace(contact.FirstName + " " + lastName)
+
+	lines := []string{
+		"BEGIN:VCARD",
+		"VERSION:" + version,
+		"N:" + escapeVCard(lastName) + ";" + escapeVCard(contact.FirstName) + ";;;",
+		"FN:" + escapeVCard(formattedName),
+	}
+	if version == VCard4 {
+		lines = append(lines, "TEL;VALUE=uri;TYPE=cell:tel:"+contact.Phone)
+	} else {
+		lines = append(lines, "TEL;TYPE=CELL:"+contact.Phone)
+	}
+	lines = append(lines, "END:VCARD")
+
+	var card strings.Builder
+	for _, line := range lines {
+		card.WriteString(foldVCardLine(line))
+		card.WriteString("\r\n")
+	}
+
+	return card.String(), nil
+}
+
+// unfoldVCard joins folded content lines and drops empty ones.
+func unfoldVCard(data string) []string {
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	data = strings.ReplaceAll(data, "\n ", "")
+	data = strings.ReplaceAll(data, "\n\t", "")
+
+	lines := make([]string, 0)
+	for _, line := range strings.Split(data, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// splitVCardLine splits a content line into its upper-cased property name without a group, parameters and value.
+func splitVCardLine(line string) (string, string, string, bool) {
+	inQuotes := false
+	for i, r := range line {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+		case r == ':' && !inQuotes:
+			name, params, _ := strings.Cut(line[:i], ";")
+			if dot := strings.LastIndexByte(name, '.'); dot >= 0 {
+				name = name[dot+1:]
+			}
+			return strings.ToUpper(name), params, line[i+1:], true
+		}
+	}
+	return "", "", "", false
+}
+
+// splitVCardValue splits a structured value at semicolons that are not escaped.
+func splitVCardValue(value string) []string {
+	parts := make([]string, 0)
+	start := 0
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			i++
+		case ';':
+			parts = append(parts, value[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, value[start:])
+}
+
+func unescapeVCard(value string) string {
+	var out strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+1 < len(value) {
+			i++
+			if value[i] == 'n' || value[i] == 'N' {
+				out.WriteByte('\n')
+			} else {
+				out.WriteByte(value[i])
+			}
+			continue
+		}
+		out.WriteByte(value[i])
+	}
+	return strings.TrimSpace(out.String())
+}
+
+func escapeVCard(value string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(value)
+}
+
+// foldVCardLine splits a long content line into chunks of at most vCardLineLimit octets without breaking UTF-8 sequences.
+func foldVCardLine(line string) string {
+	var out strings.Builder
+	limit := vCardLineLimit
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		out.WriteString(line[:cut])
+		out.WriteString("\r\n ")
+		line = line[cut:]
+		// Continuation lines start with a space that counts towards the limit
+		limit = vCardLineLimit - 1
+	}
+	out.WriteString(line)
+	return out.String()
+}
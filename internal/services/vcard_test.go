@@ -0,0 +1,143 @@
+package services
+
+import (
+	"errors"
+	"github.com/drTragger/messenger-backend/internal/models"
+	"strings"
+	"testing"
+)
+
+func TestParseVCard(t *testing.T) {
+	tests := []struct {
+		name      string
+		card      string
+		firstName string
+		lastName  string
+		phone     string
+		err       error
+	}{
+		{
+			name:      "vCard 3.0",
+			card:      "BEGIN:VCARD\r\nVERSION:3.0\r\nN:Doe;John;;;\r\nFN:John Doe\r\nTEL;TYPE=CELL:+380501234567\r\nEND:VCARD\r\n",
+			firstName: "John",
+			lastName:  "Doe",
+			phone:     "+380501234567",
+		},
+		{
+			name:      "vCard 4.0 with a tel URI",
+			card:      "BEGIN:VCARD\r\nVERSION:4.0\r\nN:Doe;John;;;\r\nTEL;VALUE=uri;TYPE=cell:tel:+380501234567\r\nEND:VCARD\r\n",
+			firstName: "John",
+			lastName:  "Doe",
+			phone:     "+380501234567",
+		},
+		{
+			name:      "folded lines",
+			card:      "BEGIN:VCARD\r\nVERSION:4.0\r\nFN:Jo\r\n hn\r\nTEL;TYPE=cell:+3805012\r\n\t34567\r\nEND:VCARD\r\n",
+			firstName: "John",
+			phone:     "+380501234567",
+		},
+		{
+			name:      "escaped separators",
+			card:      "BEGIN:VCARD\nVERSION:3.0\nN:O\\;Brien;Ann\\,Marie;;;\nTEL:+380501234567\nEND:VCARD\n",
+			firstName: "Ann,Marie",
+			lastName:  "O;Brien",
+			phone:     "+380501234567",
+		},
+		{
+			name:      "grouped properties",
+			card:      "BEGIN:VCARD\r\nVERSION:3.0\r\nitem1.N:Doe;John;;;\r\nitem2.TEL;TYPE=CELL:+380501234567\r\nitem2.X-ABLabel:mobile\r\nEND:VCARD\r\n",
+			firstName: "John",
+			lastName:  "Doe",
+			phone:     "+380501234567",
+		},
+		{
+			name:      "mobile number preferred",
+			card:      "BEGIN:VCARD\r\nVERSION:4.0\r\nFN:John\r\nTEL;TYPE=home:tel:+380441234567\r\nTEL;TYPE=\"voice,cell\":tel:+380501234567\r\nTEL;TYPE=work:tel:+380442345678\r\nEND:VCARD\r\n",
+			firstName: "John",
+			phone:     "+380501234567",
+		},
+		{
+			name:      "first number without a mobile one",
+			card:      "BEGIN:VCARD\r\nVERSION:3.0\r\nFN:John\r\nTEL;TYPE=HOME:+380441234567\r\nTEL;TYPE=WORK:+380442345678\r\nEND:VCARD\r\n",
+			firstName: "John",
+			phone:     "+380441234567",
+		},
+		{
+			name: "vCard 2.1",
+			card: "BEGIN:VCARD\r\nVERSION:2.1\r\nN:Doe;John\r\nTEL;CELL:+380501234567\r\nEND:VCARD\r\n",
+			err:  ErrUnsupportedVCard,
+		},
+		{
+			name: "without END",
+			card: "BEGIN:VCARD\r\nVERSION:4.0\r\nFN:John\r\nTEL:+380501234567\r\n",
+			err:  ErrInvalidVCard,
+		},
+		{
+			name: "without a phone",
+			card: "BEGIN:VCARD\r\nVERSION:4.0\r\nFN:John\r\nEND:VCARD\r\n",
+			err:  ErrInvalidVCard,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			contact, err := ParseVCard(tt.card)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("ParseVCard() error = %v, want %v", err, tt.err)
+			}
+			if tt.err != nil {
+				return
+			}
+			if contact.FirstName != tt.firstName || lastNameOf(contact) != tt.lastName || contact.Phone != tt.phone {
+				t.Errorf("ParseVCard() = %q %q %q, want %q %q %q",
+					contact.FirstName, lastNameOf(contact), contact.Phone, tt.firstName, tt.lastName, tt.phone)
+			}
+		})
+	}
+}
+
+func TestGenerateVCardRoundTrip(t *testing.T) {
+	lastName := "O'Brien; Smith, " + strings.Repeat("Коваленко", 10)
+	contact := &models.Contact{FirstName: "Ann,Marie", LastName: &lastName, Phone: "+380501234567"}
+
+	for _, version := range []string{VCard3, VCard4} {
+		t.Run(version, func(t *testing.T) {
+			card, err := GenerateVCard(contact, version)
+			if err != nil {
+				t.Fatalf("GenerateVCard() error = %v", err)
+			}
+
+			lines := strings.Split(strings.TrimSuffix(card, "\r\n"), "\r\n")
+			if lines[1] != "VERSION:"+version {
+				t.Errorf("version line = %q, want VERSION:%s", lines[1], version)
+			}
+			for _, line := range lines {
+				if len(line) > vCardLineLimit {
+					t.Errorf("line %q is %d octets long, want it folded", line, len(line))
+				}
+			}
+
+			parsed, err := ParseVCard(card)
+			if err != nil {
+				t.Fatalf("ParseVCard() error = %v", err)
+			}
+			if parsed.FirstName != contact.FirstName || lastNameOf(parsed) != lastName || parsed.Phone != contact.Phone {
+				t.Errorf("round trip = %q %q %q, want %q %q %q",
+					parsed.FirstName, lastNameOf(parsed), parsed.Phone, contact.FirstName, lastName, contact.Phone)
+			}
+		})
+	}
+}
+
+func TestGenerateVCardUnsupportedVersion(t *testing.T) {
+	if _, err := GenerateVCard(&models.Contact{FirstName: "John", Phone: "+380501234567"}, "2.1"); !errors.Is(err, ErrUnsupportedVCard) {
+		t.Errorf("GenerateVCard() error = %v, want %v", err, ErrUnsupportedVCard)
+	}
+}
+
+func lastNameOf(contact *models.Contact) string {
+	if contact.LastName == nil {
+		return ""
+	}
+	return *contact.LastName
+}
@@ -1,18 +1,78 @@
 package services
 
-import "github.com/drTragger/messenger-backend/internal/websocket"
+import (
+	"encoding/json"
+	"github.com/drTragger/messenger-backend/internal/models"
+	"github.com/drTragger/messenger-backend/internal/repository"
+	"github.com/drTragger/messenger-backend/internal/websocket"
+	"log"
+)
 
 type WsService struct {
 	ClientManager *websocket.ClientManager
+	UpdateRepo    *repository.UpdateRepository
 }
 
-func NewWsService(clientManager *websocket.ClientManager) *WsService {
+func NewWsService(clientManager *websocket.ClientManager, updateRepo *repository.UpdateRepository) *WsService {
 	return &WsService{
 		ClientManager: clientManager,
+		UpdateRepo:    updateRepo,
 	}
 }
 
+// SendMessage records the notification in the recipient's update log and sends it if the recipient is connected.
+// Offline recipients get the notification from the sync endpoint when they reconnect.
+// Notifications about a shadowed message are only sent to its sender, and those about a silent message are marked silent.
 func (s *WsService) SendMessage(event websocket.EventType, recipientID uint, message interface{}) {
+	about := notifiedMessage(message)
+	if about != nil && about.Shadowed && about.SenderID != recipientID {
+		return
+	}
+
 	notification := websocket.NewNotification(event, message)
+	notification.Silent = about != nil && about.Silent
+	if err := s.record(recipientID, notification); err != nil {
+		log.Printf("Error recording %s update for user %d: %s", event, recipientID, err)
+	}
 	s.ClientManager.SendMessage(recipientID, notification)
 }
+
+// Publish sends an outbox event to its user, which makes WsService a sink of the outbox relay.
+// It fails if the event can't be recorded in the update log, so that the relay retries it.
+func (s *WsService) Publish(event *models.OutboxEvent) error {
+	notification := websocket.NewNotification(websocket.EventType(event.Event), event.Payload)
+	// Only message and pin change payloads have the flag; for any other payload the notification stays audible
+	var flags struct {
+		Silent bool `json:"silent"`
+	}
+	if json.Unmarshal(event.Payload, &flags) == nil {
+		notification.Silent = flags.Silent
+	}
+	if err := s.record(event.UserID, notification); err != nil {
+		return err
+	}
+	s.ClientManager.SendMessage(event.UserID, notification)
+	return nil
+}
+
+// record appends the notification to the user's update log and sets its sequence number.
+func (s *WsService) record(userID uint, notification *websocket.Notification) error {
+	payload, err := json.Marshal(notification.Message)
+	if err != nil {
+		return err
+	}
+
+	notification.Seq, err = s.UpdateRepo.Create(userID, string(notification.Event), payload)
+	return err
+}
+
+// notifiedMessage returns the message a notification is about, or nil if its payload isn't a message.
+func notifiedMessage(payload interface{}) *models.Message {
+	switch p := payload.(type) {
+	case *models.Message:
+		return p
+	case *websocket.NewReply:
+		return p.Reply
+	}
+	return nil
+}
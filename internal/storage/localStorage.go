@@ -12,6 +12,8 @@ import (
 const (
 	ProfilePicturesDir    = "profile_pictures"
 	MessageAttachmentsDir = "message_attachments"
+	StickersDir           = "stickers"
+	ReportEvidenceDir     = "report_evidence"
 )
 
 type LocalStorage struct {
@@ -67,6 +69,22 @@ func (l *LocalStorage) GetFile(baseDir, fileName string) (string, error) {
 	return filePath, nil
 }
 
+// CopyFile copies a stored file to another directory under a new name and returns that name.
+func (l *LocalStorage) CopyFile(baseDir, fileName, targetDir string) (string, error) {
+	filePath, err := l.GetFile(baseDir, fileName)
+	if err != nil {
+		return "", err
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	return l.SaveFile(targetDir, fileName, file)
+}
+
 func (l *LocalStorage) DeleteFile(baseDir, fileName string) error {
 	filePath := l.buildFilePath(baseDir, fileName)
 
@@ -8,6 +8,7 @@ import (
 type Storage interface {
 	SaveFile(baseDir, fileName string, fileData io.Reader) (string, error)
 	GetFile(baseDir, fileName string) (string, error)
+	CopyFile(baseDir, fileName, targetDir string) (string, error)
 	DeleteFile(baseDir, fileName string) error
 }
 
@@ -29,6 +30,14 @@ var allowedExtensions = map[string]bool{
 	".xls":  true,
 	".xlsx": true,
 	".txt":  true,
+	".webp": true,
+	".webm": true,
+	".tgs":  true,
+	".ogg":  true,
+	".oga":  true,
+	".opus": true,
+	".m4a":  true,
+	".mp3":  true,
 }
 
 type Config struct {
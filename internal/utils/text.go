@@ -0,0 +1,33 @@
+package utils
+
+import (
+	"strings"
+	"unicode/utf16"
+)
+
+// Offsets and lengths exchanged with clients are measured in UTF-16 code units,
+// which is how JavaScript and most mobile platforms index strings.
+
+// UTF16Length returns the length of s in UTF-16 code units.
+func UTF16Length(s string) int {
+	return len(utf16.Encode([]rune(s)))
+}
+
+// UTF16Substring returns the part of s starting at offset with the given length,
+// both measured in UTF-16 code units. It returns false if the range is out of bounds.
+func UTF16Substring(s string, offset, length int) (string, bool) {
+	units := utf16.Encode([]rune(s))
+	if offset < 0 || length < 0 || offset+length > len(units) {
+		return "", false
+	}
+	return string(utf16.Decode(units[offset : offset+length])), true
+}
+
+// UTF16Index returns the UTF-16 offset of the first occurrence of substr in s, or -1 if it is not present.
+func UTF16Index(s, substr string) int {
+	i := strings.Index(s, substr)
+	if i < 0 {
+		return -1
+	}
+	return UTF16Length(s[:i])
+}
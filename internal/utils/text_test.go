@@ -0,0 +1,77 @@
+package utils
+
+import "testing"
+
+func TestUTF16Length(t *testing.T) {
+	tests := []struct {
+		s      string
+		length int
+	}{
+		{s: "", length: 0},
+		{s: "abc", length: 3},
+		{s: "привіт", length: 6},
+		{s: "😀", length: 2},
+		{s: "a😀b", length: 4},
+		{s: "𝕏", length: 2},
+		{s: "👍🏽", length: 4},
+		{s: "e\u0301", length: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			if length := UTF16Length(tt.s); length != tt.length {
+				t.Errorf("UTF16Length(%q) = %d, want %d", tt.s, length, tt.length)
+			}
+		})
+	}
+}
+
+func TestUTF16Substring(t *testing.T) {
+	tests := []struct {
+		name      string
+		offset    int
+		length    int
+		substring string
+		ok        bool
+	}{
+		{name: "before the emoji", offset: 0, length: 1, substring: "a", ok: true},
+		{name: "emoji", offset: 1, length: 2, substring: "😀", ok: true},
+		{name: "after the emoji", offset: 3, length: 1, substring: "b", ok: true},
+		{name: "whole string", offset: 0, length: 4, substring: "a😀b", ok: true},
+		{name: "empty at the end", offset: 4, length: 0, substring: "", ok: true},
+		{name: "past the end", offset: 3, length: 2},
+		{name: "negative offset", offset: -1, length: 1},
+		{name: "negative length", offset: 1, length: -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			substring, ok := UTF16Substring("a😀b", tt.offset, tt.length)
+			if substring != tt.substring || ok != tt.ok {
+				t.Errorf("UTF16Substring(%d, %d) = %q, %t, want %q, %t", tt.offset, tt.length, substring, ok, tt.substring, tt.ok)
+			}
+		})
+	}
+}
+
+func TestUTF16Index(t *testing.T) {
+	tests := []struct {
+		s      string
+		substr string
+		index  int
+	}{
+		{s: "hello", substr: "llo", index: 2},
+		{s: "😀 hi", substr: "hi", index: 3},
+		{s: "привіт світ", substr: "світ", index: 7},
+		{s: "𝕏𝕏 x", substr: "x", index: 5},
+		{s: "hello", substr: "bye", index: -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			if index := UTF16Index(tt.s, tt.substr); index != tt.index {
+				t.Errorf("UTF16Index(%q, %q) = %d, want %d", tt.s, tt.substr, index, tt.index)
+			}
+		})
+	}
+}
@@ -55,3 +55,13 @@ func FormatValidationError(r *http.Request, err error, translator *Translator) m
 func validatePhoneNumber(fl validator.FieldLevel) bool {
 	return phoneRegex.MatchString(fl.Field().String())
 }
+
+// IsPhone reports whether the value is a phone number in E.164 or a similar format
+func IsPhone(phone string) bool {
+	return phoneRegex.MatchString(phone)
+}
+
+// NormalizePhone removes the separators people use when writing phone numbers, e.g. "+1 (555) 010-0000"
+func NormalizePhone(phone string) string {
+	return strings.NewReplacer(" ", "", "-", "", "(", "", ")", "", ".", "").Replace(strings.TrimSpace(phone))
+}
@@ -8,10 +8,14 @@ import (
 	"time"
 )
 
+// DeliveryHook is called after a notification was written to the socket of a user.
+type DeliveryHook func(userID uint, message *Notification)
+
 type ClientManager struct {
 	Clients     map[uint]*websocket.Conn    // Map user ID to WebSocket connection
 	OnlineUsers map[uint]*models.OnlineUser // Track online status
 	mu          sync.RWMutex                // Mutex for thread-safe operations
+	onDelivered DeliveryHook
 }
 
 func NewClientManager() *ClientManager {
@@ -67,10 +71,20 @@ func (cm *ClientManager) SendMessage(userID uint, message *Notification) {
 			cm.mu.Lock()
 			delete(cm.Clients, userID)
 			cm.mu.Unlock()
+			return
+		}
+		if cm.onDelivered != nil {
+			cm.onDelivered(userID, message)
 		}
 	}(userID, conn)
 }
 
+// OnDelivered registers a hook that is called for every notification written to a socket.
+// It must be registered before the manager starts sending messages.
+func (cm *ClientManager) OnDelivered(hook DeliveryHook) {
+	cm.onDelivered = hook
+}
+
 func (cm *ClientManager) broadcastStatusChange(change *StatusChange) {
 	for uid, conn := range cm.Clients {
 		if uid == change.UserID {
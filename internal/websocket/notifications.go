@@ -1,20 +1,47 @@
 package websocket
 
-import "time"
+import (
+	"github.com/drTragger/messenger-backend/internal/models"
+	"time"
+)
 
 const (
-	NewMessageEvent    = EventType("newMessage")
-	EditMessageEvent   = EventType("editMessage")
-	DeleteMessageEvent = EventType("deleteMessage")
-	ReadMessageEvent   = EventType("readMessage")
-	StatusChangeEvent  = EventType("statusChange")
+	NewChatEvent          = EventType("newChat")
+	NewMessageEvent       = EventType("newMessage")
+	EditMessageEvent      = EventType("editMessage")
+	DeleteMessageEvent    = EventType("deleteMessage")
+	ReadMessageEvent      = EventType("readMessage")
+	DeliveredMessageEvent = EventType("deliveredMessage")
+	ListenedMessageEvent  = EventType("listenedMessage")
+	StatusChangeEvent     = EventType("statusChange")
+	PinChangeEvent        = EventType("pinChange")
+	NewReplyEvent         = EventType("newReply")
+	MentionEvent          = EventType("mention")
+	PollUpdateEvent       = EventType("pollUpdate")
+	LocationUpdateEvent   = EventType("locationUpdate")
+	ReportResolvedEvent   = EventType("reportResolved")
+	WarningEvent          = EventType("warning")
+	PreKeysLowEvent       = EventType("preKeysLow")
+)
+
+const (
+	PinAction      = PinActionType("pin")
+	UnpinAction    = PinActionType("unpin")
+	UnpinAllAction = PinActionType("unpinAll")
 )
 
 type EventType string
 
+type PinActionType string
+
+// Notification is an event sent to a user. Seq is the position of the event in the user's update log,
+// so clients can detect missed events and fetch them through the sync endpoint.
+// Silent notifications are shown without a sound or a push notification.
 type Notification struct {
 	Event   EventType   `json:"event"`
 	Message interface{} `json:"message"`
+	Seq     uint64      `json:"seq,omitempty"`
+	Silent  bool        `json:"silent,omitempty"`
 }
 
 func NewNotification(event EventType, message interface{}) *Notification {
@@ -39,3 +66,104 @@ func NewStatusChange(userID uint, isOnline bool, lastSeen time.Time) *StatusChan
 		LastSeen: lastSeen,
 	}
 }
+
+// Delivery is sent as the message of a DeliveredMessageEvent notification.
+type Delivery struct {
+	MessageID   uint      `json:"messageId"`
+	ChatID      uint      `json:"chatId"`
+	DeliveredAt time.Time `json:"deliveredAt"`
+}
+
+func NewDelivery(messageID, chatID uint, deliveredAt time.Time) *Delivery {
+	return &Delivery{
+		MessageID:   messageID,
+		ChatID:      chatID,
+		DeliveredAt: deliveredAt,
+	}
+}
+
+// PinChange is sent as the message of a PinChangeEvent notification.
+// Notify tells the client whether to show a notification about the change, and a change
+// without one is delivered silently.
+type PinChange struct {
+	ChatID    uint                  `json:"chatId"`
+	Action    PinActionType         `json:"action"`
+	MessageID *uint                 `json:"messageId,omitempty"`
+	Pin       *models.PinnedMessage `json:"pin,omitempty"`
+	Notify    bool                  `json:"notify"`
+	Silent    bool                  `json:"silent"`
+}
+
+func NewPinChange(chatID uint, action PinActionType, messageID *uint, pin *models.PinnedMessage, notify bool) *PinChange {
+	return &PinChange{
+		ChatID:    chatID,
+		Action:    action,
+		MessageID: messageID,
+		Pin:       pin,
+		Notify:    notify,
+		Silent:    !notify,
+	}
+}
+
+// NewReply is sent as the message of a NewReplyEvent notification so that thread views
+// can append the reply and update the reply counter of the parent message.
+type NewReply struct {
+	ParentID    uint            `json:"parentId"`
+	Reply       *models.Message `json:"reply"`
+	ReplyCount  int             `json:"replyCount"`
+	LastReplyAt *time.Time      `json:"lastReplyAt"`
+}
+
+func NewNewReply(parentID uint, reply *models.Message, replyCount int, lastReplyAt *time.Time) *NewReply {
+	return &NewReply{
+		ParentID:    parentID,
+		Reply:       reply,
+		ReplyCount:  replyCount,
+		LastReplyAt: lastReplyAt,
+	}
+}
+
+// ReportResolution is sent to the reporter as the message of a ReportResolvedEvent notification.
+// It tells whether action was taken, without the moderator's notes.
+type ReportResolution struct {
+	ReportID   uint      `json:"reportId"`
+	Status     string    `json:"status"`
+	ResolvedAt time.Time `json:"resolvedAt"`
+}
+
+func NewReportResolution(reportID uint, status string, resolvedAt time.Time) *ReportResolution {
+	return &ReportResolution{
+		ReportID:   reportID,
+		Status:     status,
+		ResolvedAt: resolvedAt,
+	}
+}
+
+// Warning is sent as the message of a WarningEvent notification when a moderator warns a user.
+type Warning struct {
+	Reason string    `json:"reason"`
+	Note   *string   `json:"note,omitempty"`
+	SentAt time.Time `json:"sentAt"`
+}
+
+func NewWarning(reason string, note *string, sentAt time.Time) *Warning {
+	return &Warning{
+		Reason: reason,
+		Note:   note,
+		SentAt: sentAt,
+	}
+}
+
+// PreKeysLow is sent as the message of a PreKeysLowEvent notification when a device is running out of
+// one-time prekeys and should upload more.
+type PreKeysLow struct {
+	DeviceID  uint `json:"deviceId"`
+	Remaining int  `json:"remaining"`
+}
+
+func NewPreKeysLow(deviceID uint, remaining int) *PreKeysLow {
+	return &PreKeysLow{
+		DeviceID:  deviceID,
+		Remaining: remaining,
+	}
+}